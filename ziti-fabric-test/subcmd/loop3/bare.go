@@ -0,0 +1,60 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"time"
+
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/pkg/errors"
+)
+
+// Bare mode benchmarks a plain echo server, which doesn't speak the loop3 protocol. Blocks are sent as length prefixed
+// frames without the magic header, and there's no test, metadata or result exchange. Everything sent comes back, so
+// the dialer receives its own blocks. They aren't verified, as there's no peer sending blocks of its own, so a bare run
+// only measures throughput and latency. Latency requests come back unanswered, the round trip being the time they took
+// to be echoed.
+
+// magicHeader returns the header each frame starts with, which bare mode leaves out
+func (options *protocolOptions) magicHeader() []byte {
+	if options.bare {
+		return nil
+	}
+	return MagicHeader
+}
+
+// bareTest makes the dialer's side of a test receive what it sends, as an echo server would return it
+func bareTest(test *loop3_pb.Test) error {
+	if !test.IsTxRandomHashed() {
+		return errors.Errorf("--bare needs random hashed blocks, test [%s] sends %s blocks", test.Name, test.TxBlockType)
+	}
+	test.RxRequests = test.TxRequests
+	test.RxBlockType = test.TxBlockType
+	// grants would be echoed back to this side, and nothing is verified anyway
+	test.FlowWindow = 0
+	// an echo server has no latency requests of its own to drain
+	test.DrainTimeout = 0
+	test.VerifyWorkers = 0
+	return nil
+}
+
+// rxEchoedLatency records the round trip of a latency request echoed back in bare mode
+func (p *protocol) rxEchoedLatency(block *RandHashedBlock) {
+	elapsed := time.Since(block.Timestamp)
+	MsgLatency.Update(elapsed)
+	p.stats.RecordLatency(block.Sequence, block.Timestamp, elapsed)
+}
@@ -0,0 +1,46 @@
+package loop3
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BareRunAgainstEcho(t *testing.T) {
+	req := require.New(t)
+
+	conn, peer := net.Pipe()
+	defer func() { _ = conn.Close() }()
+	go func() {
+		_, _ = io.Copy(peer, peer)
+		_ = peer.Close()
+	}()
+
+	test := newLoopbackTest("bare")
+	test.RxRequests = 0
+	test.FlowWindow = 8
+	req.NoError(bareTest(test))
+	req.Equal(test.TxRequests, test.RxRequests)
+	req.Zero(test.FlowWindow)
+
+	p, err := newProtocol(conn, &protocolOptions{bare: true})
+	req.NoError(err)
+	req.Equal(4, p.framing.HeaderLen(), "frames only carry the length")
+
+	result, err := p.run(test)
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+	req.Equal(test.TxRequests, result.RxCount)
+	req.Equal(result.TxBytes, result.RxBytes)
+	req.NotZero(result.Latency.Count, "echoed latency requests are timed")
+	req.Zero(result.LatencyDropped)
+}
+
+func Test_BareNeedsRandomHashedBlocks(t *testing.T) {
+	test := newLoopbackTest("bare-seq")
+	test.TxBlockType = loop3_pb.BlockTypeSequential
+	require.Error(t, bareTest(test))
+}
@@ -0,0 +1,143 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	benchHashCmd := newBenchHashCmd()
+	loop3Cmd.AddCommand(benchHashCmd.cmd)
+}
+
+type benchHashCmd struct {
+	cmd      *cobra.Command
+	size     string
+	duration time.Duration
+}
+
+func newBenchHashCmd() *benchHashCmd {
+	result := &benchHashCmd{
+		cmd: &cobra.Command{
+			Use:   "bench-hash",
+			Short: "Measure how fast this host hashes block payloads",
+			Long: "Measure how many MB/s this host hashes with each algorithm, using the same code the generator and " +
+				"verifier use for random hashed blocks, so hashing can be ruled out, or in, as the bottleneck of a test.",
+			Args: cobra.NoArgs,
+		},
+	}
+
+	result.cmd.Run = result.run
+
+	flags := result.cmd.Flags()
+	flags.StringVar(&result.size, "size", "64k", "Payload size to hash, such as 1024, 64k or 1m")
+	flags.DurationVar(&result.duration, "duration", time.Second, "How long to hash with each algorithm")
+
+	return result
+}
+
+func (cmd *benchHashCmd) run(_ *cobra.Command, _ []string) {
+	size, err := parsePayloadSize(cmd.size)
+	if err != nil {
+		panic(err)
+	}
+	if cmd.duration <= 0 {
+		panic(errors.Errorf("invalid duration %v, must be more than 0", cmd.duration))
+	}
+
+	data := make([]byte, size)
+	rand.New(rand.NewSource(time.Now().UnixNano())).Read(data)
+
+	var results []hashBenchResult
+	for _, algorithm := range hashAlgorithms {
+		results = append(results, benchHash(algorithm, data, cmd.duration))
+	}
+
+	if err = writeHashBench(os.Stdout, size, results); err != nil {
+		panic(err)
+	}
+}
+
+// parsePayloadSize parses a size in bytes, with an optional k, m or g suffix for KiB, MiB or GiB
+func parsePayloadSize(val string) (int, error) {
+	size, err := units.RAMInBytes(val)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid size [%s]", val)
+	}
+	if size <= 0 {
+		return 0, errors.Errorf("invalid size [%s], must be more than 0", val)
+	}
+	return int(size), nil
+}
+
+// hashBenchResult is how much one algorithm hashed in a benchmark
+type hashBenchResult struct {
+	algorithm string
+	hashes    int
+	bytes     int64
+	elapsed   time.Duration
+}
+
+func (result hashBenchResult) mbPerSec() float64 {
+	if result.elapsed <= 0 {
+		return 0
+	}
+	return float64(result.bytes) / 1e6 / result.elapsed.Seconds()
+}
+
+// benchHash hashes data with algorithm over and over for duration
+func benchHash(algorithm hashAlgorithm, data []byte, duration time.Duration) hashBenchResult {
+	result := hashBenchResult{algorithm: algorithm.name}
+	start := time.Now()
+	for result.elapsed < duration {
+		algorithm.sum(data)
+		result.hashes++
+		result.bytes += int64(len(data))
+		result.elapsed = time.Since(start)
+	}
+	return result
+}
+
+func writeHashBench(out io.Writer, size int, results []hashBenchResult) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintf(w, "ALGORITHM\tMB/s\tHASHES\t\n"); err != nil {
+		return err
+	}
+	for _, result := range results {
+		name := result.algorithm
+		if name == blockHashAlgorithm.name {
+			name += " (blocks)"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%.1f\t%d\t\n", name, result.mbPerSec(), result.hashes); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "\n%d byte payloads\n", size); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,52 @@
+package loop3
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParsePayloadSize(t *testing.T) {
+	req := require.New(t)
+
+	size, err := parsePayloadSize("64k")
+	req.NoError(err)
+	req.Equal(64*1024, size)
+
+	size, err = parsePayloadSize("1000")
+	req.NoError(err)
+	req.Equal(1000, size)
+
+	_, err = parsePayloadSize("lots")
+	req.Error(err)
+	_, err = parsePayloadSize("0")
+	req.Error(err)
+}
+
+func Test_HashAlgorithms(t *testing.T) {
+	req := require.New(t)
+
+	data := []byte("loop3")
+	req.Len(blockHash(data), 64)
+	req.Len(sumSha256(data), 32)
+	req.Len(sumCrc32(data), 4)
+
+	block := &RandHashedBlock{Data: data, Hash: blockHash(data)}
+	req.NoError(block.verifyHash())
+}
+
+func Test_BenchHash(t *testing.T) {
+	req := require.New(t)
+
+	result := benchHash(hashAlgorithms[0], make([]byte, 1024), 10*time.Millisecond)
+	req.True(result.hashes > 0)
+	req.Equal(int64(result.hashes)*1024, result.bytes)
+	req.True(result.mbPerSec() > 0)
+
+	out := &bytes.Buffer{}
+	req.NoError(writeHashBench(out, 1024, []hashBenchResult{result}))
+	req.Contains(out.String(), "SHA512 (blocks)")
+	req.Contains(out.String(), "1024 byte payloads")
+}
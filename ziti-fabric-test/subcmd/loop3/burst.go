@@ -0,0 +1,74 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import "time"
+
+type burstState int
+
+const (
+	burstOn burstState = iota
+	burstOff
+)
+
+// burstShaper alternates the txer between an on window, where it sends as pacing allows, and an off window where it
+// sends nothing
+type burstShaper struct {
+	on          time.Duration
+	off         time.Duration
+	state       burstState
+	windowStart time.Time
+	offTime     time.Duration
+}
+
+// newBurstShaper returns a shaper starting an on window at start, or nil if either window is empty
+func newBurstShaper(on, off time.Duration, start time.Time) *burstShaper {
+	if on <= 0 || off <= 0 {
+		return nil
+	}
+	return &burstShaper{
+		on:          on,
+		off:         off,
+		state:       burstOn,
+		windowStart: start,
+	}
+}
+
+// next returns how long the caller must wait before sending at now. The caller is expected to wait out the returned
+// duration, which is counted as off time
+func (b *burstShaper) next(now time.Time) time.Duration {
+	for {
+		switch b.state {
+		case burstOn:
+			end := b.windowStart.Add(b.on)
+			if now.Before(end) {
+				return 0
+			}
+			b.state = burstOff
+			b.windowStart = end
+		case burstOff:
+			end := b.windowStart.Add(b.off)
+			if now.Before(end) {
+				wait := end.Sub(now)
+				b.offTime += wait
+				return wait
+			}
+			b.state = burstOn
+			b.windowStart = end
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package loop3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BurstShaper(t *testing.T) {
+	req := require.New(t)
+
+	req.Nil(newBurstShaper(0, time.Second, time.Now()))
+	req.Nil(newBurstShaper(time.Second, 0, time.Now()))
+
+	start := time.Now()
+	at := func(millis int) time.Time {
+		return start.Add(time.Duration(millis) * time.Millisecond)
+	}
+
+	b := newBurstShaper(100*time.Millisecond, 50*time.Millisecond, start)
+
+	// on window
+	req.Equal(time.Duration(0), b.next(at(0)))
+	req.Equal(time.Duration(0), b.next(at(99)))
+
+	// off window, wait until it ends
+	req.Equal(50*time.Millisecond, b.next(at(100)))
+	req.Equal(10*time.Millisecond, b.next(at(140)))
+
+	// second on window
+	req.Equal(time.Duration(0), b.next(at(150)))
+	req.Equal(time.Duration(0), b.next(at(249)))
+
+	// a long gap skips whole cycles, landing in the off window of the cycle starting at 450ms
+	req.Equal(20*time.Millisecond, b.next(at(580)))
+
+	req.Equal(80*time.Millisecond, b.offTime)
+}
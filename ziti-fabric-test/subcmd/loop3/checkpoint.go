@@ -0,0 +1,124 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/michaelquigley/pfxlog"
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+)
+
+// checkpointer periodically writes the sequence every running test has reached to a file, so an interrupted run can
+// be picked up again with --resume-from. With several connections the lowest sequence is written, since resuming
+// applies to all of them. This is best effort, up to an interval's worth of blocks will be sent again on resume.
+type checkpointer struct {
+	path  string
+	lock  sync.Mutex
+	tests map[*protocol]*loop3_pb.Test
+}
+
+func newCheckpointer(path string) *checkpointer {
+	return &checkpointer{
+		path:  path,
+		tests: map[*protocol]*loop3_pb.Test{},
+	}
+}
+
+// add includes p, running test, in the checkpoint
+func (c *checkpointer) add(p *protocol, test *loop3_pb.Test) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tests[p] = test
+}
+
+// sequence returns the lowest sequence reached by the tests being checkpointed, and false if there are none
+func (c *checkpointer) sequence() (int32, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	found := false
+	var lowest int32
+	for p, test := range c.tests {
+		sequence := p.checkpointSequence(test)
+		if !found || sequence < lowest {
+			lowest = sequence
+			found = true
+		}
+	}
+	return lowest, found
+}
+
+// write records the current sequence, replacing the checkpoint file in one step so a crash part way through a write
+// doesn't leave an unreadable checkpoint behind
+func (c *checkpointer) write() error {
+	sequence, found := c.sequence()
+	if !found {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(tmp, "%d\n", sequence); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// run writes a checkpoint every interval until done is closed, and once more on the way out
+func (c *checkpointer) run(interval time.Duration, done <-chan struct{}) {
+	log := pfxlog.ContextLogger(c.path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.write(); err != nil {
+				log.WithError(err).Error("unable to write checkpoint")
+			}
+		case <-done:
+			if err := c.write(); err != nil {
+				log.WithError(err).Error("unable to write checkpoint")
+			}
+			return
+		}
+	}
+}
+
+// checkpointSequence returns the sequence test could be resumed from: the lower of the blocks sent and received, for
+// the directions the test sends anything in
+func (p *protocol) checkpointSequence(test *loop3_pb.Test) int32 {
+	tx := p.stats.TxCount()
+	rx := p.stats.RxCount()
+	if test.RxRequests == 0 || (test.TxRequests > 0 && tx < rx) {
+		return tx
+	}
+	return rx
+}
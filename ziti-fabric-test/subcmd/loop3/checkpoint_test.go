@@ -0,0 +1,62 @@
+package loop3
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RunLoopbackResumed(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("resumed")
+	test.ResumeFrom = 30
+
+	result, err := RunLoopback(test)
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+}
+
+func Test_ResumeRejectsSequentialBlocks(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("resumed-sequential")
+	test.TxBlockType = loop3_pb.BlockTypeSequential
+	test.ResumeFrom = 30
+
+	p, err := newProtocol(&testPeer{}, nil)
+	req.NoError(err)
+	_, err = p.run(test)
+	req.Error(err)
+}
+
+func Test_CheckpointWritesLowestSequence(t *testing.T) {
+	req := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "loop3.checkpoint")
+	checkpoints := newCheckpointer(path)
+
+	// nothing to checkpoint yet, so no file
+	req.NoError(checkpoints.write())
+	_, err := os.Stat(path)
+	req.True(os.IsNotExist(err))
+
+	bothWays := &protocol{stats: &StatsAccumulator{txCount: 40, rxCount: 25}}
+	checkpoints.add(bothWays, &loop3_pb.Test{TxRequests: 100, RxRequests: 100})
+	txOnly := &protocol{stats: &StatsAccumulator{txCount: 30}}
+	checkpoints.add(txOnly, &loop3_pb.Test{TxRequests: 100})
+
+	req.NoError(checkpoints.write())
+	data, err := os.ReadFile(path)
+	req.NoError(err)
+	req.Equal("25\n", string(data))
+
+	bothWays.stats.rxCount = 90
+	req.NoError(checkpoints.write())
+	data, err = os.ReadFile(path)
+	req.NoError(err)
+	req.Equal("30\n", string(data))
+}
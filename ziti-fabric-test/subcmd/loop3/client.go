@@ -0,0 +1,61 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	loop3Cmd.AddCommand(newClientCmd().cmd)
+}
+
+// clientCmd is the dialer for the common case of a listener hosting a ziti service: it dials the service by name with
+// an enrolled identity file, which is checked before any test runs
+type clientCmd struct {
+	dialerCmd
+	identityFile string
+}
+
+func newClientCmd() *clientCmd {
+	result := &clientCmd{}
+	result.cmd = &cobra.Command{
+		Use:   "client <scenarioFile> --service <service> --identity <identity file>",
+		Short: "Run a loop3 scenario against a ziti service",
+		Long: "Run the tests of the scenario against a loop3 listener hosting a ziti service, dialing the service by name " +
+			"with the edge SDK. The identity is authenticated, and checked for access to the service, before any test " +
+			"starts, so identity problems exit with code 2 rather than failing tests.\n\n" + exitCodesHelp,
+		Args: cobra.ExactArgs(1),
+	}
+	result.cmd.Run = result.run
+
+	flags := result.cmd.Flags()
+	flags.StringVarP(&result.service, "service", "s", "", "The ziti service to dial")
+	flags.StringVarP(&result.identityFile, "identity", "i", "", "The enrolled ziti identity file to dial with, such as id.json")
+	result.addScenarioFlags(flags)
+	_ = result.cmd.MarkFlagRequired("service")
+	_ = result.cmd.MarkFlagRequired("identity")
+
+	return result
+}
+
+func (cmd *clientCmd) run(c *cobra.Command, args []string) {
+	cmd.transport = TransportZiti
+	cmd.edgeConfigFile = cmd.identityFile
+	cmd.checkIdentity = true
+	cmd.dialerCmd.run(c, args)
+}
@@ -0,0 +1,39 @@
+package loop3
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ClientFlags(t *testing.T) {
+	req := require.New(t)
+
+	client := newClientCmd()
+	flags := client.cmd.Flags()
+	req.NotNil(flags.Lookup("repeat"), "the client runs scenarios like the dialer")
+	req.NotNil(flags.Lookup("ndjson"))
+	req.Nil(flags.Lookup("endpoint"), "the service is dialed by name")
+	req.Nil(flags.Lookup("transport"))
+
+	client.cmd.SetArgs([]string{"scenario.yml", "--service", "loop"})
+	client.cmd.SetOut(io.Discard)
+	client.cmd.SetErr(io.Discard)
+	req.EqualError(client.cmd.Execute(), `required flag(s) "identity" not set`)
+}
+
+func Test_ZitiServiceDialerReportsIdentityErrors(t *testing.T) {
+	req := require.New(t)
+	dir := t.TempDir()
+
+	_, err := NewZitiServiceDialer(filepath.Join(dir, "missing.json"), "loop")
+	req.ErrorContains(err, "unable to load ziti identity")
+
+	identityFile := filepath.Join(dir, "id.json")
+	req.NoError(os.WriteFile(identityFile, []byte(`{"ztAPI": "https://127.0.0.1:1", "id": {"cert": "missing.cert", "key": "missing.key", "ca": "missing.ca"}}`), 0600))
+	_, err = NewZitiServiceDialer(identityFile, "loop")
+	req.ErrorContains(err, "unable to authenticate ziti identity "+identityFile)
+}
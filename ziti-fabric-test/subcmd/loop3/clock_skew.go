@@ -0,0 +1,157 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"github.com/michaelquigley/pfxlog"
+	"github.com/pkg/errors"
+)
+
+// One-way delays are worked out from the sender's timestamp and the receiver's clock, so they're off by however far
+// apart the two clocks are. Before a one-way delay test the dialer estimates that offset with a few clock checks. Each
+// is a frame holding the dialer's send time, which the peer sends back with its own time appended, as in NTP. Like
+// echo checks, clock checks start with a prefix no Test message can start with, so the listener tells them apart.
+
+var clockCheckPrefix = []byte("loop3-clock-check:")
+
+const (
+	// clockCheckProbes is how many clock checks are sent. The one with the shortest round trip is used, as it has the
+	// least room for asymmetric delays to throw the estimate off
+	clockCheckProbes = 5
+
+	// clockCheckTimeout is how long the peer has to answer all the clock checks
+	clockCheckTimeout = 10 * time.Second
+
+	// defaultClockSkewThreshold is the default for --warn-on-clock-skew
+	defaultClockSkewThreshold = 10 * time.Millisecond
+)
+
+// isClockCheck returns true if body is a clock check frame
+func isClockCheck(body []byte) bool {
+	return bytes.HasPrefix(body, clockCheckPrefix)
+}
+
+// clockSample is the peer's clock offset estimated from one clock check. The estimate is only as good as rtt/2, as
+// there's no telling how the round trip was split between the two directions
+type clockSample struct {
+	offset time.Duration
+	rtt    time.Duration
+}
+
+// clockSampleOf estimates the offset of the peer's clock from the local one, assuming the peer read its clock half
+// way between sent and received
+func clockSampleOf(sent, peer, received time.Time) clockSample {
+	rtt := received.Sub(sent)
+	return clockSample{
+		offset: peer.Sub(sent.Add(rtt / 2)),
+		rtt:    rtt,
+	}
+}
+
+// skew returns the size of the offset, whichever clock is ahead
+func (sample clockSample) skew() time.Duration {
+	if sample.offset < 0 {
+		return -sample.offset
+	}
+	return sample.offset
+}
+
+// estimateClockOffset sends probes clock checks, one at a time, and returns the sample with the shortest round trip.
+// The peer is closed if it doesn't answer them all within timeout
+func (p *protocol) estimateClockOffset(probes int, timeout time.Duration) (clockSample, error) {
+	var expired int32
+	timer := time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&expired, 1)
+		_ = p.peer.Close()
+	})
+	defer timer.Stop()
+
+	var best clockSample
+	for i := 0; i < probes; i++ {
+		sample, err := p.clockCheck()
+		if err != nil {
+			if atomic.LoadInt32(&expired) == 1 {
+				return clockSample{}, errors.Errorf("peer failed clock check, no answer in %v", timeout)
+			}
+			return clockSample{}, err
+		}
+		if i == 0 || sample.rtt < best.rtt {
+			best = sample
+		}
+	}
+	return best, nil
+}
+
+func (p *protocol) clockCheck() (clockSample, error) {
+	check := make([]byte, len(clockCheckPrefix)+8)
+	copy(check, clockCheckPrefix)
+
+	sent := time.Now()
+	binary.LittleEndian.PutUint64(check[len(clockCheckPrefix):], uint64(sent.UnixNano()))
+	if err := p.framing.WriteFrame(check); err != nil {
+		return clockSample{}, errors.Wrap(err, "unable to send clock check")
+	}
+
+	body, err := p.framing.ReadFrame()
+	if err != nil {
+		return clockSample{}, errors.Wrap(err, "peer failed clock check, it's likely an older loop3 listener")
+	}
+	received := time.Now()
+
+	if len(body) != len(check)+8 || !bytes.Equal(body[:len(check)], check) {
+		return clockSample{}, errors.Errorf("peer failed clock check, its answer of %d bytes doesn't match the check sent", len(body))
+	}
+	peer := time.Unix(0, int64(binary.LittleEndian.Uint64(body[len(check):])))
+	return clockSampleOf(sent, peer, received), nil
+}
+
+// answerClockCheck sends a clock check back with the local time appended
+func (p *protocol) answerClockCheck(body []byte) error {
+	answer := make([]byte, len(body)+8)
+	copy(answer, body)
+	binary.LittleEndian.PutUint64(answer[len(body):], uint64(time.Now().UnixNano()))
+	return p.framing.WriteFrame(answer)
+}
+
+// checkClockSkew estimates the peer's clock offset, recording it for the result. If it's more than threshold the one-way
+// delays would be off by as much, so the test is refused with exitThreshold, unless allowSkew is set, in which case it's
+// only a warning
+func (p *protocol) checkClockSkew(threshold time.Duration, allowSkew bool) error {
+	sample, err := p.estimateClockOffset(clockCheckProbes, clockCheckTimeout)
+	if err != nil {
+		return err
+	}
+	p.clockOffset = sample.offset
+
+	log := pfxlog.Logger()
+	if sample.skew() <= threshold {
+		log.Infof("peer clock offset is %v (±%v)", sample.offset, sample.rtt/2)
+		return nil
+	}
+	if allowSkew {
+		log.Warnf("peer clock offset is %v (±%v), more than the %v allowed by --warn-on-clock-skew. One-way delays will be off by as much",
+			sample.offset, sample.rtt/2, threshold)
+		return nil
+	}
+	return withExitCode(exitThreshold, errors.Errorf("peer clock offset is %v (±%v), more than the %v allowed by --warn-on-clock-skew, "+
+		"so one-way delays would be off by as much. Synchronize the clocks, or run with --allow-skew", sample.offset, sample.rtt/2, threshold))
+}
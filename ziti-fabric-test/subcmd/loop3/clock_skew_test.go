@@ -0,0 +1,102 @@
+package loop3
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/framing"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ClockSampleOf(t *testing.T) {
+	req := require.New(t)
+
+	sent := time.Unix(1000, 0)
+	received := sent.Add(20 * time.Millisecond)
+
+	sample := clockSampleOf(sent, sent.Add(10*time.Millisecond), received)
+	req.Equal(time.Duration(0), sample.offset)
+	req.Equal(20*time.Millisecond, sample.rtt)
+
+	sample = clockSampleOf(sent, sent.Add(-5*time.Second), received)
+	req.Equal(-5*time.Second-10*time.Millisecond, sample.offset)
+	req.Equal(5*time.Second+10*time.Millisecond, sample.skew())
+}
+
+func Test_ClockCheckAnsweredByListener(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("clock-check")
+	test.OneWayDelay = true
+	conn, peer := net.Pipe()
+	defer func() { _ = conn.Close() }()
+	go (&listenerCmd{}).handle(peer, "clock-check")
+
+	p, err := newProtocol(conn, nil)
+	req.NoError(err)
+	// both ends share a clock, so anything but a tiny offset would be wrong
+	req.NoError(p.checkClockSkew(time.Second, false))
+	req.True(p.clockOffset < time.Second && p.clockOffset > -time.Second)
+
+	// the listener goes on to take the test as usual
+	req.NoError(p.txTest(loopbackPeerTest(test)))
+	_, err = p.exchangeMetadata(newMetadata("clock-check", ""))
+	req.NoError(err)
+	result, err := p.run(test)
+	req.NoError(err)
+	req.Equal(p.clockOffset, result.ClockOffset)
+	peerResult, err := p.rxResult(result)
+	req.NoError(err)
+	req.True(peerResult.Success, peerResult.Message)
+}
+
+func Test_ClockSkewRefused(t *testing.T) {
+	req := require.New(t)
+
+	check := func(allowSkew bool) error {
+		conn, peer := net.Pipe()
+		defer func() { _ = conn.Close() }()
+		go func() {
+			defer func() { _ = peer.Close() }()
+			// a peer whose clock is an hour ahead
+			framer := framing.New(peer, MagicHeader, binary.LittleEndian, 0)
+			for i := 0; i < clockCheckProbes; i++ {
+				body, err := framer.ReadFrame()
+				if err != nil {
+					return
+				}
+				answer := binary.LittleEndian.AppendUint64(body, uint64(time.Now().Add(time.Hour).UnixNano()))
+				if err = framer.WriteFrame(answer); err != nil {
+					return
+				}
+			}
+		}()
+
+		p, err := newProtocol(conn, nil)
+		req.NoError(err)
+		return p.checkClockSkew(10*time.Millisecond, allowSkew)
+	}
+
+	err := check(false)
+	req.ErrorContains(err, "run with --allow-skew")
+	req.Equal(exitThreshold, exitCodeOf(err))
+	req.NoError(check(true))
+}
+
+func Test_ClockCheckTimeout(t *testing.T) {
+	req := require.New(t)
+
+	conn, peer := net.Pipe()
+	defer func() { _ = peer.Close() }()
+	go func() {
+		// read the check, but never answer it
+		_, _ = framing.New(peer, MagicHeader, binary.LittleEndian, 0).ReadFrame()
+	}()
+
+	p, err := newProtocol(conn, nil)
+	req.NoError(err)
+	_, err = p.estimateClockOffset(clockCheckProbes, 50*time.Millisecond)
+	req.ErrorContains(err, "peer failed clock check, no answer in 50ms")
+}
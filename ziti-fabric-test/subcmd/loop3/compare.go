@@ -0,0 +1,296 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openziti/foundation/v2/info"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	compareCmd := newCompareCmd()
+	loop3Cmd.AddCommand(compareCmd.cmd)
+}
+
+const (
+	compareFormatText = "text"
+	compareFormatJson = "json"
+
+	unitBytesPerSec = "bytes/s"
+	unitDuration    = "ns"
+	unitPercent     = "%"
+	unitCount       = "count"
+)
+
+// compareTolerances are how much worse the second result can be than the first before the comparison fails.
+// Throughput and latency are relative, in percent, loss is in percentage points and errors are a count
+type compareTolerances struct {
+	throughputPercent float64
+	latencyPercent    float64
+	lossPoints        float64
+	errors            float64
+}
+
+type compareCmd struct {
+	cmd        *cobra.Command
+	format     string
+	tolerances compareTolerances
+}
+
+func newCompareCmd() *compareCmd {
+	result := &compareCmd{
+		cmd: &cobra.Command{
+			Use:   "compare <a.json> <b.json>",
+			Short: "Compare two loop3 results",
+			Long: "Compare two loop3 results, such as before and after a fabric change, showing how throughput, latency, " +
+				"loss and errors changed from a to b. The comparison fails if b is worse than a by more than the tolerances.",
+			Args: cobra.ExactArgs(2),
+		},
+	}
+
+	result.cmd.Run = result.run
+
+	flags := result.cmd.Flags()
+	flags.StringVarP(&result.format, "format", "f", compareFormatText, "Output format [text|json]")
+	flags.Float64Var(&result.tolerances.throughputPercent, "throughput-tolerance", 5, "Percent throughput can drop by")
+	flags.Float64Var(&result.tolerances.latencyPercent, "latency-tolerance", 10, "Percent the latency average and percentiles can grow by")
+	flags.Float64Var(&result.tolerances.lossPoints, "loss-tolerance", 0, "Percentage points the block loss can grow by")
+	flags.Float64Var(&result.tolerances.errors, "error-tolerance", 0, "How many more failures, dropped latency requests and tx retries are allowed")
+
+	return result
+}
+
+func (cmd *compareCmd) run(_ *cobra.Command, args []string) {
+	a, err := loadResult(args[0])
+	if err != nil {
+		panic(err)
+	}
+	b, err := loadResult(args[1])
+	if err != nil {
+		panic(err)
+	}
+
+	comparison := compareResults(a, b, cmd.tolerances)
+	switch cmd.format {
+	case compareFormatJson:
+		err = comparison.writeJson(os.Stdout)
+	case compareFormatText:
+		err = comparison.writeText(os.Stdout, args[0], args[1])
+	default:
+		err = errors.Errorf("unknown format [%s], expected text or json", cmd.format)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	if !comparison.Pass {
+		panic("comparison failed")
+	}
+}
+
+// loadResult reads a result written as JSON
+func loadResult(path string) (*Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read result [%s]", path)
+	}
+	result := &Result{}
+	if err = json.Unmarshal(data, result); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse result [%s]", path)
+	}
+	return result, nil
+}
+
+// comparedMetric is how one metric changed from result a to result b
+type comparedMetric struct {
+	Name  string  `json:"name"`
+	Unit  string  `json:"unit"`
+	A     float64 `json:"a"`
+	B     float64 `json:"b"`
+	Delta float64 `json:"delta"`
+
+	// DeltaPercent is the change relative to a, or 0 when a is 0
+	DeltaPercent float64 `json:"deltaPercent"`
+	Tolerance    float64 `json:"tolerance"`
+	Pass         bool    `json:"pass"`
+}
+
+type comparison struct {
+	Metrics []*comparedMetric `json:"metrics"`
+	Pass    bool              `json:"pass"`
+}
+
+// compareMetric describes a metric, how to get it from a result and what counts as it getting worse
+type compareMetric struct {
+	name           string
+	unit           string
+	value          func(r *Result) float64
+	higherIsBetter bool
+
+	// relative tolerances are a percentage of a, the others are an absolute difference
+	relative  bool
+	tolerance func(t compareTolerances) float64
+}
+
+func throughput(bytes int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes) / d.Seconds()
+}
+
+func lossPercent(lost, count int32) float64 {
+	if count <= 0 {
+		return 0
+	}
+	return 100 * float64(lost) / float64(count)
+}
+
+func boolCount(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var compareMetrics = func() []compareMetric {
+	throughputTolerance := func(t compareTolerances) float64 { return t.throughputPercent }
+	latencyTolerance := func(t compareTolerances) float64 { return t.latencyPercent }
+	lossTolerance := func(t compareTolerances) float64 { return t.lossPoints }
+	errorTolerance := func(t compareTolerances) float64 { return t.errors }
+
+	latency := func(name string, value func(l *LatencyStats) time.Duration) compareMetric {
+		return compareMetric{
+			name:      name,
+			unit:      unitDuration,
+			value:     func(r *Result) float64 { return float64(value(&r.Latency)) },
+			relative:  true,
+			tolerance: latencyTolerance,
+		}
+	}
+
+	return []compareMetric{
+		{name: "tx throughput", unit: unitBytesPerSec, higherIsBetter: true, relative: true, tolerance: throughputTolerance,
+			value: func(r *Result) float64 { return throughput(r.TxBytes, r.Duration) }},
+		{name: "rx throughput", unit: unitBytesPerSec, higherIsBetter: true, relative: true, tolerance: throughputTolerance,
+			value: func(r *Result) float64 { return throughput(r.RxBytes, r.Duration) }},
+		latency("latency avg", func(l *LatencyStats) time.Duration { return l.Avg }),
+		latency("latency p50", func(l *LatencyStats) time.Duration { return l.P50 }),
+		latency("latency p90", func(l *LatencyStats) time.Duration { return l.P90 }),
+		latency("latency p99", func(l *LatencyStats) time.Duration { return l.P99 }),
+		{name: "tx loss", unit: unitPercent, tolerance: lossTolerance,
+			value: func(r *Result) float64 { return lossPercent(r.TxLost, r.TxCount) }},
+		{name: "rx loss", unit: unitPercent, tolerance: lossTolerance,
+			value: func(r *Result) float64 { return lossPercent(r.RxLost, r.RxCount) }},
+		{name: "failed", unit: unitCount, tolerance: errorTolerance,
+			value: func(r *Result) float64 { return boolCount(!r.Success) }},
+		{name: "latency dropped", unit: unitCount, tolerance: errorTolerance,
+			value: func(r *Result) float64 { return float64(r.LatencyDropped) }},
+		{name: "tx retries", unit: unitCount, tolerance: errorTolerance,
+			value: func(r *Result) float64 { return float64(r.TxRetries) }},
+	}
+}()
+
+// compareResults compares each metric of b against a, failing those which got worse by more than the tolerances
+func compareResults(a, b *Result, tolerances compareTolerances) *comparison {
+	result := &comparison{Pass: true}
+	for _, metric := range compareMetrics {
+		compared := &comparedMetric{
+			Name:      metric.name,
+			Unit:      metric.unit,
+			A:         metric.value(a),
+			B:         metric.value(b),
+			Tolerance: metric.tolerance(tolerances),
+		}
+		compared.Delta = compared.B - compared.A
+		if compared.A != 0 {
+			compared.DeltaPercent = 100 * compared.Delta / compared.A
+		}
+
+		worse := compared.Delta
+		if metric.higherIsBetter {
+			worse = -worse
+		}
+		allowed := compared.Tolerance
+		if metric.relative {
+			allowed = compared.Tolerance / 100 * compared.A
+		}
+		compared.Pass = worse <= allowed
+
+		result.Metrics = append(result.Metrics, compared)
+		result.Pass = result.Pass && compared.Pass
+	}
+	return result
+}
+
+func (c *comparison) writeJson(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c)
+}
+
+func (c *comparison) writeText(out io.Writer, aName, bName string) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "metric\t%s\t%s\tdelta\t\n", aName, bName)
+	for _, metric := range c.Metrics {
+		status := "ok"
+		if !metric.Pass {
+			status = "FAIL"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", metric.Name, formatMetric(metric.Unit, metric.A),
+			formatMetric(metric.Unit, metric.B), metric.formatDelta(), status)
+	}
+	if c.Pass {
+		_, _ = fmt.Fprintln(w, "\npass")
+	} else {
+		_, _ = fmt.Fprintln(w, "\nfail")
+	}
+	return w.Flush()
+}
+
+func formatMetric(unit string, value float64) string {
+	switch unit {
+	case unitBytesPerSec:
+		return info.ByteCount(int64(value)) + "/s"
+	case unitDuration:
+		return time.Duration(value).String()
+	case unitPercent:
+		return fmt.Sprintf("%.2f%%", value)
+	default:
+		return fmt.Sprintf("%.0f", value)
+	}
+}
+
+// formatDelta shows relative changes for throughput and latency and the absolute change for the rest
+func (metric *comparedMetric) formatDelta() string {
+	switch metric.Unit {
+	case unitBytesPerSec, unitDuration:
+		return fmt.Sprintf("%+.1f%%", metric.DeltaPercent)
+	case unitPercent:
+		return fmt.Sprintf("%+.2f pts", metric.Delta)
+	default:
+		return fmt.Sprintf("%+.0f", metric.Delta)
+	}
+}
@@ -0,0 +1,116 @@
+package loop3
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func compareBaseline() *Result {
+	return &Result{
+		Success:  true,
+		TxCount:  1000,
+		RxCount:  1000,
+		TxBytes:  10_000_000,
+		RxBytes:  10_000_000,
+		Duration: 10 * time.Second,
+		Latency:  LatencyStats{Count: 100, Avg: 10 * time.Millisecond, P50: 8 * time.Millisecond, P90: 15 * time.Millisecond, P99: 20 * time.Millisecond},
+	}
+}
+
+func comparedByName(c *comparison, name string) *comparedMetric {
+	for _, metric := range c.Metrics {
+		if metric.Name == name {
+			return metric
+		}
+	}
+	return nil
+}
+
+func Test_CompareWithinTolerances(t *testing.T) {
+	req := require.New(t)
+
+	a := compareBaseline()
+	b := compareBaseline()
+	b.TxBytes = 9_600_000
+	b.Latency.P99 = 21 * time.Millisecond
+
+	c := compareResults(a, b, compareTolerances{throughputPercent: 5, latencyPercent: 10})
+	req.True(c.Pass)
+
+	tx := comparedByName(c, "tx throughput")
+	req.Equal(1_000_000.0, tx.A)
+	req.Equal(960_000.0, tx.B)
+	req.InDelta(-4.0, tx.DeltaPercent, 0.001)
+	req.True(tx.Pass)
+}
+
+func Test_CompareFailsRegressions(t *testing.T) {
+	req := require.New(t)
+
+	a := compareBaseline()
+	b := compareBaseline()
+	b.RxBytes = 9_000_000
+	b.Latency.P99 = 30 * time.Millisecond
+	b.TxLost = 10
+	b.TxRetries = 2
+
+	c := compareResults(a, b, compareTolerances{throughputPercent: 5, latencyPercent: 10, errors: 1})
+	req.False(c.Pass)
+	req.False(comparedByName(c, "rx throughput").Pass)
+	req.False(comparedByName(c, "latency p99").Pass)
+	req.False(comparedByName(c, "tx loss").Pass)
+	req.Equal(1.0, comparedByName(c, "tx loss").B)
+	req.False(comparedByName(c, "tx retries").Pass)
+	req.True(comparedByName(c, "tx throughput").Pass)
+	req.True(comparedByName(c, "latency p50").Pass)
+
+	// improvements never fail
+	c = compareResults(b, a, compareTolerances{})
+	req.True(c.Pass)
+}
+
+func Test_CompareOutput(t *testing.T) {
+	req := require.New(t)
+
+	dir := t.TempDir()
+	write := func(name string, result *Result) string {
+		data, err := json.Marshal(result)
+		req.NoError(err)
+		path := filepath.Join(dir, name)
+		req.NoError(os.WriteFile(path, data, 0644))
+		return path
+	}
+	b := compareBaseline()
+	b.Success = false
+	aPath := write("a.json", compareBaseline())
+	bPath := write("b.json", b)
+
+	a, err := loadResult(aPath)
+	req.NoError(err)
+	req.Equal(compareBaseline(), a)
+	loadedB, err := loadResult(bPath)
+	req.NoError(err)
+	c := compareResults(a, loadedB, compareTolerances{})
+
+	text := &bytes.Buffer{}
+	req.NoError(c.writeText(text, aPath, bPath))
+	req.Contains(text.String(), "tx throughput")
+	req.Regexp(`failed\s+0\s+1\s+\+1\s+FAIL`, text.String())
+	req.Contains(text.String(), "\nfail\n")
+
+	out := &bytes.Buffer{}
+	req.NoError(c.writeJson(out))
+	decoded := &comparison{}
+	req.NoError(json.Unmarshal(out.Bytes(), decoded))
+	req.False(decoded.Pass)
+	req.Len(decoded.Metrics, len(compareMetrics))
+
+	_, err = loadResult(filepath.Join(dir, "missing.json"))
+	req.Error(err)
+}
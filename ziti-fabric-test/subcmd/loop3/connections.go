@@ -0,0 +1,181 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/michaelquigley/pfxlog"
+	"github.com/openziti/foundation/v2/info"
+	"github.com/sirupsen/logrus"
+)
+
+// connectionStats adds up the results of the tests run over a connection, or over all of them
+type connectionStats struct {
+	tests       int
+	failed      int
+	txCount     int64
+	rxCount     int64
+	txBytes     int64
+	rxBytes     int64
+	txWireBytes int64
+	rxWireBytes int64
+}
+
+func (stats *connectionStats) add(result *Result) {
+	stats.tests++
+	if !result.Success {
+		stats.failed++
+	}
+	stats.txCount += int64(result.TxCount)
+	stats.rxCount += int64(result.RxCount)
+	stats.txBytes += result.TxBytes
+	stats.rxBytes += result.RxBytes
+	stats.txWireBytes += result.TxWireBytes
+	stats.rxWireBytes += result.RxWireBytes
+}
+
+func (stats connectionStats) String() string {
+	return fmt.Sprintf("%d tests (%d failed), tx %d blocks (%s, %s on the wire), rx %d blocks (%s, %s on the wire)",
+		stats.tests, stats.failed,
+		stats.txCount, info.ByteCount(stats.txBytes), info.ByteCount(stats.txWireBytes),
+		stats.rxCount, info.ByteCount(stats.rxBytes), info.ByteCount(stats.rxWireBytes))
+}
+
+// trackedConnection is a connection being served by the listener
+type trackedConnection struct {
+	context string
+	conn    io.Closer
+	opened  time.Time
+	busy    bool
+	stats   connectionStats
+}
+
+// connectionTracker keeps the listener's per connection and aggregate accounting, and lets a shutdown wait for the
+// tests in flight. Connections waiting for their next test are closed as soon as a shutdown starts, busy ones once
+// their test is done.
+type connectionTracker struct {
+	lock     sync.Mutex
+	open     map[*trackedConnection]struct{}
+	accepted int
+	total    connectionStats
+	draining bool
+	inFlight sync.WaitGroup
+	// summaryLog is where disconnects are logged, see protocolOptions.startSummaryOnly. The standard logger if nil
+	summaryLog *logrus.Logger
+}
+
+func newConnectionTracker() *connectionTracker {
+	return &connectionTracker{open: map[*trackedConnection]struct{}{}}
+}
+
+// add starts tracking conn, returning nil if the tracker is draining and conn shouldn't be served
+func (tracker *connectionTracker) add(conn io.Closer, context string) *trackedConnection {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	if tracker.draining {
+		return nil
+	}
+	tracked := &trackedConnection{context: context, conn: conn, opened: time.Now()}
+	tracker.open[tracked] = struct{}{}
+	tracker.accepted++
+	tracker.inFlight.Add(1)
+	return tracked
+}
+
+// startTest marks tracked busy, returning false if the tracker is draining and no new test should be started
+func (tracker *connectionTracker) startTest(tracked *trackedConnection) bool {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	if tracker.draining {
+		return false
+	}
+	tracked.busy = true
+	return true
+}
+
+// finishTest records the result of a test run over tracked, returning false if the tracker is draining and the
+// connection should be closed rather than wait for another test
+func (tracker *connectionTracker) finishTest(tracked *trackedConnection, result *Result) bool {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	tracked.busy = false
+	tracked.stats.add(result)
+	tracker.total.add(result)
+	return !tracker.draining
+}
+
+// remove stops tracking tracked, logging what was done over it
+func (tracker *connectionTracker) remove(tracked *trackedConnection) {
+	tracker.lock.Lock()
+	delete(tracker.open, tracked)
+	remaining := len(tracker.open)
+	tracker.lock.Unlock()
+
+	log := pfxlog.ContextLogger(tracked.context).Entry
+	if tracker.summaryLog != nil {
+		log = tracker.summaryLog.WithFields(log.Data)
+	}
+	log.Infof("disconnected after %v: %v. %d connection(s) still open",
+		time.Since(tracked.opened).Round(time.Millisecond), tracked.stats, remaining)
+	tracker.inFlight.Done()
+}
+
+// drain stops new connections and tests, closes idle connections and waits up to timeout for the busy ones to finish
+// their tests. It returns false if some were still busy at the timeout.
+func (tracker *connectionTracker) drain(timeout time.Duration) bool {
+	tracker.lock.Lock()
+	tracker.draining = true
+	for tracked := range tracker.open {
+		if !tracked.busy {
+			_ = tracked.conn.Close()
+		}
+	}
+	tracker.lock.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		tracker.inFlight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (tracker *connectionTracker) isDraining() bool {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	return tracker.draining
+}
+
+// summary returns the aggregate of every test run over every connection so far
+func (tracker *connectionTracker) summary() string {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	return fmt.Sprintf("%d connection(s) accepted, %d open: %v", tracker.accepted, len(tracker.open), tracker.total)
+}
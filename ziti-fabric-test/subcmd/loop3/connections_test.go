@@ -0,0 +1,77 @@
+package loop3
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// serveLoopback runs a test against listener over an in-memory pipe, leaving the connection open
+func serveLoopback(t *testing.T, listener *listenerCmd, name string) net.Conn {
+	req := require.New(t)
+
+	conn, peer := net.Pipe()
+	go listener.handle(peer, name)
+
+	test := newLoopbackTest(name)
+	result, err := runLoopbackTest(conn, test, loopbackPeerTest(test))
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+	return conn
+}
+
+func Test_ListenerAccountsPerConnection(t *testing.T) {
+	req := require.New(t)
+	listener := &listenerCmd{connections: newConnectionTracker()}
+
+	first := serveLoopback(t, listener, "first")
+	second := serveLoopback(t, listener, "second")
+	req.Contains(listener.connections.summary(), "2 connection(s) accepted, 2 open: 2 tests (0 failed), tx 100 blocks")
+
+	_ = first.Close()
+	_ = second.Close()
+	req.Eventually(func() bool {
+		listener.connections.lock.Lock()
+		defer listener.connections.lock.Unlock()
+		return len(listener.connections.open) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	total := listener.connections.total
+	req.Equal(2, total.tests)
+	req.Equal(int64(100), total.txCount)
+	req.Equal(int64(100), total.rxCount)
+	req.True(total.txWireBytes > total.txBytes)
+}
+
+func Test_DrainClosesIdleConnections(t *testing.T) {
+	req := require.New(t)
+	listener := &listenerCmd{connections: newConnectionTracker()}
+
+	// the listener waits for another test on the connection after the first, so it's idle now
+	conn := serveLoopback(t, listener, "idle")
+	defer func() { _ = conn.Close() }()
+
+	req.True(listener.connections.drain(time.Second))
+	_, err := conn.Read(make([]byte, 1))
+	req.Error(err, "the idle connection should have been closed")
+
+	// no new connections are served once draining
+	req.Nil(listener.connections.add(conn, "late"))
+}
+
+func Test_DrainTimesOutOnBusyConnections(t *testing.T) {
+	req := require.New(t)
+	tracker := newConnectionTracker()
+
+	conn, peer := net.Pipe()
+	defer func() { _ = conn.Close() }()
+	tracked := tracker.add(peer, "busy")
+	req.True(tracker.startTest(tracked))
+
+	req.False(tracker.drain(20 * time.Millisecond))
+	req.False(tracker.finishTest(tracked, &Result{Success: true}), "no more tests once draining")
+	tracker.remove(tracked)
+	req.True(tracker.drain(time.Second))
+}
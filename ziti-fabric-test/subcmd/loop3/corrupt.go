@@ -0,0 +1,92 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// --corrupt-rate is a negative path check. The txer flips a byte in the payload of a fraction of its blocks after they
+// were hashed, so the peer's verifier should report about that fraction of hash mismatches. Fewer means the corruption
+// was caught or masked on the way, more means something else is going wrong. Run it with --tolerate-corruption on the
+// peer, or the first mismatch ends the test.
+
+// maxCorruptionSamples is how many corrupted sequences are kept for the report, a lossy transport being able to corrupt
+// any number of blocks
+const maxCorruptionSamples = 20
+
+// corruptBlock flips a byte of the block's payload if the --corrupt-rate draw says so, returning true if it did. Only
+// hashed blocks with a payload are corrupted, as nothing could tell for the rest
+func (p *protocol) corruptBlock(block Block) bool {
+	if p.options.corruptRate <= 0 {
+		return false
+	}
+	hashed, ok := block.(*RandHashedBlock)
+	if !ok || len(hashed.Hash) == 0 || len(hashed.Data) == 0 {
+		return false
+	}
+	if rand.Float64() >= p.options.corruptRate {
+		return false
+	}
+	hashed.Data[rand.Intn(len(hashed.Data))] ^= 0xFF
+	atomic.AddInt32(&p.txCorrupted, 1)
+	return true
+}
+
+// verifyFailed handles a block which failed verification, returning true if the loop verifying it should stop. With
+// --tolerate-corruption a hash mismatch is only counted, as the block made it through the transport but its payload
+// didn't, so the test carries on to measure how often that happens. Anything else fails the test as usual
+func (p *protocol) verifyFailed(sequence int64, err error) bool {
+	if p.options.tolerateCorruption && errors.Is(err, errMismatchedHashes) {
+		p.phaseLogger(PhaseVerify).Debugf("block %d corrupted, continuing", sequence)
+		p.stats.RecordCorruption(sequence)
+		return false
+	}
+	return p.fail(p.newError(PhaseVerify, sequence, err))
+}
+
+// corruptionRate returns the fraction of the received blocks which were corrupted
+func corruptionRate(corrupted, rxCount int32) float64 {
+	if rxCount <= 0 {
+		return 0
+	}
+	return float64(corrupted) / float64(rxCount)
+}
+
+// corruptionSamples keeps the sequences of the first corrupted blocks. Verify workers find them concurrently
+type corruptionSamples struct {
+	lock  sync.Mutex
+	first []int64
+}
+
+func (samples *corruptionSamples) record(sequence int64) {
+	samples.lock.Lock()
+	defer samples.lock.Unlock()
+	if len(samples.first) < maxCorruptionSamples {
+		samples.first = append(samples.first, sequence)
+	}
+}
+
+func (samples *corruptionSamples) sequences() []int64 {
+	samples.lock.Lock()
+	defer samples.lock.Unlock()
+	return append([]int64(nil), samples.first...)
+}
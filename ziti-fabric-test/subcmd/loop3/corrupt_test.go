@@ -0,0 +1,118 @@
+package loop3
+
+import (
+	"crypto/sha512"
+	"net"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newHashedBlock(sequence uint32) *RandHashedBlock {
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	hash := sha512.Sum512(data)
+	return &RandHashedBlock{Type: BlockTypePlain, Sequence: sequence, Hash: hash[:], Data: data}
+}
+
+func Test_CorruptBlock(t *testing.T) {
+	req := require.New(t)
+
+	p := &protocol{options: protocolOptions{corruptRate: 1}}
+	for i := uint32(0); i < 10; i++ {
+		block := newHashedBlock(i)
+		req.True(p.corruptBlock(block))
+		req.Error(block.verifyHash())
+	}
+	req.EqualValues(10, p.txCorrupted)
+
+	// probe blocks aren't hashed, so they're left alone
+	probe := newHashedBlock(10)
+	probe.Hash = nil
+	req.False(p.corruptBlock(probe))
+	req.EqualValues(10, p.txCorrupted)
+}
+
+func Test_CorruptBlockRate(t *testing.T) {
+	req := require.New(t)
+
+	off := &protocol{}
+	block := newHashedBlock(0)
+	req.False(off.corruptBlock(block))
+	req.NoError(block.verifyHash())
+
+	p := &protocol{options: protocolOptions{corruptRate: 0.25}}
+	mismatches := 0
+	for i := uint32(0); i < 4000; i++ {
+		block := newHashedBlock(i)
+		p.corruptBlock(block)
+		if block.verifyHash() != nil {
+			mismatches++
+		}
+	}
+	req.EqualValues(mismatches, p.txCorrupted)
+	req.InDelta(1000, mismatches, 200)
+}
+
+func Test_TolerateCorruptionCountsMismatches(t *testing.T) {
+	req := require.New(t)
+
+	conn, peer := net.Pipe()
+	t.Cleanup(func() { _ = conn.Close() })
+	t.Cleanup(func() { _ = peer.Close() })
+	test := newLoopbackTest("tolerate-corruption")
+	// the listener corrupts every block it sends, which would fail the dialer at the first one
+	go (&listenerCmd{protocolOptions: protocolOptions{failFast: true, corruptRate: 1}}).handle(peer, test.Name)
+
+	p, err := newProtocol(conn, &protocolOptions{failFast: true, tolerateCorruption: true})
+	req.NoError(err)
+	req.NoError(p.txTest(loopbackPeerTest(test)))
+	_, err = p.exchangeMetadata(newMetadata(test.Name, ""))
+	req.NoError(err)
+
+	result, err := p.run(test)
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+	req.Equal(test.RxRequests, result.RxCount)
+	req.Equal(test.RxRequests, result.Corrupted)
+	req.Equal(1.0, result.CorruptionRate)
+	req.Len(p.stats.corruptedSequences(), maxCorruptionSamples)
+	req.Equal(int64(0), p.stats.corruptedSequences()[0])
+
+	peerResult, err := p.rxResult(result)
+	req.NoError(err)
+	req.True(peerResult.Success, peerResult.Message)
+	req.Zero(peerResult.Corrupted)
+}
+
+func Test_VerifyFailed(t *testing.T) {
+	req := require.New(t)
+
+	p, err := newProtocol(&testPeer{}, &protocolOptions{tolerateCorruption: true})
+	req.NoError(err)
+	req.False(p.verifyFailed(3, errMismatchedHashes))
+	req.False(p.verifyFailed(5, errMismatchedHashes))
+	req.Equal([]int64{3, 5}, p.stats.corruptedSequences())
+	req.Zero(p.stats.Snapshot().Errors)
+
+	// only mismatched hashes are tolerated
+	req.False(p.verifyFailed(6, errors.New("out of sequence")))
+	req.EqualValues(1, p.stats.Snapshot().Errors)
+
+	strict, err := newProtocol(&testPeer{}, &protocolOptions{failFast: true})
+	req.NoError(err)
+	req.True(strict.verifyFailed(3, errMismatchedHashes))
+	req.Zero(strict.stats.Snapshot().Corrupted)
+}
+
+func Test_CorruptionRate(t *testing.T) {
+	req := require.New(t)
+
+	req.Zero(corruptionRate(0, 0))
+	req.Zero(corruptionRate(3, 0))
+	req.Equal(0.25, corruptionRate(1, 4))
+}
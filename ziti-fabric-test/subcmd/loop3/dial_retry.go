@@ -0,0 +1,85 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"io"
+	"time"
+
+	"github.com/michaelquigley/pfxlog"
+	"github.com/pkg/errors"
+)
+
+// defaultDialRetryInterval is the wait before the first dial retry, doubling with each retry up to
+// dialRetryMaxBackoff
+const (
+	defaultDialRetryInterval = time.Second
+	dialRetryMaxBackoff      = 30 * time.Second
+)
+
+// retryingDialer dials again when a dial fails, so the dialer can be started alongside the fabric it tests rather than
+// racing it. Whatever the retries, a connection which can't be made ends the run with exitSetup, so scripts can
+// tell a fabric which never came up from tests which failed on it
+type retryingDialer struct {
+	Dialer
+	retries  int
+	interval time.Duration
+}
+
+// newRetryingDialer returns a Dialer which makes up to retries more attempts after the first fails, waiting interval
+// before the first retry
+func newRetryingDialer(dialer Dialer, retries int, interval time.Duration) Dialer {
+	return &retryingDialer{Dialer: dialer, retries: retries, interval: interval}
+}
+
+func (d *retryingDialer) Dial() (io.ReadWriteCloser, error) {
+	log := pfxlog.Logger()
+	attempts := d.retries + 1
+	backoff := d.interval
+	for attempt := 1; ; attempt++ {
+		conn, err := d.Dialer.Dial()
+		if err == nil {
+			if attempt > 1 {
+				log.Infof("connected on dial attempt %d of %d", attempt, attempts)
+			}
+			return conn, nil
+		}
+		if attempt >= attempts {
+			if d.retries > 0 {
+				err = errors.Wrapf(err, "unable to connect after %d dial attempts", attempts)
+			}
+			return nil, withExitCode(exitSetup, err)
+		}
+
+		log.WithError(err).Warnf("dial attempt %d of %d failed, retrying in %v", attempt, attempts, backoff)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > dialRetryMaxBackoff {
+			backoff = dialRetryMaxBackoff
+		}
+	}
+}
+
+// checkDialRetries validates --dial-retries and --dial-retry-interval
+func (cmd *dialerCmd) checkDialRetries() error {
+	if cmd.dialRetries < 0 {
+		return errors.Errorf("--dial-retries must not be negative, got %d", cmd.dialRetries)
+	}
+	if cmd.dialRetries > 0 && cmd.dialRetryInterval <= 0 {
+		return errors.Errorf("--dial-retry-interval must be positive, got %v", cmd.dialRetryInterval)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package loop3
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyDialer refuses its first few dials, as a fabric which is still starting up would
+type flakyDialer struct {
+	Dialer
+	failures int32
+	dials    int32
+}
+
+func (d *flakyDialer) Dial() (io.ReadWriteCloser, error) {
+	if atomic.AddInt32(&d.dials, 1) <= d.failures {
+		return nil, errors.New("connection refused")
+	}
+	return d.Dialer.Dial()
+}
+
+func Test_DialRetriesUntilConnected(t *testing.T) {
+	req := require.New(t)
+
+	flaky := &flakyDialer{Dialer: NewPipeDialer(echo), failures: 2}
+	requireEchoes(req, newRetryingDialer(flaky, 3, time.Millisecond))
+	req.Equal(int32(3), atomic.LoadInt32(&flaky.dials))
+}
+
+func Test_DialRetriesExhausted(t *testing.T) {
+	req := require.New(t)
+
+	flaky := &flakyDialer{Dialer: NewPipeDialer(echo), failures: 10}
+	_, err := newRetryingDialer(flaky, 2, time.Millisecond).Dial()
+	req.Error(err)
+	req.Equal(int32(3), atomic.LoadInt32(&flaky.dials))
+	// scripts were told a connection which can't be made exits with 2, retries or not
+	req.Equal(exitCode(2), exitCodeOf(err))
+	req.Equal("unable to connect after 3 dial attempts: connection refused", err.Error())
+
+	// without retries the dial error is returned as it is, still as a connection failure
+	flaky = &flakyDialer{Dialer: NewPipeDialer(echo), failures: 1}
+	_, err = newRetryingDialer(flaky, 0, time.Second).Dial()
+	req.Equal(exitSetup, exitCodeOf(err))
+	req.Equal("connection refused", err.Error())
+	req.Equal(int32(1), atomic.LoadInt32(&flaky.dials))
+}
+
+func Test_DialRetriesWaitForListener(t *testing.T) {
+	req := require.New(t)
+
+	// find a free port, then leave nothing listening on it until the dialer has been refused
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	req.NoError(err)
+	address := listener.Addr().String()
+	req.NoError(listener.Close())
+
+	connected := make(chan error, 1)
+	go func() {
+		conn, err := newRetryingDialer(NewTcpDialer("tcp:"+address), 20, 10*time.Millisecond).Dial()
+		if err == nil {
+			_ = conn.Close()
+		}
+		connected <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	listener, err = net.Listen("tcp", address)
+	req.NoError(err)
+	defer func() { _ = listener.Close() }()
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			_ = conn.Close()
+		}
+	}()
+
+	select {
+	case err := <-connected:
+		req.NoError(err)
+	case <-time.After(5 * time.Second):
+		req.Fail("dialer didn't connect once the listener was up")
+	}
+}
+
+func Test_CheckDialRetries(t *testing.T) {
+	req := require.New(t)
+
+	req.NoError((&dialerCmd{}).checkDialRetries())
+	req.NoError((&dialerCmd{dialRetries: 3, dialRetryInterval: time.Second}).checkDialRetries())
+	req.Error((&dialerCmd{dialRetries: -1}).checkDialRetries())
+	req.Error((&dialerCmd{dialRetries: 3}).checkDialRetries())
+}
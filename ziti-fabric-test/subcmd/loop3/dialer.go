@@ -23,12 +23,17 @@ import (
 	"github.com/openziti/agent"
 	"github.com/openziti/identity/dotziti"
 	"github.com/openziti/identity"
-	"github.com/openziti/sdk-golang/ziti"
-	"github.com/openziti/sdk-golang/ziti/config"
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
 	"github.com/openziti/transport/v2"
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"io"
 	"net"
+	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -38,12 +43,38 @@ func init() {
 }
 
 type dialerCmd struct {
-	cmd            *cobra.Command
-	identity       string
-	endpoint       string
-	direct         bool
-	service        string
-	edgeConfigFile string
+	cmd                *cobra.Command
+	identity           string
+	endpoint           string
+	direct             bool
+	service            string
+	edgeConfigFile     string
+	transport          string
+	poolSize           int
+	resumeFrom         int32
+	checkpointFile     string
+	checkpointInterval time.Duration
+	label              string
+	runId              string
+	seedFromTestName   bool
+	traceFile          string
+	traceLoop          bool
+	precheck           bool
+	payloadSize        int32
+	sizeDistribution   string
+	scenarioTimeout    time.Duration
+	maxRuntime         time.Duration
+	annotations        map[string]string
+	clockSkewThreshold time.Duration
+	allowSkew          bool
+	repeat             int
+	maxBytes           string
+	dialRetries        int
+	dialRetryInterval  time.Duration
+	// checkIdentity has the ziti transport authenticate and check the service can be dialed before running any test,
+	// it's set by the client command
+	checkIdentity bool
+	protocolOptions
 }
 
 func newDialerCmd() *dialerCmd {
@@ -51,6 +82,7 @@ func newDialerCmd() *dialerCmd {
 		cmd: &cobra.Command{
 			Use:   "dialer <scenarioFile>",
 			Short: "Start loop3 dialer",
+			Long:  "Start loop3 dialer, running the tests of the scenario against a listener.\n\n" + exitCodesHelp,
 			Args:  cobra.ExactArgs(1),
 		},
 	}
@@ -63,145 +95,436 @@ func newDialerCmd() *dialerCmd {
 	flags.BoolVarP(&result.direct, "direct", "d", false, "Transmit direct (no ingress)")
 	flags.StringVarP(&result.service, "service", "s", "loop", "Service name for ingress")
 	flags.StringVarP(&result.edgeConfigFile, "config-file", "c", "", "Edge SDK config file")
+	flags.StringVarP(&result.transport, "transport", "t", "", "Transport to dial over [fabric|ziti|tcp|pipe]. Defaults to ziti for edge: endpoints, fabric otherwise")
+	result.addScenarioFlags(flags)
 
 	return result
 }
 
+// addScenarioFlags adds the flags for how the scenario is run, which the dialer shares with the client
+func (cmd *dialerCmd) addScenarioFlags(flags *pflag.FlagSet) {
+	cmd.addFlags(flags)
+	flags.IntVar(&cmd.poolSize, "pool-size", 0, "Keep up to this many connections open and reuse them across workloads, which are then run one at a time")
+	flags.Int32Var(&cmd.resumeFrom, "resume-from", 0, "Start sending and verifying at this block sequence, to pick up an interrupted run from its last checkpoint")
+	flags.StringVar(&cmd.checkpointFile, "checkpoint-file", "", "Periodically write the sequence to resume from to this file")
+	flags.DurationVar(&cmd.checkpointInterval, "checkpoint-interval", time.Minute, "How often to write the checkpoint file")
+	flags.StringVar(&cmd.label, "label", "", "A label for this run, sent to the listener to show in its logs")
+	flags.StringVar(&cmd.runId, "run-id", "", "An ID for this run, sent to the listener to show in its logs. Generated if not set")
+	flags.BoolVar(&cmd.seedFromTestName, "seed-from-testname", false, "Seed each workload's payloads from its name, so they're the same every run but differ between workloads. An explicit seed wins")
+	flags.StringVar(&cmd.traceFile, "trace", "", "Replay the block gaps and sizes in this file, one '<gap> <size>' pair per line, instead of generating random sizes")
+	flags.BoolVar(&cmd.traceLoop, "trace-loop", true, "Start the trace over when it runs out. When false each workload sends at most one block per trace entry")
+	flags.Int32Var(&cmd.payloadSize, "payload-size", 0, "Send blocks with payloads of exactly this many bytes from both sides "+
+		"of every workload, overriding the scenario's payload sizes")
+	flags.StringVar(&cmd.sizeDistribution, "payload-distribution", "", "Draw the payload sizes of every workload from this "+
+		"distribution between its smallest and largest payload: fixed, uniform, normal or pareto, overriding the scenario's payloadDistribution")
+	flags.BoolVar(&cmd.precheck, "precheck", true, "Make sure the peer echoes a block back unchanged before each test, "+
+		"failing at once if it doesn't rather than after a full run")
+	flags.BoolVar(&cmd.bare, "bare", false, "Benchmark a plain echo server: send length prefixed blocks without the magic header and "+
+		"skip the test, metadata and result exchanges. Echoed blocks aren't verified, only throughput and latency are measured")
+	flags.DurationVar(&cmd.scenarioTimeout, "scenario-timeout", 0, "Fail a workload's test on any connection which is still running "+
+		"after this long, closing the connection and moving on, so a hung peer can't stall an unattended run. 0 for no limit")
+	flags.DurationVar(&cmd.maxRuntime, "max-runtime", 0, "Cancel every test once the whole run has taken this long, failing them "+
+		"as timed out, and exit if they still haven't stopped after closing their connections. A safety net for cron and CI. 0 for no limit")
+	flags.StringToStringVar(&cmd.annotations, "annotation", nil, "Annotate every workload's results with key=value, such as "+
+		"a git SHA or ticket number. Repeat for more annotations. They're added to any in the scenario, replacing those with the same key")
+	flags.DurationVar(&cmd.clockSkewThreshold, "warn-on-clock-skew", defaultClockSkewThreshold, "Before a test measuring one-way "+
+		"delay, estimate the listener's clock offset and refuse to run if it's more than this, as the delays would be off by as much. 0 to skip the check")
+	flags.BoolVar(&cmd.allowSkew, "allow-skew", false, "Only warn when the clock offset is over --warn-on-clock-skew, and run the test anyway")
+	flags.IntVar(&cmd.repeat, "repeat", 1, "Run the scenario this many times, one run after another on fresh connections, or the pool's with "+
+		"--pool-size. Each run's results are reported, then the mean, standard deviation and coefficient of variation of every test's "+
+		"throughput and latency over the runs which succeeded")
+	flags.StringVar(&cmd.maxBytes, "max-bytes", "", "Stop each side's txer once it has sent this many bytes, such as 500m or 1g, "+
+		"even if it hasn't sent all its blocks, ending the test cleanly. For metered links. Whichever of this and the block count is reached first wins")
+	flags.IntVar(&cmd.dialRetries, "dial-retries", 0, "Dial again up to this many times when a connection can't be made, backing off "+
+		"between attempts, so the run can be started alongside the fabric rather than after it. A connection which still can't be made exits with code 2")
+	flags.DurationVar(&cmd.dialRetryInterval, "dial-retry-interval", defaultDialRetryInterval, "How long to wait before the first dial "+
+		"retry, doubling with each retry up to "+dialRetryMaxBackoff.String())
+}
+
 func (cmd *dialerCmd) run(_ *cobra.Command, args []string) {
+	exitOnInterrupt()
+	err := cmd.runScenario(args[0])
+	if err != nil {
+		pfxlog.Logger().Error(err)
+	}
+	if code := exitCodeOf(err); code != exitSuccess {
+		os.Exit(int(code))
+	}
+}
+
+// runScenario runs the tests of the scenario in scenarioFile, returning an error with the exit code for the run if
+// any of them failed
+func (cmd *dialerCmd) runScenario(scenarioFile string) error {
 	log := pfxlog.Logger()
+	cmd.startEvents()
+	cmd.startSummaryOnly()
+	if err := cmd.startInflux(); err != nil {
+		return withExitCode(exitSetup, err)
+	}
+	cmd.applyRuntime()
 
 	shutdownClean := false
 	if err := agent.Listen(agent.Options{ShutdownCleanup: &shutdownClean}); err != nil {
 		pfxlog.Logger().WithError(err).Error("unable to start CLI agent")
 	}
 
-	scenario, err := LoadScenario(args[0])
+	scenario, err := LoadScenario(scenarioFile)
+	if err != nil {
+		return withExitCode(exitSetup, err)
+	}
+	if cmd.payloadSize < 0 {
+		return withExitCode(exitSetup, errors.Errorf("--payload-size must not be negative, got %d", cmd.payloadSize))
+	}
+	if err = checkPayloadDistribution(cmd.sizeDistribution); err != nil {
+		return withExitCode(exitSetup, errors.Wrap(err, "invalid --payload-distribution"))
+	}
+	if cmd.repeat < 1 {
+		return withExitCode(exitSetup, errors.Errorf("--repeat must be at least 1, got %d", cmd.repeat))
+	}
+	maxBytes, err := parseMaxBytes(cmd.maxBytes)
 	if err != nil {
-		panic(err)
+		return withExitCode(exitSetup, err)
+	}
+	if err = cmd.checkDialRetries(); err != nil {
+		return withExitCode(exitSetup, err)
+	}
+	if cmd.payloadSize > 0 {
+		scenario.setPayloadSize(cmd.payloadSize)
+	}
+	if cmd.sizeDistribution != "" {
+		scenario.setPayloadDistribution(cmd.sizeDistribution)
+	}
+	if len(cmd.annotations) > 0 {
+		scenario.annotate(cmd.annotations)
 	}
 	log.Debug(scenario)
 
+	if cmd.runId == "" {
+		cmd.runId = uuid.New()
+	}
+	log.Infof("run id [%s]", cmd.runId)
+	metadata := newMetadata(cmd.label, cmd.runId)
+
+	var trace []traceEntry
+	if cmd.traceFile != "" {
+		if trace, err = loadTrace(cmd.traceFile); err != nil {
+			return withExitCode(exitSetup, err)
+		}
+		log.Infof("replaying %d trace entries from [%s]", len(trace), cmd.traceFile)
+	}
+
 	if scenario.Metrics != nil {
 		closer := make(chan struct{})
 		if err := StartMetricsReporter(cmd.edgeConfigFile, scenario.Metrics, closer); err != nil {
-			panic(err)
+			return withExitCode(exitSetup, err)
 		}
 		defer close(closer)
 	}
 
-	resultChs := make(map[string]chan *Result)
-	for _, workload := range scenario.Workloads {
-		log.Infof("executing workload [%s] with concurrency [%d]", workload.Name, workload.Concurrency)
+	dialer, err := cmd.newDialer()
+	if err != nil {
+		return withExitCode(exitSetup, err)
+	}
+	// dial errors carry exitSetup, so they're returned as they are
+	dialer = newRetryingDialer(dialer, cmd.dialRetries, cmd.dialRetryInterval)
 
-		var conns []net.Conn
-		for i := 0; i < int(workload.Concurrency); i++ {
-			conns = append(conns, cmd.connect())
+	var pool *connPool
+	if cmd.poolSize > 0 {
+		if pool, err = newConnPool(dialer, cmd.poolSize); err != nil {
+			return err
 		}
+		defer pool.close()
+	}
 
-		for i, conn := range conns {
-			name := fmt.Sprintf("%s:%d", workload.Name, i)
-			resultCh := make(chan *Result, 1)
-			resultChs[name] = resultCh
+	var checkpoints *checkpointer
+	if cmd.checkpointFile != "" {
+		checkpoints = newCheckpointer(cmd.checkpointFile)
+		checkpointsDone := make(chan struct{})
+		defer close(checkpointsDone)
+		go checkpoints.run(cmd.checkpointInterval, checkpointsDone)
+	}
+
+	var limit *runLimit
+	if cmd.maxRuntime > 0 {
+		limit = startRunLimit(cmd.maxRuntime, maxRuntimeGrace, func() { os.Exit(int(exitTimeout)) })
+		defer limit.cancel()
+	}
+
+	code := exitSuccess
+	repeated := newRepeatedResults()
+	// dialErr is a connection which couldn't be made, which ends the run once the tests already going have finished
+	var dialErr error
+	for run := 1; run <= cmd.repeat && dialErr == nil; run++ {
+		if cmd.repeat > 1 {
+			log.Infof("starting run %d of %d", run, cmd.repeat)
+		}
+		resultChs := make(map[string]chan *Result)
+		for _, workload := range scenario.Workloads {
+			log.Infof("executing workload [%s] with concurrency [%d]", workload.Name, workload.Concurrency)
+
+			conns, dialTimes, err := cmd.dialWorkload(dialer, pool, int(workload.Concurrency))
+			if err != nil {
+				dialErr = err
+				break
+			}
 
-			go func() {
-				local, remote := workload.GetTests()
+			workloadDone := &sync.WaitGroup{}
+			for i, conn := range conns {
+				name := fmt.Sprintf("%s:%d", workload.Name, i)
+				resultCh := make(chan *Result, 1)
+				resultChs[name] = resultCh
+
+				workloadDone.Add(1)
+				go func(workload *Workload, connIndex int, conn io.ReadWriteCloser, resultCh chan *Result) {
+					defer workloadDone.Done()
+					local, remote := workload.GetTests()
+					local.ResumeFrom = cmd.resumeFrom
+					remote.ResumeFrom = cmd.resumeFrom
+					if maxBytes > 0 {
+						capBytes(local, remote, maxBytes)
+					}
+					if cmd.seedFromTestName {
+						seedFromName(local)
+						seedFromName(remote)
+					}
 
-				if proto, err := newProtocol(conn); err == nil {
-					if local.IsTxRandomHashed() {
-						if err := proto.txTest(remote); err != nil {
-							panic(err)
+					if cmd.bare {
+						if err := bareTest(local); err != nil {
+							resultCh <- failedResult(exitSetup, err)
+							return
 						}
 					}
 
-					if err := proto.run(local); err == nil {
-						if result, err := proto.rxResult(); err == nil {
-							resultCh <- result
-						} else {
-							panic(err)
+					options := cmd.protocolOptions
+					if trace != nil {
+						if !local.IsTxRandomHashed() {
+							resultCh <- failedResult(exitSetup, errors.Errorf("--trace needs random hashed blocks, workload [%s] sends %s blocks", workload.Name, local.TxBlockType))
+							return
+						}
+						if !cmd.traceLoop {
+							limitToTrace(local, remote, len(trace))
 						}
-					} else {
-						panic(err)
+						options.blockSource = newTraceSource(trace, local, cmd.traceLoop)
 					}
-				} else {
-					panic(err)
-				}
-			}()
 
-			time.Sleep(time.Duration(scenario.ConnectionDelay) * time.Millisecond)
+					proto, err := newProtocol(conn, &options)
+					if err != nil {
+						resultCh <- failedResult(exitSetup, err)
+						return
+					}
+					proto.connIndex = connIndex
+					proto.dialTime = dialTimes[connIndex]
+					if cmd.repeat > 1 {
+						proto.repeat = run
+					}
+					limit.add(proto)
+					if checkpoints != nil {
+						checkpoints.add(proto, local)
+					}
+
+					var timeout *testTimeout
+					if cmd.scenarioTimeout > 0 {
+						timeout = proto.startTimeout(local.Name, cmd.scenarioTimeout)
+					}
+					result, reusable, err := cmd.runTest(proto, local, remote, metadata)
+					if timeout != nil {
+						timeout.cancel()
+						if err != nil && timeout.hasExpired() {
+							result, reusable, err = timeout.result(), false, nil
+							proto.emitSummary(result, nil)
+						}
+					}
+					if (err != nil || proto.isStopped()) && limit.hasExpired() {
+						result, reusable, err = limit.result(proto, local.Name), false, nil
+						proto.emitSummary(result, nil)
+					}
+					if pool != nil {
+						pool.put(conn, reusable && err == nil)
+					}
+					if err != nil {
+						result = failedResult(exitCodeOf(err), err)
+					}
+					resultCh <- result
+				}(workload, i, conn, resultCh)
+
+				time.Sleep(time.Duration(scenario.ConnectionDelay) * time.Millisecond)
+			}
+
+			if pool != nil {
+				workloadDone.Wait()
+			}
 		}
+
+		for name, resultCh := range resultChs {
+			result := <-resultCh
+			label := name
+			if cmd.repeat > 1 {
+				label = fmt.Sprintf("%s run %d", name, run)
+				repeated.add(name, result)
+			}
+			if !result.Success {
+				code = code.worse(result.exitCode())
+				log.Errorf("[%s] -> %s", label, result.Message)
+			} else {
+				cmd.summaryLogger().Infof("[%s] -> success", label)
+			}
+		}
+	}
+	if cmd.repeat > 1 {
+		repeated.report(cmd.events, cmd.summaryLogger())
 	}
+	if err = cmd.influxFailure(); err != nil {
+		code = code.worse(exitFailed)
+		log.Error(err)
+	}
+	if dialErr != nil {
+		return withExitCode(code.worse(exitCodeOf(dialErr)), dialErr)
+	}
+	if code != exitSuccess {
+		return withExitCode(code, errors.New("failures detected"))
+	}
+	cmd.summaryLogger().Info("success")
+	return nil
+}
 
-	failed := false
-	for name, resultCh := range resultChs {
-		result := <-resultCh
-		if !result.Success {
-			failed = true
-			log.Errorf("[%s] -> %s", name, result.Message)
+// dialWorkload opens concurrency connections for a workload, from the pool if there is one, returning them with how
+// long each took. If one can't be made, those already open are closed or given back to the pool, as no test will run
+// over them, and the dial error is returned
+func (cmd *dialerCmd) dialWorkload(dialer Dialer, pool *connPool, concurrency int) ([]io.ReadWriteCloser, []time.Duration, error) {
+	var conns []io.ReadWriteCloser
+	var dialTimes []time.Duration
+	for i := 0; i < concurrency; i++ {
+		var conn io.ReadWriteCloser
+		var err error
+		dialStart := time.Now()
+		if pool != nil {
+			conn, err = pool.get()
 		} else {
-			log.Infof("[%s] -> success", name)
+			conn, err = cmd.connect(dialer)
 		}
+		if err != nil {
+			for _, open := range conns {
+				if pool != nil {
+					pool.put(open, true)
+				} else if closeErr := open.Close(); closeErr != nil {
+					pfxlog.Logger().WithError(closeErr).Debug("error closing connection")
+				}
+			}
+			return nil, nil, err
+		}
+		conns = append(conns, conn)
+		dialTimes = append(dialTimes, time.Since(dialStart))
 	}
-	if failed {
-		panic("failures detected")
-	} else {
-		log.Info("success")
-	}
+	return conns, dialTimes, nil
 }
 
-func (cmd *dialerCmd) connect() net.Conn {
-	log := pfxlog.Logger()
+// runTest runs a workload's test over proto, returning the result to report for it and whether the connection is left
+// ready for another test
+func (cmd *dialerCmd) runTest(proto *protocol, local, remote *loop3_pb.Test, metadata *loop3_pb.Metadata) (*Result, bool, error) {
+	if cmd.precheck && (cmd.bare || local.IsTxRandomHashed()) {
+		if err := proto.echoCheck(echoCheckTimeout); err != nil {
+			return nil, false, err
+		}
+	}
+	if cmd.bare {
+		result, err := proto.run(local)
+		if err != nil {
+			return nil, false, err
+		}
+		proto.emitSummary(result, nil)
+		return result, true, nil
+	}
+	if local.IsTxRandomHashed() {
+		if cmd.clockSkewThreshold > 0 && (local.OneWayDelay || remote.OneWayDelay) {
+			if err := proto.checkClockSkew(cmd.clockSkewThreshold, cmd.allowSkew); err != nil {
+				return nil, false, err
+			}
+		}
+		handshakeStart := time.Now()
+		if err := proto.txTest(remote); err != nil {
+			return nil, false, err
+		}
+		if _, err := proto.exchangeMetadata(metadata); err != nil {
+			return nil, false, err
+		}
+		proto.handshakeTime = time.Since(handshakeStart)
+	}
+	proto.summaryLogger().Infof("setup: %v (dial %v, handshake %v)", proto.setup(), proto.dialTime, proto.handshakeTime)
 
-	start := time.Now()
+	result, err := proto.run(local)
+	if err != nil {
+		return nil, false, err
+	}
+	peerResult, err := proto.rxResult(result)
+	if err != nil {
+		return nil, false, err
+	}
+	peerResult.ClockOffset = proto.clockOffset
+	peerResult.Setup = proto.setup()
+	proto.emitSummary(result, peerResult)
+	// the listener only waits for another test if this one was sent to it
+	return peerResult, local.IsTxRandomHashed(), nil
+}
 
-	var conn net.Conn
-	var err error
-	if strings.HasPrefix(cmd.endpoint, "edge:") {
-		var context ziti.Context
-		if cmd.edgeConfigFile != "" {
-			zitiCfg, err := config.NewFromFile(cmd.edgeConfigFile)
-			if err != nil {
-				log.Fatalf("failed to load ziti configuration from %s: %v", cmd.edgeConfigFile, err)
-			}
-			context = ziti.NewContextWithConfig(zitiCfg)
+// newDialer returns the Dialer for the selected transport. Without an explicit transport, edge: endpoints are
+// dialed with the SDK and anything else through the fabric
+func (cmd *dialerCmd) newDialer() (Dialer, error) {
+	transportType := cmd.transport
+	if transportType == "" {
+		if strings.HasPrefix(cmd.endpoint, "edge:") {
+			transportType = TransportZiti
 		} else {
-			context = ziti.NewContext()
+			transportType = TransportFabric
 		}
+	}
 
-		service := strings.TrimPrefix(cmd.endpoint, "edge:")
-		conn, err = context.DialWithOptions(service, &ziti.DialOptions{
-			ConnectTimeout: time.Second * 30,
-		})
-		if err != nil {
-			panic(err)
+	switch transportType {
+	case TransportZiti:
+		service := cmd.service
+		if strings.HasPrefix(cmd.endpoint, "edge:") {
+			service = strings.TrimPrefix(cmd.endpoint, "edge:")
 		}
-	} else {
+		if cmd.checkIdentity {
+			return NewZitiServiceDialer(cmd.edgeConfigFile, service)
+		}
+		return NewZitiDialer(cmd.edgeConfigFile, service)
+	case TransportTcp:
+		return NewTcpDialer(cmd.endpoint), nil
+	case TransportPipe:
+		listener := &listenerCmd{}
+		return NewPipeDialer(func(conn net.Conn) {
+			listener.handle(conn, "pipe")
+		}), nil
+	case TransportFabric:
 		endpoint, err := transport.ParseAddress(cmd.endpoint)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 
 		id := &identity.TokenId{Token: "test"}
 		if endpoint.Type() != "tcp" || !cmd.direct {
 			if _, id, err = dotziti.LoadIdentity(cmd.identity); err != nil {
-				panic(err)
+				return nil, err
 			}
 		}
 
-		if cmd.direct {
-			if conn, err = dialDirect(endpoint, id); err != nil {
-				panic(err)
-			}
-		} else {
-			serviceId := &identity.TokenId{Token: cmd.service}
-			if conn, err = dialIngress(endpoint, id, serviceId); err != nil {
-				panic(err)
-			}
-		}
+		return NewFabricDialer(endpoint, id, &identity.TokenId{Token: cmd.service}, cmd.direct), nil
+	default:
+		return nil, errors.Errorf("unknown transport [%s], must be one of %s, %s, %s or %s", transportType, TransportFabric, TransportZiti, TransportTcp, TransportPipe)
+	}
+}
+
+func (cmd *dialerCmd) connect(dialer Dialer) (io.ReadWriteCloser, error) {
+	start := time.Now()
+
+	conn, err := dialer.Dial()
+	if err != nil {
+		return nil, err
 	}
 
 	ConnectionTime.Update(time.Now().Sub(start))
 
-	return conn
+	return conn, nil
 }
 
 func dialDirect(endpoint transport.Address, id *identity.TokenId) (net.Conn, error) {
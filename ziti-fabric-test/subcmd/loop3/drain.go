@@ -0,0 +1,108 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Latency requests are answered by piggybacking on the blocks the peer sends, so the requests which arrive once the
+// peer has sent its last block go unanswered, and the end of a pipelined test is missing from the latency stats. With
+// a test's DrainTimeout set, the txer doesn't stop at its last block. It carries on answering the peer's latency
+// requests, with BlockTypeLateLatencyResponse blocks, until every block the peer sends has arrived or the drain
+// timeout has passed, and then ends with a BlockTypeDrained block. The rxer keeps reading until it has the peer's, so
+// neither side closes the connection with echoes still in flight.
+
+// drainPollInterval is how often the drain checks whether the peer's blocks have all arrived, while there are no
+// latency requests to answer
+const drainPollInterval = 10 * time.Millisecond
+
+// draining returns true if the test drains before closing, which needs framed blocks both ways
+func (p *protocol) draining() bool {
+	return p.test.DrainTimeout > 0 && p.test.IsTxRandomHashed() && p.test.IsRxRandomHashed()
+}
+
+// awaitingDrain returns true while the rxer still has to read the peer's drained block
+func (p *protocol) awaitingDrain() bool {
+	return p.draining() && !p.rxDrained
+}
+
+// awaitingLatencyRequests returns true while latency requests are waiting to be answered, or the peer may still send
+// some
+func (p *protocol) awaitingLatencyRequests() bool {
+	if len(p.latencies) > 0 {
+		return true
+	}
+	return p.stats.RxCount() < p.test.RxRequests && !p.rxCapReached() && atomic.LoadInt32(&p.rxerExited) == 0
+}
+
+// drain answers the peer's latency requests once the txer has sent its last block, until there are none left to come
+// or the drain timeout passes, then tells the peer it's done
+func (p *protocol) drain() {
+	log := p.phaseLogger(PhaseTx)
+	timeout := time.Duration(p.test.DrainTimeout) * time.Millisecond
+	start := time.Now()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	var answered uint32
+drain:
+	for p.awaitingLatencyRequests() {
+		select {
+		case sentAt := <-p.latencies:
+			block := &RandHashedBlock{Type: BlockTypeLateLatencyResponse, Sequence: answered, Timestamp: *sentAt}
+			if err := p.txWithRetries(block); err != nil {
+				p.fail(p.newError(PhaseTx, UnknownSequence, errors.Wrap(err, "unable to answer latency request")))
+				return
+			}
+			answered++
+		case <-time.After(drainPollInterval):
+		case <-deadline.C:
+			log.Warnf("drain timeout of %v passed with %d of %d blocks received and %d latency requests unanswered",
+				timeout, p.stats.RxCount(), p.test.RxRequests, len(p.latencies))
+			break drain
+		case <-p.stopped:
+			return
+		}
+	}
+
+	if err := p.txWithRetries(&RandHashedBlock{Type: BlockTypeDrained, Sequence: answered}); err != nil {
+		p.fail(p.newError(PhaseTx, UnknownSequence, errors.Wrap(err, "unable to end drain")))
+		return
+	}
+	log.Infof("drained in %v, %d latency requests answered after the last block", time.Since(start).Round(time.Millisecond), answered)
+}
+
+// rxDrainBlock handles the blocks the peer sends while it drains, returning false for any other block. Late latency
+// responses have already been recorded by RandHashedBlock.Rx, so there's nothing left to do with them
+func (p *protocol) rxDrainBlock(block Block) bool {
+	hashed, ok := block.(*RandHashedBlock)
+	if !ok {
+		return false
+	}
+	switch hashed.Type {
+	case BlockTypeLateLatencyResponse:
+		return true
+	case BlockTypeDrained:
+		p.rxDrained = true
+		return true
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package loop3
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DrainAnswersLatencyRequestsAfterLastBlock(t *testing.T) {
+	req := require.New(t)
+
+	// the listener sends its 2 blocks long before the dialer's latency requests stop, leaving nothing to answer with
+	test := newLoopbackTest("drain")
+	test.RxRequests = 2
+	test.LatencyFrequency = 1
+
+	result, _ := runLoopbackWithOptions(t, test, &protocolOptions{failFast: true})
+	req.Less(result.Latency.Count, test.TxRequests, "without a drain, latency requests go unanswered")
+
+	test = newLoopbackTest("drain")
+	test.RxRequests = 2
+	test.LatencyFrequency = 1
+	test.DrainTimeout = 5000
+
+	result, peerResult := runLoopbackWithOptions(t, test, &protocolOptions{failFast: true})
+	req.True(result.Success, result.Message)
+	req.Equal(test.TxRequests, result.Latency.Count, "every latency request is answered")
+	req.Equal(test.TxRequests, peerResult.RxCount)
+	req.Equal(test.RxRequests, result.RxCount, "late latency responses aren't counted as blocks")
+	req.Zero(peerResult.LatencyDropped)
+}
+
+func Test_DrainEndsAtTimeout(t *testing.T) {
+	req := require.New(t)
+
+	conn, peerConn := net.Pipe()
+	t.Cleanup(func() { _ = conn.Close() })
+	t.Cleanup(func() { _ = peerConn.Close() })
+
+	test := newLoopbackTest("drain-timeout")
+	test.DrainTimeout = 50
+	p, err := newProtocol(conn, nil)
+	req.NoError(err)
+	p.test = test
+	peer, err := newProtocol(peerConn, nil)
+	req.NoError(err)
+	peer.test = test
+
+	// none of the blocks the drain waits for ever arrive
+	sentAt := time.Now()
+	p.latencies <- &sentAt
+	start := time.Now()
+	go p.drain()
+
+	block := &RandHashedBlock{}
+	req.NoError(block.Rx(peer))
+	req.Equal(byte(BlockTypeLateLatencyResponse), block.Type)
+	req.NoError(block.Rx(peer))
+	req.Equal(byte(BlockTypeDrained), block.Type)
+	req.Equal(uint32(1), block.Sequence, "the drained block counts the late responses")
+	req.GreaterOrEqual(time.Since(start), 50*time.Millisecond)
+	req.Equal(int32(1), peer.stats.Snapshot().Latency.Count)
+}
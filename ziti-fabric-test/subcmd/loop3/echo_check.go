@@ -0,0 +1,125 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"bytes"
+	"crypto/rand"
+	"time"
+
+	"github.com/michaelquigley/pfxlog"
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// The echo check is a frame the dialer sends before the test, which the peer has to send straight back. It catches a
+// peer which isn't a loop3 listener, or mangles data, before a long run is started against it. The frame starts with
+// echoCheckPrefix, which a Test message can't start with as 'l' isn't a valid protobuf tag, so the listener can tell
+// the two apart. Plain echo servers used with --bare pass it by echoing it like anything else. The check is sent before
+// every test unless --precheck=false is given.
+
+var echoCheckPrefix = []byte("loop3-echo-check:")
+
+const (
+	// echoCheckPayloadBytes is how much random data follows the prefix
+	echoCheckPayloadBytes = 1024
+
+	// echoCheckTimeout is how long the peer has to echo the check
+	echoCheckTimeout = 10 * time.Second
+)
+
+// isEchoCheck returns true if body is an echo check frame
+func isEchoCheck(body []byte) bool {
+	return bytes.HasPrefix(body, echoCheckPrefix)
+}
+
+// echoCheck sends an echo check and makes sure the peer sends it back unchanged within timeout. The peer is closed if
+// it doesn't answer in time, as there's no other way to stop waiting for it
+func (p *protocol) echoCheck(timeout time.Duration) error {
+	payload := make([]byte, echoCheckPayloadBytes)
+	if _, err := rand.Read(payload); err != nil {
+		return errors.Wrap(err, "unable to create echo check")
+	}
+	check := append(append([]byte{}, echoCheckPrefix...), payload...)
+
+	start := time.Now()
+	if err := p.framing.WriteFrame(check); err != nil {
+		return errors.Wrap(err, "peer failed echo check, unable to send it")
+	}
+
+	type echo struct {
+		body []byte
+		err  error
+	}
+	echoed := make(chan echo, 1)
+	go func() {
+		body, err := p.framing.ReadFrame()
+		echoed <- echo{body, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case result := <-echoed:
+		if result.err != nil {
+			return errors.Wrap(result.err, "peer failed echo check, it's likely not a loop3 listener")
+		}
+		if !bytes.Equal(result.body, check) {
+			return errors.Errorf("peer failed echo check, the %d bytes it sent back don't match the %d sent", len(result.body), len(check))
+		}
+	case <-timer.C:
+		_ = p.peer.Close()
+		return errors.Errorf("peer failed echo check, no answer in %v", timeout)
+	}
+
+	pfxlog.Logger().Infof("echo check passed in %v", time.Since(start))
+	return nil
+}
+
+// rxTest reads the test from the dialer, answering any echo and clock checks sent ahead of it
+func (p *protocol) rxTest() (*loop3_pb.Test, error) {
+	for {
+		body, err := p.framing.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		if isEchoCheck(body) {
+			if err = p.framing.WriteFrame(body); err != nil {
+				return nil, errors.Wrap(err, "unable to answer echo check")
+			}
+			pfxlog.Logger().Debug("<- [echo check] ->")
+			continue
+		}
+
+		if isClockCheck(body) {
+			if err = p.answerClockCheck(body); err != nil {
+				return nil, errors.Wrap(err, "unable to answer clock check")
+			}
+			pfxlog.Logger().Debug("<- [clock check] ->")
+			continue
+		}
+
+		test := &loop3_pb.Test{}
+		if err = proto.Unmarshal(body, test); err != nil {
+			return nil, errors.Wrapf(err, "unable to unmarshal message of length %d", len(body))
+		}
+		pfxlog.Logger().Infof("<- [test]")
+		return test, nil
+	}
+}
@@ -0,0 +1,81 @@
+package loop3
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/framing"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_EchoCheckAnsweredByListener(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("echo-check")
+	conn, peer := net.Pipe()
+	defer func() { _ = conn.Close() }()
+	go (&listenerCmd{}).handle(peer, "echo-check")
+
+	p, err := newProtocol(conn, nil)
+	req.NoError(err)
+	req.NoError(p.echoCheck(time.Second))
+	// the listener goes on to take the test as usual
+	req.NoError(p.txTest(loopbackPeerTest(test)))
+	_, err = p.exchangeMetadata(newMetadata("echo-check", ""))
+	req.NoError(err)
+
+	result, err := p.run(test)
+	req.NoError(err)
+	peerResult, err := p.rxResult(result)
+	req.NoError(err)
+	req.True(peerResult.Success, peerResult.Message)
+}
+
+func Test_EchoCheckFailures(t *testing.T) {
+	req := require.New(t)
+
+	check := func(serve func(peer *framing.Framing, conn net.Conn)) error {
+		conn, peer := net.Pipe()
+		defer func() { _ = conn.Close() }()
+		go func() {
+			defer func() { _ = peer.Close() }()
+			serve(framing.New(peer, MagicHeader, binary.LittleEndian, 0), peer)
+		}()
+
+		p, err := newProtocol(conn, nil)
+		req.NoError(err)
+		return p.echoCheck(100 * time.Millisecond)
+	}
+
+	err := check(func(peer *framing.Framing, _ net.Conn) {
+		body, _ := peer.ReadFrame()
+		body[len(body)-1]++
+		_ = peer.WriteFrame(body)
+	})
+	req.ErrorContains(err, "peer failed echo check, the 1041 bytes it sent back don't match")
+
+	err = check(func(peer *framing.Framing, _ net.Conn) {
+		_, _ = peer.ReadFrame()
+	})
+	req.ErrorContains(err, "peer failed echo check, it's likely not a loop3 listener")
+	req.ErrorIs(err, io.EOF)
+
+	err = check(func(peer *framing.Framing, conn net.Conn) {
+		_, _ = peer.ReadFrame()
+		// hold the connection open without answering until the dialer gives up
+		_, _ = conn.Read(make([]byte, 1))
+	})
+	req.ErrorContains(err, "peer failed echo check, no answer in 100ms")
+}
+
+func Test_PrecheckIsOnByDefault(t *testing.T) {
+	req := require.New(t)
+
+	cmd := newDialerCmd()
+	req.True(cmd.precheck)
+	req.NoError(cmd.cmd.Flags().Parse([]string{"--precheck=false"}))
+	req.False(cmd.precheck)
+}
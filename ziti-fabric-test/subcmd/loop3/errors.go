@@ -0,0 +1,130 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	PhaseTx     = "tx"
+	PhaseRx     = "rx"
+	PhaseVerify = "verify"
+)
+
+// UnknownSequence is used as the ProtocolError sequence when the failure happened before a block could be identified
+const UnknownSequence int64 = -1
+
+// ProtocolError is a failure in one of the protocol's tx, rx or verify loops, along with enough context to tell which
+// connection and block it came from when many connections are running at once
+type ProtocolError struct {
+	Test     string
+	Phase    string
+	Conn     int
+	Sequence int64
+	Err      error
+}
+
+func (e *ProtocolError) Error() string {
+	context := []string{e.Phase, fmt.Sprintf("conn %d", e.Conn)}
+	if e.Sequence != UnknownSequence {
+		context = append(context, fmt.Sprintf("block #%d", e.Sequence))
+	}
+	if e.Test != "" {
+		context = append([]string{e.Test}, context...)
+	}
+	return fmt.Sprintf("[%s] %v", strings.Join(context, " "), e.Err)
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+// Cause supports github.com/pkg/errors.Cause
+func (e *ProtocolError) Cause() error {
+	return e.Err
+}
+
+// newError wraps cause with the context of this protocol
+func (p *protocol) newError(phase string, sequence int64, cause error) *ProtocolError {
+	name := ""
+	if p.test != nil {
+		name = p.test.Name
+	}
+	return &ProtocolError{
+		Test:     name,
+		Phase:    phase,
+		Conn:     p.connIndex,
+		Sequence: sequence,
+		Err:      cause,
+	}
+}
+
+// fail records err from the tx, rx or verify loop and returns true if the loop should stop. With fail fast the first
+// error stops the whole test. Otherwise errors are collected, to be reported when the test ends, until there's no
+// room for any more. The tx and rx loops stop after any error regardless, since the connection can't be trusted.
+func (p *protocol) fail(err *ProtocolError) bool {
+	p.phaseLogger(err.Phase).Error(err)
+	p.stats.RecordError(err)
+
+	select {
+	case p.errors <- err:
+		if !p.options.failFast {
+			return false
+		}
+	default:
+		p.logger().Errorf("too many errors (%d), stopping", cap(p.errors))
+	}
+	p.stop()
+	return true
+}
+
+// stop ends the test, closing the peer to unblock any loop waiting on it
+func (p *protocol) stop() {
+	p.stopOnce.Do(func() {
+		p.cancel()
+		if err := p.peer.Close(); err != nil {
+			p.logger().WithError(err).Error("unable to close peer")
+		}
+	})
+}
+
+// cancel ends the test without closing the peer, so loops stop at their next block but a blocked read or write isn't
+// interrupted
+func (p *protocol) cancel() {
+	p.cancelOnce.Do(func() {
+		close(p.stopped)
+	})
+}
+
+func (p *protocol) isStopped() bool {
+	select {
+	case <-p.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// blockSequence returns the sequence number a block was sent with, if it carries one
+func blockSequence(block Block) int64 {
+	if hashed, ok := block.(*RandHashedBlock); ok {
+		return int64(hashed.Sequence)
+	}
+	return UnknownSequence
+}
@@ -0,0 +1,99 @@
+package loop3
+
+import (
+	"crypto/sha512"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ProtocolErrorFormat(t *testing.T) {
+	req := require.New(t)
+
+	p := &protocol{test: &loop3_pb.Test{Name: "throughput:2"}, connIndex: 2}
+
+	err := p.newError(PhaseVerify, 17, errors.New("mismatched hashes"))
+	req.Equal("[throughput:2 verify conn 2 block #17] mismatched hashes", err.Error())
+
+	err = p.newError(PhaseRx, UnknownSequence, io.ErrUnexpectedEOF)
+	req.Equal("[throughput:2 rx conn 2] unexpected EOF", err.Error())
+	req.ErrorIs(err, io.ErrUnexpectedEOF)
+	req.Equal(io.ErrUnexpectedEOF, errors.Cause(err))
+}
+
+func Test_VerifyFailureIsProtocolError(t *testing.T) {
+	req := require.New(t)
+
+	conn, peer := net.Pipe()
+	defer func() { _ = peer.Close() }()
+
+	p, err := newProtocol(conn, nil)
+	req.NoError(err)
+	p.test = &loop3_pb.Test{Name: "verify", RxTimeout: 5000}
+	p.connIndex = 1
+
+	block := &RandHashedBlock{Type: BlockTypePlain, Sequence: 3, Data: []byte("data")}
+	go func() { p.rxBlocks <- block }()
+	p.verifier(make(chan struct{}))
+
+	var protocolErr *ProtocolError
+	req.ErrorAs(p.firstError(), &protocolErr)
+	req.Equal(PhaseVerify, protocolErr.Phase)
+	req.Equal(1, protocolErr.Conn)
+	req.Equal(int64(3), protocolErr.Sequence)
+}
+
+func Test_FailFastStopsAtFirstVerifyError(t *testing.T) {
+	req := require.New(t)
+
+	conn, peer := net.Pipe()
+	defer func() { _ = peer.Close() }()
+
+	p, err := newProtocol(conn, &protocolOptions{failFast: true})
+	req.NoError(err)
+	p.test = &loop3_pb.Test{Name: "fail-fast", RxTimeout: 5000}
+
+	go func() {
+		p.rxBlocks <- &RandHashedBlock{Type: BlockTypePlain, Sequence: 0, Data: []byte("bad")}
+	}()
+	p.verifier(make(chan struct{}))
+
+	req.True(p.isStopped())
+	_, err = conn.Write([]byte{0})
+	req.Error(err, "the peer should be closed")
+	req.Len(p.errors, 1)
+}
+
+func Test_CollectErrorsKeepsVerifying(t *testing.T) {
+	req := require.New(t)
+
+	conn, peer := net.Pipe()
+	defer func() { _ = peer.Close() }()
+
+	p, err := newProtocol(conn, &protocolOptions{failFast: false})
+	req.NoError(err)
+	p.test = &loop3_pb.Test{Name: "collect", RxTimeout: 5000}
+
+	good := []byte("good")
+	hash := sha512.Sum512(good)
+	go func() {
+		p.rxBlocks <- &RandHashedBlock{Type: BlockTypePlain, Sequence: 0, Data: []byte("bad")}
+		p.rxBlocks <- &RandHashedBlock{Type: BlockTypePlain, Sequence: 1, Data: good, Hash: hash[:]}
+		p.rxBlocks <- &RandHashedBlock{Type: BlockTypePlain, Sequence: 5, Data: good, Hash: hash[:]}
+		p.rxBlocks <- &RandHashedBlock{Type: BlockTypePlain, Sequence: 6, Data: good, Hash: hash[:]}
+		close(p.rxBlocks)
+	}()
+	p.verifier(make(chan struct{}))
+
+	req.False(p.isStopped())
+	req.Len(p.errors, 2, "only the bad hash and the sequence gap should be reported")
+
+	var protocolErr *ProtocolError
+	req.ErrorAs(p.firstError(), &protocolErr)
+	req.Equal(int64(0), protocolErr.Sequence)
+	req.Equal(uint64(7), p.rxSequence)
+}
@@ -0,0 +1,145 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/michaelquigley/pfxlog"
+	"github.com/pkg/errors"
+)
+
+// exitCode is what the dialer exits with, so scripts can tell a broken fabric from a slow one or a cancelled run
+// without reading the logs
+type exitCode int
+
+const (
+	exitSuccess exitCode = 0
+	// exitFailed is a test which failed once it was running: blocks which didn't verify, a broken exchange with the
+	// peer or a peer which failed its own side of the test
+	exitFailed exitCode = 1
+	// exitSetup is a run which couldn't get going: a bad scenario or flags, or a connection which couldn't be made, even
+	// after any --dial-retries
+	exitSetup exitCode = 2
+	// exitThreshold is a test which missed a threshold it asserts, such as a peer clock further off than
+	// --warn-on-clock-skew allows
+	exitThreshold exitCode = 3
+	// exitTimeout is a test cut short by --scenario-timeout or --max-runtime
+	exitTimeout exitCode = 4
+	// exitInterrupted is a run stopped with SIGINT, following the shell's 128 + signal convention
+	exitInterrupted exitCode = 130
+)
+
+// exitCodesHelp describes the exit codes for the dialer's help
+const exitCodesHelp = `Exit codes:
+  0    every test succeeded
+  1    a test failed: blocks didn't verify, or the exchange with the peer broke down
+  2    the run couldn't start: a bad scenario or flags, or the connection couldn't be made, even after any --dial-retries
+  3    a test missed a threshold it asserts: the peer's clock is further off than --warn-on-clock-skew allows
+  4    a test timed out, see --scenario-timeout and --max-runtime
+  130  interrupted with SIGINT
+
+When tests fail in different ways the most serious is reported, in the order 2, 1, 3, 4.`
+
+// exitPrecedence orders the failure codes from most to least serious. A run which couldn't start at all says the
+// most, then a broken test, then one which was only slow
+var exitPrecedence = []exitCode{exitSetup, exitFailed, exitThreshold, exitTimeout}
+
+// worse returns whichever of code and other is the more serious. Success is never worse than a failure
+func (code exitCode) worse(other exitCode) exitCode {
+	if code == exitSuccess {
+		return other
+	}
+	if other == exitSuccess {
+		return code
+	}
+	for _, c := range exitPrecedence {
+		if c == code || c == other {
+			return c
+		}
+	}
+	return code
+}
+
+// exitError is an error which ends the run with a specific exit code
+type exitError struct {
+	code exitCode
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// Cause supports github.com/pkg/errors.Cause
+func (e *exitError) Cause() error {
+	return e.err
+}
+
+// withExitCode returns err, which ends the run with code. A nil err stays nil
+func withExitCode(code exitCode, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// exitCodeOf returns the exit code for the error a run ended with. Errors without one are test failures
+func exitCodeOf(err error) exitCode {
+	if err == nil {
+		return exitSuccess
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return exitFailed
+}
+
+// exitCode returns the exit code the result calls for. Results from the protocol which failed are test failures,
+// the others say why they failed, see failedResult
+func (r *Result) exitCode() exitCode {
+	if r.Success {
+		return exitSuccess
+	}
+	if r.exit != exitSuccess {
+		return r.exit
+	}
+	return exitFailed
+}
+
+// failedResult is the result of a test which failed outside of the protocol, with err and the exit code it calls for
+func failedResult(code exitCode, err error) *Result {
+	return &Result{Message: err.Error(), exit: code}
+}
+
+// exitOnInterrupt exits with exitInterrupted on SIGINT, rather than the Go runtime's default of dying by the signal
+func exitOnInterrupt() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT)
+	go func() {
+		<-signals
+		pfxlog.Logger().Error("interrupted, exiting")
+		os.Exit(int(exitInterrupted))
+	}()
+}
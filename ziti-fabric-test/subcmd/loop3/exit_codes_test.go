@@ -0,0 +1,53 @@
+package loop3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ExitCodePrecedence(t *testing.T) {
+	req := require.New(t)
+
+	req.Equal(exitSuccess, exitSuccess.worse(exitSuccess))
+	req.Equal(exitTimeout, exitSuccess.worse(exitTimeout))
+	req.Equal(exitTimeout, exitTimeout.worse(exitSuccess))
+	req.Equal(exitFailed, exitTimeout.worse(exitFailed))
+	req.Equal(exitSetup, exitFailed.worse(exitSetup))
+	req.Equal(exitThreshold, exitThreshold.worse(exitTimeout))
+}
+
+func Test_ExitCodeOf(t *testing.T) {
+	req := require.New(t)
+
+	req.Equal(exitSuccess, exitCodeOf(nil))
+	req.Nil(withExitCode(exitSetup, nil))
+	req.Equal(exitFailed, exitCodeOf(errors.New("mismatched hashes")))
+
+	err := errors.Wrap(withExitCode(exitSetup, errors.New("connection refused")), "unable to dial")
+	req.Equal(exitSetup, exitCodeOf(err))
+	req.Equal("unable to dial: connection refused", err.Error())
+}
+
+func Test_ResultExitCodes(t *testing.T) {
+	req := require.New(t)
+
+	req.Equal(exitSuccess, (&Result{Success: true}).exitCode())
+	req.Equal(exitFailed, (&Result{Message: "mismatched hashes"}).exitCode())
+	req.Equal(exitSetup, failedResult(exitSetup, errors.New("bad trace")).exitCode())
+
+	// a test error keeps the exit code it carries, such as a precheck which couldn't run
+	err := errors.Wrap(withExitCode(exitSetup, errors.New("echo check timed out")), "precheck")
+	req.Equal(exitSetup, failedResult(exitCodeOf(err), err).exitCode())
+	req.Equal(exitFailed, failedResult(exitCodeOf(errors.New("eof")), errors.New("eof")).exitCode())
+
+	p, err := newProtocol(&testPeer{}, nil)
+	req.NoError(err)
+	timeout := &testTimeout{p: p, name: "slow", limit: time.Second, start: time.Now()}
+	req.Equal(exitTimeout, timeout.result().exitCode())
+
+	limit := &runLimit{limit: time.Second, starts: map[*protocol]time.Time{p: time.Now()}}
+	req.Equal(exitTimeout, limit.result(p, "slow").exitCode())
+}
@@ -0,0 +1,207 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Flow control keeps the rxer from being held up by a verifier which can't keep up. When a test's FlowWindow is set,
+// each side may have at most that many blocks sent to the peer but not yet verified by it. The rxer hands blocks to the
+// verifier through a buffer of the same size, so it never has to wait, and goes on answering latency requests on time.
+//
+// Both sides start out with a window's worth of credit. As the verifier gets through blocks it grants the peer more
+// credit, in BlockTypeWindow blocks whose sequence is the number of blocks granted. No more is granted than the peer
+// needs to send the blocks the test expects, so no grants are left in flight once the peer has sent its last block.
+// That relies on the tx count of each side matching the rx count of the other. A side stopped short by its byte cap
+// works out the grants it's still owed for the blocks it did send instead, see txCredits.stopAt.
+
+// errFlowStopped is returned by txCredits.take when the test stops while waiting for credit
+var errFlowStopped = errors.New("test stopped")
+
+// txCredits are the blocks the peer has allowed this side to send
+type txCredits struct {
+	lock      sync.Mutex
+	window    int32
+	available int32
+	granted   int32
+	needed    int32
+	changed   chan struct{}
+}
+
+// newTxCredits returns the credits for sending needed blocks, starting with a window's worth
+func newTxCredits(window, needed int32) *txCredits {
+	return &txCredits{
+		window:    window,
+		available: window,
+		granted:   window,
+		needed:    needed,
+		changed:   make(chan struct{}, 1),
+	}
+}
+
+// grant adds n blocks of credit, as granted by the peer
+func (c *txCredits) grant(n int32) {
+	c.lock.Lock()
+	c.available += n
+	c.granted += n
+	c.lock.Unlock()
+
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// take uses up one block of credit, waiting up to timeout for the peer to grant more if there's none left. Without a
+// timeout it waits until the test stops
+func (c *txCredits) take(stopped <-chan struct{}, timeout time.Duration) error {
+	var expired <-chan time.Time
+	for waiting := false; ; waiting = true {
+		c.lock.Lock()
+		if c.available > 0 {
+			c.available--
+			c.lock.Unlock()
+			return nil
+		}
+		c.lock.Unlock()
+
+		if !waiting && timeout > 0 {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			expired = timer.C
+		}
+		select {
+		case <-c.changed:
+		case <-stopped:
+			return errFlowStopped
+		case <-expired:
+			return errors.Errorf("no flow control credit granted by the peer in %v", timeout)
+		}
+	}
+}
+
+// stopAt notes that the txer stops after sending sent blocks, short of the count it was granted credit for. The peer
+// goes on granting credit as it verifies them, as if the rest were coming, so the grants to wait for are worked out
+// the way the peer's rxGrants hands them out, rather than from the blocks sent
+func (c *txCredits) stopAt(sent int32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	grants := newRxGrants(c.window, c.needed)
+	c.needed = c.window
+	for i := int32(0); i < sent; i++ {
+		c.needed += grants.verified()
+	}
+}
+
+// awaiting returns true until the peer has granted all the credit it will for the blocks to be sent
+func (c *txCredits) awaiting() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.granted < c.needed
+}
+
+// rxGrants works out when the verifier grants the peer more credit. It's only used by the verifier, or by the rxer
+// with --no-verify, as there's no verifier then
+type rxGrants struct {
+	threshold int32
+	pending   int32
+	remaining int32
+}
+
+// newRxGrants returns the grants for receiving expected blocks, which the peer starts out with a window's worth of
+// credit for. Credit is granted half a window at a time
+func newRxGrants(window, expected int32) *rxGrants {
+	grants := &rxGrants{threshold: window / 2, remaining: expected - window}
+	if grants.threshold < 1 {
+		grants.threshold = 1
+	}
+	if grants.remaining < 0 {
+		grants.remaining = 0
+	}
+	return grants
+}
+
+// verified notes that a block has been verified, returning the credit to grant the peer now, if any
+func (g *rxGrants) verified() int32 {
+	if g.remaining == 0 {
+		return 0
+	}
+	g.pending++
+	if g.pending < g.threshold && g.pending < g.remaining {
+		return 0
+	}
+	n := g.pending
+	if n > g.remaining {
+		n = g.remaining
+	}
+	g.remaining -= n
+	g.pending = 0
+	return n
+}
+
+// flowControlled returns true if the test uses flow control. Grants travel as random hashed blocks, so both directions
+// have to use them
+func (p *protocol) flowControlled() bool {
+	return p.test.FlowWindow > 0 && p.test.IsTxRandomHashed() && p.test.IsRxRandomHashed()
+}
+
+// startFlowControl sets up flow control for the test, if it's enabled
+func (p *protocol) startFlowControl() {
+	if !p.flowControlled() {
+		return
+	}
+	p.rxBlocks = make(chan Block, p.test.FlowWindow)
+	p.txCredits = newTxCredits(p.test.FlowWindow, p.test.TxRequests-p.test.ResumeFrom)
+	p.rxGrants = newRxGrants(p.test.FlowWindow, p.test.RxRequests-p.test.ResumeFrom)
+	p.logger().Infof("flow control window of %d blocks", p.test.FlowWindow)
+}
+
+// awaitingCredits returns true if the rxer has to keep reading for grants after it has received all its blocks
+func (p *protocol) awaitingCredits() bool {
+	return p.txCredits != nil && p.txCredits.awaiting()
+}
+
+// txWindow grants the peer credit for n more blocks. It's sent by the verifier, alongside the txer's blocks
+func (p *protocol) txWindow(n int32) error {
+	block := &RandHashedBlock{Type: BlockTypeWindow, Sequence: uint32(n)}
+	p.txLock.Lock()
+	defer p.txLock.Unlock()
+	return block.Tx(p)
+}
+
+// grantVerified notes that another block has been verified, granting the peer more credit if it's due
+func (p *protocol) grantVerified() error {
+	if p.rxGrants == nil {
+		return nil
+	}
+	if n := p.rxGrants.verified(); n > 0 {
+		if err := p.txWindow(n); err != nil {
+			return errors.Wrap(err, "unable to grant flow control credit")
+		}
+	}
+	return nil
+}
+
+// isWindowBlock returns true for the blocks carrying flow control grants
+func isWindowBlock(block Block) bool {
+	hashed, ok := block.(*RandHashedBlock)
+	return ok && hashed.Type == BlockTypeWindow
+}
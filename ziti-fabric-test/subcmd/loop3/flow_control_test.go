@@ -0,0 +1,92 @@
+package loop3
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TxCredits(t *testing.T) {
+	req := require.New(t)
+
+	stopped := make(chan struct{})
+	credits := newTxCredits(2, 5)
+	req.NoError(credits.take(stopped, time.Second))
+	req.NoError(credits.take(stopped, time.Second))
+	req.True(credits.awaiting())
+
+	err := credits.take(stopped, 10*time.Millisecond)
+	req.Error(err)
+	req.Contains(err.Error(), "no flow control credit")
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		credits.grant(3)
+	}()
+	req.NoError(credits.take(stopped, time.Second))
+	req.False(credits.awaiting(), "every block to send has credit")
+
+	credits = newTxCredits(0, 1)
+	close(stopped)
+	req.Equal(errFlowStopped, credits.take(stopped, 0))
+}
+
+func Test_RxGrants(t *testing.T) {
+	req := require.New(t)
+
+	// half a window at a time, never more than the peer needs on top of its first window
+	grants := newRxGrants(4, 11)
+	var granted []int32
+	for i := 0; i < 11; i++ {
+		if n := grants.verified(); n > 0 {
+			granted = append(granted, n)
+		}
+	}
+	req.Equal([]int32{2, 2, 2, 1}, granted)
+
+	grants = newRxGrants(10, 5)
+	for i := 0; i < 5; i++ {
+		req.Zero(grants.verified(), "the first window covers every block")
+	}
+
+	grants = newRxGrants(1, 3)
+	req.Equal(int32(1), grants.verified())
+	req.Equal(int32(1), grants.verified())
+	req.Zero(grants.verified())
+}
+
+func Test_FlowControlledRun(t *testing.T) {
+	req := require.New(t)
+
+	for _, window := range []int32{1, 3, 64} {
+		test := newLoopbackTest("flow")
+		test.FlowWindow = window
+		test.RxRequests = 30
+
+		conn, peer := net.Pipe()
+		go (&listenerCmd{}).handle(peer, "flow")
+
+		p, err := newProtocol(conn, nil)
+		req.NoError(err)
+		req.NoError(p.txTest(loopbackPeerTest(test)))
+		_, err = p.exchangeMetadata(newMetadata("flow", ""))
+		req.NoError(err)
+
+		result, err := p.run(test)
+		req.NoError(err, "window %d", window)
+		req.Equal(test.TxRequests, result.TxCount)
+		req.Equal(test.RxRequests, result.RxCount)
+		req.False(p.awaitingCredits())
+
+		// no grants are left over to get in the way of the result
+		peerResult, err := p.rxResult(result)
+		req.NoError(err)
+		req.True(peerResult.Success, peerResult.Message)
+		req.Equal(test.TxRequests, peerResult.RxCount)
+
+		_ = conn.Close()
+		_ = peer.Close()
+	}
+}
@@ -0,0 +1,195 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+// Package framing reads and writes the frames loop3 exchanges with its peers: a magic header, the length of the body
+// as a 4 byte signed integer, then the body, which is usually a protobuf message.
+package framing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+const lengthBytes = 4
+
+// Framing reads and writes frames on a stream. Frame lengths are only checked against the max length when reading,
+// as it's the limit this side is willing to accept, not one the peer has agreed to.
+type Framing struct {
+	rw          io.ReadWriter
+	magicHeader []byte
+	byteOrder   binary.ByteOrder
+	maxLength   int
+}
+
+// New returns a Framing for rw, starting each frame with magicHeader and writing lengths in byteOrder. Frames longer
+// than maxLength are rejected when read, a maxLength of 0 or less allows any length which fits the length field
+func New(rw io.ReadWriter, magicHeader []byte, byteOrder binary.ByteOrder, maxLength int) *Framing {
+	if maxLength <= 0 || maxLength > math.MaxInt32 {
+		maxLength = math.MaxInt32
+	}
+	return &Framing{
+		rw:          rw,
+		magicHeader: magicHeader,
+		byteOrder:   byteOrder,
+		maxLength:   maxLength,
+	}
+}
+
+// HeaderLen returns the number of bytes preceding each frame's body
+func (f *Framing) HeaderLen() int {
+	return len(f.magicHeader) + lengthBytes
+}
+
+// MaxLength returns the longest frame body ReadFrame accepts
+func (f *Framing) MaxLength() int {
+	return f.maxLength
+}
+
+// WriteMessage writes msg as the body of a frame
+func (f *Framing) WriteMessage(msg proto.Message) error {
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal message")
+	}
+	return f.WriteFrame(body)
+}
+
+// ReadMessage reads a frame and unmarshals its body into msg. Errors are as for ReadFrame.
+func (f *Framing) ReadMessage(msg proto.Message) error {
+	body, err := f.ReadFrame()
+	if err != nil {
+		return err
+	}
+	if err = proto.Unmarshal(body, msg); err != nil {
+		return errors.Wrapf(err, "unable to unmarshal message of length %d", len(body))
+	}
+	return nil
+}
+
+// WriteFrame writes a frame with body, the header and body going out in a single write
+func (f *Framing) WriteFrame(body []byte) error {
+	frame := make([]byte, 0, f.HeaderLen()+len(body))
+	frame = append(frame, f.header(len(body))...)
+	frame = append(frame, body...)
+	return f.write(frame)
+}
+
+// WriteHeader writes the header of a frame whose body is length bytes long, for callers writing the body themselves
+func (f *Framing) WriteHeader(length int) error {
+	return f.write(f.header(length))
+}
+
+// ReadFrame reads a frame and returns its body. It returns io.EOF only if the stream ended before the frame started,
+// running out part way through is io.ErrUnexpectedEOF.
+func (f *Framing) ReadFrame() ([]byte, error) {
+	length, err := f.ReadHeader()
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(f.rw, body); err != nil {
+		return nil, midFrame(err)
+	}
+	return body, nil
+}
+
+// ReadHeader reads the header of a frame and returns the length of its body, which the caller must read next. It
+// returns io.EOF only if the stream ended before the header started.
+func (f *Framing) ReadHeader() (int, error) {
+	header := make([]byte, f.HeaderLen())
+	if _, err := io.ReadFull(f.rw, header[:len(f.magicHeader)]); err != nil {
+		return 0, err
+	}
+	if got := header[:len(f.magicHeader)]; !bytes.Equal(f.magicHeader, got) {
+		if hint := otherProtocolHint(got); hint != "" {
+			return 0, errors.Errorf("bad header. Got %v, expected %v: %s", got, f.magicHeader, hint)
+		}
+		return 0, errors.Errorf("bad header. Got %v, expected %v", got, f.magicHeader)
+	}
+	if _, err := io.ReadFull(f.rw, header[len(f.magicHeader):]); err != nil {
+		return 0, midFrame(err)
+	}
+	length := int32(f.byteOrder.Uint32(header[len(f.magicHeader):]))
+	if length < 0 || int(length) > f.maxLength {
+		return 0, errors.Errorf("invalid message length %d, must be between 0 and %d", length, f.maxLength)
+	}
+	return int(length), nil
+}
+
+func (f *Framing) header(length int) []byte {
+	header := make([]byte, f.HeaderLen())
+	copy(header, f.magicHeader)
+	f.byteOrder.PutUint32(header[len(f.magicHeader):], uint32(int32(length)))
+	return header
+}
+
+func (f *Framing) write(data []byte) error {
+	n, err := f.rw.Write(data)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return io.ErrShortWrite
+	}
+	return nil
+}
+
+// httpMethods are the starts of the requests an HTTP client may send, cut to the length of a magic header
+var httpMethods = []string{"GET ", "HEAD", "POST", "PUT ", "DELE", "OPTI", "PATC", "CONN", "TRAC", "PRI "}
+
+// otherProtocolHint returns what the peer seems to be when got, the start of what it sent instead of a magic header,
+// looks like another protocol, or an empty string if it doesn't. Pointing loop3 at the wrong endpoint is a far likelier
+// mistake than a loop3 peer sending a bad header
+func otherProtocolHint(got []byte) string {
+	if len(got) >= 2 && got[1] == 0x03 {
+		switch got[0] {
+		case 0x16:
+			return "the peer appears to be a TLS server or client, not a loop3 peer"
+		case 0x15:
+			return "the peer appears to be a TLS server rejecting a plain connection, not a loop3 peer"
+		}
+	}
+
+	text := string(got)
+	// some servers answer a request they can't parse with a bare HTML error page
+	if lower := strings.ToLower(text); strings.HasPrefix(text, "HTTP") || strings.HasPrefix(lower, "<!do") || strings.HasPrefix(lower, "<htm") {
+		return "the peer appears to be an HTTP server, not a loop3 peer"
+	}
+	for _, method := range httpMethods {
+		if strings.HasPrefix(text, method) {
+			return "the peer appears to be an HTTP client, not a loop3 peer"
+		}
+	}
+	if strings.HasPrefix(text, "SSH-") {
+		return "the peer appears to be an SSH server, not a loop3 peer"
+	}
+	return ""
+}
+
+// midFrame turns io.EOF into io.ErrUnexpectedEOF, for reads after the start of a frame where running out of input
+// means the frame was cut short
+func midFrame(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
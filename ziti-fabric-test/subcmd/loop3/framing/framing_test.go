@@ -0,0 +1,134 @@
+package framing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+var testMagic = []byte{0xCA, 0xFE, 0xF0, 0x0D}
+
+func Test_MessageRoundTrip(t *testing.T) {
+	req := require.New(t)
+
+	for _, byteOrder := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		buf := &bytes.Buffer{}
+		f := New(buf, testMagic, byteOrder, 1024)
+
+		sent := &loop3_pb.Test{Name: "round-trip", TxRequests: 10, PayloadMaxBytes: 4096}
+		req.NoError(f.WriteMessage(sent))
+		req.Equal(f.HeaderLen()+proto.Size(sent), buf.Len())
+		req.Equal(testMagic, buf.Bytes()[:len(testMagic)])
+		req.Equal(uint32(proto.Size(sent)), byteOrder.Uint32(buf.Bytes()[len(testMagic):]))
+
+		read := &loop3_pb.Test{}
+		req.NoError(f.ReadMessage(read))
+		req.True(proto.Equal(sent, read))
+		req.Equal(0, buf.Len())
+	}
+}
+
+func Test_FrameRoundTrip(t *testing.T) {
+	req := require.New(t)
+
+	buf := &bytes.Buffer{}
+	f := New(buf, []byte("LOOP"), binary.BigEndian, 0)
+	req.NoError(f.WriteFrame([]byte("hello")))
+	req.NoError(f.WriteFrame(nil))
+
+	body, err := f.ReadFrame()
+	req.NoError(err)
+	req.Equal("hello", string(body))
+
+	body, err = f.ReadFrame()
+	req.NoError(err)
+	req.Empty(body)
+}
+
+func Test_ReadRejectsInvalidLengths(t *testing.T) {
+	req := require.New(t)
+
+	for _, length := range []int{-1, 1025} {
+		buf := &bytes.Buffer{}
+		f := New(buf, testMagic, binary.LittleEndian, 1024)
+		req.NoError(f.WriteHeader(length))
+
+		_, err := f.ReadFrame()
+		req.Error(err)
+		req.Contains(err.Error(), "invalid message length")
+	}
+}
+
+func Test_ReadRejectsBadMagic(t *testing.T) {
+	req := require.New(t)
+
+	buf := &bytes.Buffer{}
+	req.NoError(New(buf, []byte{1, 2, 3, 4}, binary.LittleEndian, 0).WriteFrame([]byte("data")))
+
+	_, err := New(buf, testMagic, binary.LittleEndian, 0).ReadFrame()
+	req.Error(err)
+	req.Contains(err.Error(), "bad header")
+}
+
+func Test_ReadRecognizesOtherProtocols(t *testing.T) {
+	req := require.New(t)
+
+	for sent, hint := range map[string]string{
+		"\x16\x03\x01\x02\x00\x01\x00": "the peer appears to be a TLS server or client, not a loop3 peer",
+		"\x15\x03\x03\x00\x02\x02\x32": "the peer appears to be a TLS server rejecting a plain connection",
+		"HTTP/1.1 400 Bad Request\r\n": "the peer appears to be an HTTP server",
+		"<!DOCTYPE HTML>\n":            "the peer appears to be an HTTP server",
+		"GET / HTTP/1.1\r\n":           "the peer appears to be an HTTP client",
+		"SSH-2.0-OpenSSH_9.6\r\n":      "the peer appears to be an SSH server",
+	} {
+		_, err := New(bytes.NewBufferString(sent), testMagic, binary.LittleEndian, 0).ReadFrame()
+		req.Error(err)
+		req.Contains(err.Error(), "bad header. Got ["+fmt.Sprint(sent[0]), sent)
+		req.Contains(err.Error(), hint, sent)
+	}
+
+	_, err := New(bytes.NewBufferString("\x01\x02\x03\x04"), testMagic, binary.LittleEndian, 0).ReadFrame()
+	req.Error(err)
+	req.NotContains(err.Error(), "appears to be", "unrecognized bytes are only dumped")
+}
+
+func Test_ReadTellsCleanEndFromTruncatedFrame(t *testing.T) {
+	req := require.New(t)
+
+	encoded := &bytes.Buffer{}
+	req.NoError(New(encoded, testMagic, binary.LittleEndian, 0).WriteFrame([]byte("some body")))
+	frame := encoded.Bytes()
+
+	_, err := New(&bytes.Buffer{}, testMagic, binary.LittleEndian, 0).ReadFrame()
+	req.Equal(io.EOF, err)
+
+	// cut short in the magic header, the length and the body
+	for _, cut := range []int{2, len(testMagic) + 2, len(frame) - 2} {
+		f := New(bytes.NewBuffer(append([]byte(nil), frame[:cut]...)), testMagic, binary.LittleEndian, 0)
+		_, err = f.ReadFrame()
+		req.Equal(io.ErrUnexpectedEOF, err, "cut at %d", cut)
+	}
+}
+
+type shortWriter struct{}
+
+func (shortWriter) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (shortWriter) Write(p []byte) (int, error) {
+	return len(p) / 2, nil
+}
+
+func Test_WriteReportsShortWrites(t *testing.T) {
+	req := require.New(t)
+
+	err := New(shortWriter{}, testMagic, binary.LittleEndian, 0).WriteFrame([]byte("data"))
+	req.Equal(io.ErrShortWrite, err)
+}
@@ -0,0 +1,84 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import "time"
+
+// genQueueSampleInterval is how often the progress reporter samples the generator queue between reports
+const genQueueSampleInterval = 10 * time.Millisecond
+
+// genQueueBoundRatio is how close to empty, or to full, the average occupancy has to be for a report to call the test
+// generation-bound or transport-bound
+const genQueueBoundRatio = 0.1
+
+// GenQueueStats is the occupancy of the queue between the generator and the txer, sized with --gen-queue-depth, over
+// one progress interval. A queue which is mostly empty means the txer is waiting on the generator, one which is mostly
+// full means the generator is waiting on the transport
+type GenQueueStats struct {
+	Depth   int     `json:"depth"`
+	Samples int     `json:"samples"`
+	Avg     float64 `json:"avg"`
+	Min     int     `json:"min"`
+}
+
+// genQueueSampler collects samples of the generator queue occupancy. It's only used by the progress reporter
+type genQueueSampler struct {
+	depth   int
+	samples int
+	total   int
+	min     int
+}
+
+func newGenQueueSampler(depth int) *genQueueSampler {
+	return &genQueueSampler{depth: depth, min: depth}
+}
+
+func (sampler *genQueueSampler) sample(occupancy int) {
+	sampler.samples++
+	sampler.total += occupancy
+	if occupancy < sampler.min {
+		sampler.min = occupancy
+	}
+}
+
+// stats returns the occupancy since the last call and starts over. It returns nil if there were no samples
+func (sampler *genQueueSampler) stats() *GenQueueStats {
+	if sampler.samples == 0 {
+		return nil
+	}
+	stats := &GenQueueStats{
+		Depth:   sampler.depth,
+		Samples: sampler.samples,
+		Avg:     float64(sampler.total) / float64(sampler.samples),
+		Min:     sampler.min,
+	}
+	sampler.samples, sampler.total, sampler.min = 0, 0, sampler.depth
+	return stats
+}
+
+// bound names what the occupancy says is limiting the test, or returns an empty string if the queue was neither
+// mostly empty nor mostly full
+func (stats *GenQueueStats) bound() string {
+	ratio := stats.Avg / float64(stats.Depth)
+	if ratio <= genQueueBoundRatio {
+		return "generation-bound"
+	}
+	if ratio >= 1-genQueueBoundRatio {
+		return "transport-bound"
+	}
+	return ""
+}
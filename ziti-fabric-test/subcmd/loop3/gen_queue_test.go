@@ -0,0 +1,45 @@
+package loop3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GenQueueSampler(t *testing.T) {
+	req := require.New(t)
+
+	sampler := newGenQueueSampler(8)
+	req.Nil(sampler.stats(), "no samples, no stats")
+
+	sampler.sample(8)
+	sampler.sample(2)
+	sampler.sample(5)
+	stats := sampler.stats()
+	req.Equal(&GenQueueStats{Depth: 8, Samples: 3, Avg: 5, Min: 2}, stats)
+	req.Equal("", stats.bound())
+
+	// each report only covers the samples since the last
+	sampler.sample(8)
+	req.Equal(&GenQueueStats{Depth: 8, Samples: 1, Avg: 8, Min: 8}, sampler.stats())
+}
+
+func Test_GenQueueBound(t *testing.T) {
+	req := require.New(t)
+
+	req.Equal("generation-bound", (&GenQueueStats{Depth: 10, Avg: 0.5}).bound())
+	req.Equal("transport-bound", (&GenQueueStats{Depth: 10, Avg: 9.5}).bound())
+	req.Equal("", (&GenQueueStats{Depth: 10, Avg: 5}).bound())
+}
+
+func Test_GenQueueDepth(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("gen-queue-depth")
+	result, _ := runLoopbackWithOptions(t, test, &protocolOptions{failFast: true, genQueueDepth: 16})
+	req.True(result.Success, result.Message)
+	req.Equal(test.TxRequests, result.TxCount)
+
+	_, err := newProtocol(nil, &protocolOptions{genQueueDepth: -1})
+	req.Error(err)
+}
@@ -17,67 +17,126 @@
 package loop3
 
 import (
-	"crypto/sha512"
 	"github.com/michaelquigley/pfxlog"
 	"github.com/openziti/foundation/v2/info"
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"hash/fnv"
+	"io"
 	"math/rand"
+	"sync/atomic"
+	"time"
 )
 
+// BlockSource produces the blocks a test sends, one per call to Next, which returns io.EOF once there are no more. The
+// blocks must be of the test's tx block type, as that's what the peer expects to receive. The built-in generators are
+// used unless a source is given in the protocol options.
+type BlockSource interface {
+	Next() (Block, error)
+}
+
+// pacedSource is a BlockSource which also decides when its blocks go out. gap returns how long after the previous
+// block the one last returned by Next should be handed to the txer
+type pacedSource interface {
+	BlockSource
+	gap() time.Duration
+}
+
+// generatorStats tracks how much a block source has produced and how long it spent producing it, excluding time spent
+// waiting for the txer to take blocks
+type generatorStats struct {
+	generated     int64
+	generateNanos int64
+}
+
+func (stats *generatorStats) record(start time.Time) {
+	atomic.AddInt64(&stats.generateNanos, int64(time.Since(start)))
+	atomic.AddInt64(&stats.generated, 1)
+}
+
+func (stats *generatorStats) snapshot() (int64, time.Duration) {
+	return atomic.LoadInt64(&stats.generated), time.Duration(atomic.LoadInt64(&stats.generateNanos))
+}
+
 type randomHashedBlockGenerator struct {
+	next        int
 	count       int
-	minSize     int
-	maxSize     int
+	sizes       payloadSizes
 	latencyFreq int
-	blocks      chan Block
+	oneWayDelay bool
+	probe       bool
+	rand        *rand.Rand
 	pool        [][]byte
 }
 
-func newRandomHashedBlockGenerator(count, minSize, maxSize, latencyFreq int) *randomHashedBlockGenerator {
+// newRandomHashedBlockGenerator returns a generator whose block sizes, from sizes, and payloads are drawn from seed, or
+// are different every run if seed is 0
+func newRandomHashedBlockGenerator(count int, sizes payloadSizes, latencyFreq int, oneWayDelay bool, seed int64) *randomHashedBlockGenerator {
+	r := newRand(seed)
 	g := &randomHashedBlockGenerator{
 		count:       count,
-		minSize:     minSize,
-		maxSize:     maxSize,
+		sizes:       sizes,
 		latencyFreq: latencyFreq,
-		blocks:      make(chan Block),
-		pool:        newPool(),
+		oneWayDelay: oneWayDelay,
+		rand:        r,
+		pool:        newPool(r),
 	}
 	return g
 }
 
-func (g *randomHashedBlockGenerator) run() {
-	log := pfxlog.Logger()
-	log.Debug("started")
-	defer log.Debug("complete")
-
-	for i := 0; i < g.count; i++ {
-		size := g.minSize
-		distance := g.maxSize - g.minSize
-		if distance > 0 {
-			size += rand.Intn(distance)
-		}
-		data := make([]byte, size)
-		for idx := 0; idx < size; {
-			bucket := g.pool[rand.Intn(len(g.pool))]
-			for i := 0; i < len(bucket) && idx < size; i++ {
-				data[idx] = bucket[i]
-				idx++
-			}
-		}
-		hash := sha512.Sum512(data)
-		blockType := BlockTypePlain
-		if g.latencyFreq > 0 && i%g.latencyFreq == 0 {
-			blockType = BlockTypeLatencyRequest
-		}
-		g.blocks <- &RandHashedBlock{
-			Type:     blockType,
-			Sequence: uint32(i),
-			Data:     data,
-			Hash:     hash[:],
+// newRand returns a source seeded with seed, or from the clock if seed is 0
+func newRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// Next returns the block with the next sequence, up to count
+func (g *randomHashedBlockGenerator) Next() (Block, error) {
+	if g.next >= g.count {
+		return nil, io.EOF
+	}
+	sequence := g.next
+	g.next++
+	return g.block(sequence, g.nextSize()), nil
+}
+
+// nextSize returns the payload size of the next block. Probes are all the smallest size, so their round trips compare
+func (g *randomHashedBlockGenerator) nextSize() int {
+	if g.probe {
+		return g.sizes.min
+	}
+	return g.sizes.next(g.rand)
+}
+
+// block returns the block for sequence, with a payload of size bytes
+func (g *randomHashedBlockGenerator) block(sequence, size int) Block {
+	data := make([]byte, size)
+	for idx := 0; idx < size; {
+		bucket := g.pool[g.rand.Intn(len(g.pool))]
+		for i := 0; i < len(bucket) && idx < size; i++ {
+			data[idx] = bucket[i]
+			idx++
 		}
 	}
+	blockType := BlockTypePlain
+	if g.latencyFreq > 0 && sequence%g.latencyFreq == 0 {
+		blockType = BlockTypeLatencyRequest
+	} else if g.oneWayDelay {
+		blockType = BlockTypeOneWay
+	}
+	block := &RandHashedBlock{
+		Type:     blockType,
+		Sequence: uint32(sequence),
+		Data:     data,
+	}
+	if !g.probe {
+		block.Hash = blockHash(data)
+	}
+	return block
 }
 
-func newPool() [][]byte {
+func newPool(r *rand.Rand) [][]byte {
 	log := pfxlog.Logger()
 	start := info.NowInMilliseconds()
 	log.Debug("building")
@@ -91,47 +150,59 @@ func newPool() [][]byte {
 		length := 4096
 		pool[i] = make([]byte, 0)
 		for j := 0; j < length; j++ {
-			pool[i] = append(pool[i], byte(rand.Intn(255)))
+			pool[i] = append(pool[i], byte(r.Intn(255)))
 		}
 	}
 	return pool
 }
 
-func newSeqGenerator(count, minSize, maxSize int) *seqGenerator {
+// newSeqGenerator returns a generator whose block sizes, from sizes, are drawn from seed, or are different every run if
+// seed is 0
+func newSeqGenerator(count int, sizes payloadSizes, seed int64) *seqGenerator {
 	g := &seqGenerator{
-		count:   count,
-		minSize: minSize,
-		maxSize: maxSize,
-		blocks:  make(chan Block),
+		count: count,
+		sizes: sizes,
+		rand:  newRand(seed),
 	}
 	return g
 }
 
-func (g *seqGenerator) run() {
-	log := pfxlog.Logger()
-	log.Debug("started")
-	defer log.Debug("complete")
-
-	var seq uint64
+// Next returns a block carrying the next bytes of the sequence, up to count blocks
+func (g *seqGenerator) Next() (Block, error) {
+	if g.generated >= g.count {
+		return nil, io.EOF
+	}
+	g.generated++
 
-	for i := 0; i < g.count; i++ {
-		size := g.minSize
-		distance := g.maxSize - g.minSize
-		if distance > 0 {
-			size += rand.Intn(distance)
-		}
-		data := make([]byte, size)
-		for idx := 0; idx < size; idx++ {
-			data[idx] = byte(seq)
-			seq++
-		}
-		g.blocks <- SeqBlock(data)
+	size := g.nextSize()
+	data := make([]byte, size)
+	for idx := 0; idx < size; idx++ {
+		data[idx] = byte(g.seq)
+		g.seq++
 	}
+	return SeqBlock(data), nil
+}
+
+// nextSize returns the payload size of the next block
+func (g *seqGenerator) nextSize() int {
+	return g.sizes.next(g.rand)
 }
 
 type seqGenerator struct {
-	count   int
-	minSize int
-	maxSize int
-	blocks  chan Block
+	count     int
+	generated int
+	seq       uint64
+	sizes     payloadSizes
+	rand      *rand.Rand
+}
+
+// seedFromName seeds test from a hash of its name, unless it already has a seed
+func seedFromName(test *loop3_pb.Test) {
+	if test.Seed != 0 {
+		return
+	}
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(test.Name))
+	// 0 means unseeded, so keep the seed clear of it
+	test.Seed = int64(hash.Sum64() | 1)
 }
@@ -0,0 +1,155 @@
+package loop3
+
+import (
+	"crypto/sha512"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedSizeSource sends count random hashed blocks, all of the same size
+type fixedSizeSource struct {
+	count int
+	size  int
+	next  int
+}
+
+func (source *fixedSizeSource) Next() (Block, error) {
+	if source.next >= source.count {
+		return nil, io.EOF
+	}
+	data := make([]byte, source.size)
+	data[0] = byte(source.next)
+	hash := sha512.Sum512(data)
+	block := &RandHashedBlock{Type: BlockTypePlain, Sequence: uint32(source.next), Data: data, Hash: hash[:]}
+	source.next++
+	return block, nil
+}
+
+type failingSource struct{}
+
+func (failingSource) Next() (Block, error) {
+	return nil, errors.New("no more captured sizes")
+}
+
+func Test_GeneratorsEndWithEOF(t *testing.T) {
+	req := require.New(t)
+
+	for _, source := range []BlockSource{newRandomHashedBlockGenerator(2, uniformSizes(16, 16), 0, false, 0), newSeqGenerator(2, uniformSizes(16, 16), 0)} {
+		for i := 0; i < 2; i++ {
+			block, err := source.Next()
+			req.NoError(err)
+			req.NotNil(block)
+		}
+		_, err := source.Next()
+		req.Equal(io.EOF, err)
+	}
+}
+
+// runLoopbackWithOptions runs test against an in-process listener like RunLoopback, but with the given dialer side
+// protocol options. It returns the dialer's result and the listener's
+func runLoopbackWithOptions(t *testing.T, test *loop3_pb.Test, options *protocolOptions) (*Result, *Result) {
+	req := require.New(t)
+
+	conn, peer := net.Pipe()
+	t.Cleanup(func() { _ = conn.Close() })
+	t.Cleanup(func() { _ = peer.Close() })
+	go (&listenerCmd{}).handle(peer, test.Name)
+
+	p, err := newProtocol(conn, options)
+	req.NoError(err)
+	req.NoError(p.txTest(loopbackPeerTest(test)))
+	_, err = p.exchangeMetadata(newMetadata(test.Name, ""))
+	req.NoError(err)
+
+	result, err := p.run(test)
+	req.NoError(err)
+	peerResult, err := p.rxResult(result)
+	req.NoError(err)
+	req.True(peerResult.Success, peerResult.Message)
+	return result, peerResult
+}
+
+func Test_RunWithCustomBlockSource(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("custom-source")
+	result, _ := runLoopbackWithOptions(t, test, &protocolOptions{failFast: true, blockSource: &fixedSizeSource{count: int(test.TxRequests), size: 100}})
+	req.Equal(test.TxRequests, result.TxCount)
+	req.Equal(int64(test.TxRequests)*int64((&RandHashedBlock{Hash: make([]byte, sha512.Size), Data: make([]byte, 100)}).Size()), result.TxBytes)
+}
+
+func Test_BlockSourceErrorFailsTest(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("failing-source")
+	test.RxRequests = 0
+	p, err := newProtocol(&testPeer{}, &protocolOptions{failFast: true, blockSource: failingSource{}})
+	req.NoError(err)
+
+	_, err = p.run(test)
+	var protocolErr *ProtocolError
+	req.True(errors.As(err, &protocolErr), "expected a protocol error, got %v", err)
+	req.Equal(PhaseTx, protocolErr.Phase)
+}
+
+func Test_PayloadSize(t *testing.T) {
+	req := require.New(t)
+
+	workload := &Workload{
+		Name:     "fixed",
+		Dialer:   Test{TxRequests: 20, PayloadSize: 512},
+		Listener: Test{TxRequests: 20, PayloadMinBytes: 64, PayloadMaxBytes: 4096},
+	}
+	local, remote := workload.GetTests()
+	req.Equal(int32(512), local.PayloadMinBytes)
+	req.Equal(int32(512), local.PayloadMaxBytes)
+	req.Equal(int32(512), remote.RxSeqBlockSize)
+	req.Equal(int32(64), remote.PayloadMinBytes)
+
+	scenario := &Scenario{Workloads: []*Workload{workload}}
+	scenario.setPayloadSize(256)
+	local, remote = workload.GetTests()
+	req.Equal(int32(256), remote.PayloadMinBytes)
+	req.Equal(int32(256), remote.PayloadMaxBytes)
+
+	generator := newRandomHashedBlockGenerator(int(local.TxRequests), payloadSizesOf(local), 0, false, 1)
+	for block, err := generator.Next(); err != io.EOF; block, err = generator.Next() {
+		req.NoError(err)
+		req.Len(block.(*RandHashedBlock).Data, 256)
+	}
+}
+
+func Test_WorkloadAnnotations(t *testing.T) {
+	req := require.New(t)
+
+	workload := &Workload{
+		Name:        "annotated",
+		Dialer:      Test{TxRequests: 20},
+		Listener:    Test{TxRequests: 20},
+		Annotations: map[string]string{"ticket": "ZITI-1", "env": "staging"},
+	}
+	scenario := &Scenario{Workloads: []*Workload{workload}}
+	scenario.annotate(map[string]string{"env": "prod", "sha": "abc123"})
+
+	expected := map[string]string{"ticket": "ZITI-1", "env": "prod", "sha": "abc123"}
+	local, remote := workload.GetTests()
+	req.Equal(expected, local.Annotations)
+	req.Equal(expected, remote.Annotations)
+
+	test := newLoopbackTest("annotated")
+	test.Annotations = expected
+	result, err := RunLoopback(test)
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+	req.Equal(expected, result.Annotations)
+
+	data, err := json.Marshal(result)
+	req.NoError(err)
+	req.Contains(string(data), `"annotations":{"env":"prod","sha":"abc123","ticket":"ZITI-1"}`)
+}
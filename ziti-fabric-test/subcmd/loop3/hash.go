@@ -0,0 +1,58 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// hashAlgorithm hashes block payloads. Random hashed blocks are hashed with blockHashAlgorithm by the generator and
+// the verifier, the others are only there for bench-hash to compare against
+type hashAlgorithm struct {
+	name string
+	sum  func(data []byte) []byte
+}
+
+var blockHashAlgorithm = hashAlgorithm{name: "SHA512", sum: sumSha512}
+
+var hashAlgorithms = []hashAlgorithm{
+	blockHashAlgorithm,
+	{name: "SHA256", sum: sumSha256},
+	{name: "CRC32", sum: sumCrc32},
+}
+
+// blockHash returns the hash sent with a random hashed block's payload
+func blockHash(data []byte) []byte {
+	return blockHashAlgorithm.sum(data)
+}
+
+func sumSha512(data []byte) []byte {
+	hash := sha512.Sum512(data)
+	return hash[:]
+}
+
+func sumSha256(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+func sumCrc32(data []byte) []byte {
+	return binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(data))
+}
@@ -0,0 +1,306 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michaelquigley/pfxlog"
+	"github.com/pkg/errors"
+)
+
+const (
+	influxResultMeasurement   = "loop3_result"
+	influxProgressMeasurement = "loop3_progress"
+
+	// influxTokenEnv holds the token sent with each write, when the database needs one
+	influxTokenEnv = "INFLUX_TOKEN"
+
+	influxWriteTimeout = 10 * time.Second
+)
+
+// influxWriter pushes points to an InfluxDB write endpoint in line protocol, for runs too short lived to be scraped.
+// Failed writes are logged and counted, so a database which is down doesn't fail the tests it was meant to record
+type influxWriter struct {
+	url    string
+	token  string
+	client *http.Client
+
+	lock     sync.Mutex
+	failures int
+	firstErr error
+}
+
+// newInfluxWriter returns a writer for writeUrl, which is used as is, so it carries the database or bucket to write
+// to, such as http://influx:8086/write?db=loop3 or http://influx:8086/api/v2/write?org=perf&bucket=loop3
+func newInfluxWriter(writeUrl, token string) (*influxWriter, error) {
+	parsed, err := url.Parse(writeUrl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid --influx url [%s]", writeUrl)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, errors.Errorf("invalid --influx url [%s], must be an http or https url", writeUrl)
+	}
+	return &influxWriter{
+		url:    writeUrl,
+		token:  token,
+		client: &http.Client{Timeout: influxWriteTimeout},
+	}, nil
+}
+
+// write sends points in a single request, logging and counting it if it fails
+func (w *influxWriter) write(points ...*influxPoint) {
+	if err := w.post(points); err != nil {
+		pfxlog.Logger().WithError(err).Error("unable to write results to influx")
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		if w.failures == 0 {
+			w.firstErr = err
+		}
+		w.failures++
+	}
+}
+
+func (w *influxWriter) post(points []*influxPoint) error {
+	body := &bytes.Buffer{}
+	for _, point := range points {
+		body.WriteString(point.line())
+		body.WriteByte('\n')
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Token "+w.token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.Errorf("influx answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// failure returns an error describing the writes which failed, or nil if they all succeeded
+func (w *influxWriter) failure() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.failures == 0 {
+		return nil
+	}
+	return errors.Wrapf(w.firstErr, "%d writes to influx failed, the first with", w.failures)
+}
+
+// startInflux sets up --influx, its token read from INFLUX_TOKEN so it stays out of the process list
+func (options *protocolOptions) startInflux() error {
+	if options.influxUrl == "" {
+		if options.influxRequired || options.influxProgress {
+			return errors.New("--influx-required and --influx-progress need --influx")
+		}
+		return nil
+	}
+	writer, err := newInfluxWriter(options.influxUrl, os.Getenv(influxTokenEnv))
+	if err != nil {
+		return err
+	}
+	options.influx = writer
+	return nil
+}
+
+// influxFailure returns the failed writes to report with --influx-required, nil otherwise
+func (options *protocolOptions) influxFailure() error {
+	if options.influx == nil || !options.influxRequired {
+		return nil
+	}
+	return options.influx.failure()
+}
+
+// influxPoint is a line protocol point. Tags with empty values are left out, as line protocol can't express them
+type influxPoint struct {
+	measurement string
+	tags        map[string]string
+	fields      map[string]interface{}
+	time        time.Time
+}
+
+// line returns the point in line protocol, its tags and fields sorted so the same point always gives the same line
+func (point *influxPoint) line() string {
+	buf := &strings.Builder{}
+	buf.WriteString(influxEscaper.Replace(point.measurement))
+
+	for _, key := range sortedKeys(point.tags) {
+		if value := point.tags[key]; key != "" && value != "" {
+			buf.WriteString(",")
+			buf.WriteString(influxTagEscaper.Replace(key))
+			buf.WriteString("=")
+			buf.WriteString(influxTagEscaper.Replace(value))
+		}
+	}
+
+	fieldKeys := make([]string, 0, len(point.fields))
+	for key := range point.fields {
+		fieldKeys = append(fieldKeys, key)
+	}
+	sort.Strings(fieldKeys)
+	for i, key := range fieldKeys {
+		if i == 0 {
+			buf.WriteString(" ")
+		} else {
+			buf.WriteString(",")
+		}
+		buf.WriteString(influxTagEscaper.Replace(key))
+		buf.WriteString("=")
+		buf.WriteString(influxFieldValue(point.fields[key]))
+	}
+
+	buf.WriteString(" ")
+	buf.WriteString(strconv.FormatInt(point.time.UnixNano(), 10))
+	return buf.String()
+}
+
+var (
+	influxEscaper       = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	influxTagEscaper    = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	influxStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+func influxFieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case int32:
+		return strconv.FormatInt(int64(v), 10) + "i"
+	case int64:
+		return strconv.FormatInt(v, 10) + "i"
+	case time.Duration:
+		return strconv.FormatInt(int64(v), 10) + "i"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return `"` + influxStringEscaper.Replace(v) + `"`
+	default:
+		return `"` + influxStringEscaper.Replace(fmt.Sprint(v)) + `"`
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// influxTags returns the tags of the test's points: its annotations, then the test name, connection index and repeat
+// run, which win over annotations of the same name
+func (p *protocol) influxTags() map[string]string {
+	tags := p.annotations()
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	if p.test != nil {
+		tags["test"] = p.test.Name
+	}
+	tags["conn"] = strconv.Itoa(p.connIndex)
+	if p.repeat > 0 {
+		tags["run"] = strconv.Itoa(p.repeat)
+	}
+	return tags
+}
+
+// resultPoint returns the point for a finished test. peer may be nil
+func (p *protocol) resultPoint(at time.Time, result, peer *Result) *influxPoint {
+	fields := map[string]interface{}{
+		"success":     result.Success,
+		"tx_count":    result.TxCount,
+		"rx_count":    result.RxCount,
+		"tx_bytes":    result.TxBytes,
+		"rx_bytes":    result.RxBytes,
+		"duration_ns": result.Duration,
+		"tx_lost":     result.TxLost,
+		"rx_lost":     result.RxLost,
+		"errors":      result.Errors,
+	}
+	if seconds := result.Duration.Seconds(); seconds > 0 {
+		fields["tx_bytes_per_sec"] = float64(result.TxBytes) / seconds
+		fields["rx_bytes_per_sec"] = float64(result.RxBytes) / seconds
+	}
+	addInfluxLatency(fields, result.Latency)
+	if result.Message != "" {
+		fields["message"] = result.Message
+	}
+	if result.Setup > 0 {
+		fields["setup_ns"] = result.Setup
+	}
+	if result.Corrupted > 0 {
+		fields["corrupted"] = result.Corrupted
+		fields["corruption_rate"] = result.CorruptionRate
+	}
+	if peer != nil {
+		fields["peer_success"] = peer.Success
+	}
+	return &influxPoint{measurement: influxResultMeasurement, tags: p.influxTags(), fields: fields, time: at}
+}
+
+// progressPoint returns the point for a progress report
+func (p *protocol) progressPoint(event *progressEvent) *influxPoint {
+	fields := map[string]interface{}{
+		"elapsed_ns":       event.Elapsed,
+		"tx_count":         event.TxCount,
+		"rx_count":         event.RxCount,
+		"tx_bytes":         event.TxBytes,
+		"rx_bytes":         event.RxBytes,
+		"tx_bytes_per_sec": event.TxBytesPerSec,
+		"rx_bytes_per_sec": event.RxBytesPerSec,
+	}
+	addInfluxLatency(fields, event.Latency)
+	return &influxPoint{measurement: influxProgressMeasurement, tags: p.influxTags(), fields: fields, time: event.Time}
+}
+
+// addInfluxLatency adds the latency percentiles, once there are any, so a test without latency requests doesn't
+// record a latency of 0
+func addInfluxLatency(fields map[string]interface{}, latency LatencyStats) {
+	if latency.Count == 0 {
+		return
+	}
+	fields["latency_count"] = latency.Count
+	fields["latency_min_ns"] = latency.Min
+	fields["latency_avg_ns"] = latency.Avg
+	fields["latency_max_ns"] = latency.Max
+	fields["latency_p50_ns"] = latency.P50
+	fields["latency_p90_ns"] = latency.P90
+	fields["latency_p99_ns"] = latency.P99
+}
@@ -0,0 +1,113 @@
+package loop3
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InfluxPointLine(t *testing.T) {
+	req := require.New(t)
+
+	point := &influxPoint{
+		measurement: "loop3 result",
+		tags:        map[string]string{"test": "bulk, west", "conn": "0", "empty": "", "a=b": "c"},
+		fields: map[string]interface{}{
+			"success":  true,
+			"tx_count": int32(10),
+			"rate":     1.5,
+			"latency":  2 * time.Millisecond,
+			"message":  `block "7" didn't verify`,
+		},
+		time: time.Unix(1, 5),
+	}
+	req.Equal(`loop3\ result,a\=b=c,conn=0,test=bulk\,\ west latency=2000000i,message="block \"7\" didn't verify",rate=1.5,success=true,tx_count=10i 1000000005`,
+		point.line())
+}
+
+func Test_InfluxResultPoint(t *testing.T) {
+	req := require.New(t)
+
+	p := &protocol{test: &loop3_pb.Test{Name: "throughput", Annotations: map[string]string{"sha": "abc123", "test": "ignored"}}, connIndex: 2, repeat: 3}
+	result := &Result{Success: true, TxCount: 5, TxBytes: 2000, RxBytes: 1000, Duration: 2 * time.Second, RxLost: 1,
+		Latency: LatencyStats{Count: 4, P99: time.Millisecond}}
+	line := p.resultPoint(time.Unix(0, 0), result, &Result{Success: false}).line()
+
+	req.True(strings.HasPrefix(line, "loop3_result,conn=2,run=3,sha=abc123,test=throughput "), line)
+	for _, field := range []string{"success=true", "tx_count=5i", "tx_bytes_per_sec=1000", "rx_bytes_per_sec=500", "rx_lost=1i", "latency_p99_ns=1000000i", "peer_success=false"} {
+		req.Contains(line, field)
+	}
+	req.NotContains(line, "message=")
+
+	line = p.resultPoint(time.Unix(0, 0), &Result{}, nil).line()
+	req.NotContains(line, "latency_", "no latency requests means no latency fields")
+	req.NotContains(line, "peer_success")
+}
+
+func Test_InfluxWriter(t *testing.T) {
+	req := require.New(t)
+
+	var lock sync.Mutex
+	var bodies, auths, dbs []string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		bodies = append(bodies, string(body))
+		auths = append(auths, r.Header.Get("Authorization"))
+		dbs = append(dbs, r.URL.Query().Get("db"))
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("partial write: field type conflict"))
+	}))
+	defer server.Close()
+
+	writer, err := newInfluxWriter(server.URL+"/write?db=loop3", "secret")
+	req.NoError(err)
+
+	point := &influxPoint{measurement: "m", fields: map[string]interface{}{"v": int64(1)}, time: time.Unix(0, 1)}
+	writer.write(point, point)
+	req.Equal([]string{"m v=1i 1\nm v=1i 1\n"}, bodies)
+	req.Equal([]string{"Token secret"}, auths)
+	req.Equal([]string{"loop3"}, dbs)
+	req.NoError(writer.failure())
+
+	lock.Lock()
+	status = http.StatusBadRequest
+	lock.Unlock()
+	writer.write(point)
+	writer.write(point)
+	err = writer.failure()
+	req.Error(err)
+	req.Contains(err.Error(), "2 writes to influx failed")
+	req.Contains(err.Error(), "field type conflict")
+}
+
+func Test_StartInflux(t *testing.T) {
+	req := require.New(t)
+
+	options := &protocolOptions{}
+	req.NoError(options.startInflux())
+	req.Nil(options.influx)
+
+	options = &protocolOptions{influxRequired: true}
+	req.Error(options.startInflux(), "--influx-required needs --influx")
+
+	options = &protocolOptions{influxUrl: "influx:8086"}
+	req.Error(options.startInflux())
+
+	// an unreachable database is only a failure with --influx-required
+	options = &protocolOptions{influxUrl: "http://127.0.0.1:1/write?db=loop3"}
+	req.NoError(options.startInflux())
+	options.influx.write(&influxPoint{measurement: "m", fields: map[string]interface{}{"v": true}})
+	req.NoError(options.influxFailure())
+	options.influxRequired = true
+	req.Error(options.influxFailure())
+}
@@ -0,0 +1,101 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultLatencyOutlierFactor is how many standard deviations above the mean a round trip has to be to count as
+	// an outlier, unless configured otherwise
+	defaultLatencyOutlierFactor = 3.0
+
+	// latencyWorstSamples is how many of the slowest outliers are listed
+	latencyWorstSamples = 5
+)
+
+// latencySample is the round trip of one answered latency request. The sequence is that of the latency response
+// block, as logged by the peer when it sent it, and sentAt is when the request went out
+type latencySample struct {
+	sequence uint32
+	sentAt   time.Time
+	rtt      time.Duration
+}
+
+// latencyOutliers are the round trips more than factor standard deviations above the mean
+type latencyOutliers struct {
+	factor    float64
+	threshold time.Duration
+	samples   int
+	count     int
+	worst     []latencySample
+}
+
+// outliers returns the round trips more than factor standard deviations above the mean of those recorded so far, with
+// up to worstN of the slowest of them. Nothing is an outlier when factor isn't positive
+func (stats *probeStats) outliers(factor float64, worstN int) latencyOutliers {
+	stats.lock.Lock()
+	samples := append([]latencySample(nil), stats.samples...)
+	stats.lock.Unlock()
+
+	result := latencyOutliers{factor: factor, samples: len(samples)}
+	if factor <= 0 || len(samples) < 2 {
+		return result
+	}
+
+	var mean float64
+	for _, sample := range samples {
+		mean += float64(sample.rtt)
+	}
+	mean /= float64(len(samples))
+
+	var variance float64
+	for _, sample := range samples {
+		variance += math.Pow(float64(sample.rtt)-mean, 2)
+	}
+	variance /= float64(len(samples))
+
+	result.threshold = time.Duration(mean + factor*math.Sqrt(variance))
+	var outliers []latencySample
+	for _, sample := range samples {
+		if sample.rtt > result.threshold {
+			outliers = append(outliers, sample)
+		}
+	}
+	result.count = len(outliers)
+
+	sort.SliceStable(outliers, func(i, j int) bool { return outliers[i].rtt > outliers[j].rtt })
+	if len(outliers) > worstN {
+		outliers = outliers[:worstN]
+	}
+	result.worst = outliers
+	return result
+}
+
+func (outliers latencyOutliers) String() string {
+	var worst []string
+	for _, sample := range outliers.worst {
+		worst = append(worst, fmt.Sprintf("#%d sent %s took %v", sample.sequence, sample.sentAt.Format("15:04:05.000"), sample.rtt))
+	}
+	return fmt.Sprintf("%d of %d latency samples over %v (mean + %.1f stddev), worst: %s",
+		outliers.count, outliers.samples, outliers.threshold, outliers.factor, strings.Join(worst, ", "))
+}
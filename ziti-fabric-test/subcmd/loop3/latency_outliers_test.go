@@ -0,0 +1,52 @@
+package loop3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LatencyOutliers(t *testing.T) {
+	req := require.New(t)
+
+	start := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	stats := &probeStats{}
+	for i := 0; i < 100; i++ {
+		rtt := time.Millisecond
+		switch i {
+		case 40:
+			rtt = 800 * time.Millisecond
+		case 70:
+			rtt = 900 * time.Millisecond
+		}
+		stats.record(uint32(i), start.Add(time.Duration(i)*time.Second), rtt)
+	}
+
+	outliers := stats.outliers(3, 1)
+	req.Equal(100, outliers.samples)
+	req.Equal(2, outliers.count)
+	req.Len(outliers.worst, 1)
+	req.Equal(uint32(70), outliers.worst[0].sequence)
+	req.Equal(start.Add(70*time.Second), outliers.worst[0].sentAt)
+	req.Contains(outliers.String(), "2 of 100 latency samples over")
+	req.Contains(outliers.String(), "#70 sent 12:01:10.000 took 900ms")
+
+	outliers = stats.outliers(3, latencyWorstSamples)
+	req.Len(outliers.worst, 2)
+	req.Equal(uint32(40), outliers.worst[1].sequence)
+
+	req.Zero(stats.outliers(0, latencyWorstSamples).count, "a factor of 0 disables outliers")
+	req.Zero(stats.outliers(50, latencyWorstSamples).count)
+}
+
+func Test_LatencyOutliersSteadyRtts(t *testing.T) {
+	req := require.New(t)
+
+	stats := &probeStats{}
+	for i := 0; i < 10; i++ {
+		stats.record(uint32(i), time.Now(), 5*time.Millisecond)
+	}
+	req.Zero(stats.outliers(defaultLatencyOutlierFactor, latencyWorstSamples).count)
+	req.Zero((&probeStats{}).outliers(defaultLatencyOutlierFactor, latencyWorstSamples).count)
+}
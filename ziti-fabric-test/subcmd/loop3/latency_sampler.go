@@ -0,0 +1,52 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import "time"
+
+// latencySampler decides which blocks the txer turns into latency requests to hold a rate of probes per second,
+// however many blocks are going out. Probes ride on blocks which would be sent anyway, so the rate can't exceed the
+// block rate.
+type latencySampler struct {
+	interval time.Duration
+	next     time.Time
+}
+
+// newLatencySampler returns a sampler which is due for its first probe at start, or nil if ratePerSec isn't positive
+func newLatencySampler(ratePerSec float64, start time.Time) *latencySampler {
+	if ratePerSec <= 0 {
+		return nil
+	}
+	return &latencySampler{
+		interval: time.Duration(float64(time.Second) / ratePerSec),
+		next:     start,
+	}
+}
+
+// due returns true if the block sent at now should carry a probe, in which case the next probe is scheduled. After
+// a stall, such as a pause or burst off window, the schedule restarts from now rather than catching up with a run of
+// back to back probes.
+func (s *latencySampler) due(now time.Time) bool {
+	if now.Before(s.next) {
+		return false
+	}
+	s.next = s.next.Add(s.interval)
+	if !s.next.After(now) {
+		s.next = now.Add(s.interval)
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package loop3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LatencySampler(t *testing.T) {
+	req := require.New(t)
+
+	req.Nil(newLatencySampler(0, time.Now()))
+
+	start := time.Now()
+	s := newLatencySampler(10, start)
+
+	req.True(s.due(start))
+	req.False(s.due(start.Add(50 * time.Millisecond)))
+	req.True(s.due(start.Add(100 * time.Millisecond)))
+	req.False(s.due(start.Add(150 * time.Millisecond)))
+
+	// after a stall there's one probe, then the schedule picks up from there
+	stalled := start.Add(5 * time.Second)
+	req.True(s.due(stalled))
+	req.False(s.due(stalled.Add(10 * time.Millisecond)))
+	req.True(s.due(stalled.Add(100 * time.Millisecond)))
+}
+
+func Test_LatencyRatePerSecLoopback(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("latency-rate")
+	test.TxRequests = 20
+	test.TxPacing = "10ms"
+	test.LatencyRatePerSec = 20
+
+	result, err := RunLoopback(test)
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+}
@@ -0,0 +1,106 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/michaelquigley/pfxlog"
+	"github.com/openziti/identity"
+	"github.com/openziti/transport/v2"
+	"github.com/pkg/errors"
+)
+
+// parseListenAddresses parses the --listen addresses. A plain host:port is a TCP address, anything else has to be a
+// transport address such as tls:10.0.0.5:8171. Every address is checked, so all the bad ones are reported at once
+func parseListenAddresses(values []string) ([]transport.Address, error) {
+	var addresses []transport.Address
+	var problems []string
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if strings.HasPrefix(value, "edge") {
+			problems = append(problems, fmt.Sprintf("[%s]: edge services can only be bound with --bind", value))
+			continue
+		}
+		if host, _, err := net.SplitHostPort(value); err == nil {
+			if host == "" {
+				problems = append(problems, fmt.Sprintf("[%s]: no host given, use the address of the interface to listen on", value))
+				continue
+			}
+			value = "tcp:" + value
+		}
+		address, err := transport.ParseAddress(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("[%s]: %v", value, err))
+			continue
+		}
+		addresses = append(addresses, address)
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.Errorf("invalid --listen address(es): %s", strings.Join(problems, ", "))
+	}
+	if len(addresses) == 0 {
+		return nil, errors.New("no --listen addresses given")
+	}
+	return addresses, nil
+}
+
+// listeners closes all the listeners started for --listen
+type listeners []io.Closer
+
+func (l listeners) Close() error {
+	var first error
+	for _, listener := range l {
+		if err := listener.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// listenAll binds each of addresses. A failed bind doesn't stop the others, the listener runs as long as at least one
+// address is listening, and a summary of which are and which failed is logged
+func (cmd *listenerCmd) listenAll(addresses []transport.Address, id *identity.TokenId) (io.Closer, error) {
+	var started listeners
+	var listening, failed []string
+	for _, address := range addresses {
+		listener, err := cmd.listen(address, id)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%v)", address, err))
+			continue
+		}
+		started = append(started, listener)
+		listening = append(listening, address.String())
+	}
+
+	log := pfxlog.Logger()
+	if len(failed) > 0 {
+		log.Errorf("unable to listen on %d of %d addresses: %s", len(failed), len(addresses), strings.Join(failed, ", "))
+	}
+	if len(started) == 0 {
+		return nil, errors.Errorf("unable to listen on any of the %d addresses", len(addresses))
+	}
+	log.Infof("listening on %d of %d addresses: %s", len(listening), len(addresses), strings.Join(listening, ", "))
+	return started, nil
+}
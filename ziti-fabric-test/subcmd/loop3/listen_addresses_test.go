@@ -0,0 +1,68 @@
+package loop3
+
+import (
+	"net"
+	"testing"
+
+	"github.com/openziti/identity"
+	"github.com/openziti/transport/v2"
+	"github.com/openziti/transport/v2/tcp"
+	"github.com/openziti/transport/v2/tls"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	// the commands rely on main registering these
+	transport.AddAddressParser(tcp.AddressParser{})
+	transport.AddAddressParser(tls.AddressParser{})
+}
+
+func Test_ParseListenAddresses(t *testing.T) {
+	req := require.New(t)
+
+	addresses, err := parseListenAddresses([]string{"10.0.0.5:8171", " fabric.example.com:8171", "tls:192.168.1.2:8172", ""})
+	req.NoError(err)
+	req.Len(addresses, 3)
+	req.Equal("tcp:10.0.0.5:8171", addresses[0].String())
+	req.Equal("tcp", addresses[1].Type())
+	req.Equal("tls:192.168.1.2:8172", addresses[2].String())
+
+	_, err = parseListenAddresses([]string{":8171", "edge:loop", "nonsense", "10.0.0.5:8171"})
+	req.ErrorContains(err, "[:8171]: no host given")
+	req.ErrorContains(err, "[edge:loop]: edge services can only be bound with --bind")
+	req.ErrorContains(err, "[nonsense]")
+
+	_, err = parseListenAddresses(nil)
+	req.ErrorContains(err, "no --listen addresses given")
+}
+
+func Test_ListenAllSkipsFailedBinds(t *testing.T) {
+	req := require.New(t)
+
+	// hold a port so binding it fails
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	req.NoError(err)
+	defer func() { _ = taken.Close() }()
+
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	req.NoError(err)
+	freeAddr := free.Addr().String()
+	req.NoError(free.Close())
+
+	addresses, err := parseListenAddresses([]string{taken.Addr().String(), freeAddr})
+	req.NoError(err)
+
+	cmd := &listenerCmd{connections: newConnectionTracker()}
+	id := &identity.TokenId{Token: "test"}
+	listener, err := cmd.listenAll(addresses, id)
+	req.NoError(err)
+	req.Len(listener, 1)
+
+	conn, err := net.Dial("tcp", freeAddr)
+	req.NoError(err)
+	_ = conn.Close()
+	req.NoError(listener.Close())
+
+	_, err = cmd.listenAll([]transport.Address{addresses[0]}, id)
+	req.ErrorContains(err, "unable to listen on any of the 1 addresses")
+}
@@ -28,9 +28,14 @@ import (
 	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"io"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 )
 
 func init() {
@@ -42,9 +47,14 @@ type listenerCmd struct {
 	cmd             *cobra.Command
 	identity        string
 	bindAddress     string
+	listenAddresses []string
 	edgeConfigFile  string
 	healthCheckAddr string
+	label           string
+	drainTimeout    time.Duration
 	test            *loop3_pb.Test
+	connections     *connectionTracker
+	protocolOptions
 }
 
 func newListenerCmd() *listenerCmd {
@@ -52,7 +62,11 @@ func newListenerCmd() *listenerCmd {
 		cmd: &cobra.Command{
 			Use:   "listener",
 			Short: "Start loop3 listener",
-			Args:  cobra.MaximumNArgs(1),
+			Long: "Start a loop3 listener, which serves any number of dialers at once, running the other side of each test it's sent.\n\n" +
+				"The tests run over each connection are logged when it closes, along with their block and byte counts. " +
+				"On SIGINT or SIGTERM the listener stops accepting connections, closes idle ones and waits up to --drain-timeout " +
+				"for tests in flight to finish, then logs the totals for every connection.",
+			Args: cobra.MaximumNArgs(1),
 		},
 	}
 
@@ -61,14 +75,27 @@ func newListenerCmd() *listenerCmd {
 	flags := result.cmd.Flags()
 	flags.StringVarP(&result.identity, "identity", "i", "default", ".ziti/identities.yml name")
 	flags.StringVarP(&result.bindAddress, "bind", "b", "tcp:127.0.0.1:8171", "Listener bind address")
+	flags.StringSliceVar(&result.listenAddresses, "listen", nil, "Listen on each of these comma separated host:port or transport "+
+		"addresses instead of --bind, such as only the fabric interface of a multi-homed host. Addresses which fail to bind are "+
+		"reported and skipped, as long as one is listening")
 	flags.StringVarP(&result.edgeConfigFile, "config-file", "c", "", "Edge SDK config file")
+	result.addFlags(flags)
 	flags.StringVar(&result.healthCheckAddr, "health-check-addr", "", "Edge SDK config file")
+	flags.StringVar(&result.label, "label", "", "A label for this listener, sent to dialers to show in their logs")
+	flags.Int64Var(&result.maxRxBytesPerSec, "max-conn-rate", 0, "Cap the rate blocks are read from each connection, in bytes/s. Faster dialers are throttled rather than dropped. 0 for no limit")
+	flags.DurationVar(&result.drainTimeout, "drain-timeout", 30*time.Second, "How long to wait for tests in flight to finish on shutdown")
 
 	return result
 }
 
 func (cmd *listenerCmd) run(_ *cobra.Command, args []string) {
 	log := pfxlog.Logger()
+	cmd.startEvents()
+	cmd.startSummaryOnly()
+	if err := cmd.startInflux(); err != nil {
+		panic(err)
+	}
+	cmd.applyRuntime()
 
 	var err error
 	shutdownClean := false
@@ -110,30 +137,59 @@ func (cmd *listenerCmd) run(_ *cobra.Command, args []string) {
 		defer close(closer)
 	}
 
-	log.Infof("binding to address '%v'", cmd.bindAddress)
-	if strings.HasPrefix(cmd.bindAddress, "edge") {
-		cmd.listenEdge()
+	cmd.connections = newConnectionTracker()
+	cmd.connections.summaryLog = cmd.summaryLog
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	var listener io.Closer
+	if len(cmd.listenAddresses) > 0 {
+		if cmd.cmd.Flags().Changed("bind") {
+			panic(errors.New("only one of --bind and --listen may be given"))
+		}
+		addresses, err := parseListenAddresses(cmd.listenAddresses)
+		if err != nil {
+			panic(err)
+		}
+		log.Infof("binding to addresses %v", addresses)
+		if listener, err = cmd.listenAll(addresses, cmd.identityFor(addresses...)); err != nil {
+			panic(err)
+		}
+	} else if strings.HasPrefix(cmd.bindAddress, "edge") {
+		log.Infof("binding to address '%v'", cmd.bindAddress)
+		listener = cmd.listenEdge()
 	} else {
+		log.Infof("binding to address '%v'", cmd.bindAddress)
 		bindAddress, err := transport.ParseAddress(cmd.bindAddress)
 		if err != nil {
 			panic(err)
 		}
 
-		id := &identity.TokenId{Token: "test"}
-		if bindAddress.Type() != "tcp" {
-			_, id, err = dotziti.LoadIdentity(cmd.identity)
-			if err != nil {
-				panic(err)
-			}
+		if listener, err = cmd.listen(bindAddress, cmd.identityFor(bindAddress)); err != nil {
+			panic(err)
 		}
+	}
+
+	sig := <-signals
+	log.Infof("received %v, shutting down", sig)
+	cmd.shutdown(listener)
+}
 
-		cmd.listen(bindAddress, id)
+// shutdown stops accepting connections and drains the ones open, then logs the totals across all of them
+func (cmd *listenerCmd) shutdown(listener io.Closer) {
+	log := pfxlog.Logger()
+
+	if err := listener.Close(); err != nil {
+		log.WithError(err).Error("failure closing listener")
+	}
+	if !cmd.connections.drain(cmd.drainTimeout) {
+		log.Warnf("tests still running after %v, exiting anyway", cmd.drainTimeout)
 	}
+	cmd.summaryLogger().Infof("totals: %s", cmd.connections.summary())
 }
 
-func (cmd *listenerCmd) listenEdge() {
+func (cmd *listenerCmd) listenEdge() io.Closer {
 	log := pfxlog.ContextLogger(cmd.bindAddress)
-	defer log.Error("exited")
 	log.Info("started")
 
 	var context ziti.Context
@@ -153,56 +209,113 @@ func (cmd *listenerCmd) listenEdge() {
 		panic(err)
 	}
 
-	for {
-		if conn, err := listener.Accept(); err != nil {
-			panic(err)
-		} else {
-			go cmd.handle(conn, cmd.bindAddress)
+	go func() {
+		for {
+			if conn, err := listener.Accept(); err != nil {
+				if listener.IsClosed() {
+					log.Info("stopped accepting connections")
+					return
+				}
+				panic(err)
+			} else {
+				go cmd.handle(conn, cmd.bindAddress)
+			}
 		}
-	}
+	}()
+	return listener
 }
 
-func (cmd *listenerCmd) listen(bind transport.Address, i *identity.TokenId) {
-	log := pfxlog.ContextLogger(bind.String())
-	defer log.Error("exited")
-	log.Info("started")
+// identityFor returns the identity to listen on addresses with. Plain TCP doesn't need one, anything else uses the
+// --identity from .ziti/identities.yml
+func (cmd *listenerCmd) identityFor(addresses ...transport.Address) *identity.TokenId {
+	for _, address := range addresses {
+		if address.Type() != "tcp" {
+			_, id, err := dotziti.LoadIdentity(cmd.identity)
+			if err != nil {
+				panic(err)
+			}
+			return id
+		}
+	}
+	return &identity.TokenId{Token: "test"}
+}
 
+func (cmd *listenerCmd) listen(bind transport.Address, i *identity.TokenId) (io.Closer, error) {
 	acceptF := func(peer transport.Conn) {
 		go cmd.handle(peer, peer.Detail().String())
 	}
 
-	go func() {
-		if _, err := bind.Listen("loop", i, acceptF, nil); err != nil {
-			panic(err)
-		}
-	}()
+	listener, err := bind.Listen("loop", i, acceptF, nil)
+	if err != nil {
+		return nil, err
+	}
+	pfxlog.ContextLogger(bind.String()).Info("started")
+	return listener, nil
 }
 
 func (cmd *listenerCmd) handle(conn net.Conn, context string) {
 	log := pfxlog.ContextLogger(context)
-	if proto, err := newProtocol(conn); err == nil {
+	defer func() { _ = conn.Close() }()
+
+	tracker := cmd.connections
+	if tracker == nil {
+		// not served by a listener command, such as a loopback test, so there's nothing to account to
+		tracker = newConnectionTracker()
+	}
+	tracked := tracker.add(conn, context)
+	if tracked == nil {
+		log.Info("shutting down, closing new connection")
+		return
+	}
+	defer tracker.remove(tracked)
+
+	// a dialer pooling its connections sends another test after each result, so keep serving tests until the
+	// connection is closed. Connections which didn't exchange a test, or whose test failed, aren't reused.
+	for first := true; ; first = false {
+		proto, err := newProtocol(conn, &cmd.protocolOptions)
+		if err != nil {
+			log.Errorf("error creating new protocol (%s)", err)
+			return
+		}
+
 		var test *loop3_pb.Test
+		exchanged := false
 		if cmd.test != nil && cmd.test.IsRxSequential() {
 			test = cmd.test
 		} else {
 			if test, err = proto.rxTest(); err != nil {
-				logrus.WithError(err).Error("failure receiving test parameters, closing")
-				_ = conn.Close()
+				if tracker.isDraining() {
+					log.Debug("idle connection closed for shutdown")
+				} else if first || !errors.Is(err, io.EOF) {
+					logrus.WithError(err).Error("failure receiving test parameters, closing")
+				} else {
+					log.Debug("connection closed by dialer")
+				}
 				return
 			}
+			if _, err = proto.answerMetadata(cmd.label); err != nil {
+				logrus.WithError(err).Error("failure exchanging metadata, closing")
+				return
+			}
+			exchanged = true
 		}
 
-		var result *Result
-		if err := proto.run(test); err == nil {
-			result = &Result{Success: true}
-		} else {
-			result = &Result{Success: false, Message: err.Error()}
+		if !tracker.startTest(tracked) {
+			log.Info("shutting down, not starting test")
+			return
 		}
+
+		// any error is reported to the dialer through the result
+		result, _ := proto.run(test)
+		proto.emitSummary(result, nil)
+		more := tracker.finishTest(tracked, result)
 		if err := result.Tx(proto); err != nil {
 			log.Errorf("unable to tx result (%s)", err)
+			return
 		}
 
-	} else {
-		log.Errorf("error creating new protocol (%s)", err)
+		if !exchanged || !result.Success || !more {
+			return
+		}
 	}
 }
@@ -0,0 +1,101 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"time"
+
+	"github.com/michaelquigley/pfxlog"
+	"github.com/sirupsen/logrus"
+)
+
+// The fields every log entry of a test carries, so the entries of many connections can be told apart once they're
+// collected. Entries from the tx, rx and verify loops also carry their phase
+const (
+	logFieldTest  = "test"
+	logFieldConn  = "conn"
+	logFieldPhase = "phase"
+)
+
+// logJson switches logging to JSON for all loop3 commands
+var logJson bool
+
+func init() {
+	loop3Cmd.PersistentFlags().BoolVar(&logJson, "log-json", false, "Log JSON objects, one per line, with the test name, "+
+		"connection index and phase as fields, for log pipelines")
+}
+
+// startLogging applies --log-json
+func startLogging() {
+	if logJson {
+		pfxlog.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	}
+}
+
+// startSummaryOnly applies --summary-only, which drops the info and debug logging, the per-block logging and progress
+// reports included, so it can't perturb fast runs. Warnings and errors are still logged as they happen, and the
+// summaries logged once each test and the run are done are kept, see summaryLogger
+func (options *protocolOptions) startSummaryOnly() {
+	if options.summaryOnly {
+		logrus.SetLevel(logrus.WarnLevel)
+	}
+	options.summaryLog = options.newSummaryLog()
+}
+
+// newSummaryLog returns the logger summaries go to. That's the standard logger, unless --summary-only has raised its
+// level, when it's a copy of it which stays at info level
+func (options *protocolOptions) newSummaryLog() *logrus.Logger {
+	logger := pfxlog.Logger().Logger
+	if !options.summaryOnly {
+		return logger
+	}
+	summaryLog := pfxlog.CloneLogger(logger)
+	summaryLog.SetLevel(logrus.InfoLevel)
+	return summaryLog
+}
+
+// summaryLogger returns a logger for the summary of a run
+func (options *protocolOptions) summaryLogger() *logrus.Entry {
+	if options.summaryLog == nil {
+		return pfxlog.Logger().Entry
+	}
+	return logrus.NewEntry(options.summaryLog)
+}
+
+// logFields returns the fields every log entry of the test carries
+func (p *protocol) logFields() logrus.Fields {
+	name := ""
+	if p.test != nil {
+		name = p.test.Name
+	}
+	return logrus.Fields{logFieldTest: name, logFieldConn: p.connIndex}
+}
+
+// logger returns a logger whose entries carry the test name and connection index
+func (p *protocol) logger() *logrus.Entry {
+	return pfxlog.Logger().WithFields(p.logFields())
+}
+
+// summaryLogger returns a logger for the test's summary, whose entries carry the test name and connection index
+func (p *protocol) summaryLogger() *logrus.Entry {
+	return p.options.summaryLogger().WithFields(p.logFields())
+}
+
+// phaseLogger returns a logger whose entries carry the test name, connection index and phase
+func (p *protocol) phaseLogger(phase string) *logrus.Entry {
+	return p.logger().WithField(logFieldPhase, phase)
+}
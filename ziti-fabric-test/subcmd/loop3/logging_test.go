@@ -0,0 +1,90 @@
+package loop3
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ProtocolLogsStructuredFields(t *testing.T) {
+	req := require.New(t)
+
+	logger := logrus.StandardLogger()
+	out := &bytes.Buffer{}
+	formatter, output := logger.Formatter, logger.Out
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetOutput(out)
+	defer func() {
+		logger.SetFormatter(formatter)
+		logger.SetOutput(output)
+	}()
+
+	p := &protocol{test: &loop3_pb.Test{Name: "throughput"}, connIndex: 3, stats: &StatsAccumulator{}, errors: make(chan error, 1), options: protocolOptions{failFast: false}}
+	p.fail(p.newError(PhaseVerify, 7, errors.New("mismatched hashes")))
+	p.logger().Info("summary")
+
+	decoder := json.NewDecoder(out)
+	entry := map[string]interface{}{}
+	req.NoError(decoder.Decode(&entry))
+	req.Equal("throughput", entry[logFieldTest])
+	req.Equal(3.0, entry[logFieldConn])
+	req.Equal(PhaseVerify, entry[logFieldPhase])
+
+	entry = map[string]interface{}{}
+	req.NoError(decoder.Decode(&entry))
+	req.Equal("summary", entry["msg"])
+	req.Equal("throughput", entry[logFieldTest])
+	req.NotContains(entry, logFieldPhase)
+}
+
+func Test_SummaryOnlyKeepsSummaries(t *testing.T) {
+	req := require.New(t)
+
+	logger := logrus.StandardLogger()
+	out := &bytes.Buffer{}
+	formatter, output, level := logger.Formatter, logger.Out, logger.Level
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetOutput(out)
+	options := protocolOptions{summaryOnly: true}
+	options.startSummaryOnly()
+	defer func() {
+		logger.SetFormatter(formatter)
+		logger.SetOutput(output)
+		logger.SetLevel(level)
+	}()
+
+	p := &protocol{test: &loop3_pb.Test{Name: "throughput"}, connIndex: 3, options: options}
+	req.Same(p.summaryLogger().Logger, p.summaryLogger().Logger, "the summary logger is only built once")
+	p.phaseLogger(PhaseTx).Info("-> #1")
+	p.logger().Warn("retried")
+	p.summaryLogger().Info("loss: none")
+
+	decoder := json.NewDecoder(out)
+	entry := map[string]interface{}{}
+	req.NoError(decoder.Decode(&entry))
+	req.Equal("retried", entry["msg"], "info logging is dropped, warnings aren't")
+
+	entry = map[string]interface{}{}
+	req.NoError(decoder.Decode(&entry))
+	req.Equal("loss: none", entry["msg"])
+	req.Equal("throughput", entry[logFieldTest])
+	req.False(decoder.More())
+}
+
+func Test_NewProtocolBuildsSummaryLogger(t *testing.T) {
+	req := require.New(t)
+
+	p, err := newProtocol(&testPeer{}, nil)
+	req.NoError(err)
+	req.Same(logrus.StandardLogger(), p.summaryLogger().Logger)
+
+	p, err = newProtocol(&testPeer{}, &protocolOptions{summaryOnly: true})
+	req.NoError(err)
+	req.NotSame(logrus.StandardLogger(), p.summaryLogger().Logger)
+	req.Equal(logrus.InfoLevel, p.summaryLogger().Logger.GetLevel())
+}
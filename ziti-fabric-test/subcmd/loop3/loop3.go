@@ -28,4 +28,9 @@ func init() {
 var loop3Cmd = &cobra.Command{
 	Use:   "loop3",
 	Short: "Loop testing tool, v3",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// cobra only runs the closest PersistentPreRun, so the root's is run from here
+		subcmd.Root.PersistentPreRun(cmd, args)
+		startLogging()
+	},
 }
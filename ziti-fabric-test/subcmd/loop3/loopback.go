@@ -0,0 +1,132 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"io"
+	"net"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// loopbackRxTimeout is used when a loopback test doesn't set an rx timeout, without one the verifier exits immediately
+const loopbackRxTimeout = 5000
+
+// RunLoopback runs test against an in-process listener over an in-memory pipe, exercising the full protocol without a
+// network. The listener side sends back what test expects to receive. The returned result is the one sent by the
+// listener; an error is returned if either side fails or the dialer's tx/rx counts don't match the test.
+func RunLoopback(test *loop3_pb.Test) (*Result, error) {
+	local := proto.Clone(test).(*loop3_pb.Test)
+	if local.RxTimeout == 0 {
+		local.RxTimeout = loopbackRxTimeout
+	}
+	remote := loopbackPeerTest(local)
+
+	listener := &listenerCmd{}
+	if remote.IsRxSequential() {
+		listener.test = remote
+	}
+	dialerConn, listenerConn := net.Pipe()
+	defer func() { _ = dialerConn.Close() }()
+	defer func() { _ = listenerConn.Close() }()
+	go listener.handle(listenerConn, "loopback")
+
+	return runLoopbackTest(dialerConn, local, remote)
+}
+
+// runLoopbackTest runs the dialer side of local over conn, sending remote to the listener first if needed
+func runLoopbackTest(conn io.ReadWriteCloser, local, remote *loop3_pb.Test) (*Result, error) {
+	p, err := newProtocol(conn, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if local.IsTxRandomHashed() {
+		if err = p.txTest(remote); err != nil {
+			return nil, err
+		}
+		if _, err = p.exchangeMetadata(newMetadata("loopback", "")); err != nil {
+			return nil, err
+		}
+	}
+
+	own, err := p.run(local)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := p.rxResult(own)
+	if err != nil {
+		return nil, err
+	}
+
+	// a side stopped by its byte cap sends fewer blocks, all of which should have arrived
+	expectedTx, expectedRx := local.TxRequests, local.RxRequests
+	if own.TxMaxBytesReached {
+		expectedTx = result.RxCount
+	}
+	if result.TxMaxBytesReached {
+		expectedRx = result.TxCount
+	}
+	if p.stats.TxCount() != expectedTx || p.stats.RxCount() != expectedRx {
+		return result, errors.Errorf("expected tx/rx counts of %d/%d, got %d/%d", expectedTx, expectedRx, p.stats.TxCount(), p.stats.RxCount())
+	}
+
+	return result, nil
+}
+
+// loopbackPeerTest returns the test for the listener side of a loopback run of test, sending what test expects to
+// receive, unpaced
+func loopbackPeerTest(test *loop3_pb.Test) *loop3_pb.Test {
+	payloadBytes := test.RxSeqBlockSize
+	if payloadBytes == 0 {
+		payloadBytes = test.PayloadMinBytes
+	}
+
+	return &loop3_pb.Test{
+		Name:            test.Name,
+		TxRequests:      test.RxRequests,
+		TxPacing:        "0s",
+		TxMaxJitter:     "0s",
+		TxPauseEvery:    "0s",
+		TxPauseFor:      "0s",
+		RxRequests:      test.TxRequests,
+		RxTimeout:       test.RxTimeout,
+		ConnectTimeout:  test.ConnectTimeout,
+		RxIdleTimeout:   test.RxIdleTimeout,
+		RxPacing:        "0s",
+		RxMaxJitter:     "0s",
+		RxPauseEvery:    "0s",
+		RxPauseFor:      "0s",
+		RxSeqBlockSize:  test.PayloadMinBytes,
+		PayloadMinBytes: payloadBytes,
+		PayloadMaxBytes: payloadBytes,
+		TxBlockType:     test.RxBlockType,
+		RxBlockType:     test.TxBlockType,
+		ResumeFrom:      test.ResumeFrom,
+		ProbeMode:       test.ProbeMode,
+		FlowWindow:      test.FlowWindow,
+		DrainTimeout:    test.DrainTimeout,
+		VerifyWorkers:   test.VerifyWorkers,
+		StreamSummary:   test.StreamSummary,
+		TxMaxBytes:      test.RxMaxBytes,
+		RxMaxBytes:      test.TxMaxBytes,
+		Annotations:     test.Annotations,
+	}
+}
@@ -0,0 +1,78 @@
+package loop3
+
+import (
+	"testing"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func newLoopbackTest(name string) *loop3_pb.Test {
+	return &loop3_pb.Test{
+		Name:             name,
+		TxRequests:       50,
+		TxPacing:         "0s",
+		TxMaxJitter:      "0s",
+		TxPauseEvery:     "0s",
+		TxPauseFor:       "0s",
+		RxRequests:       50,
+		RxTimeout:        5000,
+		RxPacing:         "0s",
+		RxMaxJitter:      "0s",
+		RxPauseEvery:     "0s",
+		RxPauseFor:       "0s",
+		PayloadMinBytes:  64,
+		PayloadMaxBytes:  4096,
+		LatencyFrequency: 10,
+	}
+}
+
+func Test_RunLoopbackRandomHashed(t *testing.T) {
+	req := require.New(t)
+
+	result, err := RunLoopback(newLoopbackTest("random-hashed"))
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+}
+
+func Test_RunLoopbackAsymmetric(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("asymmetric")
+	test.RxRequests = 5
+	test.RxTimeout = 0
+
+	result, err := RunLoopback(test)
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+	req.Equal(int32(0), test.RxTimeout, "the caller's test should not be modified")
+}
+
+func Test_RunLoopbackSequential(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("sequential")
+	test.TxBlockType = loop3_pb.BlockTypeSequential
+	test.PayloadMinBytes = 512
+	test.PayloadMaxBytes = 512
+	test.RxBlockType = loop3_pb.BlockTypeSequential
+	test.RxSeqBlockSize = 256
+
+	result, err := RunLoopback(test)
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+}
+
+func Test_RunLoopbackNoVerify(t *testing.T) {
+	req := require.New(t)
+
+	for _, window := range []int32{0, 4} {
+		test := newLoopbackTest("no-verify")
+		test.FlowWindow = window
+		result, peerResult := runLoopbackWithOptions(t, test, &protocolOptions{failFast: true, noVerify: true})
+		req.True(result.Success, result.Message)
+		req.Equal(test.RxRequests, result.RxCount, "window %d", window)
+		req.True(peerResult.Success, peerResult.Message)
+		req.Equal(test.TxRequests, peerResult.RxCount, "window %d", window)
+	}
+}
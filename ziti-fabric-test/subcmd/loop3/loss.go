@@ -0,0 +1,54 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"fmt"
+)
+
+// blockLoss is how many of the blocks sent in one direction never arrived
+type blockLoss struct {
+	sent     int32
+	received int32
+}
+
+func (loss blockLoss) lost() int32 {
+	if loss.received > loss.sent {
+		return 0
+	}
+	return loss.sent - loss.received
+}
+
+func (loss blockLoss) percent() float64 {
+	if loss.sent == 0 {
+		return 0
+	}
+	return 100 * float64(loss.lost()) / float64(loss.sent)
+}
+
+func (loss blockLoss) String() string {
+	return fmt.Sprintf("%d/%d lost (%.2f%%)", loss.lost(), loss.sent, loss.percent())
+}
+
+// loss compares what this side sent and received with the counts the peer reported in its result. Both sides count
+// from the test's resume sequence, so that's taken off the totals
+func (p *protocol) loss(result *Result) (tx blockLoss, rx blockLoss) {
+	base := p.test.ResumeFrom
+	tx = blockLoss{sent: p.stats.TxCount() - base, received: result.RxCount - base}
+	rx = blockLoss{sent: result.TxCount - base, received: p.stats.RxCount() - base}
+	return tx, rx
+}
@@ -0,0 +1,106 @@
+package loop3
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ResultSerDeser(t *testing.T) {
+	req := require.New(t)
+
+	p, err := newProtocol(&testPeer{}, nil)
+	req.NoError(err)
+	p.test = &loop3_pb.Test{Name: "test"}
+	result := &Result{
+		Success:  false,
+		Message:  "rx timeout",
+		TxCount:  100,
+		RxCount:  97,
+		TxBytes:  6400,
+		RxBytes:  6208,
+		Duration: 3 * time.Second,
+		Latency:  LatencyStats{Count: 2, Min: time.Millisecond, Avg: 2 * time.Millisecond, Max: 3 * time.Millisecond},
+		TxLost:   1,
+		RxLost:   2,
+
+		LatencyDropped: 3,
+		TxWireBytes:    7000,
+		RxWireBytes:    6800,
+		TxRetries:      4,
+		SndBuf:         262144,
+		RcvBuf:         131072,
+	}
+	req.NoError(result.Tx(p))
+
+	local := &Result{}
+	read, err := p.rxResult(local)
+	req.NoError(err)
+	req.Equal(result, read)
+}
+
+func Test_RunReturnsResult(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("result")
+	test.LatencyFrequency = 5
+	conn, peer := net.Pipe()
+	defer func() { _ = conn.Close() }()
+	defer func() { _ = peer.Close() }()
+	go (&listenerCmd{}).handle(peer, "result")
+
+	p, err := newProtocol(conn, nil)
+	req.NoError(err)
+	req.NoError(p.txTest(loopbackPeerTest(test)))
+	_, err = p.exchangeMetadata(newMetadata("result", ""))
+	req.NoError(err)
+
+	result, err := p.run(test)
+	req.NoError(err)
+	req.True(result.Success)
+	req.Equal(test.TxRequests, result.TxCount)
+	req.Equal(test.RxRequests, result.RxCount)
+	req.True(result.TxBytes > 0)
+	req.True(result.RxBytes > 0)
+	req.True(result.Duration > 0)
+	req.True(result.Latency.Count > 0)
+
+	peerResult, err := p.rxResult(result)
+	req.NoError(err)
+	req.True(peerResult.Success, peerResult.Message)
+	req.Equal(result.TxBytes, peerResult.RxBytes)
+	req.Zero(result.TxLost)
+	req.Zero(result.RxLost)
+}
+
+func Test_Loss(t *testing.T) {
+	req := require.New(t)
+
+	p := &protocol{test: &loop3_pb.Test{ResumeFrom: 100}, stats: &StatsAccumulator{txCount: 200, rxCount: 150}}
+	tx, rx := p.loss(&Result{TxCount: 200, RxCount: 190})
+
+	req.Equal(int32(100), tx.sent)
+	req.Equal(int32(10), tx.lost())
+	req.Equal(10.0, tx.percent())
+	req.Equal(int32(50), rx.lost())
+	req.Equal(50.0, rx.percent())
+	req.Equal("10/100 lost (10.00%)", tx.String())
+
+	req.Equal(0.0, blockLoss{}.percent())
+}
+
+func Test_RunLoopbackReportsCounts(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("counts")
+	test.RxRequests = 20
+
+	result, err := RunLoopback(test)
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+	req.Equal(int32(50), result.RxCount)
+	req.Equal(int32(20), result.TxCount)
+}
@@ -0,0 +1,76 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	units "github.com/docker/go-units"
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/pkg/errors"
+)
+
+// A test's TxMaxBytes caps how much the txer sends, whatever its TxRequests. The txer stops once it has sent that
+// many bytes, as counted in TxBytes, so the block which reaches the cap is the last one and the cap is exceeded by
+// less than a block. The peer's RxMaxBytes is the same cap, and it stops receiving at the same block, as the sizes it
+// counts are those sent. Whichever of the count and the cap is reached first ends the test, and --scenario-timeout or
+// --max-runtime can still cut it short.
+
+// parseMaxBytes parses --max-bytes, such as 500m or 1g. An empty value is no cap
+func parseMaxBytes(val string) (int64, error) {
+	if val == "" {
+		return 0, nil
+	}
+	size, err := units.RAMInBytes(val)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid --max-bytes [%s]", val)
+	}
+	if size <= 0 {
+		return 0, errors.Errorf("invalid --max-bytes [%s], must be more than 0", val)
+	}
+	return size, nil
+}
+
+// capBytes caps what each side of a test sends at maxBytes, letting the receiving side know where the sender stops
+func capBytes(local, remote *loop3_pb.Test, maxBytes int64) {
+	local.TxMaxBytes = maxBytes
+	local.RxMaxBytes = maxBytes
+	remote.TxMaxBytes = maxBytes
+	remote.RxMaxBytes = maxBytes
+}
+
+// isLastBeforeTxCap returns true if sending size more bytes reaches the tx cap
+func (p *protocol) isLastBeforeTxCap(size int) bool {
+	return p.test.TxMaxBytes > 0 && p.stats.TxBytes()+int64(size) >= p.test.TxMaxBytes
+}
+
+// txCapReached returns true once the txer has sent TxMaxBytes
+func (p *protocol) txCapReached() bool {
+	return p.test.TxMaxBytes > 0 && p.stats.TxBytes() >= p.test.TxMaxBytes
+}
+
+// rxCapReached returns true once the peer's txer has reached its cap, so no more blocks are coming
+func (p *protocol) rxCapReached() bool {
+	return p.test.RxMaxBytes > 0 && p.stats.RxBytes() >= p.test.RxMaxBytes
+}
+
+// stopTxAtCap tells flow control that the block about to be sent is the last, so the rxer only waits for the grants
+// the peer makes for the blocks actually sent. It has to happen before the block goes out, as the peer may answer
+// with the last grant as soon as it has the block
+func (p *protocol) stopTxAtCap() {
+	if p.txCredits != nil {
+		p.txCredits.stopAt(p.stats.TxCount() + 1 - p.test.ResumeFrom)
+	}
+}
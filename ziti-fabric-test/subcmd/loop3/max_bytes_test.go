@@ -0,0 +1,82 @@
+package loop3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseMaxBytes(t *testing.T) {
+	req := require.New(t)
+
+	size, err := parseMaxBytes("")
+	req.NoError(err)
+	req.Equal(int64(0), size)
+
+	size, err = parseMaxBytes("1GB")
+	req.NoError(err)
+	req.Equal(int64(1<<30), size)
+
+	size, err = parseMaxBytes("500k")
+	req.NoError(err)
+	req.Equal(int64(500<<10), size)
+
+	_, err = parseMaxBytes("lots")
+	req.Error(err)
+
+	_, err = parseMaxBytes("0")
+	req.Error(err)
+}
+
+func Test_TxCreditsStopAt(t *testing.T) {
+	req := require.New(t)
+
+	// the peer grants half a window at a time, so 5 blocks verified is 2 grants of 2 on top of the first window
+	credits := newTxCredits(4, 100)
+	credits.stopAt(5)
+	req.Equal(int32(8), credits.needed)
+	credits.grant(2)
+	req.True(credits.awaiting())
+	credits.grant(2)
+	req.False(credits.awaiting())
+
+	credits = newTxCredits(4, 3)
+	credits.stopAt(2)
+	req.Equal(int32(4), credits.needed, "nothing is granted beyond the first window")
+	req.False(credits.awaiting())
+}
+
+func Test_RunWithMaxBytes(t *testing.T) {
+	for _, flowWindow := range []int32{0, 4} {
+		req := require.New(t)
+
+		test := newLoopbackTest("max-bytes")
+		test.TxRequests = 200
+		test.RxRequests = 200
+		test.FlowWindow = flowWindow
+		capBytes(test, test, 20000)
+
+		result, peerResult := runLoopbackWithOptions(t, test, &protocolOptions{failFast: true})
+		req.True(result.Success, result.Message)
+		req.True(result.TxMaxBytesReached)
+		req.True(peerResult.TxMaxBytesReached)
+		req.Less(result.TxCount, int32(200))
+		req.GreaterOrEqual(result.TxBytes, int64(20000))
+		req.Less(result.TxBytes, int64(20000+4096))
+		req.Equal(result.TxCount, peerResult.RxCount)
+		req.Equal(peerResult.TxCount, result.RxCount)
+	}
+}
+
+func Test_RunWithMaxBytesAboveCount(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("max-bytes-above-count")
+	capBytes(test, test, 1<<30)
+
+	result, peerResult := runLoopbackWithOptions(t, test, &protocolOptions{failFast: true})
+	req.True(result.Success, result.Message)
+	req.False(result.TxMaxBytesReached, "the count is reached first")
+	req.False(peerResult.TxMaxBytesReached)
+	req.Equal(int32(50), result.TxCount)
+}
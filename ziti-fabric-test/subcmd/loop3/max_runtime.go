@@ -0,0 +1,134 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/michaelquigley/pfxlog"
+	"github.com/pkg/errors"
+)
+
+// maxRuntimeGrace is how long each step of winding down after --max-runtime waits for the one before it to work
+const maxRuntimeGrace = 10 * time.Second
+
+// runLimit is the dialer's --max-runtime, a last resort for unattended runs. When it runs out every test is cancelled,
+// which stops the tx and rx loops at their next block. Tests still running a grace period later have their connection
+// closed, to unblock loops stuck in a read or write, and fail as timed out. If the process is somehow still running
+// another grace period after that, it exits.
+type runLimit struct {
+	limit   time.Duration
+	grace   time.Duration
+	exit    func()
+	expired int32
+
+	lock   sync.Mutex
+	starts map[*protocol]time.Time
+	timer  *time.Timer
+	done   chan struct{}
+}
+
+// startRunLimit starts the clock on a run which may last limit, calling exit if winding it down doesn't work
+func startRunLimit(limit, grace time.Duration, exit func()) *runLimit {
+	l := &runLimit{
+		limit:  limit,
+		grace:  grace,
+		exit:   exit,
+		starts: map[*protocol]time.Time{},
+		done:   make(chan struct{}),
+	}
+	l.timer = time.AfterFunc(limit, l.expire)
+	return l
+}
+
+// add puts p under the limit, cancelling it at once if the limit has already run out. It does nothing without a limit
+func (l *runLimit) add(p *protocol) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	l.starts[p] = time.Now()
+	l.lock.Unlock()
+	if l.hasExpired() {
+		p.cancel()
+	}
+}
+
+// protocols returns the protocols under the limit
+func (l *runLimit) protocols() []*protocol {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var result []*protocol
+	for p := range l.starts {
+		result = append(result, p)
+	}
+	return result
+}
+
+func (l *runLimit) expire() {
+	atomic.StoreInt32(&l.expired, 1)
+	log := pfxlog.Logger()
+	log.Errorf("max runtime of %v exceeded, cancelling all tests", l.limit)
+	for _, p := range l.protocols() {
+		p.cancel()
+	}
+
+	select {
+	case <-l.done:
+		return
+	case <-time.After(l.grace):
+	}
+	log.Errorf("tests still running %v after being cancelled, closing their connections", l.grace)
+	for _, p := range l.protocols() {
+		p.stop()
+	}
+
+	select {
+	case <-l.done:
+		return
+	case <-time.After(l.grace):
+	}
+	log.Errorf("run still going %v after closing every connection, exiting", l.grace)
+	l.exit()
+}
+
+// hasExpired returns true once the limit has run out. A run without a limit never does
+func (l *runLimit) hasExpired() bool {
+	return l != nil && atomic.LoadInt32(&l.expired) == 1
+}
+
+// cancel ends the limit once the run is done, whether or not it ran out
+func (l *runLimit) cancel() {
+	if l == nil {
+		return
+	}
+	l.timer.Stop()
+	close(l.done)
+}
+
+// result is the failed result of p's test, named name, when the limit cut it short
+func (l *runLimit) result(p *protocol, name string) *Result {
+	l.lock.Lock()
+	start := l.starts[p]
+	l.lock.Unlock()
+	err := errors.Errorf("[%s conn %d] cut short by the max runtime of %v", name, p.connIndex, l.limit)
+	result := p.result(start, err)
+	result.exit = exitTimeout
+	return result
+}
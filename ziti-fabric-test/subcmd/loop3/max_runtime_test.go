@@ -0,0 +1,77 @@
+package loop3
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RunLimitStopsHungTest(t *testing.T) {
+	req := require.New(t)
+
+	// the peer accepts the connection, then never answers
+	conn, peer := net.Pipe()
+	defer func() { _ = peer.Close() }()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := peer.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	exited := make(chan struct{})
+	limit := startRunLimit(50*time.Millisecond, 50*time.Millisecond, func() { close(exited) })
+
+	p, err := newProtocol(conn, nil)
+	req.NoError(err)
+	limit.add(p)
+	test := newLoopbackTest("hung")
+	cmd := &dialerCmd{precheck: true}
+	_, _, err = cmd.runTest(p, test, loopbackPeerTest(test), newMetadata("", ""))
+	limit.cancel()
+	req.Error(err)
+	req.True(limit.hasExpired())
+	req.True(p.isStopped())
+
+	result := limit.result(p, test.Name)
+	req.False(result.Success)
+	req.Equal("[hung conn 0] cut short by the max runtime of 50ms", result.Message)
+
+	select {
+	case <-exited:
+		req.Fail("exited although the run was done")
+	case <-time.After(150 * time.Millisecond):
+	}
+}
+
+func Test_RunLimitExitsWhenStuck(t *testing.T) {
+	exited := make(chan struct{})
+	limit := startRunLimit(10*time.Millisecond, 10*time.Millisecond, func() { close(exited) })
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "didn't exit")
+	}
+	require.True(t, limit.hasExpired())
+}
+
+func Test_RunLimitCancelsLateTests(t *testing.T) {
+	req := require.New(t)
+
+	var limit *runLimit
+	req.False(limit.hasExpired())
+	limit.add(&protocol{stopped: make(chan struct{})})
+	limit.cancel()
+
+	limit = startRunLimit(time.Millisecond, time.Minute, func() {})
+	req.Eventually(limit.hasExpired, time.Second, time.Millisecond)
+	p := &protocol{stopped: make(chan struct{})}
+	limit.add(p)
+	req.True(p.isStopped())
+	limit.cancel()
+}
@@ -2,13 +2,13 @@ package loop3
 
 import (
 	"bytes"
-	"crypto/sha512"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
-	"github.com/michaelquigley/pfxlog"
 	"github.com/openziti/foundation/v2/info"
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
 	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
 	"time"
 )
 
@@ -17,71 +17,171 @@ type Message interface {
 	Rx(p *protocol) error
 }
 
-var success = []byte{1}
-var failure = []byte{0}
-
+// Result is the outcome of one side of a test. The listener sends its result to the dialer once its side is done, with
+// the counts of blocks it sent and received so the dialer can work out how many were lost in each direction
 type Result struct {
-	Success bool
-	Message string
+	Success  bool          `json:"success"`
+	Message  string        `json:"message,omitempty"`
+	TxCount  int32         `json:"txCount"`
+	RxCount  int32         `json:"rxCount"`
+	TxBytes  int64         `json:"txBytes"`
+	RxBytes  int64         `json:"rxBytes"`
+	Duration time.Duration `json:"durationNanos"`
+	Latency  LatencyStats  `json:"latency"`
+
+	// TxWireBytes and RxWireBytes are everything written to and read from the connection, messages and framing included
+	TxWireBytes int64 `json:"txWireBytes"`
+	RxWireBytes int64 `json:"rxWireBytes"`
+
+	// TxLost and RxLost are only known to the dialer, once it has the listener's result
+	TxLost int32 `json:"txLost"`
+	RxLost int32 `json:"rxLost"`
+
+	// LatencyDropped is how many of the peer's latency requests went unanswered because too many were waiting for a
+	// block to answer them with. The peer's latency stats don't cover those
+	LatencyDropped int32 `json:"latencyDropped"`
+
+	// TxRetries is how many block writes were retried after a transient error, see --tx-retries
+	TxRetries int32 `json:"txRetries"`
+
+	// Errors is how many errors the test reported, which is only ever more than 1 with --fail-fast=false. It isn't
+	// sent to the peer
+	Errors int32 `json:"errors,omitempty"`
+
+	// SndBuf and RcvBuf are the socket buffer sizes the OS settled on, when the peer is a TCP connection
+	SndBuf int32 `json:"sndBuf,omitempty"`
+	RcvBuf int32 `json:"rcvBuf,omitempty"`
+
+	// TxMaxBytesReached is set when the txer stopped at the test's TxMaxBytes, short of its TxRequests
+	TxMaxBytesReached bool `json:"txMaxBytesReached,omitempty"`
+	// TxRateLimited is how many blocks the txer held back to stay under the test's MaxBlocksPerSec
+	TxRateLimited int32 `json:"txRateLimited,omitempty"`
+
+	// Corrupted is how many received blocks failed their hash check with --tolerate-corruption, which counts them
+	// instead of failing the test, and CorruptionRate is the fraction of the blocks received they make up
+	Corrupted      int32   `json:"corrupted,omitempty"`
+	CorruptionRate float64 `json:"corruptionRate,omitempty"`
+
+	// ClockOffset is how far the listener's clock was estimated to be ahead of the dialer's, when one-way delays were
+	// measured. It's only known to the dialer
+	ClockOffset time.Duration `json:"clockOffsetNanos,omitempty"`
+
+	// Setup is how long it took to dial the connection and exchange the test and metadata with the listener, before
+	// any block was sent. It leaves out --precheck and the clock skew check, and a pooled connection which was reused
+	// took no time to dial. It's only known to the dialer
+	Setup time.Duration `json:"setupNanos,omitempty"`
+
+	// Annotations are copied from the test, see Workload.Annotations. They aren't sent with the result, as both sides
+	// have the same annotations on their copies of the test
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// exit is the exit code a failed result calls for when it isn't a plain test failure, such as a timeout. It's
+	// only known to the dialer
+	exit exitCode
 }
 
-func (r *Result) getSuccessBytes() []byte {
-	if r.Success {
-		return success
-	}
-	return failure
+// LatencyStats is the round trip time distribution of the latency requests answered during a test
+type LatencyStats struct {
+	Count int32         `json:"count"`
+	Min   time.Duration `json:"minNanos"`
+	Avg   time.Duration `json:"avgNanos"`
+	Max   time.Duration `json:"maxNanos"`
+	P50   time.Duration `json:"p50Nanos"`
+	P90   time.Duration `json:"p90Nanos"`
+	P99   time.Duration `json:"p99Nanos"`
 }
 
 func (r *Result) Tx(p *protocol) error {
-	dataLen := 1 + len(r.Message)
-	if err := p.txHeader(p.peer, dataLen); err != nil {
-		return err
+	msg := &loop3_pb.Result{
+		Success:       r.Success,
+		Message:       r.Message,
+		TxCount:       r.TxCount,
+		RxCount:       r.RxCount,
+		TxBytes:       r.TxBytes,
+		RxBytes:       r.RxBytes,
+		DurationNanos: r.Duration.Nanoseconds(),
+		Latency: &loop3_pb.Latency{
+			Count:    r.Latency.Count,
+			MinNanos: r.Latency.Min.Nanoseconds(),
+			AvgNanos: r.Latency.Avg.Nanoseconds(),
+			MaxNanos: r.Latency.Max.Nanoseconds(),
+			P50Nanos: r.Latency.P50.Nanoseconds(),
+			P90Nanos: r.Latency.P90.Nanoseconds(),
+			P99Nanos: r.Latency.P99.Nanoseconds(),
+		},
+		TxLost:         r.TxLost,
+		RxLost:         r.RxLost,
+		LatencyDropped: r.LatencyDropped,
+		TxWireBytes:    r.TxWireBytes,
+		RxWireBytes:    r.RxWireBytes,
+		TxRetries:      r.TxRetries,
+		SndBuf:         r.SndBuf,
+		RcvBuf:         r.RcvBuf,
+
+		TxMaxBytesReached: r.TxMaxBytesReached,
+		TxRateLimited:     r.TxRateLimited,
+		Corrupted:         r.Corrupted,
 	}
-
-	if _, err := p.peer.Write(r.getSuccessBytes()); err != nil {
+	if err := p.framing.WriteMessage(msg); err != nil {
 		return err
 	}
 
-	if len(r.Message) > 0 {
-		if _, err := p.peer.Write([]byte(r.Message)); err != nil {
-			return err
-		}
-	}
-
 	MsgTxRate.Mark(1)
-	BytesTxRate.Mark(int64(4 + 4 + dataLen))
+	BytesTxRate.Mark(int64(p.framing.HeaderLen() + proto.Size(msg)))
 
 	if r.Success {
-		pfxlog.ContextLogger(p.test.Name).Infof("<- [result+]")
+		p.logger().Infof("-> [result+]")
 	} else {
-		pfxlog.ContextLogger(p.test.Name).Infof("<- [result-]")
+		p.logger().Infof("-> [result-]")
 	}
 
 	return nil
 }
 
 func (r *Result) Rx(p *protocol) error {
-	msgLen, err := p.rxHeader()
-	if err != nil {
+	msg := &loop3_pb.Result{}
+	if err := p.framing.ReadMessage(msg); err != nil {
 		return err
 	}
-	if msgLen < 1 {
-		return errors.Errorf("not enough data to deserialize Result need at least one byte")
-	}
-	body, err := p.rxMsgBody(msgLen)
-	if err != nil {
-		return err
+	r.Success = msg.Success
+	r.Message = msg.Message
+	r.TxCount = msg.TxCount
+	r.RxCount = msg.RxCount
+	r.TxBytes = msg.TxBytes
+	r.RxBytes = msg.RxBytes
+	r.Duration = time.Duration(msg.DurationNanos)
+	if latency := msg.Latency; latency != nil {
+		r.Latency = LatencyStats{
+			Count: latency.Count,
+			Min:   time.Duration(latency.MinNanos),
+			Avg:   time.Duration(latency.AvgNanos),
+			Max:   time.Duration(latency.MaxNanos),
+			P50:   time.Duration(latency.P50Nanos),
+			P90:   time.Duration(latency.P90Nanos),
+			P99:   time.Duration(latency.P99Nanos),
+		}
 	}
-	r.Success = body[0] == 1
-	r.Message = string(body[1:])
+	r.TxLost = msg.TxLost
+	r.RxLost = msg.RxLost
+	r.LatencyDropped = msg.LatencyDropped
+	r.TxWireBytes = msg.TxWireBytes
+	r.RxWireBytes = msg.RxWireBytes
+	r.TxRetries = msg.TxRetries
+	r.SndBuf = msg.SndBuf
+	r.RcvBuf = msg.RcvBuf
+	r.TxMaxBytesReached = msg.TxMaxBytesReached
+	r.TxRateLimited = msg.TxRateLimited
+	r.Corrupted = msg.Corrupted
+	r.CorruptionRate = corruptionRate(msg.Corrupted, msg.RxCount)
+	r.Annotations = p.annotations()
 
 	MsgRxRate.Mark(1)
-	BytesRxRate.Mark(int64(4 + 4 + msgLen))
+	BytesRxRate.Mark(int64(p.framing.HeaderLen() + proto.Size(msg)))
 
 	if r.Success {
-		pfxlog.ContextLogger(p.test.Name).Infof("<- [result+]")
+		p.logger().Infof("<- [result+]")
 	} else {
-		pfxlog.ContextLogger(p.test.Name).Infof("<- [result-]")
+		p.logger().Infof("<- [result-]")
 	}
 
 	return nil
@@ -91,12 +191,52 @@ const (
 	BlockTypePlain           byte = 1
 	BlockTypeLatencyRequest       = 2
 	BlockTypeLatencyResponse      = 3
+	BlockTypeOneWay               = 4
+
+	// BlockTypeWindow grants the peer flow control credit for as many blocks as its sequence. It carries no payload
+	BlockTypeWindow = 5
+
+	// BlockTypeStreamSummary ends a stream, its sequence being the number of blocks sent. See streamChecksum
+	BlockTypeStreamSummary = 6
+
+	// BlockTypeLateLatencyResponse answers a latency request after the txer has sent its last block, carrying the
+	// request's timestamp and no payload. BlockTypeDrained follows the last of them, its sequence being how many
+	// were sent. Neither counts as a block of the test, see drain
+	BlockTypeLateLatencyResponse = 7
+	BlockTypeDrained             = 8
+)
+
+// RandHashedBlock wire format. Following the magic header and message length, each block starts with a fixed header:
+//
+//	version      1 byte, BlockHeaderVersion
+//	type         1 byte, one of the BlockType constants
+//	flags        1 byte, a combination of the BlockFlag constants
+//	sequence     4 bytes, little endian
+//	hash length  2 bytes, little endian
+//
+// If BlockFlagTimestamp is set, the header is followed by a 1 byte timestamp length and a binary encoded time.Time.
+// Then come the hash, of the given length, and the payload, which is the rest of the message.
+//
+// Parsers reject versions they don't know, and flags they can't handle, so the format can be extended by adding
+// versions, block types and flags.
+const (
+	BlockHeaderVersion byte = 1
+	BlockHeaderLen          = 1 /* version */ + 1 /* type */ + 1 /* flags */ + 4 /* sequence */ + 2 /* hash length */
+
+	BlockFlagHashed     byte = 1 << 0
+	BlockFlagCompressed byte = 1 << 1
+	BlockFlagTimestamp  byte = 1 << 2
+
+	// blockFlagsSupported are the flags this version can parse
+	blockFlagsSupported = BlockFlagHashed | BlockFlagTimestamp
 )
 
 type Block interface {
 	PrepForSend(p *protocol)
 	Tx(p *protocol) error
 	Verify(p *protocol) error
+	// Size returns the number of bytes the block takes on the wire, not counting any timestamp
+	Size() int
 }
 
 type RandHashedBlock struct {
@@ -108,13 +248,13 @@ type RandHashedBlock struct {
 }
 
 func (block *RandHashedBlock) getTimestampBytes() ([]byte, error) {
-	if block.Type == BlockTypeLatencyRequest {
+	if block.Type == BlockTypeLatencyRequest || block.Type == BlockTypeOneWay {
 		block.Timestamp = time.Now()
 	}
 
 	tsBuf := bytes.Buffer{}
 
-	if block.Type != BlockTypePlain {
+	if block.Type != BlockTypePlain && block.Type != BlockTypeWindow && block.Type != BlockTypeStreamSummary && block.Type != BlockTypeDrained {
 		ts, err := block.Timestamp.MarshalBinary()
 		if err != nil {
 			return nil, err
@@ -130,142 +270,227 @@ func (block *RandHashedBlock) getTimestampBytes() ([]byte, error) {
 	return tsBuf.Bytes(), nil
 }
 
+func (block *RandHashedBlock) Size() int {
+	return 8 /* header */ + BlockHeaderLen + len(block.Hash) + len(block.Data)
+}
+
 func (block *RandHashedBlock) PrepForSend(p *protocol) {
 	var latency *time.Time
-	if block.Type == BlockTypePlain {
+	if block.Type == BlockTypePlain && p.test.IsProbeEcho() {
+		// every block answers a probe, so wait for the next one to arrive
+		select {
+		case latency = <-p.latencies:
+		case <-time.After(time.Duration(p.probeTimeout()) * time.Millisecond):
+			p.phaseLogger(PhaseTx).Warnf("no probe received in %d ms", p.probeTimeout())
+		}
+	} else if block.Type == BlockTypePlain || block.Type == BlockTypeOneWay {
 		select {
 		case latency = <-p.latencies:
 		default:
 			latency = nil
 		}
+	}
 
-		if latency != nil {
-			block.Type = BlockTypeLatencyResponse
-			block.Timestamp = *latency
-		}
+	if latency != nil {
+		block.Type = BlockTypeLatencyResponse
+		block.Timestamp = *latency
 	}
 }
 
-func (block *RandHashedBlock) Tx(p *protocol) error {
+// encode returns the message body for the block, stamping the timestamp for block types which carry the send time
+func (block *RandHashedBlock) encode() ([]byte, error) {
 	tsBytes, err := block.getTimestampBytes()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	dataLen := 1 /* block type */ + len(tsBytes) + 4 /* sequence bytes */ + len(block.Hash) + len(block.Data)
-
-	buf := &bytes.Buffer{}
-	if err := p.txHeader(buf, dataLen); err != nil {
-		return err
+	if len(block.Hash) > 0xFFFF {
+		return nil, errors.Errorf("hash too long: %d bytes", len(block.Hash))
 	}
 
-	if _, err := buf.Write([]byte{block.Type}); err != nil {
-		return err
+	var flags byte
+	if len(block.Hash) > 0 {
+		flags |= BlockFlagHashed
 	}
-
 	if len(tsBytes) > 0 {
-		if _, err := buf.Write(tsBytes); err != nil {
-			return err
-		}
+		flags |= BlockFlagTimestamp
 	}
 
+	buf := bytes.NewBuffer(make([]byte, 0, BlockHeaderLen+len(tsBytes)+len(block.Hash)+len(block.Data)))
+	buf.Write([]byte{BlockHeaderVersion, block.Type, flags})
+
 	seqBytes := make([]byte, 4)
 	binary.LittleEndian.PutUint32(seqBytes, block.Sequence)
+	buf.Write(seqBytes)
 
-	if _, err := buf.Write(seqBytes); err != nil {
-		return err
-	}
+	hashLenBytes := make([]byte, 2)
+	binary.LittleEndian.PutUint16(hashLenBytes, uint16(len(block.Hash)))
+	buf.Write(hashLenBytes)
 
-	if _, err := buf.Write(block.Hash); err != nil {
-		return err
-	}
+	buf.Write(tsBytes)
+	buf.Write(block.Hash)
+	buf.Write(block.Data)
 
-	if _, err := buf.Write(block.Data); err != nil {
-		return err
-	}
+	return buf.Bytes(), nil
+}
 
-	if _, err := p.peer.Write(buf.Bytes()); err != nil {
-		return err
+// decode populates the block from a message body produced by encode
+func (block *RandHashedBlock) decode(body []byte) error {
+	if len(body) < BlockHeaderLen {
+		return errors.Errorf("block too short for header: %d bytes, need at least %d", len(body), BlockHeaderLen)
+	}
+	if version := body[0]; version != BlockHeaderVersion {
+		return errors.Errorf("unsupported block header version %d, expected %d", version, BlockHeaderVersion)
+	}
+	block.Type = body[1]
+	flags := body[2]
+	if unsupported := flags &^ blockFlagsSupported; unsupported != 0 {
+		return errors.Errorf("unsupported block flags 0x%02x", unsupported)
 	}
+	block.Sequence = binary.LittleEndian.Uint32(body[3:7])
+	hashLen := int(binary.LittleEndian.Uint16(body[7:9]))
 
-	MsgTxRate.Mark(1)
-	BytesTxRate.Mark(int64(8 + dataLen))
+	buf := bytes.NewBuffer(body[BlockHeaderLen:])
+
+	if flags&BlockFlagTimestamp != 0 {
+		tsLen, err := buf.ReadByte()
+		if err != nil {
+			return errors.Wrap(err, "block too short for timestamp length")
+		}
+		if buf.Len() < int(tsLen) {
+			return errors.Errorf("block too short for timestamp: %d bytes left, need %d", buf.Len(), tsLen)
+		}
+		if err = block.Timestamp.UnmarshalBinary(buf.Next(int(tsLen))); err != nil {
+			return err
+		}
+	}
 
-	pfxlog.ContextLogger(p.test.Name).Infof("-> #%d (%s)", block.Sequence, info.ByteCount(int64(len(block.Data))))
+	if buf.Len() < hashLen {
+		return errors.Errorf("block too short for hash: %d bytes left, need %d", buf.Len(), hashLen)
+	}
+	block.Hash = buf.Next(hashLen)
+	block.Data = buf.Bytes()
 
 	return nil
 }
 
-func (block *RandHashedBlock) Rx(p *protocol) error {
-	length, err := p.rxHeader()
+func (block *RandHashedBlock) Tx(p *protocol) error {
+	body, err := block.encode()
 	if err != nil {
 		return err
 	}
 
-	body, err := p.rxMsgBody(length)
-	if err != nil {
+	if err := p.framing.WriteFrame(body); err != nil {
 		return err
 	}
 
-	buf := bytes.NewBuffer(body)
-	block.Type, err = buf.ReadByte()
+	MsgTxRate.Mark(1)
+	BytesTxRate.Mark(int64(p.framing.HeaderLen() + len(body)))
+
+	if block.Type == BlockTypeWindow {
+		p.phaseLogger(PhaseTx).Debugf("-> [window +%d]", block.Sequence)
+	} else if block.Type == BlockTypeStreamSummary {
+		p.phaseLogger(PhaseTx).Infof("-> [stream summary of %d blocks]", block.Sequence)
+	} else if block.Type == BlockTypeLateLatencyResponse {
+		p.phaseLogger(PhaseTx).Debugf("-> [late latency response #%d]", block.Sequence)
+	} else if block.Type == BlockTypeDrained {
+		p.phaseLogger(PhaseTx).Infof("-> [drained, %d late latency responses]", block.Sequence)
+	} else {
+		p.phaseLogger(PhaseTx).Infof("-> #%d (%s)", block.Sequence, info.ByteCount(int64(len(block.Data))))
+	}
+
+	return nil
+}
+
+func (block *RandHashedBlock) Rx(p *protocol) error {
+	body, err := p.framing.ReadFrame()
 	if err != nil {
 		return err
 	}
 
-	if block.Type != BlockTypePlain {
-		tsLen, err := buf.ReadByte()
-		if err != nil {
-			return err
-		}
-		if err := block.Timestamp.UnmarshalBinary(buf.Next(int(tsLen))); err != nil {
-			return err
-		}
+	if err = block.decode(body); err != nil {
+		return err
 	}
 
-	seqBytes := buf.Next(4)
-	block.Sequence = binary.LittleEndian.Uint32(seqBytes)
-
-	block.Hash = buf.Next(64)
-	block.Data = buf.Bytes()
-
 	MsgRxRate.Mark(1)
-	BytesRxRate.Mark(int64(8 + length))
+	BytesRxRate.Mark(int64(p.framing.HeaderLen() + len(body)))
 
-	if block.Type == BlockTypeLatencyResponse {
+	if block.Type == BlockTypeLatencyResponse || block.Type == BlockTypeLateLatencyResponse {
 		elapsed := time.Now().Sub(block.Timestamp)
 		MsgLatency.Update(elapsed)
+		p.stats.RecordLatency(block.Sequence, block.Timestamp, elapsed)
+	} else if block.Type == BlockTypeOneWay {
+		// relies on synchronized clocks, skew between the peers shows up here (possibly as negative delays)
+		MsgOneWayDelay.Update(time.Now().Sub(block.Timestamp))
 	}
 
-	pfxlog.ContextLogger(p.test.Name).Infof("<- #%d (%s)", block.Sequence, info.ByteCount(int64(len(block.Data))))
+	if block.Type == BlockTypeWindow {
+		p.phaseLogger(PhaseRx).Debugf("<- [window +%d]", block.Sequence)
+	} else if block.Type == BlockTypeStreamSummary {
+		p.phaseLogger(PhaseRx).Infof("<- [stream summary of %d blocks]", block.Sequence)
+	} else if block.Type == BlockTypeLateLatencyResponse {
+		p.phaseLogger(PhaseRx).Debugf("<- [late latency response #%d]", block.Sequence)
+	} else if block.Type == BlockTypeDrained {
+		p.phaseLogger(PhaseRx).Infof("<- [drained, %d late latency responses]", block.Sequence)
+	} else {
+		p.phaseLogger(PhaseRx).Infof("<- #%d (%s)", block.Sequence, info.ByteCount(int64(len(block.Data))))
+	}
 
 	return nil
 }
 
 func (block *RandHashedBlock) Verify(p *protocol) error {
-	if block.Sequence != uint32(p.rxSequence) {
-		return fmt.Errorf("expected sequence [%d] got sequence [%d]", p.rxSequence, block.Sequence)
+	if err := block.verifySequence(p); err != nil {
+		return err
 	}
 
-	hash := sha512.Sum512(block.Data)
-	if hex.EncodeToString(hash[:]) != hex.EncodeToString(block.Hash) {
-		return errors.New("mismatched hashes")
+	// probes aren't hashed, only their round trip matters
+	if !p.test.ProbeMode {
+		return block.verifyHash()
 	}
-	p.rxSequence++
 
 	return nil
 }
 
+// verifySequence checks the block is the next one expected. It must be called in the order blocks were received
+func (block *RandHashedBlock) verifySequence(p *protocol) error {
+	expected := p.rxSequence
+	// carry on from this block whether it verifies or not, so one bad block doesn't fail every block after it
+	p.rxSequence = uint64(block.Sequence) + 1
+
+	if block.Sequence != uint32(expected) {
+		return fmt.Errorf("expected sequence [%d] got sequence [%d]", expected, block.Sequence)
+	}
+	return nil
+}
+
+// errMismatchedHashes is returned for a block whose data doesn't match its hash, such as one corrupted on the way
+var errMismatchedHashes = errors.New("mismatched hashes")
+
+// verifyHash checks the block's data against its hash. It doesn't touch the protocol, so blocks can be hashed in any
+// order, on any goroutine
+func (block *RandHashedBlock) verifyHash() error {
+	hash := blockHash(block.Data)
+	if hex.EncodeToString(hash) != hex.EncodeToString(block.Hash) {
+		return errMismatchedHashes
+	}
+	return nil
+}
+
 type SeqBlock []byte
 
 func (s SeqBlock) PrepForSend(*protocol) {
 	// does nothing
 }
 
+func (s SeqBlock) Size() int {
+	return len(s)
+}
+
 func (s SeqBlock) Tx(p *protocol) error {
 	_, err := p.peer.Write(s)
 	if err == nil {
-		pfxlog.ContextLogger(p.test.Name).Infof("-> #%d (%s)", p.txCount, info.ByteCount(int64(len(s))))
+		p.phaseLogger(PhaseTx).Infof("-> #%d (%s)", p.stats.TxCount(), info.ByteCount(int64(len(s))))
 	}
 	return err
 }
@@ -274,8 +499,9 @@ func (block SeqBlock) Verify(p *protocol) error {
 	for idx, b := range block {
 		cmp := byte(p.rxSequence)
 		if cmp != b {
-			err := fmt.Errorf("expected sequence [%d] got sequence [%d] at index %v", cmp, b, idx)
-			panic(err)
+			// skip the rest of the block, so the next one is checked from where it should start
+			p.rxSequence += uint64(len(block) - idx)
+			return fmt.Errorf("expected sequence [%d] got sequence [%d] at index %v", cmp, b, idx)
 		}
 		p.rxSequence++
 	}
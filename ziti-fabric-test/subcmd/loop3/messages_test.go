@@ -6,9 +6,11 @@ import (
 	"github.com/google/go-cmp/cmp"
 	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
 	"github.com/stretchr/testify/require"
+	"io"
 	"math/rand"
 	"reflect"
 	"testing"
+	"time"
 )
 
 type testPeer struct {
@@ -33,14 +35,9 @@ func Test_MessageSerDeser(t *testing.T) {
 		Data:     data,
 	}
 
-	testBuf := &testPeer{}
-
-	p := &protocol{
-		peer: testBuf,
-		test: &loop3_pb.Test{
-			Name: "test",
-		},
-	}
+	p, err := newProtocol(&testPeer{}, nil)
+	req.NoError(err)
+	p.test = &loop3_pb.Test{Name: "test"}
 
 	req.NoError(block.Tx(p))
 
@@ -67,3 +64,161 @@ func Test_MessageSerDeser(t *testing.T) {
 
 	req.Equal("", cmp.Diff(block, readBlock))
 }
+
+func Test_OneWayBlockRecordsDelay(t *testing.T) {
+	req := require.New(t)
+	data := make([]byte, 512)
+	rand.Read(data)
+	hash := sha512.Sum512(data)
+
+	block := &RandHashedBlock{
+		Type:     BlockTypeOneWay,
+		Sequence: 3,
+		Hash:     hash[:],
+		Data:     data,
+	}
+
+	p, err := newProtocol(&testPeer{}, nil)
+	req.NoError(err)
+	p.test = &loop3_pb.Test{Name: "test"}
+
+	before := MsgOneWayDelay.Count()
+	req.NoError(block.Tx(p))
+	req.False(block.Timestamp.IsZero(), "one-way blocks should be stamped when sent")
+
+	readBlock := &RandHashedBlock{}
+	req.NoError(readBlock.Rx(p))
+	req.Equal("", cmp.Diff(block, readBlock))
+	req.Equal(before+1, MsgOneWayDelay.Count())
+}
+
+func Test_LatencyRequestsDroppedWhenQueueFull(t *testing.T) {
+	req := require.New(t)
+
+	p, err := newProtocol(&testPeer{}, nil)
+	req.NoError(err)
+	p.test = &loop3_pb.Test{Name: "test"}
+	p.latencies = make(chan *time.Time, 1)
+
+	for i := 0; i < 3; i++ {
+		block := &RandHashedBlock{Type: BlockTypeLatencyRequest, Sequence: uint32(i), Data: []byte{byte(i)}}
+		req.NoError(block.Tx(p))
+	}
+	for i := 0; i < 3; i++ {
+		_, err := p.rxRandomHashedBlock()
+		req.NoError(err)
+	}
+
+	req.Len(p.latencies, 1)
+	req.Equal(int32(2), p.result(time.Now(), nil).LatencyDropped)
+}
+
+func Test_RxHeaderRespectsMaxMessageBytes(t *testing.T) {
+	req := require.New(t)
+
+	peer := &testPeer{}
+	p, err := newProtocol(peer, &protocolOptions{maxMessageBytes: 1024})
+	req.NoError(err)
+
+	req.NoError(p.framing.WriteHeader(1024))
+	length, err := p.framing.ReadHeader()
+	req.NoError(err)
+	req.Equal(1024, length)
+
+	req.NoError(p.framing.WriteHeader(1025))
+	_, err = p.framing.ReadHeader()
+	req.Error(err)
+}
+
+func Test_BlockHeaderRoundTrip(t *testing.T) {
+	req := require.New(t)
+
+	for _, blockType := range []byte{BlockTypePlain, BlockTypeLatencyRequest, BlockTypeLatencyResponse, BlockTypeOneWay} {
+		data := make([]byte, 100)
+		rand.Read(data)
+		hash := sha512.Sum512(data)
+
+		block := &RandHashedBlock{
+			Type:      blockType,
+			Sequence:  0xDEADBEEF,
+			Hash:      hash[:],
+			Data:      data,
+			Timestamp: time.Now().Round(0),
+		}
+		if blockType == BlockTypePlain {
+			block.Timestamp = time.Time{}
+		}
+
+		body, err := block.encode()
+		req.NoError(err)
+		req.Equal(BlockHeaderVersion, body[0])
+		req.Equal(blockType, body[1])
+		req.Equal(BlockFlagHashed, body[2]&BlockFlagHashed)
+		req.Equal(blockType != BlockTypePlain, body[2]&BlockFlagTimestamp != 0)
+		req.Equal([]byte{0xEF, 0xBE, 0xAD, 0xDE}, body[3:7])
+		req.Equal([]byte{64, 0}, body[7:9])
+
+		decoded := &RandHashedBlock{}
+		req.NoError(decoded.decode(body))
+		req.Equal("", cmp.Diff(block, decoded))
+		if blockType == BlockTypePlain {
+			req.Equal(8+len(body), block.Size())
+		}
+	}
+}
+
+func Test_BlockHeaderRejectsUnknownFormats(t *testing.T) {
+	req := require.New(t)
+
+	block := &RandHashedBlock{Type: BlockTypePlain, Sequence: 1, Hash: []byte{1, 2, 3}, Data: []byte("data")}
+	body, err := block.encode()
+	req.NoError(err)
+
+	for name, corrupt := range map[string]func([]byte) []byte{
+		"version":     func(b []byte) []byte { b[0] = BlockHeaderVersion + 1; return b },
+		"compressed":  func(b []byte) []byte { b[2] |= BlockFlagCompressed; return b },
+		"short":       func(b []byte) []byte { return b[:BlockHeaderLen-1] },
+		"hash length": func(b []byte) []byte { b[7] = 0xFF; return b },
+		"timestamp":   func(b []byte) []byte { b[2] |= BlockFlagTimestamp; b[BlockHeaderLen] = 0xFF; return b },
+	} {
+		corrupted := corrupt(append([]byte(nil), body...))
+		req.Error((&RandHashedBlock{}).decode(corrupted), name)
+	}
+}
+
+func Test_RxTellsCleanCloseFromTruncatedBlock(t *testing.T) {
+	req := require.New(t)
+
+	p, err := newProtocol(&testPeer{}, &protocolOptions{})
+	req.NoError(err)
+	_, err = p.rxRandomHashedBlock()
+	req.Equal(io.EOF, err)
+
+	peer := &testPeer{}
+	p, err = newProtocol(peer, &protocolOptions{})
+	req.NoError(err)
+	req.NoError(p.framing.WriteHeader(100))
+	_, err = p.rxRandomHashedBlock()
+	req.Equal(io.ErrUnexpectedEOF, err)
+
+	peer = &testPeer{}
+	p, err = newProtocol(peer, &protocolOptions{})
+	req.NoError(err)
+	req.NoError(p.framing.WriteHeader(100))
+	peer.Write(make([]byte, 10))
+	err = p.framing.ReadMessage(&loop3_pb.Test{})
+	req.Equal(io.ErrUnexpectedEOF, err)
+}
+
+func Test_RxerStopsWithoutErrorOnCleanClose(t *testing.T) {
+	req := require.New(t)
+
+	p, err := newProtocol(&testPeer{}, &protocolOptions{})
+	req.NoError(err)
+	p.test = &loop3_pb.Test{Name: "test", RxRequests: 10}
+
+	done := make(chan bool, 1)
+	p.rxer(done, p.rxRandomHashedBlock)
+	req.NoError(p.firstError())
+	req.Equal(int32(0), p.stats.RxCount())
+}
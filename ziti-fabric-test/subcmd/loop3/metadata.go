@@ -0,0 +1,79 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"os"
+
+	"github.com/michaelquigley/pfxlog"
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/sirupsen/logrus"
+)
+
+// newMetadata describes this end of a run: a label chosen by the user, the host it's running on and the run ID used
+// to find the logs of both ends of the run
+func newMetadata(label, runId string) *loop3_pb.Metadata {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return &loop3_pb.Metadata{
+		Label: label,
+		Host:  host,
+		RunId: runId,
+	}
+}
+
+// exchangeMetadata is the dialer's side of the metadata exchange, which follows the test: it sends local and returns
+// the listener's metadata
+func (p *protocol) exchangeMetadata(local *loop3_pb.Metadata) (*loop3_pb.Metadata, error) {
+	if err := p.framing.WriteMessage(local); err != nil {
+		return nil, err
+	}
+	pfxlog.Logger().Info("-> [metadata]")
+
+	peer := &loop3_pb.Metadata{}
+	if err := p.framing.ReadMessage(peer); err != nil {
+		return nil, err
+	}
+	metadataLogger(peer).Info("<- [metadata]")
+	return peer, nil
+}
+
+// answerMetadata is the listener's side of the metadata exchange: it returns the dialer's metadata after answering
+// with its own, which carries the dialer's run ID so the listener's logs can be matched up with the run
+func (p *protocol) answerMetadata(label string) (*loop3_pb.Metadata, error) {
+	peer := &loop3_pb.Metadata{}
+	if err := p.framing.ReadMessage(peer); err != nil {
+		return nil, err
+	}
+	metadataLogger(peer).Info("<- [metadata]")
+
+	if err := p.framing.WriteMessage(newMetadata(label, peer.RunId)); err != nil {
+		return nil, err
+	}
+	pfxlog.Logger().Info("-> [metadata]")
+	return peer, nil
+}
+
+func metadataLogger(metadata *loop3_pb.Metadata) *logrus.Entry {
+	return pfxlog.Logger().WithFields(logrus.Fields{
+		"peerLabel": metadata.Label,
+		"peerHost":  metadata.Host,
+		"runId":     metadata.RunId,
+	})
+}
@@ -0,0 +1,46 @@
+package loop3
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MetadataExchange(t *testing.T) {
+	req := require.New(t)
+
+	dialerConn, listenerConn := net.Pipe()
+	defer func() { _ = dialerConn.Close() }()
+	defer func() { _ = listenerConn.Close() }()
+
+	dialer, err := newProtocol(dialerConn, nil)
+	req.NoError(err)
+	listener, err := newProtocol(listenerConn, nil)
+	req.NoError(err)
+
+	type answer struct {
+		label, runId string
+		err          error
+	}
+	answered := make(chan answer, 1)
+	go func() {
+		peer, err := listener.answerMetadata("shared-listener")
+		if err != nil {
+			answered <- answer{err: err}
+			return
+		}
+		answered <- answer{label: peer.Label, runId: peer.RunId}
+	}()
+
+	peer, err := dialer.exchangeMetadata(newMetadata("nightly", "run-42"))
+	req.NoError(err)
+	req.Equal("shared-listener", peer.Label)
+	req.Equal("run-42", peer.RunId, "the listener should answer with the dialer's run id")
+	req.NotEmpty(peer.Host)
+
+	result := <-answered
+	req.NoError(result.err)
+	req.Equal("nightly", result.label)
+	req.Equal("run-42", result.runId)
+}
@@ -21,6 +21,7 @@ var MsgRxRate = metrics.NewMeter()
 var BytesTxRate = metrics.NewMeter()
 var BytesRxRate = metrics.NewMeter()
 var MsgLatency = metrics.NewTimer()
+var MsgOneWayDelay = metrics.NewTimer()
 
 func init() {
 	register := func(name string, metric interface{}) {
@@ -34,6 +35,7 @@ func init() {
 	register("rx.msg.rate", MsgRxRate)
 	register("rx.bytes.rate", BytesRxRate)
 	register("msg.latency", MsgLatency)
+	register("msg.oneway.delay", MsgOneWayDelay)
 	register("conn.time", ConnectionTime)
 }
 
@@ -0,0 +1,138 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/michaelquigley/pfxlog"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	eventTypeProgress = "progress"
+	eventTypeSummary  = "summary"
+)
+
+// ndjsonWriter writes events as newline delimited JSON, one object per line. It's shared by all the tests being run,
+// so writes are serialized to keep lines from different tests from interleaving
+type ndjsonWriter struct {
+	lock sync.Mutex
+	out  io.Writer
+}
+
+func newNdjsonWriter(out io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{out: out}
+}
+
+func (w *ndjsonWriter) write(event interface{}) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		pfxlog.Logger().WithError(err).Error("unable to encode event")
+		return
+	}
+	line = append(line, '\n')
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if _, err = w.out.Write(line); err != nil {
+		pfxlog.Logger().WithError(err).Error("unable to write event")
+	}
+}
+
+// progressEvent is written every --progress-interval with --ndjson. The rates cover the time since the last event
+type progressEvent struct {
+	Type          string        `json:"type"`
+	Test          string        `json:"test"`
+	Conn          int           `json:"conn"`
+	Time          time.Time     `json:"time"`
+	Elapsed       time.Duration `json:"elapsedNanos"`
+	TxCount       int32         `json:"txCount"`
+	RxCount       int32         `json:"rxCount"`
+	TxBytes       int64         `json:"txBytes"`
+	RxBytes       int64         `json:"rxBytes"`
+	TxBytesPerSec float64       `json:"txBytesPerSec"`
+	RxBytesPerSec float64       `json:"rxBytesPerSec"`
+	Latency       LatencyStats  `json:"latency"`
+
+	// GenQueue is only set with --gen-queue-depth
+	GenQueue *GenQueueStats `json:"genQueue,omitempty"`
+}
+
+// summaryEvent is written with --ndjson once a test is done. Peer is the result sent back by the listener, which only
+// the dialer has
+type summaryEvent struct {
+	Type   string    `json:"type"`
+	Test   string    `json:"test"`
+	Conn   int       `json:"conn"`
+	Run    int       `json:"run,omitempty"`
+	Time   time.Time `json:"time"`
+	Result *Result   `json:"result"`
+	Peer   *Result   `json:"peer,omitempty"`
+}
+
+// startEvents sets up --ndjson, which takes stdout over for events, so logging is sent to stderr
+func (options *protocolOptions) startEvents() {
+	if options.ndjson {
+		logrus.SetOutput(os.Stderr)
+		options.events = newNdjsonWriter(os.Stdout)
+	}
+}
+
+// progressEvent returns the progress at current, with rates worked out since last
+func (p *protocol) progressEvent(start time.Time, last, current progressSnapshot) *progressEvent {
+	event := &progressEvent{
+		Type:    eventTypeProgress,
+		Test:    p.test.Name,
+		Conn:    p.connIndex,
+		Time:    current.at,
+		Elapsed: current.at.Sub(start),
+		TxCount: current.sent,
+		RxCount: current.received,
+		TxBytes: current.txBytes,
+		RxBytes: current.rxBytes,
+		Latency: current.latency,
+	}
+	if interval := current.at.Sub(last.at).Seconds(); interval > 0 {
+		event.TxBytesPerSec = float64(current.txBytes-last.txBytes) / interval
+		event.RxBytesPerSec = float64(current.rxBytes-last.rxBytes) / interval
+	}
+	return event
+}
+
+// emitSummary writes the summary of a finished test with --ndjson and --influx. peer may be nil
+func (p *protocol) emitSummary(result, peer *Result) {
+	if p.options.influx != nil {
+		p.options.influx.write(p.resultPoint(time.Now(), result, peer))
+	}
+	if p.options.events == nil {
+		return
+	}
+	p.options.events.write(&summaryEvent{
+		Type:   eventTypeSummary,
+		Test:   p.test.Name,
+		Conn:   p.connIndex,
+		Run:    p.repeat,
+		Time:   time.Now(),
+		Result: result,
+		Peer:   peer,
+	})
+}
@@ -0,0 +1,89 @@
+package loop3
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NdjsonProgressAndSummary(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("ndjson")
+	test.TxPacing = "5ms"
+	test.LatencyFrequency = 2
+	conn, peer := net.Pipe()
+	defer func() { _ = conn.Close() }()
+	defer func() { _ = peer.Close() }()
+	go (&listenerCmd{}).handle(peer, "ndjson")
+
+	out := &bytes.Buffer{}
+	p, err := newProtocol(conn, &protocolOptions{progressInterval: 20 * time.Millisecond, events: newNdjsonWriter(out)})
+	req.NoError(err)
+	req.NoError(p.txTest(loopbackPeerTest(test)))
+	_, err = p.exchangeMetadata(newMetadata("ndjson", ""))
+	req.NoError(err)
+
+	result, err := p.run(test)
+	req.NoError(err)
+	peerResult, err := p.rxResult(result)
+	req.NoError(err)
+	p.emitSummary(result, peerResult)
+
+	var progress []*progressEvent
+	var summary *summaryEvent
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		event := map[string]interface{}{}
+		req.NoError(json.Unmarshal(scanner.Bytes(), &event), "every line should be a JSON object")
+		switch event["type"] {
+		case eventTypeProgress:
+			req.Nil(summary, "the summary should be last")
+			progressed := &progressEvent{}
+			req.NoError(json.Unmarshal(scanner.Bytes(), progressed))
+			progress = append(progress, progressed)
+		case eventTypeSummary:
+			summary = &summaryEvent{}
+			req.NoError(json.Unmarshal(scanner.Bytes(), summary))
+		default:
+			req.Failf("unexpected event", "%v", event)
+		}
+	}
+
+	req.NotEmpty(progress)
+	last := progress[len(progress)-1]
+	req.Equal("ndjson", last.Test)
+	req.True(last.Elapsed > 0)
+	req.True(last.TxCount > 0)
+	req.True(last.TxBytes > 0)
+
+	req.NotNil(summary)
+	req.Equal(result.TxBytes, summary.Result.TxBytes)
+	req.Equal(result.Latency, summary.Result.Latency)
+	req.NotNil(summary.Peer)
+	req.Equal(result.TxBytes, summary.Peer.RxBytes)
+}
+
+func Test_NdjsonProgressRates(t *testing.T) {
+	req := require.New(t)
+
+	p, err := newProtocol(&testPeer{}, nil)
+	req.NoError(err)
+	p.test = newLoopbackTest("rates")
+
+	start := time.Now()
+	last := progressSnapshot{at: start, txBytes: 1000, rxBytes: 500}
+	current := progressSnapshot{at: start.Add(2 * time.Second), sent: 10, received: 5, txBytes: 5000, rxBytes: 2500}
+	event := p.progressEvent(start, last, current)
+	req.Equal(2*time.Second, event.Elapsed)
+	req.Equal(2000.0, event.TxBytesPerSec)
+	req.Equal(1000.0, event.RxBytesPerSec)
+	req.Equal(int32(5), event.RxCount)
+
+	req.Zero(p.progressEvent(start, current, current).TxBytesPerSec)
+}
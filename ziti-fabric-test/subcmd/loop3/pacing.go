@@ -0,0 +1,109 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// pacingRecorder records, for --pacing-report, how far apart the txer meant to send each block and how far apart it
+// actually did. It's only used by the txer, and read once the txer is done
+type pacingRecorder struct {
+	lastSend time.Time
+	targets  []time.Duration
+	errors   []time.Duration
+	writes   []time.Duration
+}
+
+// record notes a block write which started at sentAt and took write, target after the one before it. Deliberate gaps,
+// such as pauses and burst off periods, are left out by calling skip before the next record
+func (r *pacingRecorder) record(target time.Duration, sentAt time.Time, write time.Duration) {
+	r.writes = append(r.writes, write)
+	if !r.lastSend.IsZero() {
+		r.targets = append(r.targets, target)
+		r.errors = append(r.errors, sentAt.Sub(r.lastSend)-target)
+	}
+	r.lastSend = sentAt
+}
+
+// skip leaves the interval up to the next block out of the report
+func (r *pacingRecorder) skip() {
+	r.lastSend = time.Time{}
+}
+
+// pacingReport is the distribution of the pacing error, the achieved interval less the target one. A positive error
+// means the block went out late, which the OS or runtime is to blame for when the target is well above the write time
+type pacingReport struct {
+	intervals   int
+	targetAvg   time.Duration
+	achievedAvg time.Duration
+	errorMin    time.Duration
+	errorP50    time.Duration
+	errorP90    time.Duration
+	errorP99    time.Duration
+	errorMax    time.Duration
+	writeP50    time.Duration
+	writeP99    time.Duration
+	writeMax    time.Duration
+}
+
+// report sums up what's been recorded
+func (r *pacingRecorder) report() pacingReport {
+	report := pacingReport{intervals: len(r.errors)}
+	if len(r.errors) > 0 {
+		var targets, errs time.Duration
+		for i, err := range r.errors {
+			targets += r.targets[i]
+			errs += err
+		}
+		report.targetAvg = targets / time.Duration(len(r.errors))
+		report.achievedAvg = (targets + errs) / time.Duration(len(r.errors))
+
+		sorted := sortedDurations(r.errors)
+		report.errorMin = sorted[0]
+		report.errorP50 = durationPercentile(sorted, 0.5)
+		report.errorP90 = durationPercentile(sorted, 0.9)
+		report.errorP99 = durationPercentile(sorted, 0.99)
+		report.errorMax = sorted[len(sorted)-1]
+	}
+	if len(r.writes) > 0 {
+		sorted := sortedDurations(r.writes)
+		report.writeP50 = durationPercentile(sorted, 0.5)
+		report.writeP99 = durationPercentile(sorted, 0.99)
+		report.writeMax = sorted[len(sorted)-1]
+	}
+	return report
+}
+
+func (report pacingReport) String() string {
+	return fmt.Sprintf("pacing over %d intervals: target avg %v, achieved avg %v. error min/p50/p90/p99/max = %v/%v/%v/%v/%v, "+
+		"block write p50/p99/max = %v/%v/%v", report.intervals, report.targetAvg, report.achievedAvg, report.errorMin, report.errorP50,
+		report.errorP90, report.errorP99, report.errorMax, report.writeP50, report.writeP99, report.writeMax)
+}
+
+func sortedDurations(durations []time.Duration) []time.Duration {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// durationPercentile returns the p percentile of sorted, which mustn't be empty
+func durationPercentile(sorted []time.Duration, p float64) time.Duration {
+	return sorted[int(p*float64(len(sorted)-1))]
+}
@@ -0,0 +1,56 @@
+package loop3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PacingReport(t *testing.T) {
+	req := require.New(t)
+
+	start := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	recorder := &pacingRecorder{}
+	sentAt := start
+	for i := 0; i < 101; i++ {
+		// every tenth block goes out 5ms late
+		interval := 10 * time.Millisecond
+		if i%10 == 0 {
+			interval += 5 * time.Millisecond
+		}
+		sentAt = sentAt.Add(interval)
+		recorder.record(10*time.Millisecond, sentAt, time.Duration(i)*time.Microsecond)
+	}
+
+	report := recorder.report()
+	req.Equal(100, report.intervals, "the first block has no interval")
+	req.Equal(10*time.Millisecond, report.targetAvg)
+	req.Equal(10*time.Millisecond+500*time.Microsecond, report.achievedAvg)
+	req.Zero(report.errorMin)
+	req.Zero(report.errorP50)
+	req.Equal(5*time.Millisecond, report.errorP99)
+	req.Equal(5*time.Millisecond, report.errorMax)
+	req.Equal(50*time.Microsecond, report.writeP50)
+	req.Equal(100*time.Microsecond, report.writeMax)
+	req.Contains(report.String(), "pacing over 100 intervals: target avg 10ms, achieved avg 10.5ms")
+}
+
+func Test_PacingReportSkipsGaps(t *testing.T) {
+	req := require.New(t)
+
+	start := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	recorder := &pacingRecorder{}
+	recorder.record(time.Millisecond, start, 0)
+	recorder.record(time.Millisecond, start.Add(time.Millisecond), 0)
+	recorder.skip()
+	recorder.record(time.Millisecond, start.Add(time.Second), 0)
+	recorder.record(time.Millisecond, start.Add(time.Second+3*time.Millisecond), 0)
+
+	report := recorder.report()
+	req.Equal(2, report.intervals)
+	req.Zero(report.errorMin)
+	req.Equal(2*time.Millisecond, report.errorMax)
+
+	req.Zero((&pacingRecorder{}).report().intervals)
+}
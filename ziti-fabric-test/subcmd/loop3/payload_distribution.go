@@ -0,0 +1,152 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"math"
+	"math/rand"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/pkg/errors"
+)
+
+const (
+	PayloadDistributionFixed   = "fixed"
+	PayloadDistributionUniform = "uniform"
+	PayloadDistributionNormal  = "normal"
+	PayloadDistributionPareto  = "pareto"
+
+	// defaultParetoAlpha gives the 80/20 split, most blocks near the smallest size and a long tail of large ones
+	defaultParetoAlpha = 1.16
+
+	// normalSizeRetries is how many sizes are drawn from a normal distribution for one inside the range, before
+	// settling for the nearest bound
+	normalSizeRetries = 16
+)
+
+// payloadSizes is the distribution block payload sizes are drawn from, which are always between min and max
+type payloadSizes struct {
+	distribution string
+	min          int
+	max          int
+	// mean and stddev shape the normal distribution, defaulting to the middle of the range and a sixth of its width
+	mean   float64
+	stddev float64
+	// alpha is the pareto shape, lower values making for a heavier tail
+	alpha float64
+}
+
+// uniformSizes returns sizes drawn evenly from min up to, but not including, max
+func uniformSizes(min, max int) payloadSizes {
+	return payloadSizes{distribution: PayloadDistributionUniform, min: min, max: max}
+}
+
+// payloadSizesOf returns the distribution of the blocks test sends
+func payloadSizesOf(test *loop3_pb.Test) payloadSizes {
+	return payloadSizes{
+		distribution: test.PayloadDistribution,
+		min:          int(test.PayloadMinBytes),
+		max:          int(test.PayloadMaxBytes),
+		mean:         test.PayloadMeanBytes,
+		stddev:       test.PayloadStddevBytes,
+		alpha:        test.PayloadParetoAlpha,
+	}
+}
+
+// checkPayloadDistribution returns an error if distribution isn't one of the payload distributions, or empty for
+// the default of uniform
+func checkPayloadDistribution(distribution string) error {
+	switch distribution {
+	case "", PayloadDistributionFixed, PayloadDistributionUniform, PayloadDistributionNormal, PayloadDistributionPareto:
+		return nil
+	}
+	return errors.Errorf("must be one of %s, %s, %s or %s, got [%s]", PayloadDistributionFixed, PayloadDistributionUniform,
+		PayloadDistributionNormal, PayloadDistributionPareto, distribution)
+}
+
+// next draws a size from r
+func (sizes payloadSizes) next(r *rand.Rand) int {
+	distance := sizes.max - sizes.min
+	if distance <= 0 {
+		return sizes.min
+	}
+
+	switch sizes.distribution {
+	case PayloadDistributionFixed:
+		return sizes.max
+	case PayloadDistributionNormal:
+		return sizes.nextNormal(r)
+	case PayloadDistributionPareto:
+		return sizes.nextPareto(r)
+	default:
+		return sizes.min + r.Intn(distance)
+	}
+}
+
+// nextNormal draws from a normal distribution cut off at the bounds, so sizes pile up at neither of them
+func (sizes payloadSizes) nextNormal(r *rand.Rand) int {
+	mean, stddev := sizes.mean, sizes.stddev
+	if mean == 0 {
+		mean = float64(sizes.min+sizes.max) / 2
+	}
+	if stddev == 0 {
+		stddev = float64(sizes.max-sizes.min) / 6
+	}
+
+	size := mean
+	for i := 0; i < normalSizeRetries; i++ {
+		if size = mean + r.NormFloat64()*stddev; size >= float64(sizes.min) && size <= float64(sizes.max) {
+			break
+		}
+	}
+	return sizes.clamp(size)
+}
+
+// nextPareto draws from a pareto distribution bounded by the range, by inverting its CDF, so the tail is cut off at
+// max without being folded onto it. The distribution starts at min, or at 1 byte when min is 0
+func (sizes payloadSizes) nextPareto(r *rand.Rand) int {
+	alpha := sizes.alpha
+	if alpha == 0 {
+		alpha = defaultParetoAlpha
+	}
+	low, high := math.Max(float64(sizes.min), 1), float64(sizes.max)
+	lowA, highA := math.Pow(low, alpha), math.Pow(high, alpha)
+
+	u := r.Float64()
+	size := math.Pow((highA+u*lowA-u*highA)/(highA*lowA), -1/alpha)
+	return sizes.clamp(size)
+}
+
+func (sizes payloadSizes) clamp(size float64) int {
+	rounded := int(math.Round(size))
+	if rounded < sizes.min {
+		return sizes.min
+	}
+	if rounded > sizes.max {
+		return sizes.max
+	}
+	return rounded
+}
+
+// setPayloadDistribution draws the payload sizes of every workload from distribution, keeping any parameters the
+// scenario gives it
+func (scenario *Scenario) setPayloadDistribution(distribution string) {
+	for _, workload := range scenario.Workloads {
+		workload.Dialer.PayloadDistribution = distribution
+		workload.Listener.PayloadDistribution = distribution
+	}
+}
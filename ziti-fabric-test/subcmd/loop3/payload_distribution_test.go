@@ -0,0 +1,90 @@
+package loop3
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/stretchr/testify/require"
+)
+
+// drawSizes returns n sizes drawn from sizes, sorted
+func drawSizes(sizes payloadSizes, n int) []int {
+	r := rand.New(rand.NewSource(1))
+	drawn := make([]int, n)
+	for i := range drawn {
+		drawn[i] = sizes.next(r)
+	}
+	sort.Ints(drawn)
+	return drawn
+}
+
+func Test_PayloadSizesStayInRange(t *testing.T) {
+	req := require.New(t)
+
+	for _, distribution := range []string{"", PayloadDistributionFixed, PayloadDistributionUniform, PayloadDistributionNormal, PayloadDistributionPareto} {
+		sizes := payloadSizes{distribution: distribution, min: 100, max: 1000, stddev: 2000}
+		drawn := drawSizes(sizes, 10000)
+		req.GreaterOrEqual(drawn[0], 100, distribution)
+		req.LessOrEqual(drawn[len(drawn)-1], 1000, distribution)
+
+		sizes.max = sizes.min
+		req.Equal([]int{100, 100}, drawSizes(sizes, 2), "%s with no range", distribution)
+	}
+}
+
+func Test_PayloadSizesShapes(t *testing.T) {
+	req := require.New(t)
+
+	req.Equal([]int{1000, 1000, 1000}, drawSizes(payloadSizes{distribution: PayloadDistributionFixed, min: 100, max: 1000}, 3))
+
+	median := func(drawn []int) int { return drawn[len(drawn)/2] }
+
+	uniform := drawSizes(uniformSizes(0, 1000), 10000)
+	req.InDelta(500, median(uniform), 30)
+
+	normal := drawSizes(payloadSizes{distribution: PayloadDistributionNormal, min: 0, max: 1000, mean: 200, stddev: 50}, 10000)
+	req.InDelta(200, median(normal), 10)
+	req.Less(normal[len(normal)*99/100], 350, "nearly everything is within 3 stddevs of the mean")
+
+	// most pareto sizes are near the smallest, with a tail reaching towards the largest
+	pareto := drawSizes(payloadSizes{distribution: PayloadDistributionPareto, min: 100, max: 100000}, 10000)
+	req.Less(median(pareto), 300)
+	req.Greater(pareto[len(pareto)-1], 20000)
+
+	heavier := drawSizes(payloadSizes{distribution: PayloadDistributionPareto, min: 100, max: 100000, alpha: 0.5}, 10000)
+	req.Greater(median(heavier), median(pareto), "a lower alpha has a heavier tail")
+}
+
+func Test_GeneratorsDrawSizesFromTheTest(t *testing.T) {
+	req := require.New(t)
+
+	test := &loop3_pb.Test{TxRequests: 200, PayloadMinBytes: 10, PayloadMaxBytes: 5000, PayloadDistribution: PayloadDistributionPareto}
+	hashed := newRandomHashedBlockGenerator(int(test.TxRequests), payloadSizesOf(test), 0, false, 1)
+	seq := newSeqGenerator(int(test.TxRequests), payloadSizesOf(test), 1)
+	for _, nextSize := range []func() int{hashed.nextSize, seq.nextSize} {
+		small := 0
+		for i := 0; i < int(test.TxRequests); i++ {
+			if size := nextSize(); size < 100 {
+				small++
+			}
+		}
+		req.Greater(small, 150, "most pareto sizes are near the smallest")
+	}
+
+	hashed.probe = true
+	req.Equal(10, hashed.nextSize(), "probes are the smallest size")
+}
+
+func Test_ValidatePayloadDistribution(t *testing.T) {
+	req := require.New(t)
+
+	req.Empty(validateTest("dialer", &Test{PayloadDistribution: PayloadDistributionPareto, PayloadParetoAlpha: 2}))
+
+	errs := validateTest("dialer", &Test{PayloadDistribution: "zipf", PayloadStddevBytes: -1})
+	req.Len(errs, 2)
+	req.Equal("dialer.payloadDistribution", errs[0].Path)
+	req.Contains(errs[0].Message, "must be one of fixed, uniform, normal or pareto, got [zipf]")
+	req.Equal("dialer.payloadStddevBytes", errs[1].Path)
+}
@@ -25,24 +25,46 @@ type Test struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Name             string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	TxRequests       int32  `protobuf:"varint,2,opt,name=txRequests,proto3" json:"txRequests,omitempty"`
-	TxPacing         string `protobuf:"bytes,3,opt,name=txPacing,proto3" json:"txPacing,omitempty"`
-	TxMaxJitter      string `protobuf:"bytes,4,opt,name=txMaxJitter,proto3" json:"txMaxJitter,omitempty"`
-	TxPauseEvery     string `protobuf:"bytes,5,opt,name=txPauseEvery,proto3" json:"txPauseEvery,omitempty"`
-	TxPauseFor       string `protobuf:"bytes,6,opt,name=txPauseFor,proto3" json:"txPauseFor,omitempty"`
-	RxRequests       int32  `protobuf:"varint,7,opt,name=rxRequests,proto3" json:"rxRequests,omitempty"`
-	RxTimeout        int32  `protobuf:"varint,8,opt,name=rxTimeout,proto3" json:"rxTimeout,omitempty"`
-	RxPauseEvery     string `protobuf:"bytes,9,opt,name=rxPauseEvery,proto3" json:"rxPauseEvery,omitempty"`
-	RxPauseFor       string `protobuf:"bytes,10,opt,name=rxPauseFor,proto3" json:"rxPauseFor,omitempty"`
-	PayloadMinBytes  int32  `protobuf:"varint,11,opt,name=payloadMinBytes,proto3" json:"payloadMinBytes,omitempty"`
-	PayloadMaxBytes  int32  `protobuf:"varint,12,opt,name=payloadMaxBytes,proto3" json:"payloadMaxBytes,omitempty"`
-	LatencyFrequency int32  `protobuf:"varint,13,opt,name=latencyFrequency,proto3" json:"latencyFrequency,omitempty"`
-	TxBlockType      string `protobuf:"bytes,14,opt,name=txBlockType,proto3" json:"txBlockType,omitempty"`
-	RxBlockType      string `protobuf:"bytes,15,opt,name=rxBlockType,proto3" json:"rxBlockType,omitempty"`
-	RxSeqBlockSize   int32  `protobuf:"varint,16,opt,name=rxSeqBlockSize,proto3" json:"rxSeqBlockSize,omitempty"`
-	RxPacing         string `protobuf:"bytes,17,opt,name=rxPacing,proto3" json:"rxPacing,omitempty"`
-	RxMaxJitter      string `protobuf:"bytes,18,opt,name=rxMaxJitter,proto3" json:"rxMaxJitter,omitempty"`
+	Name                string            `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	TxRequests          int32             `protobuf:"varint,2,opt,name=txRequests,proto3" json:"txRequests,omitempty"`
+	TxPacing            string            `protobuf:"bytes,3,opt,name=txPacing,proto3" json:"txPacing,omitempty"`
+	TxMaxJitter         string            `protobuf:"bytes,4,opt,name=txMaxJitter,proto3" json:"txMaxJitter,omitempty"`
+	TxPauseEvery        string            `protobuf:"bytes,5,opt,name=txPauseEvery,proto3" json:"txPauseEvery,omitempty"`
+	TxPauseFor          string            `protobuf:"bytes,6,opt,name=txPauseFor,proto3" json:"txPauseFor,omitempty"`
+	RxRequests          int32             `protobuf:"varint,7,opt,name=rxRequests,proto3" json:"rxRequests,omitempty"`
+	RxTimeout           int32             `protobuf:"varint,8,opt,name=rxTimeout,proto3" json:"rxTimeout,omitempty"`
+	RxPauseEvery        string            `protobuf:"bytes,9,opt,name=rxPauseEvery,proto3" json:"rxPauseEvery,omitempty"`
+	RxPauseFor          string            `protobuf:"bytes,10,opt,name=rxPauseFor,proto3" json:"rxPauseFor,omitempty"`
+	PayloadMinBytes     int32             `protobuf:"varint,11,opt,name=payloadMinBytes,proto3" json:"payloadMinBytes,omitempty"`
+	PayloadMaxBytes     int32             `protobuf:"varint,12,opt,name=payloadMaxBytes,proto3" json:"payloadMaxBytes,omitempty"`
+	LatencyFrequency    int32             `protobuf:"varint,13,opt,name=latencyFrequency,proto3" json:"latencyFrequency,omitempty"`
+	TxBlockType         string            `protobuf:"bytes,14,opt,name=txBlockType,proto3" json:"txBlockType,omitempty"`
+	RxBlockType         string            `protobuf:"bytes,15,opt,name=rxBlockType,proto3" json:"rxBlockType,omitempty"`
+	RxSeqBlockSize      int32             `protobuf:"varint,16,opt,name=rxSeqBlockSize,proto3" json:"rxSeqBlockSize,omitempty"`
+	RxPacing            string            `protobuf:"bytes,17,opt,name=rxPacing,proto3" json:"rxPacing,omitempty"`
+	RxMaxJitter         string            `protobuf:"bytes,18,opt,name=rxMaxJitter,proto3" json:"rxMaxJitter,omitempty"`
+	OneWayDelay         bool              `protobuf:"varint,19,opt,name=oneWayDelay,proto3" json:"oneWayDelay,omitempty"`
+	BurstOnMillis       int32             `protobuf:"varint,20,opt,name=burstOnMillis,proto3" json:"burstOnMillis,omitempty"`
+	BurstOffMillis      int32             `protobuf:"varint,21,opt,name=burstOffMillis,proto3" json:"burstOffMillis,omitempty"`
+	TargetBytesPerSec   int64             `protobuf:"varint,22,opt,name=targetBytesPerSec,proto3" json:"targetBytesPerSec,omitempty"`
+	ResumeFrom          int32             `protobuf:"varint,23,opt,name=resumeFrom,proto3" json:"resumeFrom,omitempty"`
+	ProbeMode           bool              `protobuf:"varint,24,opt,name=probeMode,proto3" json:"probeMode,omitempty"`
+	LatencyRatePerSec   float64           `protobuf:"fixed64,25,opt,name=latencyRatePerSec,proto3" json:"latencyRatePerSec,omitempty"`
+	Seed                int64             `protobuf:"varint,26,opt,name=seed,proto3" json:"seed,omitempty"`
+	FlowWindow          int32             `protobuf:"varint,27,opt,name=flowWindow,proto3" json:"flowWindow,omitempty"`
+	VerifyWorkers       int32             `protobuf:"varint,28,opt,name=verifyWorkers,proto3" json:"verifyWorkers,omitempty"`
+	StreamSummary       bool              `protobuf:"varint,29,opt,name=streamSummary,proto3" json:"streamSummary,omitempty"`
+	Annotations         map[string]string `protobuf:"bytes,30,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	TxMaxBytes          int64             `protobuf:"varint,31,opt,name=txMaxBytes,proto3" json:"txMaxBytes,omitempty"`
+	RxMaxBytes          int64             `protobuf:"varint,32,opt,name=rxMaxBytes,proto3" json:"rxMaxBytes,omitempty"`
+	ConnectTimeout      int32             `protobuf:"varint,33,opt,name=connectTimeout,proto3" json:"connectTimeout,omitempty"`
+	RxIdleTimeout       int32             `protobuf:"varint,34,opt,name=rxIdleTimeout,proto3" json:"rxIdleTimeout,omitempty"`
+	MaxBlocksPerSec     float64           `protobuf:"fixed64,35,opt,name=maxBlocksPerSec,proto3" json:"maxBlocksPerSec,omitempty"`
+	PayloadDistribution string            `protobuf:"bytes,36,opt,name=payloadDistribution,proto3" json:"payloadDistribution,omitempty"`
+	PayloadMeanBytes    float64           `protobuf:"fixed64,37,opt,name=payloadMeanBytes,proto3" json:"payloadMeanBytes,omitempty"`
+	PayloadStddevBytes  float64           `protobuf:"fixed64,38,opt,name=payloadStddevBytes,proto3" json:"payloadStddevBytes,omitempty"`
+	PayloadParetoAlpha  float64           `protobuf:"fixed64,39,opt,name=payloadParetoAlpha,proto3" json:"payloadParetoAlpha,omitempty"`
+	DrainTimeout        int32             `protobuf:"varint,40,opt,name=drainTimeout,proto3" json:"drainTimeout,omitempty"`
 }
 
 func (x *Test) Reset() {
@@ -203,11 +225,514 @@ func (x *Test) GetRxMaxJitter() string {
 	return ""
 }
 
+func (x *Test) GetOneWayDelay() bool {
+	if x != nil {
+		return x.OneWayDelay
+	}
+	return false
+}
+
+func (x *Test) GetBurstOnMillis() int32 {
+	if x != nil {
+		return x.BurstOnMillis
+	}
+	return 0
+}
+
+func (x *Test) GetBurstOffMillis() int32 {
+	if x != nil {
+		return x.BurstOffMillis
+	}
+	return 0
+}
+
+func (x *Test) GetTargetBytesPerSec() int64 {
+	if x != nil {
+		return x.TargetBytesPerSec
+	}
+	return 0
+}
+
+func (x *Test) GetResumeFrom() int32 {
+	if x != nil {
+		return x.ResumeFrom
+	}
+	return 0
+}
+
+func (x *Test) GetProbeMode() bool {
+	if x != nil {
+		return x.ProbeMode
+	}
+	return false
+}
+
+func (x *Test) GetLatencyRatePerSec() float64 {
+	if x != nil {
+		return x.LatencyRatePerSec
+	}
+	return 0
+}
+
+func (x *Test) GetSeed() int64 {
+	if x != nil {
+		return x.Seed
+	}
+	return 0
+}
+
+func (x *Test) GetFlowWindow() int32 {
+	if x != nil {
+		return x.FlowWindow
+	}
+	return 0
+}
+
+func (x *Test) GetVerifyWorkers() int32 {
+	if x != nil {
+		return x.VerifyWorkers
+	}
+	return 0
+}
+
+func (x *Test) GetStreamSummary() bool {
+	if x != nil {
+		return x.StreamSummary
+	}
+	return false
+}
+
+func (x *Test) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+func (x *Test) GetTxMaxBytes() int64 {
+	if x != nil {
+		return x.TxMaxBytes
+	}
+	return 0
+}
+
+func (x *Test) GetRxMaxBytes() int64 {
+	if x != nil {
+		return x.RxMaxBytes
+	}
+	return 0
+}
+
+func (x *Test) GetConnectTimeout() int32 {
+	if x != nil {
+		return x.ConnectTimeout
+	}
+	return 0
+}
+
+func (x *Test) GetRxIdleTimeout() int32 {
+	if x != nil {
+		return x.RxIdleTimeout
+	}
+	return 0
+}
+
+func (x *Test) GetMaxBlocksPerSec() float64 {
+	if x != nil {
+		return x.MaxBlocksPerSec
+	}
+	return 0
+}
+
+func (x *Test) GetPayloadDistribution() string {
+	if x != nil {
+		return x.PayloadDistribution
+	}
+	return ""
+}
+
+func (x *Test) GetPayloadMeanBytes() float64 {
+	if x != nil {
+		return x.PayloadMeanBytes
+	}
+	return 0
+}
+
+func (x *Test) GetPayloadStddevBytes() float64 {
+	if x != nil {
+		return x.PayloadStddevBytes
+	}
+	return 0
+}
+
+func (x *Test) GetPayloadParetoAlpha() float64 {
+	if x != nil {
+		return x.PayloadParetoAlpha
+	}
+	return 0
+}
+
+func (x *Test) GetDrainTimeout() int32 {
+	if x != nil {
+		return x.DrainTimeout
+	}
+	return 0
+}
+
+type Result struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Success           bool     `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message           string   `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	TxCount           int32    `protobuf:"varint,3,opt,name=txCount,proto3" json:"txCount,omitempty"`
+	RxCount           int32    `protobuf:"varint,4,opt,name=rxCount,proto3" json:"rxCount,omitempty"`
+	TxBytes           int64    `protobuf:"varint,5,opt,name=txBytes,proto3" json:"txBytes,omitempty"`
+	RxBytes           int64    `protobuf:"varint,6,opt,name=rxBytes,proto3" json:"rxBytes,omitempty"`
+	DurationNanos     int64    `protobuf:"varint,7,opt,name=durationNanos,proto3" json:"durationNanos,omitempty"`
+	Latency           *Latency `protobuf:"bytes,8,opt,name=latency,proto3" json:"latency,omitempty"`
+	TxLost            int32    `protobuf:"varint,9,opt,name=txLost,proto3" json:"txLost,omitempty"`
+	RxLost            int32    `protobuf:"varint,10,opt,name=rxLost,proto3" json:"rxLost,omitempty"`
+	LatencyDropped    int32    `protobuf:"varint,11,opt,name=latencyDropped,proto3" json:"latencyDropped,omitempty"`
+	TxWireBytes       int64    `protobuf:"varint,12,opt,name=txWireBytes,proto3" json:"txWireBytes,omitempty"`
+	RxWireBytes       int64    `protobuf:"varint,13,opt,name=rxWireBytes,proto3" json:"rxWireBytes,omitempty"`
+	TxRetries         int32    `protobuf:"varint,14,opt,name=txRetries,proto3" json:"txRetries,omitempty"`
+	SndBuf            int32    `protobuf:"varint,15,opt,name=sndBuf,proto3" json:"sndBuf,omitempty"`
+	RcvBuf            int32    `protobuf:"varint,16,opt,name=rcvBuf,proto3" json:"rcvBuf,omitempty"`
+	TxMaxBytesReached bool     `protobuf:"varint,17,opt,name=txMaxBytesReached,proto3" json:"txMaxBytesReached,omitempty"`
+	TxRateLimited     int32    `protobuf:"varint,18,opt,name=txRateLimited,proto3" json:"txRateLimited,omitempty"`
+	Corrupted         int32    `protobuf:"varint,19,opt,name=corrupted,proto3" json:"corrupted,omitempty"`
+}
+
+func (x *Result) Reset() {
+	*x = Result{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_loop3_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Result) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Result) ProtoMessage() {}
+
+func (x *Result) ProtoReflect() protoreflect.Message {
+	mi := &file_loop3_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Result.ProtoReflect.Descriptor instead.
+func (*Result) Descriptor() ([]byte, []int) {
+	return file_loop3_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Result) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *Result) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Result) GetTxCount() int32 {
+	if x != nil {
+		return x.TxCount
+	}
+	return 0
+}
+
+func (x *Result) GetRxCount() int32 {
+	if x != nil {
+		return x.RxCount
+	}
+	return 0
+}
+
+func (x *Result) GetTxBytes() int64 {
+	if x != nil {
+		return x.TxBytes
+	}
+	return 0
+}
+
+func (x *Result) GetRxBytes() int64 {
+	if x != nil {
+		return x.RxBytes
+	}
+	return 0
+}
+
+func (x *Result) GetDurationNanos() int64 {
+	if x != nil {
+		return x.DurationNanos
+	}
+	return 0
+}
+
+func (x *Result) GetLatency() *Latency {
+	if x != nil {
+		return x.Latency
+	}
+	return nil
+}
+
+func (x *Result) GetTxLost() int32 {
+	if x != nil {
+		return x.TxLost
+	}
+	return 0
+}
+
+func (x *Result) GetRxLost() int32 {
+	if x != nil {
+		return x.RxLost
+	}
+	return 0
+}
+
+func (x *Result) GetLatencyDropped() int32 {
+	if x != nil {
+		return x.LatencyDropped
+	}
+	return 0
+}
+
+func (x *Result) GetTxWireBytes() int64 {
+	if x != nil {
+		return x.TxWireBytes
+	}
+	return 0
+}
+
+func (x *Result) GetRxWireBytes() int64 {
+	if x != nil {
+		return x.RxWireBytes
+	}
+	return 0
+}
+
+func (x *Result) GetTxRetries() int32 {
+	if x != nil {
+		return x.TxRetries
+	}
+	return 0
+}
+
+func (x *Result) GetSndBuf() int32 {
+	if x != nil {
+		return x.SndBuf
+	}
+	return 0
+}
+
+func (x *Result) GetRcvBuf() int32 {
+	if x != nil {
+		return x.RcvBuf
+	}
+	return 0
+}
+
+func (x *Result) GetTxMaxBytesReached() bool {
+	if x != nil {
+		return x.TxMaxBytesReached
+	}
+	return false
+}
+
+func (x *Result) GetTxRateLimited() int32 {
+	if x != nil {
+		return x.TxRateLimited
+	}
+	return 0
+}
+
+func (x *Result) GetCorrupted() int32 {
+	if x != nil {
+		return x.Corrupted
+	}
+	return 0
+}
+
+type Latency struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Count    int32 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	MinNanos int64 `protobuf:"varint,2,opt,name=minNanos,proto3" json:"minNanos,omitempty"`
+	AvgNanos int64 `protobuf:"varint,3,opt,name=avgNanos,proto3" json:"avgNanos,omitempty"`
+	MaxNanos int64 `protobuf:"varint,4,opt,name=maxNanos,proto3" json:"maxNanos,omitempty"`
+	P50Nanos int64 `protobuf:"varint,5,opt,name=p50Nanos,proto3" json:"p50Nanos,omitempty"`
+	P90Nanos int64 `protobuf:"varint,6,opt,name=p90Nanos,proto3" json:"p90Nanos,omitempty"`
+	P99Nanos int64 `protobuf:"varint,7,opt,name=p99Nanos,proto3" json:"p99Nanos,omitempty"`
+}
+
+func (x *Latency) Reset() {
+	*x = Latency{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_loop3_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Latency) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Latency) ProtoMessage() {}
+
+func (x *Latency) ProtoReflect() protoreflect.Message {
+	mi := &file_loop3_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Latency.ProtoReflect.Descriptor instead.
+func (*Latency) Descriptor() ([]byte, []int) {
+	return file_loop3_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Latency) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Latency) GetMinNanos() int64 {
+	if x != nil {
+		return x.MinNanos
+	}
+	return 0
+}
+
+func (x *Latency) GetAvgNanos() int64 {
+	if x != nil {
+		return x.AvgNanos
+	}
+	return 0
+}
+
+func (x *Latency) GetMaxNanos() int64 {
+	if x != nil {
+		return x.MaxNanos
+	}
+	return 0
+}
+
+func (x *Latency) GetP50Nanos() int64 {
+	if x != nil {
+		return x.P50Nanos
+	}
+	return 0
+}
+
+func (x *Latency) GetP90Nanos() int64 {
+	if x != nil {
+		return x.P90Nanos
+	}
+	return 0
+}
+
+func (x *Latency) GetP99Nanos() int64 {
+	if x != nil {
+		return x.P99Nanos
+	}
+	return 0
+}
+
+type Metadata struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Label string `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"`
+	Host  string `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	RunId string `protobuf:"bytes,3,opt,name=runId,proto3" json:"runId,omitempty"`
+}
+
+func (x *Metadata) Reset() {
+	*x = Metadata{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_loop3_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Metadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Metadata) ProtoMessage() {}
+
+func (x *Metadata) ProtoReflect() protoreflect.Message {
+	mi := &file_loop3_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Metadata.ProtoReflect.Descriptor instead.
+func (*Metadata) Descriptor() ([]byte, []int) {
+	return file_loop3_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Metadata) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *Metadata) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *Metadata) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
 var File_loop3_proto protoreflect.FileDescriptor
 
 var file_loop3_proto_rawDesc = []byte{
 	0x0a, 0x0b, 0x6c, 0x6f, 0x6f, 0x70, 0x33, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0d, 0x7a,
-	0x69, 0x74, 0x69, 0x2e, 0x6c, 0x6f, 0x6f, 0x70, 0x33, 0x2e, 0x70, 0x62, 0x22, 0xe8, 0x04, 0x0a,
+	0x69, 0x74, 0x69, 0x2e, 0x6c, 0x6f, 0x6f, 0x70, 0x33, 0x2e, 0x70, 0x62, 0x22, 0x94, 0x0c, 0x0a,
 	0x04, 0x54, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20,
 	0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x74, 0x78, 0x52,
 	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x74,
@@ -246,12 +771,126 @@ var file_loop3_proto_rawDesc = []byte{
 	0x61, 0x63, 0x69, 0x6e, 0x67, 0x18, 0x11, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x72, 0x78, 0x50,
 	0x61, 0x63, 0x69, 0x6e, 0x67, 0x12, 0x20, 0x0a, 0x0b, 0x72, 0x78, 0x4d, 0x61, 0x78, 0x4a, 0x69,
 	0x74, 0x74, 0x65, 0x72, 0x18, 0x12, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x72, 0x78, 0x4d, 0x61,
-	0x78, 0x4a, 0x69, 0x74, 0x74, 0x65, 0x72, 0x42, 0x44, 0x5a, 0x42, 0x67, 0x69, 0x74, 0x68, 0x75,
-	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6f, 0x70, 0x65, 0x6e, 0x7a, 0x69, 0x74, 0x69, 0x2f, 0x7a,
-	0x69, 0x74, 0x69, 0x2f, 0x7a, 0x69, 0x74, 0x69, 0x2d, 0x66, 0x61, 0x62, 0x72, 0x69, 0x63, 0x2d,
-	0x74, 0x65, 0x73, 0x74, 0x2f, 0x73, 0x75, 0x62, 0x63, 0x6d, 0x64, 0x2f, 0x6c, 0x6f, 0x6f, 0x70,
-	0x33, 0x2f, 0x70, 0x62, 0x2f, 0x6c, 0x6f, 0x6f, 0x70, 0x33, 0x5f, 0x70, 0x62, 0x62, 0x06, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x78, 0x4a, 0x69, 0x74, 0x74, 0x65, 0x72, 0x12, 0x20, 0x0a, 0x0b, 0x6f, 0x6e, 0x65, 0x57, 0x61,
+	0x79, 0x44, 0x65, 0x6c, 0x61, 0x79, 0x18, 0x13, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x6f, 0x6e,
+	0x65, 0x57, 0x61, 0x79, 0x44, 0x65, 0x6c, 0x61, 0x79, 0x12, 0x24, 0x0a, 0x0d, 0x62, 0x75, 0x72,
+	0x73, 0x74, 0x4f, 0x6e, 0x4d, 0x69, 0x6c, 0x6c, 0x69, 0x73, 0x18, 0x14, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x0d, 0x62, 0x75, 0x72, 0x73, 0x74, 0x4f, 0x6e, 0x4d, 0x69, 0x6c, 0x6c, 0x69, 0x73, 0x12,
+	0x26, 0x0a, 0x0e, 0x62, 0x75, 0x72, 0x73, 0x74, 0x4f, 0x66, 0x66, 0x4d, 0x69, 0x6c, 0x6c, 0x69,
+	0x73, 0x18, 0x15, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0e, 0x62, 0x75, 0x72, 0x73, 0x74, 0x4f, 0x66,
+	0x66, 0x4d, 0x69, 0x6c, 0x6c, 0x69, 0x73, 0x12, 0x2c, 0x0a, 0x11, 0x74, 0x61, 0x72, 0x67, 0x65,
+	0x74, 0x42, 0x79, 0x74, 0x65, 0x73, 0x50, 0x65, 0x72, 0x53, 0x65, 0x63, 0x18, 0x16, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x11, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x42, 0x79, 0x74, 0x65, 0x73, 0x50,
+	0x65, 0x72, 0x53, 0x65, 0x63, 0x12, 0x1e, 0x0a, 0x0a, 0x72, 0x65, 0x73, 0x75, 0x6d, 0x65, 0x46,
+	0x72, 0x6f, 0x6d, 0x18, 0x17, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x72, 0x65, 0x73, 0x75, 0x6d,
+	0x65, 0x46, 0x72, 0x6f, 0x6d, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x4d, 0x6f,
+	0x64, 0x65, 0x18, 0x18, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x4d,
+	0x6f, 0x64, 0x65, 0x12, 0x2c, 0x0a, 0x11, 0x6c, 0x61, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x52, 0x61,
+	0x74, 0x65, 0x50, 0x65, 0x72, 0x53, 0x65, 0x63, 0x18, 0x19, 0x20, 0x01, 0x28, 0x01, 0x52, 0x11,
+	0x6c, 0x61, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x52, 0x61, 0x74, 0x65, 0x50, 0x65, 0x72, 0x53, 0x65,
+	0x63, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x65, 0x65, 0x64, 0x18, 0x1a, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x04, 0x73, 0x65, 0x65, 0x64, 0x12, 0x1e, 0x0a, 0x0a, 0x66, 0x6c, 0x6f, 0x77, 0x57, 0x69, 0x6e,
+	0x64, 0x6f, 0x77, 0x18, 0x1b, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x66, 0x6c, 0x6f, 0x77, 0x57,
+	0x69, 0x6e, 0x64, 0x6f, 0x77, 0x12, 0x24, 0x0a, 0x0d, 0x76, 0x65, 0x72, 0x69, 0x66, 0x79, 0x57,
+	0x6f, 0x72, 0x6b, 0x65, 0x72, 0x73, 0x18, 0x1c, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x76, 0x65,
+	0x72, 0x69, 0x66, 0x79, 0x57, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x73, 0x12, 0x24, 0x0a, 0x0d, 0x73,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x18, 0x1d, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x0d, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72,
+	0x79, 0x12, 0x46, 0x0a, 0x0b, 0x61, 0x6e, 0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73,
+	0x18, 0x1e, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x7a, 0x69, 0x74, 0x69, 0x2e, 0x6c, 0x6f,
+	0x6f, 0x70, 0x33, 0x2e, 0x70, 0x62, 0x2e, 0x54, 0x65, 0x73, 0x74, 0x2e, 0x41, 0x6e, 0x6e, 0x6f,
+	0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0b, 0x61, 0x6e,
+	0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x74, 0x78, 0x4d,
+	0x61, 0x78, 0x42, 0x79, 0x74, 0x65, 0x73, 0x18, 0x1f, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x74,
+	0x78, 0x4d, 0x61, 0x78, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x72, 0x78, 0x4d,
+	0x61, 0x78, 0x42, 0x79, 0x74, 0x65, 0x73, 0x18, 0x20, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x72,
+	0x78, 0x4d, 0x61, 0x78, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x26, 0x0a, 0x0e, 0x63, 0x6f, 0x6e,
+	0x6e, 0x65, 0x63, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x18, 0x21, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x0e, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75,
+	0x74, 0x12, 0x24, 0x0a, 0x0d, 0x72, 0x78, 0x49, 0x64, 0x6c, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x6f,
+	0x75, 0x74, 0x18, 0x22, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x72, 0x78, 0x49, 0x64, 0x6c, 0x65,
+	0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x12, 0x28, 0x0a, 0x0f, 0x6d, 0x61, 0x78, 0x42, 0x6c,
+	0x6f, 0x63, 0x6b, 0x73, 0x50, 0x65, 0x72, 0x53, 0x65, 0x63, 0x18, 0x23, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x0f, 0x6d, 0x61, 0x78, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x50, 0x65, 0x72, 0x53, 0x65,
+	0x63, 0x12, 0x30, 0x0a, 0x13, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x44, 0x69, 0x73, 0x74,
+	0x72, 0x69, 0x62, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x24, 0x20, 0x01, 0x28, 0x09, 0x52, 0x13,
+	0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x44, 0x69, 0x73, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x2a, 0x0a, 0x10, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x4d, 0x65,
+	0x61, 0x6e, 0x42, 0x79, 0x74, 0x65, 0x73, 0x18, 0x25, 0x20, 0x01, 0x28, 0x01, 0x52, 0x10, 0x70,
+	0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x4d, 0x65, 0x61, 0x6e, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12,
+	0x2e, 0x0a, 0x12, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x53, 0x74, 0x64, 0x64, 0x65, 0x76,
+	0x42, 0x79, 0x74, 0x65, 0x73, 0x18, 0x26, 0x20, 0x01, 0x28, 0x01, 0x52, 0x12, 0x70, 0x61, 0x79,
+	0x6c, 0x6f, 0x61, 0x64, 0x53, 0x74, 0x64, 0x64, 0x65, 0x76, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12,
+	0x2e, 0x0a, 0x12, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x50, 0x61, 0x72, 0x65, 0x74, 0x6f,
+	0x41, 0x6c, 0x70, 0x68, 0x61, 0x18, 0x27, 0x20, 0x01, 0x28, 0x01, 0x52, 0x12, 0x70, 0x61, 0x79,
+	0x6c, 0x6f, 0x61, 0x64, 0x50, 0x61, 0x72, 0x65, 0x74, 0x6f, 0x41, 0x6c, 0x70, 0x68, 0x61, 0x12,
+	0x22, 0x0a, 0x0c, 0x64, 0x72, 0x61, 0x69, 0x6e, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x18,
+	0x28, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0c, 0x64, 0x72, 0x61, 0x69, 0x6e, 0x54, 0x69, 0x6d, 0x65,
+	0x6f, 0x75, 0x74, 0x1a, 0x3e, 0x0a, 0x10, 0x41, 0x6e, 0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a,
+	0x02, 0x38, 0x01, 0x22, 0xd8, 0x04, 0x0a, 0x06, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x18,
+	0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x78, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x07, 0x74, 0x78, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x18, 0x0a, 0x07,
+	0x72, 0x78, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x72,
+	0x78, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x78, 0x42, 0x79, 0x74, 0x65,
+	0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x74, 0x78, 0x42, 0x79, 0x74, 0x65, 0x73,
+	0x12, 0x18, 0x0a, 0x07, 0x72, 0x78, 0x42, 0x79, 0x74, 0x65, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x07, 0x72, 0x78, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x24, 0x0a, 0x0d, 0x64, 0x75,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x0d, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x4e, 0x61, 0x6e, 0x6f, 0x73,
+	0x12, 0x30, 0x0a, 0x07, 0x6c, 0x61, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x16, 0x2e, 0x7a, 0x69, 0x74, 0x69, 0x2e, 0x6c, 0x6f, 0x6f, 0x70, 0x33, 0x2e, 0x70,
+	0x62, 0x2e, 0x4c, 0x61, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x52, 0x07, 0x6c, 0x61, 0x74, 0x65, 0x6e,
+	0x63, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x78, 0x4c, 0x6f, 0x73, 0x74, 0x18, 0x09, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x06, 0x74, 0x78, 0x4c, 0x6f, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x78,
+	0x4c, 0x6f, 0x73, 0x74, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x72, 0x78, 0x4c, 0x6f,
+	0x73, 0x74, 0x12, 0x26, 0x0a, 0x0e, 0x6c, 0x61, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x44, 0x72, 0x6f,
+	0x70, 0x70, 0x65, 0x64, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0e, 0x6c, 0x61, 0x74, 0x65,
+	0x6e, 0x63, 0x79, 0x44, 0x72, 0x6f, 0x70, 0x70, 0x65, 0x64, 0x12, 0x20, 0x0a, 0x0b, 0x74, 0x78,
+	0x57, 0x69, 0x72, 0x65, 0x42, 0x79, 0x74, 0x65, 0x73, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x0b, 0x74, 0x78, 0x57, 0x69, 0x72, 0x65, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x20, 0x0a, 0x0b,
+	0x72, 0x78, 0x57, 0x69, 0x72, 0x65, 0x42, 0x79, 0x74, 0x65, 0x73, 0x18, 0x0d, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x0b, 0x72, 0x78, 0x57, 0x69, 0x72, 0x65, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x1c,
+	0x0a, 0x09, 0x74, 0x78, 0x52, 0x65, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x0e, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x09, 0x74, 0x78, 0x52, 0x65, 0x74, 0x72, 0x69, 0x65, 0x73, 0x12, 0x16, 0x0a, 0x06,
+	0x73, 0x6e, 0x64, 0x42, 0x75, 0x66, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x73, 0x6e,
+	0x64, 0x42, 0x75, 0x66, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x63, 0x76, 0x42, 0x75, 0x66, 0x18, 0x10,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x72, 0x63, 0x76, 0x42, 0x75, 0x66, 0x12, 0x2c, 0x0a, 0x11,
+	0x74, 0x78, 0x4d, 0x61, 0x78, 0x42, 0x79, 0x74, 0x65, 0x73, 0x52, 0x65, 0x61, 0x63, 0x68, 0x65,
+	0x64, 0x18, 0x11, 0x20, 0x01, 0x28, 0x08, 0x52, 0x11, 0x74, 0x78, 0x4d, 0x61, 0x78, 0x42, 0x79,
+	0x74, 0x65, 0x73, 0x52, 0x65, 0x61, 0x63, 0x68, 0x65, 0x64, 0x12, 0x24, 0x0a, 0x0d, 0x74, 0x78,
+	0x52, 0x61, 0x74, 0x65, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x65, 0x64, 0x18, 0x12, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x0d, 0x74, 0x78, 0x52, 0x61, 0x74, 0x65, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x65, 0x64,
+	0x12, 0x1c, 0x0a, 0x09, 0x63, 0x6f, 0x72, 0x72, 0x75, 0x70, 0x74, 0x65, 0x64, 0x18, 0x13, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x09, 0x63, 0x6f, 0x72, 0x72, 0x75, 0x70, 0x74, 0x65, 0x64, 0x22, 0xc7,
+	0x01, 0x0a, 0x07, 0x4c, 0x61, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f,
+	0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74,
+	0x12, 0x1a, 0x0a, 0x08, 0x6d, 0x69, 0x6e, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x08, 0x6d, 0x69, 0x6e, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x12, 0x1a, 0x0a, 0x08,
+	0x61, 0x76, 0x67, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08,
+	0x61, 0x76, 0x67, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x6d, 0x61, 0x78, 0x4e,
+	0x61, 0x6e, 0x6f, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x6d, 0x61, 0x78, 0x4e,
+	0x61, 0x6e, 0x6f, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x35, 0x30, 0x4e, 0x61, 0x6e, 0x6f, 0x73,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x70, 0x35, 0x30, 0x4e, 0x61, 0x6e, 0x6f, 0x73,
+	0x12, 0x1a, 0x0a, 0x08, 0x70, 0x39, 0x30, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x08, 0x70, 0x39, 0x30, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x12, 0x1a, 0x0a, 0x08,
+	0x70, 0x39, 0x39, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08,
+	0x70, 0x39, 0x39, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x22, 0x4a, 0x0a, 0x08, 0x4d, 0x65, 0x74, 0x61,
+	0x64, 0x61, 0x74, 0x61, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f,
+	0x73, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x12, 0x14,
+	0x0a, 0x05, 0x72, 0x75, 0x6e, 0x49, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x72,
+	0x75, 0x6e, 0x49, 0x64, 0x42, 0x44, 0x5a, 0x42, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63,
+	0x6f, 0x6d, 0x2f, 0x6f, 0x70, 0x65, 0x6e, 0x7a, 0x69, 0x74, 0x69, 0x2f, 0x7a, 0x69, 0x74, 0x69,
+	0x2f, 0x7a, 0x69, 0x74, 0x69, 0x2d, 0x66, 0x61, 0x62, 0x72, 0x69, 0x63, 0x2d, 0x74, 0x65, 0x73,
+	0x74, 0x2f, 0x73, 0x75, 0x62, 0x63, 0x6d, 0x64, 0x2f, 0x6c, 0x6f, 0x6f, 0x70, 0x33, 0x2f, 0x70,
+	0x62, 0x2f, 0x6c, 0x6f, 0x6f, 0x70, 0x33, 0x5f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x33,
 }
 
 var (
@@ -266,16 +905,22 @@ func file_loop3_proto_rawDescGZIP() []byte {
 	return file_loop3_proto_rawDescData
 }
 
-var file_loop3_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_loop3_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_loop3_proto_goTypes = []interface{}{
-	(*Test)(nil), // 0: ziti.loop3.pb.Test
+	(*Test)(nil),     // 0: ziti.loop3.pb.Test
+	(*Result)(nil),   // 1: ziti.loop3.pb.Result
+	(*Latency)(nil),  // 2: ziti.loop3.pb.Latency
+	(*Metadata)(nil), // 3: ziti.loop3.pb.Metadata
+	nil,              // 4: ziti.loop3.pb.Test.AnnotationsEntry
 }
 var file_loop3_proto_depIdxs = []int32{
-	0, // [0:0] is the sub-list for method output_type
-	0, // [0:0] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	4, // 0: ziti.loop3.pb.Test.annotations:type_name -> ziti.loop3.pb.Test.AnnotationsEntry
+	2, // 1: ziti.loop3.pb.Result.latency:type_name -> ziti.loop3.pb.Latency
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_loop3_proto_init() }
@@ -296,6 +941,42 @@ func file_loop3_proto_init() {
 				return nil
 			}
 		}
+		file_loop3_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Result); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_loop3_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Latency); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_loop3_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Metadata); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -303,7 +984,7 @@ func file_loop3_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_loop3_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   1,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -20,3 +20,30 @@ func (test *Test) IsTxRandomHashed() bool {
 func (test *Test) IsTxSequential() bool {
 	return test.TxBlockType == BlockTypeSequential
 }
+
+// ConnectTimeoutMillis returns how many milliseconds to wait for the first block, ConnectTimeout or else RxTimeout
+func (test *Test) ConnectTimeoutMillis() int32 {
+	if test.ConnectTimeout > 0 {
+		return test.ConnectTimeout
+	}
+	return test.RxTimeout
+}
+
+// RxIdleTimeoutMillis returns how many milliseconds may pass between blocks once the first has arrived, RxIdleTimeout
+// or else RxTimeout
+func (test *Test) RxIdleTimeoutMillis() int32 {
+	if test.RxIdleTimeout > 0 {
+		return test.RxIdleTimeout
+	}
+	return test.RxTimeout
+}
+
+// IsProber returns true for the side of a probe mode test which sends latency probes
+func (test *Test) IsProber() bool {
+	return test.ProbeMode && test.LatencyFrequency > 0
+}
+
+// IsProbeEcho returns true for the side of a probe mode test which answers latency probes
+func (test *Test) IsProbeEcho() bool {
+	return test.ProbeMode && test.LatencyFrequency == 0
+}
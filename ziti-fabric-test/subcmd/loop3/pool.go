@@ -0,0 +1,98 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"io"
+	"time"
+
+	"github.com/michaelquigley/pfxlog"
+)
+
+// connPool keeps connections open between tests, so short tests measure steady state throughput rather than
+// connection setup. A connection is only returned to the pool after a test which ended cleanly, leaving the
+// connection ready for the next test exchange; anything else is closed and replaced by a new dial when needed.
+type connPool struct {
+	dialer Dialer
+	idle   chan io.ReadWriteCloser
+}
+
+// newConnPool returns a pool holding up to size connections, dialing all of them up front
+func newConnPool(dialer Dialer, size int) (*connPool, error) {
+	pool := &connPool{
+		dialer: dialer,
+		idle:   make(chan io.ReadWriteCloser, size),
+	}
+
+	for i := 0; i < size; i++ {
+		conn, err := pool.dial()
+		if err != nil {
+			pool.close()
+			return nil, err
+		}
+		pool.idle <- conn
+	}
+
+	return pool, nil
+}
+
+func (pool *connPool) dial() (io.ReadWriteCloser, error) {
+	start := time.Now()
+	conn, err := pool.dialer.Dial()
+	if err != nil {
+		return nil, err
+	}
+	ConnectionTime.Update(time.Now().Sub(start))
+	return conn, nil
+}
+
+// get returns an idle connection, or dials a new one if there are none
+func (pool *connPool) get() (io.ReadWriteCloser, error) {
+	select {
+	case conn := <-pool.idle:
+		return conn, nil
+	default:
+		return pool.dial()
+	}
+}
+
+// put returns conn to the pool if it can be reused and there's room for it, otherwise it's closed
+func (pool *connPool) put(conn io.ReadWriteCloser, reusable bool) {
+	if reusable {
+		select {
+		case pool.idle <- conn:
+			return
+		default:
+		}
+	}
+
+	if err := conn.Close(); err != nil {
+		pfxlog.Logger().WithError(err).Debug("error closing pooled connection")
+	}
+}
+
+// close closes all idle connections
+func (pool *connPool) close() {
+	for {
+		select {
+		case conn := <-pool.idle:
+			_ = conn.Close()
+		default:
+			return
+		}
+	}
+}
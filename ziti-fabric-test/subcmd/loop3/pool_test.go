@@ -0,0 +1,133 @@
+package loop3
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type countingDialer struct {
+	Dialer
+	dials int32
+}
+
+func (d *countingDialer) Dial() (io.ReadWriteCloser, error) {
+	atomic.AddInt32(&d.dials, 1)
+	return d.Dialer.Dial()
+}
+
+func Test_ConnPoolReusesConnections(t *testing.T) {
+	req := require.New(t)
+
+	listener := &listenerCmd{}
+	dialer := &countingDialer{Dialer: NewPipeDialer(func(conn net.Conn) {
+		listener.handle(conn, "pool")
+	})}
+
+	pool, err := newConnPool(dialer, 1)
+	req.NoError(err)
+	defer pool.close()
+	req.Equal(int32(1), atomic.LoadInt32(&dialer.dials))
+
+	for i := 0; i < 3; i++ {
+		conn, err := pool.get()
+		req.NoError(err)
+
+		local := newLoopbackTest("pooled")
+		result, err := runLoopbackTest(conn, local, loopbackPeerTest(local))
+		req.NoError(err)
+		req.True(result.Success, result.Message)
+
+		pool.put(conn, true)
+	}
+
+	req.Equal(int32(1), atomic.LoadInt32(&dialer.dials), "the pooled connection should have been reused")
+
+	// a connection which can't be reused is replaced with a new one
+	conn, err := pool.get()
+	req.NoError(err)
+	pool.put(conn, false)
+
+	conn, err = pool.get()
+	req.NoError(err)
+	req.Equal(int32(2), atomic.LoadInt32(&dialer.dials))
+	pool.put(conn, true)
+}
+
+// closeCountingConn counts how many of the connections it was dialed with have been closed
+type closeCountingConn struct {
+	io.ReadWriteCloser
+	closed *int32
+}
+
+func (c *closeCountingConn) Close() error {
+	atomic.AddInt32(c.closed, 1)
+	return c.ReadWriteCloser.Close()
+}
+
+type closeCountingDialer struct {
+	Dialer
+	closed int32
+}
+
+func (d *closeCountingDialer) Dial() (io.ReadWriteCloser, error) {
+	conn, err := d.Dialer.Dial()
+	if err != nil {
+		return nil, err
+	}
+	return &closeCountingConn{ReadWriteCloser: conn, closed: &d.closed}, nil
+}
+
+func Test_DialWorkloadClosesConnectionsOnFailure(t *testing.T) {
+	req := require.New(t)
+
+	counting := &closeCountingDialer{Dialer: NewPipeDialer(echo)}
+	conns, dialTimes, err := (&dialerCmd{}).dialWorkload(counting, nil, 3)
+	req.NoError(err)
+	req.Len(conns, 3)
+	req.Len(dialTimes, 3)
+	req.Zero(atomic.LoadInt32(&counting.closed))
+	for _, conn := range conns {
+		req.NoError(conn.Close())
+	}
+
+	// the third dial fails, so the two made before it are closed
+	counting.closed = 0
+	flaky := &failingAfterDialer{Dialer: counting, successes: 2}
+	_, _, err = (&dialerCmd{}).dialWorkload(newRetryingDialer(flaky, 0, 0), nil, 3)
+	req.Error(err)
+	req.Equal(exitSetup, exitCodeOf(err))
+	req.Equal(int32(2), atomic.LoadInt32(&counting.closed))
+}
+
+func Test_DialWorkloadReturnsPooledConnectionsOnFailure(t *testing.T) {
+	req := require.New(t)
+
+	flaky := &failingAfterDialer{Dialer: NewPipeDialer(echo), successes: 1}
+	pool, err := newConnPool(flaky, 1)
+	req.NoError(err)
+	defer pool.close()
+
+	// the pooled connection is checked out, then the dial for the second fails
+	_, _, err = (&dialerCmd{}).dialWorkload(flaky, pool, 2)
+	req.Error(err)
+	req.Len(pool.idle, 1, "the pooled connection is back in the pool")
+}
+
+// failingAfterDialer fails every dial after its first successes
+type failingAfterDialer struct {
+	Dialer
+	successes int32
+	dials     int32
+}
+
+func (d *failingAfterDialer) Dial() (io.ReadWriteCloser, error) {
+	if atomic.AddInt32(&d.dials, 1) > d.successes {
+		return nil, errors.New("connection refused")
+	}
+	return d.Dialer.Dial()
+}
@@ -0,0 +1,158 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+)
+
+const (
+	// probePayloadBytes is the payload size of every block in probe mode
+	probePayloadBytes = 16
+
+	// defaultProbeInterval is how often probes are sent when the prober has no tx pacing set
+	defaultProbeInterval = time.Second
+)
+
+// applyProbeMode turns a pair of tests into a latency probe: prober sends a small latency request at a steady rate
+// and echo answers each one with a latency response
+func applyProbeMode(prober, echo *loop3_pb.Test) {
+	for _, test := range []*loop3_pb.Test{prober, echo} {
+		test.ProbeMode = true
+		test.TxBlockType = loop3_pb.BlockTypeRandomHashed
+		test.RxBlockType = loop3_pb.BlockTypeRandomHashed
+		test.PayloadMinBytes = probePayloadBytes
+		test.PayloadMaxBytes = probePayloadBytes
+		test.OneWayDelay = false
+		test.BurstOnMillis = 0
+		test.BurstOffMillis = 0
+		test.TargetBytesPerSec = 0
+	}
+
+	prober.LatencyFrequency = 1
+	prober.LatencyRatePerSec = 0
+	prober.RxRequests = prober.TxRequests
+	if d, err := time.ParseDuration(prober.TxPacing); err != nil || d == 0 {
+		prober.TxPacing = defaultProbeInterval.String()
+	}
+
+	echo.LatencyFrequency = 0
+	echo.LatencyRatePerSec = 0
+	echo.TxRequests = prober.TxRequests
+	echo.RxRequests = prober.TxRequests
+	echo.TxPacing = "0s"
+	echo.TxMaxJitter = "0s"
+	echo.TxPauseEvery = "0s"
+	echo.TxPauseFor = "0s"
+}
+
+// probeTimeout returns how many milliseconds the echo side waits for the next probe. The first may take as long to
+// arrive as the first block of any test
+func (p *protocol) probeTimeout() int32 {
+	if p.stats.RxCount() == 0 {
+		return p.test.ConnectTimeoutMillis()
+	}
+	return p.test.RxIdleTimeoutMillis()
+}
+
+// probeStats collects the round trip times of answered latency requests
+type probeStats struct {
+	lock    sync.Mutex
+	samples []latencySample
+}
+
+func (stats *probeStats) record(sequence uint32, sentAt time.Time, rtt time.Duration) {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+	stats.samples = append(stats.samples, latencySample{sequence: sequence, sentAt: sentAt, rtt: rtt})
+}
+
+// latencyStats returns the distribution of the round trip times recorded so far
+func (stats *probeStats) latencyStats() LatencyStats {
+	stats.lock.Lock()
+	rtts := make([]time.Duration, 0, len(stats.samples))
+	for _, sample := range stats.samples {
+		rtts = append(rtts, sample.rtt)
+	}
+	stats.lock.Unlock()
+
+	latency := LatencyStats{Count: int32(len(rtts))}
+	if len(rtts) == 0 {
+		return latency
+	}
+
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	var total time.Duration
+	for _, rtt := range rtts {
+		total += rtt
+	}
+	percentile := func(p float64) time.Duration {
+		return rtts[int(p*float64(len(rtts)-1))]
+	}
+
+	latency.Min = rtts[0]
+	latency.Max = rtts[len(rtts)-1]
+	latency.Avg = total / time.Duration(len(rtts))
+	latency.P50 = percentile(0.5)
+	latency.P90 = percentile(0.9)
+	latency.P99 = percentile(0.99)
+	return latency
+}
+
+// summary returns the RTT distribution of the probes answered so far, out of sent
+func (stats *probeStats) summary(sent int32) probeSummary {
+	latency := stats.latencyStats()
+	return probeSummary{
+		sent:   sent,
+		echoed: latency.Count,
+		min:    latency.Min,
+		avg:    latency.Avg,
+		max:    latency.Max,
+		p50:    latency.P50,
+		p90:    latency.P90,
+		p99:    latency.P99,
+	}
+}
+
+type probeSummary struct {
+	sent   int32
+	echoed int32
+	min    time.Duration
+	avg    time.Duration
+	max    time.Duration
+	p50    time.Duration
+	p90    time.Duration
+	p99    time.Duration
+}
+
+// loss returns the percentage of probes sent which weren't echoed
+func (summary probeSummary) loss() float64 {
+	if summary.sent == 0 {
+		return 0
+	}
+	return 100 * float64(summary.sent-summary.echoed) / float64(summary.sent)
+}
+
+func (summary probeSummary) String() string {
+	return fmt.Sprintf("%d probes sent, %d echoed, %.1f%% loss. rtt min/avg/max = %v/%v/%v, p50/p90/p99 = %v/%v/%v",
+		summary.sent, summary.echoed, summary.loss(), summary.min, summary.avg, summary.max, summary.p50, summary.p90, summary.p99)
+}
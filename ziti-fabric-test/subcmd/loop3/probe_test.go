@@ -0,0 +1,59 @@
+package loop3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ProbeSummary(t *testing.T) {
+	req := require.New(t)
+
+	stats := &probeStats{}
+	for i := 10; i >= 1; i-- {
+		stats.record(uint32(i), time.Now(), time.Duration(i)*time.Millisecond)
+	}
+
+	summary := stats.summary(20)
+	req.Equal(int32(10), summary.echoed)
+	req.Equal(50.0, summary.loss())
+	req.Equal(time.Millisecond, summary.min)
+	req.Equal(10*time.Millisecond, summary.max)
+	req.Equal(5500*time.Microsecond, summary.avg)
+	req.Equal(5*time.Millisecond, summary.p50)
+	req.Equal(9*time.Millisecond, summary.p90)
+
+	empty := (&probeStats{}).summary(0)
+	req.Equal(0.0, empty.loss())
+}
+
+func Test_ProbeModeWorkload(t *testing.T) {
+	req := require.New(t)
+
+	workload := &Workload{
+		Name:      "probe",
+		ProbeMode: true,
+		Dialer: Test{
+			TxRequests:      20,
+			TxPacing:        time.Millisecond,
+			RxTimeout:       5000,
+			PayloadMinBytes: 1024,
+			PayloadMaxBytes: 1024 * 1024,
+		},
+		Listener: Test{TxRequests: 1000, RxTimeout: 5000},
+	}
+
+	local, remote := workload.GetTests()
+	req.True(local.IsProber())
+	req.True(remote.IsProbeEcho())
+	req.Equal(int32(probePayloadBytes), local.PayloadMaxBytes)
+	req.Equal(local.TxRequests, local.RxRequests)
+	req.Equal(local.TxRequests, remote.TxRequests)
+
+	before := MsgLatency.Count()
+	result, err := RunLoopback(local)
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+	req.Equal(before+20, MsgLatency.Count(), "every probe should be echoed")
+}
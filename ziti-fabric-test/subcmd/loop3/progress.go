@@ -0,0 +1,126 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// generatorBoundRatio is the fraction of sends which had to wait on the generator before a test is considered
+// generator-bound
+const generatorBoundRatio = 0.5
+
+// progressSnapshot is the generator, txer and rxer progress at a point in time
+type progressSnapshot struct {
+	at             time.Time
+	generated      int64
+	generatingTime time.Duration
+	sent           int32
+	generatorWaits int32
+	received       int32
+	txBytes        int64
+	rxBytes        int64
+	latency        LatencyStats
+}
+
+func (p *protocol) progress() progressSnapshot {
+	stats := p.stats.Snapshot()
+	snapshot := progressSnapshot{
+		at:             time.Now(),
+		sent:           stats.TxCount,
+		generatorWaits: atomic.LoadInt32(&p.txGeneratorWaits),
+		received:       stats.RxCount,
+		txBytes:        stats.TxBytes,
+		rxBytes:        stats.RxBytes,
+		latency:        stats.Latency,
+	}
+	if p.generator != nil {
+		snapshot.generated, snapshot.generatingTime = p.generator.snapshot()
+	}
+	return snapshot
+}
+
+// generatorBound returns true if the txer frequently found no block ready between last and current
+func (current progressSnapshot) generatorBound(last progressSnapshot) bool {
+	sent := current.sent - last.sent
+	waits := current.generatorWaits - last.generatorWaits
+	return sent > 0 && float64(waits)/float64(sent) > generatorBoundRatio
+}
+
+// reportProgress logs what the generator produced and the txer sent every interval, so low throughput can be
+// attributed to either payload generation or the transport. With --ndjson each report is also written as an event,
+// and with --influx-progress as an influx point
+func (p *protocol) reportProgress(start time.Time, interval time.Duration, done <-chan struct{}) {
+	log := p.logger()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// an unbuffered queue is always empty, so there's nothing to sample
+	var queue *genQueueSampler
+	var sampleTicks <-chan time.Time
+	if depth := cap(p.blocks); depth > 0 {
+		queue = newGenQueueSampler(depth)
+		sampleTicker := time.NewTicker(genQueueSampleInterval)
+		defer sampleTicker.Stop()
+		sampleTicks = sampleTicker.C
+	}
+
+	last := p.progress()
+	for {
+		select {
+		case <-sampleTicks:
+			queue.sample(len(p.blocks))
+		case <-ticker.C:
+			current := p.progress()
+			log.Infof("progress: generated %d (+%d, %v generating), sent %d (+%d), waited on generator %d (+%d)",
+				current.generated, current.generated-last.generated, current.generatingTime-last.generatingTime,
+				current.sent, current.sent-last.sent,
+				current.generatorWaits, current.generatorWaits-last.generatorWaits)
+
+			// a paced source holds blocks back on purpose, so the txer waiting on it is expected
+			if !p.pacedBySource && current.generatorBound(last) {
+				log.Warnf("generator-bound: no block was ready for %d of the last %d sends, payload generation is limiting throughput",
+					current.generatorWaits-last.generatorWaits, current.sent-last.sent)
+			}
+			var queueStats *GenQueueStats
+			if queue != nil {
+				if queueStats = queue.stats(); queueStats != nil {
+					msg := "generator queue: avg %.1f, min %d of %d"
+					if bound := queueStats.bound(); bound != "" {
+						msg += ", " + bound
+					}
+					log.Infof(msg, queueStats.Avg, queueStats.Min, queueStats.Depth)
+				}
+			}
+			if p.options.events != nil || (p.options.influx != nil && p.options.influxProgress) {
+				event := p.progressEvent(start, last, current)
+				event.GenQueue = queueStats
+				if p.options.events != nil {
+					p.options.events.write(event)
+				}
+				if p.options.influx != nil && p.options.influxProgress {
+					p.options.influx.write(p.progressPoint(event))
+				}
+			}
+			last = current
+		case <-done:
+			return
+		}
+	}
+}
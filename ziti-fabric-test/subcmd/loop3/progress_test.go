@@ -0,0 +1,35 @@
+package loop3
+
+import (
+	"testing"
+	"time"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GeneratorStats(t *testing.T) {
+	req := require.New(t)
+
+	p := &protocol{test: &loop3_pb.Test{Name: "test"}, blocks: make(chan Block), stopped: make(chan struct{})}
+	stats := &generatorStats{}
+	go p.generate(newSeqGenerator(5, uniformSizes(16, 16), 0), stats)
+	for i := 0; i < 5; i++ {
+		<-p.blocks
+	}
+	_, open := <-p.blocks
+	req.False(open, "blocks should be closed once the source runs out")
+
+	generated, generating := stats.snapshot()
+	req.Equal(int64(5), generated)
+	req.True(generating > 0)
+}
+
+func Test_GeneratorBound(t *testing.T) {
+	req := require.New(t)
+
+	last := progressSnapshot{sent: 100, generatorWaits: 10}
+	req.False(progressSnapshot{sent: 200, generatorWaits: 20}.generatorBound(last))
+	req.True(progressSnapshot{sent: 200, generatorWaits: 90}.generatorBound(last))
+	req.False(progressSnapshot{sent: 100, generatorWaits: 10, generatingTime: time.Second}.generatorBound(last))
+}
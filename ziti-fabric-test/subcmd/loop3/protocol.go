@@ -17,16 +17,17 @@
 package loop3
 
 import (
-	"bytes"
 	"encoding/binary"
-	"fmt"
-	"google.golang.org/protobuf/proto"
 	"github.com/michaelquigley/pfxlog"
 	"github.com/openziti/foundation/v2/info"
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/framing"
 	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
 	"io"
 	"math/rand"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -44,45 +45,232 @@ type protocol struct {
 	rxPauseEvery time.Duration
 	rxPauseFor   time.Duration
 	peer         io.ReadWriteCloser
+	wire         *countingConn
+	framing      *framing.Framing
 	rxBlocks     chan Block
-	txCount      int32
-	rxCount      int32
+	stats        *StatsAccumulator
 	lastRx       int64
 	latencies    chan *time.Time
 	errors       chan error
+	stopped      chan struct{}
+	stopOnce     sync.Once
+	cancelOnce   sync.Once
+	options      protocolOptions
+	connIndex    int
+	// repeat is which --repeat run the test is part of, counting from 1, or 0 when the scenario is only run once
+	repeat int
+
+	generator        *generatorStats
+	pacedBySource    bool
+	txGeneratorWaits int32
+	pacing           *pacingRecorder
+	sndBuf           int32
+	rcvBuf           int32
+	txCorrupted      int32
+	clockOffset      time.Duration
+	// dialTime and handshakeTime are how long the dialer took to open the connection and exchange the test with the
+	// listener, see Result.Setup
+	dialTime      time.Duration
+	handshakeTime time.Duration
+	// txMaxBytesReached is set by the txer when it stops at the test's TxMaxBytes
+	txMaxBytesReached bool
+	// txRateLimited is how many blocks the txer held back to stay under the test's MaxBlocksPerSec
+	txRateLimited int32
+
+	// txChecksum belongs to the txer and rxChecksum and rxSummary to the rxer, until it's done. See streamChecksum
+	txChecksum streamChecksum
+	rxChecksum streamChecksum
+	rxSummary  *streamChecksum
+
+	// rxDrained belongs to the rxer as well, and rxerExited is set once it stops reading. See drain
+	rxDrained  bool
+	rxerExited int32
+
+	// txLock serializes the block writes of the txer with the flow control grants sent by the verifier
+	txLock    sync.Mutex
+	txCredits *txCredits
+	rxGrants  *rxGrants
 }
 
 var MagicHeader = []byte{0xCA, 0xFE, 0xF0, 0x0D}
 
-func newProtocol(peer io.ReadWriteCloser) (*protocol, error) {
+// latencyQueueSize is how many received latency requests can wait for the txer to answer them
+const latencyQueueSize = 1024
+
+// DefaultMaxMessageBytes is the largest message body which will be read from a peer, unless configured otherwise
+const DefaultMaxMessageBytes = 64 * 1024 * 1024
+
+// protocolOptions are the protocol settings which can be configured by the dialer and listener commands
+type protocolOptions struct {
+	maxMessageBytes  int
+	progressInterval time.Duration
+	failFast         bool
+	txRetries        int
+
+	// latencyOutlierFactor is how many standard deviations above the mean a round trip has to be to be reported as
+	// an outlier, 0 to not report them
+	latencyOutlierFactor float64
+
+	// maxRxBytesPerSec caps the rate blocks are read at, it's only offered by the listener
+	maxRxBytesPerSec int64
+
+	// blockSource replaces the built-in generator for the test's tx block type. It isn't set by any flag
+	blockSource BlockSource
+
+	// ndjson writes progress and summary events to stdout, through events once startEvents has been called
+	ndjson bool
+	events *ndjsonWriter
+
+	// influxUrl pushes each test's result, and with influxProgress each progress report, to InfluxDB through influx
+	// once startInflux has been called. Failed writes only fail the dialer's run with influxRequired
+	influxUrl      string
+	influxProgress bool
+	influxRequired bool
+	influx         *influxWriter
+
+	// gomaxprocs and lockThreads reduce scheduler noise when benchmarking, see applyRuntime and lockThread
+	gomaxprocs  int
+	lockThreads bool
+
+	// pacingReport records the interval between block writes, see pacingRecorder
+	pacingReport bool
+
+	// bare talks to a plain echo server, it's only offered by the dialer. See bareTest
+	bare bool
+
+	// soSndbuf and soRcvbuf size the socket buffers of TCP peers, see applySocketBuffers
+	soSndbuf int
+	soRcvbuf int
+
+	// corruptRate is the fraction of blocks sent with a byte flipped after hashing, see corruptBlock
+	corruptRate float64
+	// tolerateCorruption counts received blocks which fail their hash check instead of failing the test, see verifyFailed
+	tolerateCorruption bool
+
+	// genQueueDepth is how many generated blocks may wait for the txer, see GenQueueStats
+	genQueueDepth int
+
+	// noVerify has the rxer drop received blocks instead of handing them to the verifier, which isn't started
+	noVerify bool
+
+	// summaryOnly only logs warnings, errors and summaries, which go to summaryLog, see startSummaryOnly
+	summaryOnly bool
+	summaryLog  *logrus.Logger
+}
+
+func (options *protocolOptions) addFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&options.maxMessageBytes, "max-message-bytes", DefaultMaxMessageBytes, "Largest message length accepted from the peer")
+	flags.DurationVar(&options.progressInterval, "progress-interval", 10*time.Second, "How often to report generator and tx progress, 0 to disable")
+	flags.BoolVar(&options.failFast, "fail-fast", true, "Stop a test at its first error. When false, verification errors are collected and all of them reported at the end")
+	flags.IntVar(&options.txRetries, "tx-retries", 0, "Retry a block write which fails with a transient network error, such as a timeout, up to this many times "+
+		"with exponential backoff. Closed connections and other errors still fail the test at once")
+	flags.BoolVar(&options.ndjson, "ndjson", false, "Write a JSON object to stdout at each --progress-interval and a summary when each test "+
+		"is done, one per line, for live dashboards. Logging goes to stderr")
+	flags.StringVar(&options.influxUrl, "influx", "", "Write each test's result to this InfluxDB write url in line protocol when it's done, "+
+		"tagged with the test name, connection and annotations, such as http://influx:8086/write?db=loop3. A token in "+influxTokenEnv+" is sent with each write. "+
+		"Failed writes are logged without failing the test")
+	flags.BoolVar(&options.influxProgress, "influx-progress", false, "Also write each --progress-interval report to --influx")
+	flags.BoolVar(&options.influxRequired, "influx-required", false, "Fail the dialer's run with exit code 1 if any write to --influx fails")
+	flags.IntVar(&options.gomaxprocs, "gomaxprocs", 0, "Set GOMAXPROCS for more consistent benchmark runs. It applies to the whole "+
+		"process, SDK and fabric included, so setting it too low can make them the bottleneck. 0 leaves the Go default")
+	flags.BoolVar(&options.lockThreads, "lock-threads", false, "Lock each test's txer and rxer to their own OS thread, to keep "+
+		"them from being moved between threads mid run. Needs two threads per connection, so use with few connections")
+	flags.Float64Var(&options.latencyOutlierFactor, "latency-outlier-factor", defaultLatencyOutlierFactor, "Report round trips more than this many "+
+		"standard deviations above the mean latency as outliers, listing the slowest with their block sequence and when they were sent. 0 to disable")
+	flags.IntVar(&options.soSndbuf, "so-sndbuf", 0, "Set the socket send buffer to this many bytes when the peer is a TCP "+
+		"connection, for high bandwidth-delay links. 0 leaves the OS default")
+	flags.IntVar(&options.soRcvbuf, "so-rcvbuf", 0, "Set the socket receive buffer to this many bytes when the peer is a TCP "+
+		"connection. 0 leaves the OS default")
+	flags.BoolVar(&options.pacingReport, "pacing-report", false, "Time each block write and report how far the interval between "+
+		"them strayed from the --tx-pacing or bandwidth target, to tell a slow pacing config from OS or runtime scheduling delays")
+	flags.Float64Var(&options.corruptRate, "corrupt-rate", 0, "Flip a byte in this fraction of sent blocks, from 0 to 1, after they're "+
+		"hashed, to check the peer's verifier catches it. Run the peer with --tolerate-corruption to count the mismatches")
+	flags.BoolVar(&options.tolerateCorruption, "tolerate-corruption", false, "Count received blocks which fail their hash check and carry on, "+
+		"instead of failing the test at the first, to measure a lossy transport's error rate. The result reports how many blocks were "+
+		"corrupted and what fraction of those received they were. Sequence errors still fail the test")
+	flags.IntVar(&options.genQueueDepth, "gen-queue-depth", 0, "Let the generator get this many blocks ahead of the txer. "+
+		"When more than 0, progress reports include how full the queue was, to tell generation-bound from transport-bound tests")
+	flags.BoolVar(&options.noVerify, "no-verify", false, "Count received blocks and drop them without verifying them, for pure "+
+		"throughput tests. Corrupted, reordered and duplicated blocks are NOT detected, and there's no rx timeout. Lost blocks still show up in the counts")
+	flags.BoolVar(&options.summaryOnly, "summary-only", false, "Only log warnings, errors and the summary of each test and of "+
+		"the run, leaving out the per-block logging and progress reports. With --ndjson only the summary events are written")
+}
+
+func newProtocol(peer io.ReadWriteCloser, options *protocolOptions) (*protocol, error) {
+	wire := newCountingConn(peer)
 	p := &protocol{
 		rxSequence: 0,
-		peer:       peer,
+		peer:       wire,
+		wire:       wire,
 		rxBlocks:   make(chan Block),
-		txCount:    0,
-		rxCount:    0,
-		latencies:  make(chan *time.Time, 1024),
+		stats:      &StatsAccumulator{},
+		latencies:  make(chan *time.Time, latencyQueueSize),
 		errors:     make(chan error, 10240),
+		stopped:    make(chan struct{}),
+	}
+	if options != nil {
+		p.options = *options
+	} else {
+		p.options.failFast = true
+	}
+	if p.options.summaryLog == nil {
+		p.options.summaryLog = p.options.newSummaryLog()
 	}
+	if p.options.genQueueDepth < 0 {
+		return nil, errors.Errorf("invalid generator queue depth %d, must not be negative", p.options.genQueueDepth)
+	}
+	p.framing = framing.New(wire, p.options.magicHeader(), binary.LittleEndian, p.maxMessageBytes())
 	return p, nil
 }
 
-func (p *protocol) run(test *loop3_pb.Test) error {
+func (p *protocol) maxMessageBytes() int {
+	if p.options.maxMessageBytes > 0 {
+		return p.options.maxMessageBytes
+	}
+	return DefaultMaxMessageBytes
+}
+
+// run runs this side of test, returning what it sent and received along with the first error, if any. The result is
+// returned even when the test fails, covering what happened up to the failure
+func (p *protocol) run(test *loop3_pb.Test) (*Result, error) {
 	p.test = test
+	start := time.Now()
 
 	var rxBlock func() (Block, error)
 
-	if test.IsTxRandomHashed() {
-		txGenerator := newRandomHashedBlockGenerator(int(test.TxRequests), int(test.PayloadMinBytes), int(test.PayloadMaxBytes), int(test.LatencyFrequency))
-		p.blocks = txGenerator.blocks
-		go txGenerator.run()
-	} else if test.IsTxSequential() {
-		txGenerator := newSeqGenerator(int(test.TxRequests), int(test.PayloadMinBytes), int(test.PayloadMaxBytes))
-		p.blocks = txGenerator.blocks
-		go txGenerator.run()
-	} else {
-		panic(errors.Errorf("unknown tx block type %v", test.TxBlockType))
+	if err := p.applySocketBuffers(); err != nil {
+		return p.result(start, err), err
+	}
+
+	if test.ResumeFrom > 0 {
+		// sequential blocks are verified byte by byte, with random block sizes, so there's no way to know where
+		// a given block count leaves the byte sequence
+		if !test.IsTxRandomHashed() || !test.IsRxRandomHashed() {
+			err := errors.Errorf("resuming is only supported for random hashed blocks")
+			return p.result(start, err), err
+		}
+		p.stats.resumeFrom(test.ResumeFrom)
+		p.rxSequence = uint64(test.ResumeFrom)
+		p.logger().Infof("resuming from sequence %d", test.ResumeFrom)
+	}
+
+	source := p.options.blockSource
+	if source == nil {
+		if test.IsTxRandomHashed() {
+			txGenerator := newRandomHashedBlockGenerator(int(test.TxRequests), payloadSizesOf(test), txLatencyFrequency(test), test.OneWayDelay, test.Seed)
+			txGenerator.next = int(test.ResumeFrom)
+			txGenerator.probe = test.ProbeMode
+			source = txGenerator
+		} else if test.IsTxSequential() {
+			source = newSeqGenerator(int(test.TxRequests), payloadSizesOf(test), test.Seed)
+		} else {
+			panic(errors.Errorf("unknown tx block type %v", test.TxBlockType))
+		}
 	}
+	_, p.pacedBySource = source.(pacedSource)
+	p.blocks = make(chan Block, p.options.genQueueDepth)
+	p.generator = &generatorStats{}
+	go p.generate(source, p.generator)
 
 	if test.IsRxRandomHashed() {
 		rxBlock = p.rxRandomHashedBlock
@@ -110,100 +298,425 @@ func (p *protocol) run(test *loop3_pb.Test) error {
 	p.rxPauseEvery = parseTime(p.test.RxPauseEvery)
 	p.rxPauseFor = parseTime(p.test.RxPauseFor)
 
+	p.startFlowControl()
+	if p.options.pacingReport {
+		p.pacing = &pacingRecorder{}
+	}
+
 	rxerDone := make(chan bool)
 	go p.rxer(rxerDone, rxBlock)
-	if p.test.RxRequests > 0 {
-		go p.verifier()
+	verifierDone := make(chan struct{})
+	if p.options.noVerify && p.test.RxRequests > 0 {
+		p.phaseLogger(PhaseVerify).Warn("--no-verify is set, received blocks are counted and dropped without checking " +
+			"their sequence or contents, so corrupted and reordered blocks go unnoticed")
+	}
+	if p.test.RxRequests > 0 && !p.options.noVerify {
+		go p.verifier(verifierDone)
+	} else {
+		close(verifierDone)
 	}
 
 	txerDone := make(chan bool)
 	go p.txer(txerDone)
 
+	if p.options.progressInterval > 0 && !test.ProbeMode && !p.options.summaryOnly {
+		progressDone := make(chan struct{})
+		defer close(progressDone)
+		go p.reportProgress(start, p.options.progressInterval, progressDone)
+	}
+
 	<-rxerDone
 	<-txerDone
+	// the verifier may still be checking buffered blocks, and sending grants, once the rxer is done
+	<-verifierDone
 
-	if len(p.errors) > 0 {
-		err := <-p.errors
-		return err
+	if test.StreamSummary && !p.isStopped() {
+		if err := p.verifyStreamSummary(); err != nil {
+			p.fail(p.newError(PhaseVerify, UnknownSequence, err))
+		}
 	}
 
-	return nil
+	if test.IsProber() {
+		p.summaryLogger().Info(p.stats.latency.summary(p.stats.TxCount() - test.ResumeFrom))
+	}
+
+	if outliers := p.stats.latency.outliers(p.options.latencyOutlierFactor, latencyWorstSamples); outliers.count > 0 {
+		p.logger().Warn(outliers)
+	}
+
+	stats := p.stats.Snapshot()
+	if drops := stats.LatencyDropped; drops > 0 {
+		p.logger().Warnf("%d latency requests dropped unanswered, more than %d were waiting for the txer. "+
+			"The peer's latency stats are missing those samples", drops, latencyQueueSize)
+	}
+
+	if p.pacing != nil {
+		p.summaryLogger().Info(p.pacing.report())
+	}
+
+	if retries := stats.TxRetries; retries > 0 {
+		p.logger().Warnf("%d block writes retried after transient errors", retries)
+	}
+
+	if corrupted := stats.Corrupted; corrupted > 0 {
+		p.summaryLogger().Warnf("%d of %d blocks received were corrupted (%.4f%%), the first being %v",
+			corrupted, stats.RxCount, stats.CorruptionRate*100, p.stats.corruptedSequences())
+	}
+
+	err := p.firstError()
+	result := p.result(start, err)
+	if p.wire != nil {
+		p.summaryLogger().Info(result.wireSummary())
+	}
+	return result, err
+}
+
+// result sums up a run which started at start and ended with err
+func (p *protocol) result(start time.Time, err error) *Result {
+	snapshot := p.stats.Snapshot()
+	result := &snapshot
+	result.Success = err == nil
+	result.Duration = time.Since(start)
+	result.SndBuf = p.sndBuf
+	result.RcvBuf = p.rcvBuf
+	result.ClockOffset = p.clockOffset
+	result.Setup = p.setup()
+	result.TxMaxBytesReached = p.txMaxBytesReached
+	result.TxRateLimited = p.txRateLimited
+	if err != nil {
+		result.Message = err.Error()
+		result.exit = exitCodeOf(err)
+	}
+	if p.wire != nil {
+		result.RxWireBytes, result.TxWireBytes = p.wire.snapshot()
+	}
+	result.Annotations = p.annotations()
+	return result
+}
+
+// setup returns the time it took to get the test going, see Result.Setup
+func (p *protocol) setup() time.Duration {
+	return p.dialTime + p.handshakeTime
+}
+
+// annotations returns a copy of the test's annotations, or nil if it has none
+func (p *protocol) annotations() map[string]string {
+	if p.test == nil || len(p.test.Annotations) == 0 {
+		return nil
+	}
+	annotations := map[string]string{}
+	for k, v := range p.test.Annotations {
+		annotations[k] = v
+	}
+	return annotations
+}
+
+// firstError drains the errors reported by the tx, rx and verify loops, logging each of them, and returns the first
+func (p *protocol) firstError() error {
+	var first error
+	count := 0
+	for {
+		select {
+		case err := <-p.errors:
+			count++
+			if first == nil {
+				first = err
+			} else {
+				p.logger().WithError(err).Error("additional protocol error")
+			}
+		default:
+			if count > 1 {
+				p.logger().Errorf("%d protocol errors, returning the first", count)
+			}
+			return first
+		}
+	}
+}
+
+// generate feeds the txer from source until the source runs out of blocks or the test stops. Time spent in source is
+// recorded in stats, time spent waiting for the txer to take a block isn't
+func (p *protocol) generate(source BlockSource, stats *generatorStats) {
+	log := p.logger()
+	log.Debug("generator started")
+	defer log.Debug("generator complete")
+	defer close(p.blocks)
+
+	paced, isPaced := source.(pacedSource)
+	var lastHandoff time.Time
+	for {
+		start := time.Now()
+		block, err := source.Next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			p.fail(p.newError(PhaseTx, UnknownSequence, errors.Wrap(err, "unable to generate block")))
+			return
+		}
+		stats.record(start)
+
+		if isPaced && !lastHandoff.IsZero() {
+			if wait := time.Until(lastHandoff.Add(paced.gap())); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-p.stopped:
+					return
+				}
+			}
+		}
+
+		select {
+		case p.blocks <- block:
+			lastHandoff = time.Now()
+		case <-p.stopped:
+			return
+		}
+	}
+}
+
+// txLatencyFrequency returns how often the generator should make a block a latency request
+func txLatencyFrequency(test *loop3_pb.Test) int {
+	if test.LatencyRatePerSec > 0 {
+		// the txer picks the probes instead
+		return 0
+	}
+	return int(test.LatencyFrequency)
 }
 
 func (p *protocol) txer(done chan bool) {
-	log := pfxlog.ContextLogger(p.test.Name)
+	log := p.phaseLogger(PhaseTx)
 	log.Debug("started")
 	defer func() { done <- true }()
 	defer log.Debug("complete")
+	defer p.lockThread()()
 
 	var lastSend time.Time
-	lastPause := time.Now()
-	for p.txCount < p.test.TxRequests {
+	txStart := time.Now()
+	lastPause := txStart
+	burst := newBurstShaper(time.Duration(p.test.BurstOnMillis)*time.Millisecond, time.Duration(p.test.BurstOffMillis)*time.Millisecond, txStart)
+
+	var bandwidth *tokenBucket
+	if p.test.TargetBytesPerSec > 0 {
+		// allow up to 10ms worth of bytes to go out back to back
+		rate := float64(p.test.TargetBytesPerSec)
+		bandwidth = newTokenBucket(rate, rate/100, txStart)
+	}
+	var latency *latencySampler
+	if p.test.IsTxRandomHashed() {
+		latency = newLatencySampler(p.test.LatencyRatePerSec, txStart)
+	}
+	rateLimit := newBlockRateLimiter(p.test.MaxBlocksPerSec, txStart)
+	if rateLimit != nil {
+		defer func() {
+			p.txRateLimited = rateLimit.limited
+			p.summaryLogger().WithField(logFieldPhase, PhaseTx).Info(rateLimit.report(p.stats.TxCount()))
+		}()
+	}
+	for p.stats.TxCount() < p.test.TxRequests && !p.txCapReached() {
+		if p.isStopped() {
+			return
+		}
+		if burst != nil {
+			if wait := burst.next(time.Now()); wait > 0 {
+				time.Sleep(wait)
+				if p.pacing != nil {
+					p.pacing.skip()
+				}
+			}
+		}
+
 		now := time.Now()
 		if p.txPauseEvery > 0 && now.Sub(lastPause) > p.txPauseEvery {
 			time.Sleep(p.txPauseFor)
 			lastPause = time.Now()
+			if p.pacing != nil {
+				p.pacing.skip()
+			}
 		}
+		var block Block
 		select {
-		case block := <-p.blocks:
-			if block != nil {
-				if p.txPacing > 0 {
-					jitter := time.Duration(0)
-					if p.txMaxJitter > 0 {
-						jitter = time.Duration(rand.Intn(int(p.txMaxJitter)))
-					}
+		case block = <-p.blocks:
+		default:
+			// no block ready, the generator isn't keeping up with the txer
+			atomic.AddInt32(&p.txGeneratorWaits, 1)
+			block = <-p.blocks
+		}
 
-					nextSend := lastSend.Add(p.txPacing + jitter)
-					if nextSend.After(now) {
-						time.Sleep(nextSend.Sub(now))
-						lastSend = nextSend
-					} else {
-						lastSend = now
-					}
+		if block != nil {
+			var target time.Duration
+			if bandwidth != nil {
+				target = time.Duration(float64(block.Size()) / bandwidth.rate * float64(time.Second))
+				if wait := bandwidth.take(block.Size(), time.Now()); wait > 0 {
+					time.Sleep(wait)
+				}
+			} else if p.txPacing > 0 {
+				jitter := time.Duration(0)
+				if p.txMaxJitter > 0 {
+					jitter = time.Duration(rand.Intn(int(p.txMaxJitter)))
 				}
 
-				block.PrepForSend(p)
-				if err := block.Tx(p); err == nil {
-					atomic.AddInt32(&p.txCount, 1)
+				target = p.txPacing + jitter
+				nextSend := lastSend.Add(target)
+				if nextSend.After(now) {
+					time.Sleep(nextSend.Sub(now))
+					lastSend = nextSend
 				} else {
-					log.Errorf("error sending block (%s)", err)
-					p.errors <- err
+					lastSend = now
+				}
+			}
+
+			if hashed, ok := block.(*RandHashedBlock); ok && latency != nil {
+				if (hashed.Type == BlockTypePlain || hashed.Type == BlockTypeOneWay) && latency.due(time.Now()) {
+					hashed.Type = BlockTypeLatencyRequest
+				}
+			}
+
+			if p.txCredits != nil {
+				if err := p.txCredits.take(p.stopped, time.Duration(p.test.RxIdleTimeoutMillis())*time.Millisecond); err != nil {
+					if err != errFlowStopped {
+						p.fail(p.newError(PhaseTx, blockSequence(block), err))
+					}
 					return
 				}
+			}
+
+			if rateLimit != nil {
+				if wait := rateLimit.take(time.Now()); wait > 0 {
+					time.Sleep(wait)
+				}
+			}
+
+			if p.isLastBeforeTxCap(block.Size()) {
+				p.stopTxAtCap()
+			}
+
+			p.corruptBlock(block)
+			block.PrepForSend(p)
+			sentAt := time.Now()
+			err := p.txWithRetries(block)
+			if p.pacing != nil {
+				p.pacing.record(target, sentAt, time.Since(sentAt))
+			}
+			if err == nil {
+				p.stats.RecordTx(block.Size())
+				if sequence := blockSequence(block); sequence != UnknownSequence {
+					p.txChecksum.add(uint32(sequence))
+				}
 			} else {
-				log.Errorf("tx blocks chan closed")
+				sequence := blockSequence(block)
+				if sequence == UnknownSequence {
+					sequence = int64(p.stats.TxCount())
+				}
+				// a failed write leaves the connection unusable, so the txer stops either way
+				p.fail(p.newError(PhaseTx, sequence, err))
 				return
 			}
+		} else {
+			log.Errorf("tx blocks chan closed")
+			return
 		}
 	}
 
-	log.Info("tx count reached")
+	if sent := p.stats.TxCount(); sent < p.test.TxRequests {
+		p.txMaxBytesReached = true
+		log.Infof("tx byte cap of %d reached after %d of %d blocks (%d bytes), stopping", p.test.TxMaxBytes, sent, p.test.TxRequests, p.stats.TxBytes())
+	} else if burst != nil {
+		wallClock := time.Since(txStart)
+		active := wallClock - burst.offTime
+		sent := p.stats.TxCount()
+		log.Infof("tx count reached. %d blocks in %v, %.1f blocks/s wall-clock, %.1f blocks/s active (%v)",
+			sent, wallClock, float64(sent)/wallClock.Seconds(), float64(sent)/active.Seconds(), active)
+	} else {
+		log.Info("tx count reached")
+	}
+	if p.test.StreamSummary {
+		if err := p.txWithRetries(p.txChecksum.block()); err != nil {
+			p.fail(p.newError(PhaseTx, UnknownSequence, errors.Wrap(err, "unable to send stream summary")))
+		}
+	}
+	if p.draining() && !p.isStopped() {
+		p.drain()
+	}
+	if p.options.corruptRate > 0 {
+		log.Warnf("corrupted %d of %d blocks sent, --corrupt-rate is %v", atomic.LoadInt32(&p.txCorrupted), p.stats.TxCount(), p.options.corruptRate)
+	}
 }
 
 func (p *protocol) rxer(done chan bool, rxBlock func() (Block, error)) {
-	log := pfxlog.ContextLogger(p.test.Name)
+	log := p.phaseLogger(PhaseRx)
 	log.Debug("started")
 	defer func() { done <- true }()
 	defer log.Debug("complete")
+	defer p.lockThread()()
+	// lets the verifier finish with what was received, however the rxer ends
+	defer close(p.rxBlocks)
+	defer atomic.StoreInt32(&p.rxerExited, 1)
 
 	lastRx := time.Now()
 	lastPause := time.Now()
-	for p.rxCount < p.test.RxRequests {
+	throttle := newRxThrottle(p.options.maxRxBytesPerSec, lastRx)
+	// with flow control, the rxer keeps reading grants until the txer has been granted credit for all its blocks
+	for (p.stats.RxCount() < p.test.RxRequests && !p.rxCapReached()) || p.awaitingCredits() || p.awaitingStreamSummary() || p.awaitingDrain() {
 		now := time.Now()
 		if p.rxPauseEvery > 0 && now.Sub(lastPause) > p.rxPauseEvery {
 			time.Sleep(p.rxPauseFor)
 			lastPause = time.Now()
 		}
 		block, err := rxBlock()
+		if err == io.EOF {
+			// the peer closed its side between blocks, the missing blocks show up as loss rather than as an error
+			log.Warnf("peer closed the connection after %d of %d blocks", p.stats.RxCount(), p.test.RxRequests)
+			return
+		}
 		if err != nil {
-			p.errors <- err
-			log.Error(err)
+			// after a failed read there's no telling where the next block starts, so the rxer stops either way
+			p.fail(p.newError(PhaseRx, UnknownSequence, err))
 			return
 		}
+		if isWindowBlock(block) && p.txCredits != nil {
+			p.txCredits.grant(int32(blockSequence(block)))
+			continue
+		}
+		if isStreamSummary(block) && p.test.StreamSummary {
+			if err = p.rxStreamSummary(block.(*RandHashedBlock)); err != nil {
+				p.fail(p.newError(PhaseRx, UnknownSequence, err))
+				return
+			}
+			continue
+		}
+		if p.rxDrainBlock(block) {
+			continue
+		}
+		if sequence := blockSequence(block); sequence != UnknownSequence {
+			p.rxChecksum.add(uint32(sequence))
+		}
 
-		atomic.AddInt32(&p.rxCount, 1)
+		p.stats.RecordRx(block.Size())
 		atomic.StoreInt64(&p.lastRx, info.NowInMilliseconds())
-		p.rxBlocks <- block
+		if p.options.noVerify {
+			// the block is dropped here, but the peer still needs its credit back
+			if err = p.grantVerified(); err != nil {
+				p.fail(p.newError(PhaseTx, UnknownSequence, err))
+				return
+			}
+		} else {
+			select {
+			case p.rxBlocks <- block:
+			case <-p.stopped:
+				return
+			}
+		}
+
+		if throttle != nil {
+			wait, throttled := throttle.take(block.Size(), time.Now())
+			if throttled > 0 {
+				log.Warnf("rx throttled for %v of the last %v, the peer is sending faster than %d bytes/s",
+					throttled.Round(time.Millisecond), throttleWindow, p.options.maxRxBytesPerSec)
+			}
+			if wait > 0 {
+				time.Sleep(wait)
+			}
+		}
 
 		if p.rxPacing > 0 {
 			jitter := time.Duration(0)
@@ -222,74 +735,98 @@ func (p *protocol) rxer(done chan bool, rxBlock func() (Block, error)) {
 		}
 	}
 
-	close(p.rxBlocks)
-	log.Info("rx count reached")
+	if received := p.stats.RxCount(); received < p.test.RxRequests {
+		log.Infof("peer's tx byte cap of %d reached after %d of %d blocks, stopping", p.test.RxMaxBytes, received, p.test.RxRequests)
+	} else {
+		log.Info("rx count reached")
+	}
 }
 
-func (p *protocol) verifier() {
-	log := pfxlog.ContextLogger(p.test.Name)
+func (p *protocol) verifier(done chan struct{}) {
+	log := p.phaseLogger(PhaseVerify)
 	log.Debug("started")
+	defer close(done)
 	defer log.Debug("complete")
 
+	verify := func(block Block) error { return block.Verify(p) }
+	if p.options.bare {
+		verify = func(Block) error { return nil }
+	} else if p.parallelVerify() {
+		workers := p.startHashWorkers(int(p.test.VerifyWorkers))
+		defer workers.stop()
+		verify = func(block Block) error {
+			if hashed, ok := block.(*RandHashedBlock); ok {
+				return workers.verify(hashed)
+			}
+			return block.Verify(p)
+		}
+	}
+
+	// the peer gets longer to send its first block than it may then take between blocks
+	timeout, received := p.test.ConnectTimeoutMillis(), false
 	for {
 		select {
 		case block := <-p.rxBlocks:
 			if block != nil {
-				if err := block.Verify(p); err != nil {
-					p.errors <- err
-					if closeErr := p.peer.Close(); closeErr != nil {
-						log.Error(closeErr)
+				if !received {
+					timeout, received = p.test.RxIdleTimeoutMillis(), true
+				}
+				if err := verify(block); err != nil {
+					if p.verifyFailed(blockSequence(block), err) {
+						return
 					}
-					log.Error(err)
+				}
+				if err := p.grantVerified(); err != nil {
+					p.fail(p.newError(PhaseTx, UnknownSequence, err))
 					return
 				}
 			} else {
 				return
 			}
 
-		case <-time.After(time.Duration(p.test.RxTimeout) * time.Millisecond):
-			timeSinceLastRx := info.NowInMilliseconds() - atomic.LoadInt64(&p.lastRx)
-			errStr := fmt.Sprintf("rx timeout exceeded (%d ms.). Last rx: %v. tx count: %v, rx count: %v",
-				p.test.RxTimeout, timeSinceLastRx, atomic.LoadInt32(&p.txCount), atomic.LoadInt32(&p.rxCount))
-			// err := errors.New(errStr)
-			log.Errorf(errStr)
-			// p.errors <- err
-			//if closeErr := p.peer.Close(); closeErr != nil {
-			//	log.Error(closeErr)
-			//}
+		case <-p.stopped:
+			return
+
+		case <-time.After(time.Duration(timeout) * time.Millisecond):
+			var err error
+			if received {
+				timeSinceLastRx := info.NowInMilliseconds() - atomic.LoadInt64(&p.lastRx)
+				err = errors.Errorf("rx idle timeout exceeded (%d ms.). Last rx: %v. tx count: %v, rx count: %v",
+					timeout, timeSinceLastRx, p.stats.TxCount(), p.stats.RxCount())
+			} else {
+				err = errors.Errorf("connect timeout exceeded (%d ms.), no block received. tx count: %v",
+					timeout, p.stats.TxCount())
+			}
+			// a stalled peer fails the test even without fail fast, stopping the rxer which is waiting on it
+			p.fail(p.newError(PhaseRx, UnknownSequence, withExitCode(exitTimeout, err)))
+			p.stop()
 			return
 		}
 	}
 }
 
 func (p *protocol) txTest(test *loop3_pb.Test) error {
-	if err := p.txPb(test); err != nil {
+	if err := p.framing.WriteMessage(test); err != nil {
 		return err
 	}
 	pfxlog.Logger().Info("-> [test]")
 	return nil
 }
 
-func (p *protocol) rxTest() (*loop3_pb.Test, error) {
-	test := &loop3_pb.Test{}
-	if err := p.rxPb(test); err != nil {
-		return nil, err
-	}
-	pfxlog.Logger().Infof("<- [test]")
-	return test, nil
-}
-
 func (p *protocol) rxRandomHashedBlock() (Block, error) {
 	block := &RandHashedBlock{}
 	if err := block.Rx(p); err != nil {
 		return nil, err
 	}
 
-	if block.Type == BlockTypeLatencyRequest {
+	if block.Type == BlockTypeLatencyRequest && p.options.bare {
+		p.rxEchoedLatency(block)
+	} else if block.Type == BlockTypeLatencyRequest {
 		select {
 		case p.latencies <- &block.Timestamp:
 		default:
-			pfxlog.Logger().Warn("latency channel out of room")
+			// reported at the end of the run, as this can happen for every request once the txer falls behind
+			p.stats.RecordLatencyDrop()
 		}
 	}
 
@@ -303,151 +840,36 @@ func (p *protocol) rxSeqBlock() (Block, error) {
 		return nil, err
 	}
 
-	pfxlog.ContextLogger(p.test.Name).Infof("<- #%d (%s)", p.rxSequence, info.ByteCount(int64(len(block))))
+	// rxSequence belongs to the verifier, so report the block count instead
+	p.phaseLogger(PhaseRx).Infof("<- #%d (%s)", p.stats.RxCount(), info.ByteCount(int64(len(block))))
 
 	return SeqBlock(block), nil
 }
 
-func (p *protocol) rxResult() (*Result, error) {
+// rxResult receives the peer's result, recording in local how many blocks were lost in each direction
+func (p *protocol) rxResult(local *Result) (*Result, error) {
 	result := &Result{}
 	if err := result.Rx(p); err != nil {
 		return nil, err
 	}
-	return result, nil
-}
-
-func (p *protocol) txPb(pb proto.Message) error {
-	data, err := proto.Marshal(pb)
 
-	if err != nil {
-		return err
-	}
-	if err = p.txMagicHeader(p.peer); err != nil {
-		return err
-	}
-	if err := p.txLength(p.peer, len(data)); err != nil {
-		return err
-	}
-	n, err := p.peer.Write(data)
-	if err != nil {
-		return err
-	}
-	if n != len(data) {
-		return errors.New("short data write")
-	}
-	return nil
-}
-
-func (p *protocol) rxPb(pb proto.Message) error {
-	if err := p.rxMagicHeader(); err != nil {
-		return err
-	}
-	length, err := p.rxLength()
-	if err != nil {
-		return err
-	}
-
-	defer func() {
-		if err := recover(); err != nil {
-			pfxlog.ContextLogger(p.test.Name).Errorf("failure while reading message of length %v", length)
-			panic(err)
-		}
-	}()
-
-	data := make([]byte, length)
-	n, err := io.ReadFull(p.peer, data)
-	if err != nil {
-		return err
-	}
-	if n != length {
-		return fmt.Errorf("short data read [%d != %d]", n, length)
-	}
-	if err := proto.Unmarshal(data, pb); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (p *protocol) txMagicHeader(w io.Writer) error {
-	n, err := w.Write(MagicHeader)
-	if err != nil {
-		return err
-	}
-	if n != len(MagicHeader) {
-		return errors.New("short data write (magic header)")
-	}
-	return nil
-}
-
-func (p *protocol) txLength(w io.Writer, length int) error {
-	out := make([]byte, 4)
-	binary.LittleEndian.PutUint32(out, uint32(length))
-	n, err := w.Write(out)
-	if err != nil {
-		return err
-	}
-	if n != 4 {
-		return errors.New("short length write")
-	}
-	return nil
-}
-
-func (p *protocol) txHeader(w io.Writer, msgLen int) error {
-	if err := p.txMagicHeader(w); err != nil {
-		return err
-	}
-
-	if err := p.txLength(w, msgLen); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (p *protocol) rxLength() (int, error) {
-	data := make([]byte, 4)
-	n, err := io.ReadFull(p.peer, data)
-	if err != nil {
-		return -1, err
-	}
-	if n != 4 {
-		return -1, fmt.Errorf("short length read [%d != 4]", n)
-	}
-	buf := bytes.NewBuffer(data)
-	var length int32 = -1
-	err = binary.Read(buf, binary.LittleEndian, &length)
-	if err != nil {
-		return -1, err
+	tx, rx := p.loss(result)
+	local.TxLost = tx.lost()
+	local.RxLost = rx.lost()
+	log := p.logger()
+	if tx.lost() > 0 || rx.lost() > 0 {
+		log.Warnf("loss: tx %v, rx %v", tx, rx)
+	} else {
+		p.summaryLogger().Infof("loss: tx %v, rx %v", tx, rx)
 	}
-	return int(length), nil
-}
-
-func (p *protocol) rxMagicHeader() error {
-	data := make([]byte, len(MagicHeader))
-	n, err := io.ReadFull(p.peer, data)
-	if err != nil {
-		return err
+	if result.LatencyDropped > 0 {
+		log.Warnf("peer dropped %d latency requests, latency stats are missing those samples", result.LatencyDropped)
 	}
-	if n != len(data) {
-		return fmt.Errorf("short magic header read [%v != %v]", n, len(data))
+	if result.TxRetries > 0 {
+		log.Warnf("peer retried %d block writes after transient errors", result.TxRetries)
 	}
-	if !bytes.Equal(MagicHeader, data) {
-		return errors.Errorf("bad header. Got %v, expected %v", data, MagicHeader)
+	if result.Corrupted > 0 {
+		log.Warnf("peer received %d corrupted blocks, %.4f%% of the blocks it received", result.Corrupted, result.CorruptionRate*100)
 	}
-	return nil
-}
-
-func (p *protocol) rxHeader() (int, error) {
-	if err := p.rxMagicHeader(); err != nil {
-		return 0, err
-	}
-	return p.rxLength()
-}
-
-func (p *protocol) rxMsgBody(length int) ([]byte, error) {
-	data := make([]byte, length)
-	_, err := io.ReadFull(p.peer, data)
-	if err != nil {
-		return nil, err
-	}
-	return data, err
+	return result, nil
 }
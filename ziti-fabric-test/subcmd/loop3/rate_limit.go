@@ -0,0 +1,60 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"fmt"
+	"time"
+)
+
+// blockRateLimiter holds the txer to a test's MaxBlocksPerSec. The txer takes a token just before each block goes
+// out, after any pacing, jitter and flow control waits, so nothing which bunches blocks up can push the rate over the
+// cap. The bucket only holds one token, so a pause never saves up a burst for later.
+type blockRateLimiter struct {
+	bucket *tokenBucket
+	// limited counts the blocks which had to wait for a token, and waited is how long they waited in all
+	limited int32
+	waited  time.Duration
+}
+
+// newBlockRateLimiter returns a limiter for blocksPerSec, or nil if blocksPerSec isn't positive
+func newBlockRateLimiter(blocksPerSec float64, now time.Time) *blockRateLimiter {
+	if blocksPerSec <= 0 {
+		return nil
+	}
+	return &blockRateLimiter{bucket: newTokenBucket(blocksPerSec, 1, now)}
+}
+
+// take accounts for a block about to be sent at now, returning how long to wait before sending it
+func (l *blockRateLimiter) take(now time.Time) time.Duration {
+	wait := l.bucket.take(1, now)
+	if wait > 0 {
+		l.limited++
+		l.waited += wait
+	}
+	return wait
+}
+
+// report says whether the cap held the txer back, or whether generation, pacing or the transport kept it under the
+// cap anyway, out of sent blocks
+func (l *blockRateLimiter) report(sent int32) string {
+	if l.limited == 0 {
+		return fmt.Sprintf("block rate cap of %v/s never held a block back, the rate was set by something else", l.bucket.rate)
+	}
+	return fmt.Sprintf("block rate cap of %v/s held back %d of %d blocks for %v in all, the cap was the binding constraint",
+		l.bucket.rate, l.limited, sent, l.waited.Round(time.Millisecond))
+}
@@ -0,0 +1,49 @@
+package loop3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BlockRateLimiter(t *testing.T) {
+	req := require.New(t)
+
+	req.Nil(newBlockRateLimiter(0, time.Now()))
+
+	start := time.Now()
+	limiter := newBlockRateLimiter(10, start)
+	req.Equal(time.Duration(0), limiter.take(start), "the first block goes out at once")
+	req.Equal(100*time.Millisecond, limiter.take(start), "a second block at the same time waits for its token")
+
+	// a long pause saves up one token, not a burst
+	later := start.Add(5 * time.Second)
+	req.Equal(time.Duration(0), limiter.take(later))
+	req.Equal(100*time.Millisecond, limiter.take(later))
+
+	req.Equal(int32(2), limiter.limited)
+	req.Equal(200*time.Millisecond, limiter.waited)
+	req.Contains(limiter.report(4), "held back 2 of 4 blocks")
+
+	idle := newBlockRateLimiter(10, start)
+	idle.take(start)
+	idle.take(start.Add(time.Second))
+	req.Contains(idle.report(2), "never held a block back")
+}
+
+func Test_RunWithMaxBlocksPerSec(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("max-blocks-per-sec")
+	test.TxRequests = 20
+	test.TxMaxJitter = "50ms"
+	test.MaxBlocksPerSec = 100
+
+	start := time.Now()
+	result, peerResult := runLoopbackWithOptions(t, test, &protocolOptions{failFast: true})
+	req.True(result.Success, result.Message)
+	req.Equal(int32(0), peerResult.TxRateLimited, "the peer isn't capped")
+	req.GreaterOrEqual(time.Since(start), 190*time.Millisecond, "20 blocks at 100/s take at least 190ms")
+	req.Greater(result.TxRateLimited, int32(0))
+}
@@ -0,0 +1,154 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const eventTypeAggregate = "aggregate"
+
+// repeatedMetric is how a metric varied over the runs of a repeated test. Values has the metric for each run which
+// succeeded, in run order. CvPercent, the coefficient of variation, is the standard deviation relative to the mean,
+// so runs of different configs can be told apart only when their means differ by well more than it
+type repeatedMetric struct {
+	Name      string    `json:"name"`
+	Unit      string    `json:"unit"`
+	Values    []float64 `json:"values"`
+	Mean      float64   `json:"mean"`
+	Stddev    float64   `json:"stddev"`
+	CvPercent float64   `json:"cvPercent"`
+}
+
+// aggregateEvent is written with --ndjson for each test once every --repeat run is done
+type aggregateEvent struct {
+	Type      string            `json:"type"`
+	Test      string            `json:"test"`
+	Time      time.Time         `json:"time"`
+	Runs      int               `json:"runs"`
+	Succeeded int               `json:"succeeded"`
+	Metrics   []*repeatedMetric `json:"metrics"`
+}
+
+// repeatedMetrics are the metrics aggregated over --repeat runs: the throughput and latency metrics compared by the
+// compare command, along with setup, which isn't compared as results from before it was measured don't have it
+var repeatedMetrics = func() []compareMetric {
+	var metrics []compareMetric
+	for _, metric := range compareMetrics {
+		if metric.unit == unitBytesPerSec || metric.unit == unitDuration {
+			metrics = append(metrics, metric)
+		}
+	}
+	return append(metrics, compareMetric{name: "setup", unit: unitDuration,
+		value: func(r *Result) float64 { return float64(r.Setup) }})
+}()
+
+// repeatedResults collects the results of each --repeat run, keyed by the workload and connection they're for
+type repeatedResults struct {
+	results map[string][]*Result
+}
+
+func newRepeatedResults() *repeatedResults {
+	return &repeatedResults{results: map[string][]*Result{}}
+}
+
+func (r *repeatedResults) add(name string, result *Result) {
+	r.results[name] = append(r.results[name], result)
+}
+
+// aggregate returns how each test's throughput and latency varied over the runs. Failed runs are left out, as their
+// numbers only cover part of the test, but they're counted in Runs
+func (r *repeatedResults) aggregate() []*aggregateEvent {
+	var names []string
+	for name := range r.results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var events []*aggregateEvent
+	for _, name := range names {
+		results := r.results[name]
+		event := &aggregateEvent{Type: eventTypeAggregate, Test: name, Time: time.Now(), Runs: len(results)}
+		var succeeded []*Result
+		for _, result := range results {
+			if result.Success {
+				succeeded = append(succeeded, result)
+			}
+		}
+		event.Succeeded = len(succeeded)
+
+		for _, metric := range repeatedMetrics {
+			repeated := &repeatedMetric{Name: metric.name, Unit: metric.unit, Values: []float64{}}
+			for _, result := range succeeded {
+				repeated.Values = append(repeated.Values, metric.value(result))
+			}
+			repeated.Mean, repeated.Stddev = meanAndStddev(repeated.Values)
+			if repeated.Mean != 0 {
+				repeated.CvPercent = 100 * repeated.Stddev / repeated.Mean
+			}
+			event.Metrics = append(event.Metrics, repeated)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// meanAndStddev returns the mean and sample standard deviation of values. The deviation is 0 for fewer than 2 values
+func meanAndStddev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	squares := 0.0
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)-1))
+}
+
+// report logs how each test varied over the runs to log, and writes it as an event with --ndjson
+func (r *repeatedResults) report(events *ndjsonWriter, log *logrus.Entry) {
+	for _, aggregate := range r.aggregate() {
+		if events != nil {
+			events.write(aggregate)
+		}
+		log.Infof("[%s] %d of %d runs succeeded", aggregate.Test, aggregate.Succeeded, aggregate.Runs)
+		if aggregate.Succeeded == 0 {
+			continue
+		}
+		for _, metric := range aggregate.Metrics {
+			var values []string
+			for _, v := range metric.Values {
+				values = append(values, formatMetric(metric.Unit, v))
+			}
+			log.Infof("[%s] %s: mean %s, stddev %s (%.1f%%), runs: %s", aggregate.Test, metric.Name,
+				formatMetric(metric.Unit, metric.Mean), formatMetric(metric.Unit, metric.Stddev), metric.CvPercent, strings.Join(values, ", "))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package loop3
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/michaelquigley/pfxlog"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MeanAndStddev(t *testing.T) {
+	req := require.New(t)
+
+	mean, stddev := meanAndStddev([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	req.Equal(5.0, mean)
+	req.InDelta(math.Sqrt(32.0/7), stddev, 1e-9)
+
+	mean, stddev = meanAndStddev([]float64{3})
+	req.Equal(3.0, mean)
+	req.Equal(0.0, stddev)
+
+	mean, stddev = meanAndStddev(nil)
+	req.Equal(0.0, mean)
+	req.Equal(0.0, stddev)
+}
+
+func Test_RepeatedResultsAggregate(t *testing.T) {
+	req := require.New(t)
+
+	repeated := newRepeatedResults()
+	for _, rxBytes := range []int64{1000, 1200, 800} {
+		repeated.add("throughput:0", &Result{Success: true, RxBytes: rxBytes, Duration: time.Second,
+			Latency: LatencyStats{P99: time.Duration(rxBytes) * time.Microsecond}})
+	}
+	repeated.add("throughput:0", &Result{Message: "mismatched hashes", RxBytes: 10, Duration: time.Second})
+
+	aggregates := repeated.aggregate()
+	req.Len(aggregates, 1)
+	aggregate := aggregates[0]
+	req.Equal("throughput:0", aggregate.Test)
+	req.Equal(4, aggregate.Runs)
+	req.Equal(3, aggregate.Succeeded)
+
+	metrics := map[string]*repeatedMetric{}
+	for _, metric := range aggregate.Metrics {
+		metrics[metric.Name] = metric
+	}
+	req.NotContains(metrics, "tx loss", "only throughput and latency are aggregated")
+
+	rx := metrics["rx throughput"]
+	req.Equal([]float64{1000, 1200, 800}, rx.Values, "failed runs are left out")
+	req.Equal(1000.0, rx.Mean)
+	req.InDelta(200, rx.Stddev, 1e-9)
+	req.InDelta(20, rx.CvPercent, 1e-9)
+
+	p99 := metrics["latency p99"]
+	req.Equal(float64(time.Millisecond), p99.Mean)
+
+	out := &bytes.Buffer{}
+	repeated.report(newNdjsonWriter(out), pfxlog.Logger().Entry)
+	event := map[string]interface{}{}
+	req.NoError(json.Unmarshal(out.Bytes(), &event))
+	req.Equal(eventTypeAggregate, event["type"])
+	req.Equal(3.0, event["succeeded"])
+}
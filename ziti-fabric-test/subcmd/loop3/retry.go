@@ -0,0 +1,81 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// txRetryBackoff is the wait before the first retry of a block write, doubling with each retry up to txRetryMaxBackoff
+const (
+	txRetryBackoff    = 50 * time.Millisecond
+	txRetryMaxBackoff = 2 * time.Second
+)
+
+// txWithRetries sends block, retrying writes which fail with a transient error up to the configured number of times.
+// A write is only retried if it didn't get any of the block out, as the peer would otherwise read the start of the
+// block followed by the whole of it.
+func (p *protocol) txWithRetries(block Block) error {
+	backoff := txRetryBackoff
+	for attempt := 1; ; attempt++ {
+		// held for the write only, so flow control grants can go out during the backoff
+		p.txLock.Lock()
+		_, writtenBefore := p.wire.snapshot()
+		err := block.Tx(p)
+		_, written := p.wire.snapshot()
+		p.txLock.Unlock()
+		if err == nil || attempt > p.options.txRetries || !isTransient(err) {
+			return err
+		}
+		if written != writtenBefore {
+			return errors.Wrapf(err, "unable to retry, %d bytes of the block were already written", written-writtenBefore)
+		}
+
+		p.stats.RecordTxRetry()
+		p.phaseLogger(PhaseTx).WithError(err).Warnf("retrying block #%d in %v (retry %d of %d)",
+			blockSequence(block), backoff, attempt, p.options.txRetries)
+		select {
+		case <-time.After(backoff):
+		case <-p.stopped:
+			return err
+		}
+		if backoff *= 2; backoff > txRetryMaxBackoff {
+			backoff = txRetryMaxBackoff
+		}
+	}
+}
+
+// isTransient returns true if err is a network error the connection may recover from, such as a timeout
+func isTransient(err error) bool {
+	if errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, io.EOF) {
+		return false
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) {
+		return false
+	}
+	if netErr.Timeout() {
+		return true
+	}
+	// Temporary is deprecated on net.Error, but some transports still use it to flag errors worth retrying
+	temporary, ok := netErr.(interface{ Temporary() bool })
+	return ok && temporary.Temporary()
+}
@@ -0,0 +1,90 @@
+package loop3
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// flakyPeer fails its first failures writes with err, after writing partial bytes of each
+type flakyPeer struct {
+	testPeer
+	failures int
+	partial  int
+	err      error
+}
+
+func (peer *flakyPeer) Write(b []byte) (int, error) {
+	if peer.failures > 0 {
+		peer.failures--
+		n, _ := peer.testPeer.Write(b[:peer.partial])
+		return n, peer.err
+	}
+	return peer.testPeer.Write(b)
+}
+
+func newRetryTestProtocol(t *testing.T, peer *flakyPeer, retries int) *protocol {
+	p, err := newProtocol(peer, &protocolOptions{txRetries: retries})
+	require.NoError(t, err)
+	p.test = &loop3_pb.Test{Name: "retry"}
+	return p
+}
+
+func Test_TxRetriesTransientErrors(t *testing.T) {
+	req := require.New(t)
+
+	peer := &flakyPeer{failures: 2, err: timeoutError{}}
+	p := newRetryTestProtocol(t, peer, 2)
+	block := &RandHashedBlock{Type: BlockTypePlain, Sequence: 7, Data: []byte("data")}
+	req.NoError(p.txWithRetries(block))
+	req.Equal(int32(2), p.result(time.Now(), nil).TxRetries)
+
+	read := &RandHashedBlock{}
+	req.NoError(read.Rx(p))
+	req.Equal(uint32(7), read.Sequence)
+}
+
+func Test_TxRetriesGiveUp(t *testing.T) {
+	req := require.New(t)
+
+	// more failures than retries
+	p := newRetryTestProtocol(t, &flakyPeer{failures: 2, err: timeoutError{}}, 1)
+	err := p.txWithRetries(&RandHashedBlock{Type: BlockTypePlain, Data: []byte("data")})
+	req.Equal(timeoutError{}, err)
+	req.Equal(int32(1), p.stats.Snapshot().TxRetries)
+
+	// closed connections aren't retried
+	p = newRetryTestProtocol(t, &flakyPeer{failures: 1, err: net.ErrClosed}, 3)
+	err = p.txWithRetries(&RandHashedBlock{Type: BlockTypePlain, Data: []byte("data")})
+	req.ErrorIs(err, net.ErrClosed)
+	req.Equal(int32(0), p.stats.Snapshot().TxRetries)
+
+	// nor are writes which got part of the block out
+	p = newRetryTestProtocol(t, &flakyPeer{failures: 1, partial: 3, err: timeoutError{}}, 3)
+	err = p.txWithRetries(&RandHashedBlock{Type: BlockTypePlain, Data: []byte("data")})
+	req.Error(err)
+	req.Contains(err.Error(), "3 bytes of the block were already written")
+	req.Equal(int32(0), p.stats.Snapshot().TxRetries)
+}
+
+func Test_IsTransient(t *testing.T) {
+	req := require.New(t)
+
+	req.True(isTransient(timeoutError{}))
+	req.True(isTransient(errors.Wrap(&net.OpError{Op: "write", Err: timeoutError{}}, "write failed")))
+	req.False(isTransient(net.ErrClosed))
+	req.False(isTransient(&net.OpError{Op: "write", Err: net.ErrClosed}))
+	req.False(isTransient(io.EOF))
+	req.False(isTransient(io.ErrShortWrite))
+}
@@ -0,0 +1,42 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"runtime"
+
+	"github.com/michaelquigley/pfxlog"
+)
+
+// applyRuntime applies --gomaxprocs. It's process wide, so it limits everything else in the process as well, such as
+// the SDK and the fabric, not just the tests
+func (options *protocolOptions) applyRuntime() {
+	if options.gomaxprocs > 0 {
+		previous := runtime.GOMAXPROCS(options.gomaxprocs)
+		pfxlog.Logger().Infof("GOMAXPROCS set to %d, was %d", options.gomaxprocs, previous)
+	}
+}
+
+// lockThread wires the calling goroutine to its OS thread with --lock-threads, returning a func to undo it, which
+// must be called from the same goroutine before it exits
+func (p *protocol) lockThread() func() {
+	if !p.options.lockThreads {
+		return func() {}
+	}
+	runtime.LockOSThread()
+	return runtime.UnlockOSThread
+}
@@ -0,0 +1,49 @@
+package loop3
+
+import (
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ApplyRuntimeSetsGomaxprocs(t *testing.T) {
+	req := require.New(t)
+
+	previous := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(previous)
+
+	(&protocolOptions{}).applyRuntime()
+	req.Equal(previous, runtime.GOMAXPROCS(0), "0 should leave GOMAXPROCS alone")
+
+	(&protocolOptions{gomaxprocs: 1}).applyRuntime()
+	req.Equal(1, runtime.GOMAXPROCS(0))
+}
+
+func Test_RunWithLockedThreads(t *testing.T) {
+	req := require.New(t)
+
+	p, err := newProtocol(&testPeer{}, &protocolOptions{lockThreads: true})
+	req.NoError(err)
+	unlock := p.lockThread()
+	unlock()
+
+	test := newLoopbackTest("locked")
+	conn, peer := net.Pipe()
+	defer func() { _ = conn.Close() }()
+	defer func() { _ = peer.Close() }()
+	go (&listenerCmd{protocolOptions: protocolOptions{lockThreads: true}}).handle(peer, "locked")
+
+	p, err = newProtocol(conn, &protocolOptions{lockThreads: true})
+	req.NoError(err)
+	req.NoError(p.txTest(loopbackPeerTest(test)))
+	_, err = p.exchangeMetadata(newMetadata("locked", ""))
+	req.NoError(err)
+
+	result, err := p.run(test)
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+	req.Equal(test.TxRequests, result.TxCount)
+	req.Equal(test.RxRequests, result.RxCount)
+}
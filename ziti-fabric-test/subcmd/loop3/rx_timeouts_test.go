@@ -0,0 +1,98 @@
+package loop3
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RxTimeoutIsTheDefault(t *testing.T) {
+	req := require.New(t)
+
+	test := &loop3_pb.Test{RxTimeout: 5000}
+	req.Equal(int32(5000), test.ConnectTimeoutMillis())
+	req.Equal(int32(5000), test.RxIdleTimeoutMillis())
+
+	test.ConnectTimeout = 30000
+	req.Equal(int32(30000), test.ConnectTimeoutMillis())
+	req.Equal(int32(5000), test.RxIdleTimeoutMillis())
+
+	test.RxIdleTimeout = 500
+	req.Equal(int32(500), test.RxIdleTimeoutMillis())
+}
+
+func Test_GetTestsCopiesRxTimeouts(t *testing.T) {
+	req := require.New(t)
+
+	workload := &Workload{
+		Dialer:   Test{RxTimeout: 5000, ConnectTimeout: 30000},
+		Listener: Test{RxTimeout: 5000, RxIdleTimeout: 500},
+	}
+	local, remote := workload.GetTests()
+	req.Equal(int32(30000), local.ConnectTimeoutMillis())
+	req.Equal(int32(5000), local.RxIdleTimeoutMillis())
+	req.Equal(int32(5000), remote.ConnectTimeoutMillis())
+	req.Equal(int32(500), remote.RxIdleTimeoutMillis())
+}
+
+func Test_VerifierWaitsLongerForTheFirstBlock(t *testing.T) {
+	req := require.New(t)
+
+	conn, peer := net.Pipe()
+	defer func() { _ = peer.Close() }()
+
+	p, err := newProtocol(conn, &protocolOptions{failFast: true})
+	req.NoError(err)
+	p.test = &loop3_pb.Test{Name: "timeouts", ConnectTimeout: 2000, RxIdleTimeout: 50}
+
+	done := make(chan struct{})
+	go func() {
+		p.verifier(make(chan struct{}))
+		close(done)
+	}()
+
+	// longer than the idle timeout, which only applies once a block has arrived
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case p.rxBlocks <- hashedBlock(0, []byte{1, 2, 3}):
+	case <-done:
+		req.Fail("the verifier gave up before the connect timeout")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		req.Fail("the verifier didn't stop at the idle timeout")
+	}
+	req.Equal(uint64(1), p.rxSequence)
+}
+
+func Test_SilentPeerFailsAtConnectTimeout(t *testing.T) {
+	for _, failFast := range []bool{true, false} {
+		req := require.New(t)
+
+		conn, peer := net.Pipe()
+		t.Cleanup(func() { _ = conn.Close() })
+		t.Cleanup(func() { _ = peer.Close() })
+		// the peer takes the blocks sent to it but never sends any back
+		go func() { _, _ = io.Copy(io.Discard, peer) }()
+
+		test := newLoopbackTest("silent-peer")
+		test.ConnectTimeout = 100
+		p, err := newProtocol(conn, &protocolOptions{failFast: failFast})
+		req.NoError(err)
+
+		start := time.Now()
+		result, err := p.run(test)
+		req.Error(err)
+		req.Contains(err.Error(), "connect timeout exceeded")
+		req.Less(time.Since(start), 2*time.Second, "the test fails at the connect timeout, not the rx timeout")
+		req.Equal(exitTimeout, exitCodeOf(err))
+		req.False(result.Success)
+		req.Equal(exitTimeout, result.exitCode())
+	}
+}
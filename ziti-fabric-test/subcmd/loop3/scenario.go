@@ -34,6 +34,25 @@ type Workload struct {
 	Concurrency int32  `yaml:"concurrency"`
 	Dialer      Test   `yaml:"dialer"`
 	Listener    Test   `yaml:"listener"`
+
+	// ProbeMode only measures round trip time. The dialer sends dialer.txRequests small latency probes, paced by
+	// dialer.txPacing (once a second by default), and the listener echoes each one. Payload and block type settings
+	// are ignored
+	ProbeMode bool `yaml:"probeMode"`
+
+	// FlowWindow limits each side to this many blocks sent but not yet verified by the other, so a slow verifier
+	// doesn't hold up the peer's rxer. 0 disables flow control. Both sides must use random hashed blocks
+	FlowWindow int32 `yaml:"flowWindow"`
+
+	// DrainTimeout is how many milliseconds each side carries on answering the other's latency requests once it has
+	// sent its last block, so the requests still in flight at the end of the test get their echoes. The drain ends
+	// sooner once all the other side's blocks have arrived. 0 closes right away. Both sides must use random hashed
+	// blocks
+	DrainTimeout int32 `yaml:"drainTimeout"`
+
+	// Annotations are free-form key/value pairs, such as a git SHA or ticket number, which are copied verbatim into
+	// the results of the workload's tests on both sides
+	Annotations map[string]string `yaml:"annotations"`
 }
 
 type Test struct {
@@ -53,49 +72,135 @@ type Test struct {
 	PayloadMaxBytes  int32  `yaml:"payloadMaxBytes"`
 	LatencyFrequency int32  `yaml:"latencyFrequency"`
 	BlockType        string `yaml:"blockType"`
+
+	// PayloadSize is shorthand for setting PayloadMinBytes and PayloadMaxBytes to the same size, for fixed size blocks
+	PayloadSize int32 `yaml:"payloadSize"`
+
+	// LatencyRatePerSec sends latency probes at this rate, whatever the block rate, up to one probe per block. It takes
+	// precedence over LatencyFrequency
+	LatencyRatePerSec float64 `yaml:"latencyRatePerSec"`
+
+	// dialer and listener clocks are synchronized, which the dialer checks first, see --warn-on-clock-skew
+	// dialer and listener clocks are synchronized
+	OneWayDelay bool `yaml:"oneWayDelay"`
+
+	// BurstOnMillis and BurstOffMillis make the txer alternate between sending and pausing. Both must be set
+	BurstOnMillis  int32 `yaml:"burstOnMillis"`
+	BurstOffMillis int32 `yaml:"burstOffMillis"`
+
+	// TargetBytesPerSec paces sends by block size to hold a steady bitrate. It takes precedence over TxPacing
+	TargetBytesPerSec int64 `yaml:"targetBytesPerSec"`
+	// Seed makes the block sizes and payloads the same from run to run. 0 leaves them different every run
+	Seed int64 `yaml:"seed"`
+
+	// VerifyWorkers hashes received random hashed blocks on this many goroutines, for when a single verifier can't
+	// keep up with a fast transport. The sequence is still checked in order. 0 or 1 verifies on the verifier alone
+	VerifyWorkers int32 `yaml:"verifyWorkers"`
+
+	// ConnectTimeout is how many milliseconds to wait for the first block, and RxIdleTimeout how many may pass between
+	// blocks after it, so a peer slow to start can be given longer than one which stalls mid-stream. Either one left
+	// at 0 is RxTimeout
+	ConnectTimeout int32 `yaml:"connectTimeout"`
+	RxIdleTimeout  int32 `yaml:"rxIdleTimeout"`
+
+	// MaxBlocksPerSec is a hard cap on the send rate, which pacing and jitter can't push the txer over. 0 for no cap
+	MaxBlocksPerSec float64 `yaml:"maxBlocksPerSec"`
+
+	// PayloadDistribution is how payload sizes are drawn from between PayloadMinBytes and PayloadMaxBytes: fixed,
+	// uniform, normal or pareto. Empty is uniform. PayloadMeanBytes and PayloadStddevBytes shape the normal
+	// distribution and PayloadParetoAlpha the pareto one, each defaulting when 0
+	PayloadDistribution string  `yaml:"payloadDistribution"`
+	PayloadMeanBytes    float64 `yaml:"payloadMeanBytes"`
+	PayloadStddevBytes  float64 `yaml:"payloadStddevBytes"`
+	PayloadParetoAlpha  float64 `yaml:"payloadParetoAlpha"`
 }
 
 func (workload *Workload) GetTests() (*loop3_pb.Test, *loop3_pb.Test) {
+	dialerMin, dialerMax := workload.Dialer.payloadRange()
+	listenerMin, listenerMax := workload.Listener.payloadRange()
+
 	local := &loop3_pb.Test{
-		Name:             workload.Name,
-		TxRequests:       workload.Dialer.TxRequests,
-		TxPacing:         workload.Dialer.TxPacing.String(),
-		TxMaxJitter:      workload.Dialer.TxMaxJitter.String(),
-		TxPauseEvery:     workload.Dialer.TxPauseEvery.String(),
-		TxPauseFor:       workload.Dialer.TxPauseFor.String(),
-		RxRequests:       workload.Listener.TxRequests,
-		RxPacing:         workload.Dialer.RxPacing.String(),
-		RxMaxJitter:      workload.Dialer.RxMaxJitter.String(),
-		RxPauseEvery:     workload.Dialer.RxPauseEvery.String(),
-		RxPauseFor:       workload.Dialer.RxPauseFor.String(),
-		RxTimeout:        workload.Dialer.RxTimeout,
-		RxSeqBlockSize:   workload.Listener.PayloadMinBytes,
-		PayloadMinBytes:  workload.Dialer.PayloadMinBytes,
-		PayloadMaxBytes:  workload.Dialer.PayloadMaxBytes,
-		LatencyFrequency: workload.Dialer.LatencyFrequency,
-		TxBlockType:      workload.Dialer.BlockType,
-		RxBlockType:      workload.Listener.BlockType,
+		Name:                workload.Name,
+		TxRequests:          workload.Dialer.TxRequests,
+		TxPacing:            workload.Dialer.TxPacing.String(),
+		TxMaxJitter:         workload.Dialer.TxMaxJitter.String(),
+		TxPauseEvery:        workload.Dialer.TxPauseEvery.String(),
+		TxPauseFor:          workload.Dialer.TxPauseFor.String(),
+		RxRequests:          workload.Listener.TxRequests,
+		RxPacing:            workload.Dialer.RxPacing.String(),
+		RxMaxJitter:         workload.Dialer.RxMaxJitter.String(),
+		RxPauseEvery:        workload.Dialer.RxPauseEvery.String(),
+		RxPauseFor:          workload.Dialer.RxPauseFor.String(),
+		RxTimeout:           workload.Dialer.RxTimeout,
+		ConnectTimeout:      workload.Dialer.ConnectTimeout,
+		RxIdleTimeout:       workload.Dialer.RxIdleTimeout,
+		MaxBlocksPerSec:     workload.Dialer.MaxBlocksPerSec,
+		PayloadDistribution: workload.Dialer.PayloadDistribution,
+		PayloadMeanBytes:    workload.Dialer.PayloadMeanBytes,
+		PayloadStddevBytes:  workload.Dialer.PayloadStddevBytes,
+		PayloadParetoAlpha:  workload.Dialer.PayloadParetoAlpha,
+		RxSeqBlockSize:      listenerMin,
+		PayloadMinBytes:     dialerMin,
+		PayloadMaxBytes:     dialerMax,
+		LatencyFrequency:    workload.Dialer.LatencyFrequency,
+		LatencyRatePerSec:   workload.Dialer.LatencyRatePerSec,
+		TxBlockType:         workload.Dialer.BlockType,
+		RxBlockType:         workload.Listener.BlockType,
+		OneWayDelay:         workload.Dialer.OneWayDelay,
+		BurstOnMillis:       workload.Dialer.BurstOnMillis,
+		BurstOffMillis:      workload.Dialer.BurstOffMillis,
+		TargetBytesPerSec:   workload.Dialer.TargetBytesPerSec,
+		Seed:                workload.Dialer.Seed,
+		FlowWindow:          workload.FlowWindow,
+		DrainTimeout:        workload.DrainTimeout,
+		VerifyWorkers:       workload.Dialer.VerifyWorkers,
+		Annotations:         workload.Annotations,
 	}
 
 	remote := &loop3_pb.Test{
-		Name:             workload.Name,
-		TxRequests:       workload.Listener.TxRequests,
-		TxPacing:         workload.Listener.TxPacing.String(),
-		TxMaxJitter:      workload.Listener.TxMaxJitter.String(),
-		TxPauseEvery:     workload.Listener.TxPauseEvery.String(),
-		TxPauseFor:       workload.Listener.TxPauseFor.String(),
-		RxRequests:       workload.Dialer.TxRequests,
-		RxPacing:         workload.Listener.RxPacing.String(),
-		RxMaxJitter:      workload.Listener.RxMaxJitter.String(),
-		RxTimeout:        workload.Listener.RxTimeout,
-		RxPauseEvery:     workload.Listener.RxPauseEvery.String(),
-		RxPauseFor:       workload.Listener.RxPauseFor.String(),
-		RxSeqBlockSize:   workload.Dialer.PayloadMinBytes,
-		PayloadMinBytes:  workload.Listener.PayloadMinBytes,
-		PayloadMaxBytes:  workload.Listener.PayloadMaxBytes,
-		LatencyFrequency: workload.Listener.LatencyFrequency,
-		TxBlockType:      workload.Listener.BlockType,
-		RxBlockType:      workload.Dialer.BlockType,
+		Name:                workload.Name,
+		TxRequests:          workload.Listener.TxRequests,
+		TxPacing:            workload.Listener.TxPacing.String(),
+		TxMaxJitter:         workload.Listener.TxMaxJitter.String(),
+		TxPauseEvery:        workload.Listener.TxPauseEvery.String(),
+		TxPauseFor:          workload.Listener.TxPauseFor.String(),
+		RxRequests:          workload.Dialer.TxRequests,
+		RxPacing:            workload.Listener.RxPacing.String(),
+		RxMaxJitter:         workload.Listener.RxMaxJitter.String(),
+		RxTimeout:           workload.Listener.RxTimeout,
+		ConnectTimeout:      workload.Listener.ConnectTimeout,
+		RxIdleTimeout:       workload.Listener.RxIdleTimeout,
+		MaxBlocksPerSec:     workload.Listener.MaxBlocksPerSec,
+		PayloadDistribution: workload.Listener.PayloadDistribution,
+		PayloadMeanBytes:    workload.Listener.PayloadMeanBytes,
+		PayloadStddevBytes:  workload.Listener.PayloadStddevBytes,
+		PayloadParetoAlpha:  workload.Listener.PayloadParetoAlpha,
+		RxPauseEvery:        workload.Listener.RxPauseEvery.String(),
+		RxPauseFor:          workload.Listener.RxPauseFor.String(),
+		RxSeqBlockSize:      dialerMin,
+		PayloadMinBytes:     listenerMin,
+		PayloadMaxBytes:     listenerMax,
+		LatencyFrequency:    workload.Listener.LatencyFrequency,
+		LatencyRatePerSec:   workload.Listener.LatencyRatePerSec,
+		TxBlockType:         workload.Listener.BlockType,
+		RxBlockType:         workload.Dialer.BlockType,
+		OneWayDelay:         workload.Listener.OneWayDelay,
+		BurstOnMillis:       workload.Listener.BurstOnMillis,
+		BurstOffMillis:      workload.Listener.BurstOffMillis,
+		TargetBytesPerSec:   workload.Listener.TargetBytesPerSec,
+		Seed:                workload.Listener.Seed,
+		FlowWindow:          workload.FlowWindow,
+		DrainTimeout:        workload.DrainTimeout,
+		VerifyWorkers:       workload.Listener.VerifyWorkers,
+		Annotations:         workload.Annotations,
+	}
+
+	if workload.ProbeMode {
+		applyProbeMode(local, remote)
+	} else if local.IsTxRandomHashed() && local.IsRxRandomHashed() {
+		// sequential blocks aren't framed, so there's nowhere to put a summary
+		local.StreamSummary = true
+		remote.StreamSummary = true
 	}
 
 	return local, remote
@@ -118,6 +223,10 @@ func LoadScenario(path string) (*Scenario, error) {
 		return nil, err
 	}
 
+	if err := scenario.validate(); err != nil {
+		return nil, err
+	}
+
 	return scenario, nil
 }
 
@@ -128,3 +237,34 @@ func (scenario *Scenario) String() string {
 	}
 	return string(data)
 }
+
+// payloadRange returns the smallest and largest payload sizes of the test's blocks
+func (test *Test) payloadRange() (int32, int32) {
+	if test.PayloadSize > 0 {
+		return test.PayloadSize, test.PayloadSize
+	}
+	return test.PayloadMinBytes, test.PayloadMaxBytes
+}
+
+// annotate adds annotations to every workload, replacing any the workload already has with the same keys
+func (scenario *Scenario) annotate(annotations map[string]string) {
+	for _, workload := range scenario.Workloads {
+		if workload.Annotations == nil {
+			workload.Annotations = map[string]string{}
+		}
+		for k, v := range annotations {
+			workload.Annotations[k] = v
+		}
+	}
+}
+
+// setPayloadSize gives every block of every workload a payload of size bytes
+func (scenario *Scenario) setPayloadSize(size int32) {
+	for _, workload := range scenario.Workloads {
+		for _, test := range []*Test{&workload.Dialer, &workload.Listener} {
+			test.PayloadSize = size
+			test.PayloadMinBytes = 0
+			test.PayloadMaxBytes = 0
+		}
+	}
+}
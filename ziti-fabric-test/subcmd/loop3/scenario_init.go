@@ -0,0 +1,242 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+func init() {
+	loop3Cmd.AddCommand(newScenarioCmd())
+}
+
+func newScenarioCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scenario",
+		Short: "Work with loop3 scenario files",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "init",
+		Short: "Write a commented scenario template to stdout",
+		Long: "Write a scenario with one workload to stdout, listing every setting with what it does and its default, " +
+			"ready to edit and run with the dialer. The metrics block is commented out, as it needs a metrics service.",
+		Args: cobra.NoArgs,
+		Run: func(*cobra.Command, []string) {
+			if err := writeScenarioTemplate(os.Stdout); err != nil {
+				panic(err)
+			}
+		},
+	})
+
+	return cmd
+}
+
+// scenarioFieldDocs explains each scenario setting, keyed by the Go type it's in and its yaml name. The template is
+// built from the scenario types, so a setting added to them without an entry here fails the template
+var scenarioFieldDocs = map[string]string{
+	"Scenario.workloads":       "The workloads to run, one after the other",
+	"Scenario.connectionDelay": "Milliseconds to wait between opening each connection of a workload",
+	"Scenario.metrics":         "Stream metrics to a ziti service while the dialer runs",
+
+	"Metrics.service":  "The service to send metrics to",
+	"Metrics.interval": "How often to send metrics",
+	"Metrics.clientId": "The source id the metrics are reported under",
+
+	"Workload.name":         "Shown in logs and results, and used to seed payloads with --seed-from-testname",
+	"Workload.concurrency":  "How many connections run the workload at once",
+	"Workload.dialer":       "What the dialer sends, and how it receives",
+	"Workload.listener":     "What the listener sends, and how it receives",
+	"Workload.probeMode":    "Only measure round trips: the dialer sends dialer.txRequests small probes, paced by dialer.txPacing",
+	"Workload.flowWindow":   "Most blocks either side may have sent but not yet verified by the other, 0 for no flow control",
+	"Workload.drainTimeout": "Milliseconds each side keeps answering latency requests after its last block, so late echoes aren't lost. 0 to close right away",
+	"Workload.annotations":  "Free-form key/value pairs, such as a git SHA, copied into the results",
+
+	"Test.txRequests":        "How many blocks to send",
+	"Test.txPacing":          "Gap between sends, 0s to send as fast as possible",
+	"Test.txMaxJitter":       "Up to this much random time is added to each send gap",
+	"Test.txPauseEvery":      "Pause sending this often, 0s to never pause",
+	"Test.txPauseFor":        "How long each send pause lasts",
+	"Test.rxTimeout":         "Milliseconds without receiving a block before the test gives up on the rest. The default for connectTimeout and rxIdleTimeout",
+	"Test.rxPacing":          "Gap between reads, to simulate a slow receiver",
+	"Test.rxMaxJitter":       "Up to this much random time is added to each read gap",
+	"Test.rxPauseEvery":      "Pause reading this often, 0s to never pause",
+	"Test.rxPauseFor":        "How long each read pause lasts",
+	"Test.payloadMinBytes":   "Smallest block payload, in bytes",
+	"Test.payloadMaxBytes":   "Largest block payload, in bytes",
+	"Test.latencyFrequency":  "Make every nth block a latency probe, 0 for none",
+	"Test.blockType":         fmt.Sprintf("%s (framed and verified by hash) or %s (a raw byte stream)", loop3_pb.BlockTypeRandomHashed, loop3_pb.BlockTypeSequential),
+	"Test.payloadSize":       "Shorthand for the same payloadMinBytes and payloadMaxBytes, for fixed size blocks",
+	"Test.latencyRatePerSec": "Send this many latency probes a second, whatever the block rate. Overrides latencyFrequency",
+	"Test.oneWayDelay":       "Timestamp every block to measure one-way delay, which needs synchronized clocks",
+	"Test.burstOnMillis":     "Send for this many milliseconds, then pause for burstOffMillis. Both must be set",
+	"Test.burstOffMillis":    "Pause for this many milliseconds between bursts",
+	"Test.targetBytesPerSec": "Pace sends to hold this bitrate, 0 for no target. Overrides txPacing",
+	"Test.seed":              "Seed for block sizes and payloads, to repeat them from run to run. 0 for different every run",
+	"Test.verifyWorkers":     "Hash received blocks on this many goroutines, for when one verifier can't keep up",
+	"Test.connectTimeout":    "Milliseconds to wait for the first block, 0 for rxTimeout",
+	"Test.rxIdleTimeout":     "Milliseconds allowed between blocks once they're arriving, 0 for rxTimeout",
+	"Test.maxBlocksPerSec":   "Never send more than this many blocks a second, whatever the pacing and jitter. 0 for no cap",
+	"Test.payloadDistribution": fmt.Sprintf("How payload sizes are drawn between payloadMinBytes and payloadMaxBytes: %s (always the max), %s, %s or %s (heavy-tailed). Empty for %s",
+		PayloadDistributionFixed, PayloadDistributionUniform, PayloadDistributionNormal, PayloadDistributionPareto, PayloadDistributionUniform),
+	"Test.payloadMeanBytes":   "Mean payload size of the normal distribution, 0 for the middle of the range",
+	"Test.payloadStddevBytes": "Standard deviation of the normal distribution, 0 for a sixth of the range",
+	"Test.payloadParetoAlpha": fmt.Sprintf("Shape of the pareto distribution, lower for a heavier tail. 0 for %v, the 80/20 rule", defaultParetoAlpha),
+}
+
+// scenarioTemplate is what scenario init writes: one modest random hashed workload in each direction
+func scenarioTemplate() *Scenario {
+	test := Test{
+		TxRequests:       1000,
+		RxTimeout:        30000,
+		PayloadMinBytes:  64,
+		PayloadMaxBytes:  10240,
+		LatencyFrequency: 100,
+		BlockType:        loop3_pb.BlockTypeRandomHashed,
+	}
+	return &Scenario{
+		ConnectionDelay: 250,
+		Workloads: []*Workload{{
+			Name:        "throughput",
+			Concurrency: 1,
+			Dialer:      test,
+			Listener:    test,
+		}},
+	}
+}
+
+// writeScenarioTemplate writes scenarioTemplate as yaml, with each setting's doc and default in a comment above it
+func writeScenarioTemplate(out io.Writer) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("# loop3 scenario, run it with: loop3 dialer <this file>\n\n")
+	defaults := &Scenario{ConnectionDelay: 250} // as LoadScenario starts out with
+	if err := writeYamlFields(buf, reflect.ValueOf(scenarioTemplate()).Elem(), reflect.ValueOf(defaults).Elem(), ""); err != nil {
+		return err
+	}
+	_, err := out.Write(buf.Bytes())
+	return err
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// writeYamlFields writes the fields of the struct value at indent, noting where they differ from defaults
+func writeYamlFields(buf *bytes.Buffer, value, defaults reflect.Value, indent string) error {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		doc, found := scenarioFieldDocs[t.Name()+"."+name]
+		if !found {
+			return errors.Errorf("no doc for scenario setting %s.%s", t.Name(), name)
+		}
+		fieldValue, fieldDefault := value.Field(i), defaults.Field(i)
+		if field.Type.Kind() != reflect.Struct && field.Type.Kind() != reflect.Slice && field.Type.Kind() != reflect.Ptr &&
+			!reflect.DeepEqual(fieldValue.Interface(), fieldDefault.Interface()) {
+			doc += fmt.Sprintf(" (default %s)", yamlScalar(fieldDefault))
+		}
+		if indent == "" && i > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + "# " + doc + "\n")
+
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			buf.WriteString(indent + name + ":\n")
+			if err := writeYamlFields(buf, fieldValue, fieldDefault, indent+"  "); err != nil {
+				return err
+			}
+		case reflect.Ptr:
+			// unset blocks are written commented out, filled in with their defaults
+			elem := fieldValue
+			if elem.IsNil() {
+				elem = reflect.New(field.Type.Elem())
+			}
+			block := &bytes.Buffer{}
+			block.WriteString(name + ":\n")
+			if err := writeYamlFields(block, elem.Elem(), reflect.New(field.Type.Elem()).Elem(), "  "); err != nil {
+				return err
+			}
+			prefix := indent
+			if fieldValue.IsNil() {
+				prefix += "# "
+			}
+			for _, line := range strings.SplitAfter(strings.TrimSuffix(block.String(), "\n"), "\n") {
+				buf.WriteString(prefix + line)
+			}
+			buf.WriteString("\n")
+		case reflect.Slice:
+			buf.WriteString(indent + name + ":\n")
+			for j := 0; j < fieldValue.Len(); j++ {
+				elem := fieldValue.Index(j)
+				if elem.Kind() == reflect.Ptr {
+					elem = elem.Elem()
+				}
+				item := &bytes.Buffer{}
+				if err := writeYamlFields(item, elem, reflect.New(elem.Type()).Elem(), indent+"    "); err != nil {
+					return err
+				}
+				buf.WriteString(startListItem(item.String(), indent+"    ", indent+"  - "))
+			}
+		default:
+			buf.WriteString(indent + name + ": " + yamlScalar(fieldValue) + "\n")
+		}
+	}
+	return nil
+}
+
+// startListItem puts the list item marker on the first setting of item, lining up the comments above it with the marker
+func startListItem(item, indent, marker string) string {
+	lines := strings.SplitAfter(item, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, indent+"#") {
+			lines[i] = marker + strings.TrimPrefix(line, indent)
+			break
+		}
+		lines[i] = strings.TrimRight(marker, "- ") + "  " + strings.TrimPrefix(line, indent)
+	}
+	return strings.Join(lines, "")
+}
+
+// yamlScalar returns value as it's written in a scenario file
+func yamlScalar(value reflect.Value) string {
+	if value.Type() == durationType {
+		return time.Duration(value.Int()).String()
+	}
+	if value.Kind() == reflect.Map && value.Len() == 0 {
+		return "{}"
+	}
+	out, err := yaml.Marshal(value.Interface())
+	if err != nil {
+		panic(err)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
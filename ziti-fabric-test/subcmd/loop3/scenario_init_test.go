@@ -0,0 +1,69 @@
+package loop3
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ScenarioTemplateLoads(t *testing.T) {
+	req := require.New(t)
+
+	out := &bytes.Buffer{}
+	req.NoError(writeScenarioTemplate(out))
+
+	path := filepath.Join(t.TempDir(), "scenario.yml")
+	req.NoError(os.WriteFile(path, out.Bytes(), 0600))
+	scenario, err := LoadScenario(path)
+	req.NoError(err)
+
+	expected := scenarioTemplate()
+	req.Nil(scenario.Metrics)
+	req.Equal(expected.ConnectionDelay, scenario.ConnectionDelay)
+	req.Len(scenario.Workloads, 1)
+	req.Empty(scenario.Workloads[0].Annotations)
+	scenario.Workloads[0].Annotations = nil
+	req.Equal(expected.Workloads, scenario.Workloads)
+
+	req.Contains(out.String(), "# How many blocks to send (default 0)\n      txRequests: 1000\n")
+	req.Contains(out.String(), "# metrics:\n#   # The service to send metrics to\n#   service: \"\"\n")
+}
+
+// every scenario setting has a doc, and every doc is for a setting which still exists
+func Test_ScenarioFieldDocs(t *testing.T) {
+	req := require.New(t)
+
+	settings := map[string]bool{}
+	var collect func(t reflect.Type)
+	collect = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			settings[t.Name()+"."+name] = true
+
+			fieldType := field.Type
+			for fieldType.Kind() == reflect.Ptr || fieldType.Kind() == reflect.Slice {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct && fieldType.PkgPath() == t.PkgPath() && !settings[fieldType.Name()] {
+				settings[fieldType.Name()] = true
+				collect(fieldType)
+			}
+		}
+	}
+	collect(reflect.TypeOf(Scenario{}))
+
+	for setting := range settings {
+		if strings.Contains(setting, ".") {
+			req.Contains(scenarioFieldDocs, setting)
+		}
+	}
+	for setting := range scenarioFieldDocs {
+		req.True(settings[setting], "doc for unknown setting %s", setting)
+	}
+}
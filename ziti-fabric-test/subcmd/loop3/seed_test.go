@@ -0,0 +1,44 @@
+package loop3
+
+import (
+	"testing"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func generateHashed(seed int64) [][]byte {
+	g := newRandomHashedBlockGenerator(3, uniformSizes(16, 256), 0, false, seed)
+	var data [][]byte
+	for i := 0; i < 3; i++ {
+		block, _ := g.Next()
+		data = append(data, block.(*RandHashedBlock).Data)
+	}
+	return data
+}
+
+func Test_SeededGeneratorRepeats(t *testing.T) {
+	req := require.New(t)
+	req.Equal(generateHashed(42), generateHashed(42))
+	req.NotEqual(generateHashed(42), generateHashed(43))
+}
+
+func Test_SeedFromName(t *testing.T) {
+	req := require.New(t)
+
+	first := &loop3_pb.Test{Name: "first"}
+	seedFromName(first)
+	req.NotZero(first.Seed)
+
+	again := &loop3_pb.Test{Name: "first"}
+	seedFromName(again)
+	req.Equal(first.Seed, again.Seed)
+
+	second := &loop3_pb.Test{Name: "second"}
+	seedFromName(second)
+	req.NotEqual(first.Seed, second.Seed)
+
+	explicit := &loop3_pb.Test{Name: "first", Seed: 7}
+	seedFromName(explicit)
+	req.Equal(int64(7), explicit.Seed)
+}
@@ -0,0 +1,52 @@
+package loop3
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RunTestMeasuresSetup(t *testing.T) {
+	req := require.New(t)
+
+	listener := &listenerCmd{}
+	dialerConn, listenerConn := net.Pipe()
+	defer func() { _ = dialerConn.Close() }()
+	defer func() { _ = listenerConn.Close() }()
+	go listener.handle(listenerConn, "setup")
+
+	local := newLoopbackTest("setup")
+	remote := loopbackPeerTest(local)
+
+	cmd := &dialerCmd{}
+	proto, err := newProtocol(dialerConn, &cmd.protocolOptions)
+	req.NoError(err)
+	proto.dialTime = 20 * time.Millisecond
+
+	peerResult, _, err := cmd.runTest(proto, local, remote, newMetadata("setup", ""))
+	req.NoError(err)
+	req.True(peerResult.Success, peerResult.Message)
+	req.Greater(proto.handshakeTime, time.Duration(0), "the test and metadata exchange is timed")
+	req.Equal(proto.dialTime+proto.handshakeTime, peerResult.Setup)
+}
+
+func Test_RepeatedResultsAggregateSetup(t *testing.T) {
+	req := require.New(t)
+
+	repeated := newRepeatedResults()
+	for _, setup := range []time.Duration{10, 30, 20} {
+		repeated.add("short-flows:0", &Result{Success: true, Duration: time.Second, Setup: setup * time.Millisecond})
+	}
+
+	for _, metric := range repeated.aggregate()[0].Metrics {
+		if metric.Name == "setup" {
+			req.Equal(unitDuration, metric.Unit)
+			req.Equal([]float64{1e7, 3e7, 2e7}, metric.Values)
+			req.Equal(float64(20*time.Millisecond), metric.Mean)
+			return
+		}
+	}
+	req.Fail("setup isn't aggregated")
+}
@@ -0,0 +1,60 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// applySocketBuffers sets the socket buffer sizes asked for with --so-sndbuf and --so-rcvbuf, then records the sizes
+// the OS actually uses, which can differ: Linux doubles what it's asked for and caps it at net.core.wmem_max and
+// rmem_max. They're only known for TCP peers, other peers are left alone
+func (p *protocol) applySocketBuffers() error {
+	var peer interface{} = p.peer
+	if p.wire != nil {
+		peer = p.wire.ReadWriteCloser
+	}
+	conn, ok := peer.(*net.TCPConn)
+	if !ok {
+		if p.options.soSndbuf > 0 || p.options.soRcvbuf > 0 {
+			p.logger().Debugf("ignoring socket buffer sizes, the peer is a %T, not a TCP connection", peer)
+		}
+		return nil
+	}
+
+	if p.options.soSndbuf > 0 {
+		if err := conn.SetWriteBuffer(p.options.soSndbuf); err != nil {
+			return errors.Wrapf(err, "unable to set the socket send buffer to %d bytes", p.options.soSndbuf)
+		}
+	}
+	if p.options.soRcvbuf > 0 {
+		if err := conn.SetReadBuffer(p.options.soRcvbuf); err != nil {
+			return errors.Wrapf(err, "unable to set the socket receive buffer to %d bytes", p.options.soRcvbuf)
+		}
+	}
+
+	sndBuf, rcvBuf, err := socketBuffers(conn)
+	if err != nil {
+		p.logger().WithError(err).Debug("unable to read the socket buffer sizes")
+		return nil
+	}
+	p.sndBuf, p.rcvBuf = int32(sndBuf), int32(rcvBuf)
+	p.logger().Infof("socket buffers: send %d bytes, receive %d bytes", sndBuf, rcvBuf)
+	return nil
+}
@@ -0,0 +1,52 @@
+package loop3
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SocketBuffersOfTcpPeer(t *testing.T) {
+	req := require.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	req.NoError(err)
+	defer func() { _ = listener.Close() }()
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			_ = conn.Close()
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	req.NoError(err)
+	defer func() { _ = conn.Close() }()
+
+	p, err := newProtocol(conn, &protocolOptions{soSndbuf: 64 * 1024, soRcvbuf: 32 * 1024})
+	req.NoError(err)
+	p.test = &loop3_pb.Test{Name: "buffers"}
+	req.NoError(p.applySocketBuffers())
+
+	// the OS may round the sizes, but not below what was asked for
+	result := p.result(time.Now(), nil)
+	req.GreaterOrEqual(result.SndBuf, int32(64*1024))
+	req.GreaterOrEqual(result.RcvBuf, int32(32*1024))
+}
+
+func Test_SocketBuffersIgnoredForOtherPeers(t *testing.T) {
+	req := require.New(t)
+
+	conn, peer := net.Pipe()
+	defer func() { _ = conn.Close() }()
+	defer func() { _ = peer.Close() }()
+
+	p, err := newProtocol(conn, &protocolOptions{soSndbuf: 64 * 1024})
+	req.NoError(err)
+	p.test = &loop3_pb.Test{Name: "buffers"}
+	req.NoError(p.applySocketBuffers())
+	req.Zero(p.sndBuf)
+	req.Zero(p.rcvBuf)
+}
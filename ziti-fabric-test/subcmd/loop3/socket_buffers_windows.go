@@ -0,0 +1,31 @@
+//go:build windows
+// +build windows
+
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// socketBuffers isn't supported on Windows, where the sizes can only be set
+func socketBuffers(*net.TCPConn) (sndBuf, rcvBuf int, err error) {
+	return 0, 0, errors.New("reading socket buffer sizes isn't supported on windows")
+}
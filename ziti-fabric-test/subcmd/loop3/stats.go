@@ -0,0 +1,126 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// StatsAccumulator collects what a test measures as it runs: the blocks and bytes sent and received, round trip
+// times and the problems along the way. The txer, rxer and verifier record into it from their own goroutines, and
+// the progress reporter and the final result read it through Snapshot, so they always agree
+type StatsAccumulator struct {
+	txCount      int32
+	rxCount      int32
+	txBytes      int64
+	rxBytes      int64
+	latencyDrops int32
+	txRetries    int32
+	errors       int32
+	corrupted    int32
+	latency      probeStats
+	corruption   corruptionSamples
+}
+
+// RecordTx counts a block of size bytes sent
+func (stats *StatsAccumulator) RecordTx(size int) {
+	atomic.AddInt32(&stats.txCount, 1)
+	atomic.AddInt64(&stats.txBytes, int64(size))
+}
+
+// RecordRx counts a block of size bytes received
+func (stats *StatsAccumulator) RecordRx(size int) {
+	atomic.AddInt32(&stats.rxCount, 1)
+	atomic.AddInt64(&stats.rxBytes, int64(size))
+}
+
+// RecordLatency records the round trip time of the latency request for block sequence, which was sent at sentAt.
+// The sequence and send time are kept for the outlier report
+func (stats *StatsAccumulator) RecordLatency(sequence uint32, sentAt time.Time, rtt time.Duration) {
+	stats.latency.record(sequence, sentAt, rtt)
+}
+
+// RecordLatencyDrop counts a latency request from the peer which went unanswered
+func (stats *StatsAccumulator) RecordLatencyDrop() {
+	atomic.AddInt32(&stats.latencyDrops, 1)
+}
+
+// RecordTxRetry counts a block write retried after a transient error
+func (stats *StatsAccumulator) RecordTxRetry() {
+	atomic.AddInt32(&stats.txRetries, 1)
+}
+
+// RecordError counts an error reported by the test. The errors themselves are kept by the protocol, see fail
+func (stats *StatsAccumulator) RecordError(error) {
+	atomic.AddInt32(&stats.errors, 1)
+}
+
+// RecordCorruption counts a received block which failed its hash check, with --tolerate-corruption
+func (stats *StatsAccumulator) RecordCorruption(sequence int64) {
+	atomic.AddInt32(&stats.corrupted, 1)
+	stats.corruption.record(sequence)
+}
+
+// corruptedSequences returns the sequences of the first corrupted blocks, in the order they were found
+func (stats *StatsAccumulator) corruptedSequences() []int64 {
+	return stats.corruption.sequences()
+}
+
+// resumeFrom counts the blocks before sequence as sent and received, as a resumed test doesn't send them again
+func (stats *StatsAccumulator) resumeFrom(sequence int32) {
+	atomic.StoreInt32(&stats.txCount, sequence)
+	atomic.StoreInt32(&stats.rxCount, sequence)
+}
+
+// TxCount returns the blocks sent so far
+func (stats *StatsAccumulator) TxCount() int32 {
+	return atomic.LoadInt32(&stats.txCount)
+}
+
+// RxCount returns the blocks received so far
+func (stats *StatsAccumulator) RxCount() int32 {
+	return atomic.LoadInt32(&stats.rxCount)
+}
+
+// TxBytes returns the bytes sent so far
+func (stats *StatsAccumulator) TxBytes() int64 {
+	return atomic.LoadInt64(&stats.txBytes)
+}
+
+// RxBytes returns the bytes received so far
+func (stats *StatsAccumulator) RxBytes() int64 {
+	return atomic.LoadInt64(&stats.rxBytes)
+}
+
+// Snapshot returns the measurements so far. Success, Message, Duration and the settings of the connection are up to
+// the caller, as the accumulator doesn't know them
+func (stats *StatsAccumulator) Snapshot() Result {
+	rxCount, corrupted := atomic.LoadInt32(&stats.rxCount), atomic.LoadInt32(&stats.corrupted)
+	return Result{
+		TxCount:        atomic.LoadInt32(&stats.txCount),
+		RxCount:        rxCount,
+		TxBytes:        atomic.LoadInt64(&stats.txBytes),
+		RxBytes:        atomic.LoadInt64(&stats.rxBytes),
+		Latency:        stats.latency.latencyStats(),
+		LatencyDropped: atomic.LoadInt32(&stats.latencyDrops),
+		TxRetries:      atomic.LoadInt32(&stats.txRetries),
+		Errors:         atomic.LoadInt32(&stats.errors),
+		Corrupted:      corrupted,
+		CorruptionRate: corruptionRate(corrupted, rxCount),
+	}
+}
@@ -0,0 +1,78 @@
+package loop3
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StatsAccumulatorSnapshot(t *testing.T) {
+	req := require.New(t)
+
+	stats := &StatsAccumulator{}
+	stats.RecordTx(100)
+	stats.RecordTx(50)
+	stats.RecordRx(200)
+	stats.RecordLatency(1, time.Now(), 10*time.Millisecond)
+	stats.RecordLatency(2, time.Now(), 30*time.Millisecond)
+	stats.RecordLatencyDrop()
+	stats.RecordTxRetry()
+	stats.RecordError(errors.New("first"))
+	stats.RecordError(errors.New("second"))
+
+	result := stats.Snapshot()
+	req.Equal(int32(2), result.TxCount)
+	req.Equal(int64(150), result.TxBytes)
+	req.Equal(int32(1), result.RxCount)
+	req.Equal(int64(200), result.RxBytes)
+	req.Equal(int32(2), result.Latency.Count)
+	req.Equal(20*time.Millisecond, result.Latency.Avg)
+	req.Equal(int32(1), result.LatencyDropped)
+	req.Equal(int32(1), result.TxRetries)
+	req.Equal(int32(2), result.Errors)
+	req.False(result.Success, "success is up to the caller")
+
+	stats.resumeFrom(100)
+	req.Equal(int32(100), stats.TxCount())
+	req.Equal(int32(100), stats.RxCount())
+}
+
+func Test_StatsAccumulatorConcurrentRecords(t *testing.T) {
+	req := require.New(t)
+
+	stats := &StatsAccumulator{}
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				stats.RecordTx(1)
+				stats.RecordRx(2)
+				_ = stats.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	result := stats.Snapshot()
+	req.Equal(int32(4000), result.TxCount)
+	req.Equal(int64(8000), result.RxBytes)
+}
+
+func Test_ProtocolErrorsCounted(t *testing.T) {
+	req := require.New(t)
+
+	p, err := newProtocol(&testPeer{}, &protocolOptions{failFast: false})
+	req.NoError(err)
+	p.test = newLoopbackTest("errors")
+	req.False(p.fail(p.newError(PhaseVerify, 1, errors.New("mismatched hashes"))))
+	req.False(p.fail(p.newError(PhaseVerify, 2, errors.New("mismatched hashes"))))
+
+	result := p.result(time.Now(), p.firstError())
+	req.False(result.Success)
+	req.Equal(int32(2), result.Errors)
+}
@@ -0,0 +1,99 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// With a test's StreamSummary set, the txer ends its stream with a BlockTypeStreamSummary block, carrying a checksum of
+// the sequences of every block it sent. The receiver keeps the same checksum of the blocks it read, and the test only
+// succeeds if the two match. Each block is hashed on its own, so this catches blocks which went missing or were
+// repeated without a trace in any single block, as well as a stream cut short after its last good block.
+
+// streamSummaryPayloadLen is the length of a stream summary's payload, the XOR and the sum of the sequences. The
+// block's sequence is the number of blocks
+const streamSummaryPayloadLen = 4 /* xor */ + 8 /* sum */
+
+// streamChecksum sums up the sequences of a stream of blocks, in any order
+type streamChecksum struct {
+	count uint32
+	xor   uint32
+	sum   uint64
+}
+
+func (checksum *streamChecksum) add(sequence uint32) {
+	checksum.count++
+	checksum.xor ^= sequence
+	checksum.sum += uint64(sequence)
+}
+
+// block returns the stream summary block carrying the checksum
+func (checksum *streamChecksum) block() *RandHashedBlock {
+	data := make([]byte, streamSummaryPayloadLen)
+	binary.LittleEndian.PutUint32(data, checksum.xor)
+	binary.LittleEndian.PutUint64(data[4:], checksum.sum)
+	return &RandHashedBlock{Type: BlockTypeStreamSummary, Sequence: checksum.count, Data: data}
+}
+
+// streamChecksumOf returns the checksum carried by a stream summary block
+func streamChecksumOf(block *RandHashedBlock) (*streamChecksum, error) {
+	if len(block.Data) != streamSummaryPayloadLen {
+		return nil, errors.Errorf("stream summary payload is %d bytes, expected %d", len(block.Data), streamSummaryPayloadLen)
+	}
+	return &streamChecksum{
+		count: block.Sequence,
+		xor:   binary.LittleEndian.Uint32(block.Data),
+		sum:   binary.LittleEndian.Uint64(block.Data[4:]),
+	}, nil
+}
+
+// isStreamSummary returns true for the block ending a stream
+func isStreamSummary(block Block) bool {
+	hashed, ok := block.(*RandHashedBlock)
+	return ok && hashed.Type == BlockTypeStreamSummary
+}
+
+// awaitingStreamSummary returns true while the rxer still has to read the peer's stream summary
+func (p *protocol) awaitingStreamSummary() bool {
+	return p.test.StreamSummary && p.rxSummary == nil
+}
+
+// rxStreamSummary records the peer's stream summary
+func (p *protocol) rxStreamSummary(block *RandHashedBlock) error {
+	summary, err := streamChecksumOf(block)
+	if err != nil {
+		return err
+	}
+	p.rxSummary = summary
+	return nil
+}
+
+// verifyStreamSummary compares the peer's stream summary with the blocks received. It's called once the rxer is done
+func (p *protocol) verifyStreamSummary() error {
+	if p.rxSummary == nil {
+		return errors.Errorf("no stream summary received after %d blocks, the stream was cut short", p.rxChecksum.count)
+	}
+	if *p.rxSummary != p.rxChecksum {
+		return errors.Errorf("stream summary mismatch, the peer sent %d blocks (xor %08x, sum %d) but %d were received (xor %08x, sum %d)",
+			p.rxSummary.count, p.rxSummary.xor, p.rxSummary.sum, p.rxChecksum.count, p.rxChecksum.xor, p.rxChecksum.sum)
+	}
+	p.phaseLogger(PhaseVerify).Debugf("stream summary of %d blocks verified", p.rxChecksum.count)
+	return nil
+}
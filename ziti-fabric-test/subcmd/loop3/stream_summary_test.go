@@ -0,0 +1,87 @@
+package loop3
+
+import (
+	"testing"
+
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StreamSummaryEncoding(t *testing.T) {
+	req := require.New(t)
+
+	checksum := &streamChecksum{}
+	for _, sequence := range []uint32{0, 1, 2, 3, 1 << 31} {
+		checksum.add(sequence)
+	}
+
+	body, err := checksum.block().encode()
+	req.NoError(err)
+	block := &RandHashedBlock{}
+	req.NoError(block.decode(body))
+	req.True(isStreamSummary(block))
+
+	decoded, err := streamChecksumOf(block)
+	req.NoError(err)
+	req.Equal(checksum, decoded)
+
+	block.Data = block.Data[1:]
+	_, err = streamChecksumOf(block)
+	req.EqualError(err, "stream summary payload is 11 bytes, expected 12")
+}
+
+func Test_VerifyStreamSummary(t *testing.T) {
+	req := require.New(t)
+
+	p := &protocol{test: &loop3_pb.Test{StreamSummary: true}}
+	req.True(p.awaitingStreamSummary())
+	req.EqualError(p.verifyStreamSummary(), "no stream summary received after 0 blocks, the stream was cut short")
+
+	sent := streamChecksum{}
+	for sequence := uint32(0); sequence < 4; sequence++ {
+		sent.add(sequence)
+		// blocks 1 and 2 go missing and block 3 arrives three times, so the count still matches
+		if sequence != 1 && sequence != 2 {
+			p.rxChecksum.add(sequence)
+		}
+	}
+	p.rxChecksum.add(3)
+	p.rxChecksum.add(3)
+	req.NoError(p.rxStreamSummary(sent.block()))
+	req.False(p.awaitingStreamSummary())
+	req.Equal(sent.count, p.rxChecksum.count)
+	req.EqualError(p.verifyStreamSummary(), "stream summary mismatch, the peer sent 4 blocks (xor 00000000, sum 6) "+
+		"but 4 were received (xor 00000003, sum 9)")
+
+	p.rxChecksum = sent
+	req.NoError(p.verifyStreamSummary())
+}
+
+func Test_RunWithStreamSummary(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("stream-summary")
+	test.StreamSummary = true
+	result, peerResult := runLoopbackWithOptions(t, test, nil)
+	req.True(result.Success, result.Message)
+	req.EqualValues(50, peerResult.RxCount)
+	req.EqualValues(50, result.RxCount)
+}
+
+func Test_GetTestsEnablesStreamSummary(t *testing.T) {
+	req := require.New(t)
+
+	workload := &Workload{Name: "summary"}
+	local, remote := workload.GetTests()
+	req.True(local.StreamSummary)
+	req.True(remote.StreamSummary)
+
+	workload.Listener.BlockType = "sequential"
+	local, remote = workload.GetTests()
+	req.False(local.StreamSummary)
+	req.False(remote.StreamSummary)
+
+	workload = &Workload{Name: "probe", ProbeMode: true}
+	local, _ = workload.GetTests()
+	req.False(local.StreamSummary)
+}
@@ -0,0 +1,65 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// testTimeout is the dialer's wall clock limit on a test, from the echo check to the peer's result, set with
+// --scenario-timeout. A test which hangs, say with a silent peer and no rx timeout, is stopped when it runs out, which
+// closes the connection and unblocks whatever step the test was waiting in. The test is then reported as timed out,
+// rather than with whatever error the closed connection caused
+type testTimeout struct {
+	p       *protocol
+	name    string
+	limit   time.Duration
+	start   time.Time
+	timer   *time.Timer
+	expired int32
+}
+
+// startTimeout stops p, running the named test, once limit has passed unless cancelled first
+func (p *protocol) startTimeout(name string, limit time.Duration) *testTimeout {
+	timeout := &testTimeout{p: p, name: name, limit: limit, start: time.Now()}
+	timeout.timer = time.AfterFunc(limit, func() {
+		atomic.StoreInt32(&timeout.expired, 1)
+		p.logger().WithField(logFieldTest, name).Errorf("timed out after %v, closing the connection", limit)
+		p.stop()
+	})
+	return timeout
+}
+
+// cancel stops the timer, once the test is done
+func (timeout *testTimeout) cancel() {
+	timeout.timer.Stop()
+}
+
+func (timeout *testTimeout) hasExpired() bool {
+	return atomic.LoadInt32(&timeout.expired) == 1
+}
+
+// result is the failed result of a test which timed out
+func (timeout *testTimeout) result() *Result {
+	err := errors.Errorf("[%s conn %d] timed out after %v", timeout.name, timeout.p.connIndex, timeout.limit)
+	result := timeout.p.result(timeout.start, err)
+	result.exit = exitTimeout
+	return result
+}
@@ -0,0 +1,62 @@
+package loop3
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TestTimeoutStopsHungTest(t *testing.T) {
+	req := require.New(t)
+
+	// the peer accepts the connection, then never answers
+	conn, peer := net.Pipe()
+	defer func() { _ = peer.Close() }()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := peer.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	p, err := newProtocol(conn, nil)
+	req.NoError(err)
+	test := newLoopbackTest("hung")
+	timeout := p.startTimeout(test.Name, 100*time.Millisecond)
+
+	cmd := &dialerCmd{precheck: true}
+	_, _, err = cmd.runTest(p, test, loopbackPeerTest(test), newMetadata("", ""))
+	timeout.cancel()
+	req.Error(err)
+	req.True(timeout.hasExpired())
+
+	result := timeout.result()
+	req.False(result.Success)
+	req.Equal("[hung conn 0] timed out after 100ms", result.Message)
+}
+
+func Test_TestTimeoutNotExpired(t *testing.T) {
+	req := require.New(t)
+
+	conn, peer := net.Pipe()
+	defer func() { _ = conn.Close() }()
+	defer func() { _ = peer.Close() }()
+	go (&listenerCmd{}).handle(peer, "in-time")
+
+	p, err := newProtocol(conn, nil)
+	req.NoError(err)
+	test := newLoopbackTest("in-time")
+	timeout := p.startTimeout(test.Name, time.Minute)
+
+	cmd := &dialerCmd{precheck: true}
+	result, reusable, err := cmd.runTest(p, test, loopbackPeerTest(test), newMetadata("", ""))
+	timeout.cancel()
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+	req.True(reusable)
+	req.False(timeout.hasExpired())
+}
@@ -0,0 +1,60 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import "time"
+
+// throttleWindow is how often a throttled connection is checked for sustained throttling
+const throttleWindow = 10 * time.Second
+
+// rxThrottle caps the rate blocks are read from a connection. Holding off reads pushes back on the sender through the
+// transport, so a fast sender is slowed down rather than dropped.
+type rxThrottle struct {
+	bucket      *tokenBucket
+	windowStart time.Time
+	throttled   time.Duration
+}
+
+// newRxThrottle returns a throttle for bytesPerSec, or nil if bytesPerSec isn't positive
+func newRxThrottle(bytesPerSec int64, now time.Time) *rxThrottle {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	// allow up to 10ms worth of bytes to be read back to back
+	rate := float64(bytesPerSec)
+	return &rxThrottle{
+		bucket:      newTokenBucket(rate, rate/100, now),
+		windowStart: now,
+	}
+}
+
+// take accounts for a block of size bytes read at now, returning how long to wait before reading the next. When a
+// throttle window ends, throttled is how long reads were held back during it if that was more than half the window,
+// and 0 otherwise
+func (t *rxThrottle) take(size int, now time.Time) (wait time.Duration, throttled time.Duration) {
+	wait = t.bucket.take(size, now)
+	t.throttled += wait
+
+	if now.Sub(t.windowStart) >= throttleWindow {
+		if t.throttled > now.Sub(t.windowStart)/2 {
+			throttled = t.throttled
+		}
+		t.windowStart = now
+		t.throttled = 0
+	}
+	return wait, throttled
+}
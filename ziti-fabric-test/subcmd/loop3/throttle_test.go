@@ -0,0 +1,56 @@
+package loop3
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RxThrottle(t *testing.T) {
+	req := require.New(t)
+
+	req.Nil(newRxThrottle(0, time.Now()))
+
+	start := time.Now()
+	throttle := newRxThrottle(1000, start)
+
+	wait, throttled := throttle.take(510, start)
+	req.Equal(500*time.Millisecond, wait)
+	req.Zero(throttled)
+
+	// held back for 9.5s of the 10s window
+	wait, throttled = throttle.take(9000, start.Add(500*time.Millisecond))
+	req.Equal(9*time.Second, wait)
+	req.Zero(throttled, "the window hasn't ended yet")
+	_, throttled = throttle.take(0, start.Add(throttleWindow))
+	req.Equal(9500*time.Millisecond, throttled)
+
+	// a window which is mostly unthrottled isn't reported
+	_, throttled = throttle.take(1000, start.Add(2*throttleWindow))
+	req.Zero(throttled)
+}
+
+func Test_MaxConnRateSlowsSender(t *testing.T) {
+	req := require.New(t)
+
+	listener := &listenerCmd{}
+	listener.maxRxBytesPerSec = 50 * 1000
+	conn, peer := net.Pipe()
+	defer func() { _ = conn.Close() }()
+	go listener.handle(peer, "throttled")
+
+	test := newLoopbackTest("throttled")
+	test.TxRequests = 20
+	test.RxRequests = 1
+	test.PayloadMinBytes = 1000
+	test.PayloadMaxBytes = 1000
+
+	start := time.Now()
+	result, err := runLoopbackTest(conn, test, loopbackPeerTest(test))
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+	// roughly 20 kB at 50 kB/s, less the initial burst
+	req.True(time.Since(start) >= 300*time.Millisecond, "expected the listener to throttle the dialer, took %v", time.Since(start))
+}
@@ -0,0 +1,47 @@
+package loop3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TokenBucket(t *testing.T) {
+	req := require.New(t)
+
+	start := time.Now()
+	b := newTokenBucket(1000, 100, start)
+
+	// the initial capacity can go out immediately
+	req.Equal(time.Duration(0), b.take(100, start))
+
+	// after that, 500 bytes at 1000 bytes/s takes half a second
+	req.Equal(500*time.Millisecond, b.take(500, start))
+
+	// waiting out the debt brings the bucket back to empty, not to capacity
+	req.Equal(100*time.Millisecond, b.take(100, start.Add(500*time.Millisecond)))
+
+	// tokens don't accumulate past capacity
+	req.Equal(time.Duration(0), b.take(100, start.Add(time.Hour)))
+	req.Equal(time.Second, b.take(1000, start.Add(time.Hour)))
+}
+
+func Test_TargetBytesPerSecTakesPrecedence(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("bandwidth")
+	test.TxRequests = 20
+	test.RxRequests = 1
+	test.LatencyFrequency = 0
+	test.PayloadMinBytes = 1000
+	test.PayloadMaxBytes = 1000
+	test.TxPacing = "1h"
+	test.TargetBytesPerSec = 1000 * 1000
+
+	start := time.Now()
+	result, err := RunLoopback(test)
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+	req.True(time.Since(start) < time.Minute, "tx pacing should be ignored when a target bitrate is set")
+}
@@ -0,0 +1,129 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/pkg/errors"
+)
+
+// traceEntry is one block of a recorded flow: the gap since the previous block and the block's size
+type traceEntry struct {
+	gap  time.Duration
+	size int
+}
+
+// loadTrace reads a block size trace from path, see parseTrace for the format
+func loadTrace(path string) ([]traceEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open trace file [%s]", path)
+	}
+	defer func() { _ = f.Close() }()
+
+	trace, err := parseTrace(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid trace file [%s]", path)
+	}
+	return trace, nil
+}
+
+// parseTrace reads one entry per line, a gap and a size separated by whitespace or a comma, such as "1.5ms 1400". The
+// gap is a duration, the size is in bytes. Blank lines and lines starting with # are skipped.
+func parseTrace(r io.Reader) ([]traceEntry, error) {
+	var trace []traceEntry
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) != 2 {
+			return nil, errors.Errorf("line %d: expected a gap and a size, got [%s]", lineNumber, line)
+		}
+		gap, err := time.ParseDuration(fields[0])
+		if err != nil || gap < 0 {
+			return nil, errors.Errorf("line %d: invalid gap [%s], expected a duration such as 1.5ms", lineNumber, fields[0])
+		}
+		size, err := strconv.Atoi(fields[1])
+		if err != nil || size < 1 {
+			return nil, errors.Errorf("line %d: invalid size [%s], expected a positive number of bytes", lineNumber, fields[1])
+		}
+		trace = append(trace, traceEntry{gap: gap, size: size})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(trace) == 0 {
+		return nil, errors.New("no entries found")
+	}
+	return trace, nil
+}
+
+// traceSource replays a trace as random hashed blocks, with the trace's sizes and gaps. The block at sequence n takes
+// its size from entry n of the trace, wrapping around if loop is set and ending with the trace otherwise
+type traceSource struct {
+	blocks  *randomHashedBlockGenerator
+	trace   []traceEntry
+	loop    bool
+	current traceEntry
+}
+
+func newTraceSource(trace []traceEntry, test *loop3_pb.Test, loop bool) *traceSource {
+	blocks := newRandomHashedBlockGenerator(int(test.TxRequests), uniformSizes(0, 0), txLatencyFrequency(test), test.OneWayDelay, test.Seed)
+	blocks.next = int(test.ResumeFrom)
+	return &traceSource{
+		blocks: blocks,
+		trace:  trace,
+		loop:   loop,
+	}
+}
+
+func (source *traceSource) Next() (Block, error) {
+	sequence := source.blocks.next
+	if sequence >= source.blocks.count || (!source.loop && sequence >= len(source.trace)) {
+		return nil, io.EOF
+	}
+	source.blocks.next++
+
+	source.current = source.trace[sequence%len(source.trace)]
+	return source.blocks.block(sequence, source.current.size), nil
+}
+
+func (source *traceSource) gap() time.Duration {
+	return source.current.gap
+}
+
+// limitToTrace has local send at most one block per trace entry, and tells the listener, through remote, to expect
+// that many
+func limitToTrace(local, remote *loop3_pb.Test, entries int) {
+	if int(local.TxRequests) > entries {
+		local.TxRequests = int32(entries)
+		remote.RxRequests = int32(entries)
+	}
+}
@@ -0,0 +1,74 @@
+package loop3
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseTrace(t *testing.T) {
+	req := require.New(t)
+
+	trace, err := parseTrace(strings.NewReader("# gap size\n0s 100\n\n1.5ms,1400\n250us\t64\n"))
+	req.NoError(err)
+	req.Equal([]traceEntry{{0, 100}, {1500 * time.Microsecond, 1400}, {250 * time.Microsecond, 64}}, trace)
+
+	for _, invalid := range []string{"", "# only a comment\n", "1ms\n", "1ms 10 20\n", "fast 10\n", "-1ms 10\n", "1ms 0\n", "1ms big\n"} {
+		_, err = parseTrace(strings.NewReader(invalid))
+		req.Error(err, "expected [%s] to be rejected", invalid)
+	}
+
+	_, err = parseTrace(strings.NewReader("1ms 10\n2ms\n"))
+	req.ErrorContains(err, "line 2")
+}
+
+func traceSizes(source *traceSource) []int {
+	var sizes []int
+	for {
+		block, err := source.Next()
+		if err == io.EOF {
+			return sizes
+		}
+		sizes = append(sizes, len(block.(*RandHashedBlock).Data))
+	}
+}
+
+func Test_TraceSourceLoopsOrStops(t *testing.T) {
+	req := require.New(t)
+	trace := []traceEntry{{time.Millisecond, 10}, {2 * time.Millisecond, 20}}
+
+	test := newLoopbackTest("trace")
+	test.TxRequests = 5
+	req.Equal([]int{10, 20, 10, 20, 10}, traceSizes(newTraceSource(trace, test, true)))
+	req.Equal([]int{10, 20}, traceSizes(newTraceSource(trace, test, false)))
+
+	test.ResumeFrom = 3
+	req.Equal([]int{20, 10}, traceSizes(newTraceSource(trace, test, true)))
+
+	source := newTraceSource(trace, test, true)
+	_, _ = source.Next()
+	req.Equal(2*time.Millisecond, source.gap())
+
+	remote := loopbackPeerTest(test)
+	limitToTrace(test, remote, len(trace))
+	req.Equal(int32(2), test.TxRequests)
+	req.Equal(int32(2), remote.RxRequests)
+}
+
+func Test_TraceReplayKeepsGaps(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("trace-replay")
+	test.TxRequests = 6
+	test.RxRequests = 6
+	trace := []traceEntry{{20 * time.Millisecond, 100}, {10 * time.Millisecond, 2000}}
+
+	result, peerResult := runLoopbackWithOptions(t, test, &protocolOptions{failFast: true, blockSource: newTraceSource(trace, test, true)})
+	req.Equal(int32(6), result.TxCount)
+	req.Equal(int32(6), peerResult.RxCount)
+	// five gaps after the first block, alternating 10ms and 20ms
+	req.True(result.Duration >= 70*time.Millisecond, "expected the trace's gaps to be kept, took %v", result.Duration)
+}
@@ -0,0 +1,156 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/openziti/identity"
+	"github.com/openziti/sdk-golang/ziti"
+	"github.com/openziti/sdk-golang/ziti/config"
+	"github.com/openziti/transport/v2"
+	"github.com/pkg/errors"
+)
+
+const (
+	TransportFabric = "fabric"
+	TransportZiti   = "ziti"
+	TransportTcp    = "tcp"
+	TransportPipe   = "pipe"
+
+	dialTimeout = 30 * time.Second
+)
+
+// Dialer opens the connections loop3 runs its tests over
+type Dialer interface {
+	Dial() (io.ReadWriteCloser, error)
+}
+
+// NewFabricDialer returns a Dialer which connects through a router's transport ingress to serviceId or, if direct is
+// set, straight to the transport endpoint
+func NewFabricDialer(endpoint transport.Address, id, serviceId *identity.TokenId, direct bool) Dialer {
+	return &fabricDialer{
+		endpoint:  endpoint,
+		id:        id,
+		serviceId: serviceId,
+		direct:    direct,
+	}
+}
+
+type fabricDialer struct {
+	endpoint  transport.Address
+	id        *identity.TokenId
+	serviceId *identity.TokenId
+	direct    bool
+}
+
+func (d *fabricDialer) Dial() (io.ReadWriteCloser, error) {
+	if d.direct {
+		return dialDirect(d.endpoint, d.id)
+	}
+	return dialIngress(d.endpoint, d.id, d.serviceId)
+}
+
+// NewZitiDialer returns a Dialer which dials service with the edge SDK, using the identity in configFile or the
+// SDK's default identity if configFile is empty
+func NewZitiDialer(configFile, service string) (Dialer, error) {
+	var context ziti.Context
+	if configFile != "" {
+		zitiCfg, err := config.NewFromFile(configFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load ziti configuration from %s", configFile)
+		}
+		context = ziti.NewContextWithConfig(zitiCfg)
+	} else {
+		context = ziti.NewContext()
+	}
+
+	return &zitiDialer{
+		context: context,
+		service: service,
+	}, nil
+}
+
+// NewZitiServiceDialer returns a Dialer like NewZitiDialer, but first authenticates with the identity in identityFile
+// and makes sure it may dial service. Problems with the identity, such as one which isn't enrolled or has no dial
+// policy for the service, are then reported as such before any test starts, rather than as connections which fail
+func NewZitiServiceDialer(identityFile, service string) (Dialer, error) {
+	zitiCfg, err := config.NewFromFile(identityFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to load ziti identity %s, is it an enrolled identity file?", identityFile)
+	}
+	context := ziti.NewContextWithConfig(zitiCfg)
+	// the SDK can't close a context which failed to authenticate
+	if err = context.Authenticate(); err != nil {
+		return nil, errors.Wrapf(err, "unable to authenticate ziti identity %s", identityFile)
+	}
+	if _, found, err := context.GetServiceId(service); err != nil {
+		context.Close()
+		return nil, errors.Wrapf(err, "unable to list the services of ziti identity %s", identityFile)
+	} else if !found {
+		context.Close()
+		return nil, errors.Errorf("ziti identity %s can't dial service [%s], it doesn't exist or no dial policy grants it", identityFile, service)
+	}
+
+	return &zitiDialer{
+		context: context,
+		service: service,
+	}, nil
+}
+
+type zitiDialer struct {
+	context ziti.Context
+	service string
+}
+
+func (d *zitiDialer) Dial() (io.ReadWriteCloser, error) {
+	return d.context.DialWithOptions(d.service, &ziti.DialOptions{
+		ConnectTimeout: dialTimeout,
+	})
+}
+
+// NewTcpDialer returns a Dialer which opens plain TCP connections to address, which may have a tcp: prefix
+func NewTcpDialer(address string) Dialer {
+	return &tcpDialer{address: strings.TrimPrefix(address, "tcp:")}
+}
+
+type tcpDialer struct {
+	address string
+}
+
+func (d *tcpDialer) Dial() (io.ReadWriteCloser, error) {
+	return net.DialTimeout("tcp", d.address, dialTimeout)
+}
+
+// NewPipeDialer returns a Dialer which connects to handler in memory. Each Dial runs handler on its own goroutine
+// with the far end of the pipe
+func NewPipeDialer(handler func(conn net.Conn)) Dialer {
+	return &pipeDialer{handler: handler}
+}
+
+type pipeDialer struct {
+	handler func(conn net.Conn)
+}
+
+func (d *pipeDialer) Dial() (io.ReadWriteCloser, error) {
+	local, remote := net.Pipe()
+	go d.handler(remote)
+	return local, nil
+}
@@ -0,0 +1,60 @@
+package loop3
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func echo(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	_, _ = io.Copy(conn, conn)
+}
+
+func requireEchoes(req *require.Assertions, dialer Dialer) {
+	conn, err := dialer.Dial()
+	req.NoError(err)
+	defer func() { _ = conn.Close() }()
+
+	msg := []byte("hello, loop3")
+	go func() {
+		_, _ = conn.Write(msg)
+	}()
+
+	read := make([]byte, len(msg))
+	_, err = io.ReadFull(conn, read)
+	req.NoError(err)
+	req.Equal(msg, read)
+}
+
+func Test_PipeDialer(t *testing.T) {
+	requireEchoes(require.New(t), NewPipeDialer(echo))
+}
+
+func Test_TcpDialer(t *testing.T) {
+	req := require.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	req.NoError(err)
+	defer func() { _ = listener.Close() }()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go echo(conn)
+		}
+	}()
+
+	requireEchoes(req, NewTcpDialer("tcp:"+listener.Addr().String()))
+}
+
+func Test_UnknownTransport(t *testing.T) {
+	cmd := &dialerCmd{transport: "carrier-pigeon"}
+	_, err := cmd.newDialer()
+	require.Error(t, err)
+}
@@ -0,0 +1,187 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	loop3_pb "github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+)
+
+// FieldError is a problem with a single setting in a scenario file. Path is where the setting is in the file, such as
+// workloads[0].dialer.payloadMinBytes
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// FieldErrors are all the problems found in a scenario, reported together so they can be fixed in one pass
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	lines := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		lines = append(lines, fieldErr.Error())
+	}
+	return fmt.Sprintf("invalid scenario, %d problem(s) found:\n  %s", len(e), strings.Join(lines, "\n  "))
+}
+
+// validate checks the scenario's settings, returning FieldErrors if any are invalid
+func (scenario *Scenario) validate() error {
+	var errs FieldErrors
+	if scenario.ConnectionDelay < 0 {
+		errs = append(errs, negativeError("connectionDelay", int64(scenario.ConnectionDelay)))
+	}
+	if scenario.Metrics != nil && scenario.Metrics.ReportInterval < 0 {
+		errs = append(errs, FieldError{Path: "metrics.interval", Message: fmt.Sprintf("must not be negative, got %v", scenario.Metrics.ReportInterval)})
+	}
+
+	for i, workload := range scenario.Workloads {
+		path := fmt.Sprintf("workloads[%d]", i)
+		if workload == nil {
+			errs = append(errs, FieldError{Path: path, Message: "must not be empty"})
+			continue
+		}
+		if workload.Concurrency < 0 {
+			errs = append(errs, negativeError(path+".concurrency", int64(workload.Concurrency)))
+		}
+		if workload.FlowWindow < 0 {
+			errs = append(errs, negativeError(path+".flowWindow", int64(workload.FlowWindow)))
+		}
+		if workload.DrainTimeout < 0 {
+			errs = append(errs, negativeError(path+".drainTimeout", int64(workload.DrainTimeout)))
+		}
+		errs = append(errs, validateTest(path+".dialer", &workload.Dialer)...)
+		errs = append(errs, validateTest(path+".listener", &workload.Listener)...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateTest checks one side of a workload, found at path in the scenario file
+func validateTest(path string, test *Test) []FieldError {
+	var errs []FieldError
+
+	counts := []struct {
+		name  string
+		value int64
+	}{
+		{"txRequests", int64(test.TxRequests)},
+		{"rxTimeout", int64(test.RxTimeout)},
+		{"connectTimeout", int64(test.ConnectTimeout)},
+		{"rxIdleTimeout", int64(test.RxIdleTimeout)},
+		{"payloadMinBytes", int64(test.PayloadMinBytes)},
+		{"payloadMaxBytes", int64(test.PayloadMaxBytes)},
+		{"latencyFrequency", int64(test.LatencyFrequency)},
+		{"burstOnMillis", int64(test.BurstOnMillis)},
+		{"burstOffMillis", int64(test.BurstOffMillis)},
+		{"targetBytesPerSec", test.TargetBytesPerSec},
+		{"verifyWorkers", int64(test.VerifyWorkers)},
+		{"payloadSize", int64(test.PayloadSize)},
+	}
+	for _, count := range counts {
+		if count.value < 0 {
+			errs = append(errs, negativeError(path+"."+count.name, count.value))
+		}
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"txPacing", test.TxPacing},
+		{"txMaxJitter", test.TxMaxJitter},
+		{"txPauseEvery", test.TxPauseEvery},
+		{"txPauseFor", test.TxPauseFor},
+		{"rxPacing", test.RxPacing},
+		{"rxMaxJitter", test.RxMaxJitter},
+		{"rxPauseEvery", test.RxPauseEvery},
+		{"rxPauseFor", test.RxPauseFor},
+	}
+	for _, duration := range durations {
+		if duration.value < 0 {
+			errs = append(errs, FieldError{Path: path + "." + duration.name, Message: fmt.Sprintf("must not be negative, got %v", duration.value)})
+		}
+	}
+
+	if test.LatencyRatePerSec < 0 {
+		errs = append(errs, FieldError{Path: path + ".latencyRatePerSec", Message: fmt.Sprintf("must not be negative, got %v", test.LatencyRatePerSec)})
+	}
+	if test.MaxBlocksPerSec < 0 {
+		errs = append(errs, FieldError{Path: path + ".maxBlocksPerSec", Message: fmt.Sprintf("must not be negative, got %v", test.MaxBlocksPerSec)})
+	}
+	if err := checkPayloadDistribution(test.PayloadDistribution); err != nil {
+		errs = append(errs, FieldError{Path: path + ".payloadDistribution", Message: err.Error()})
+	}
+	for _, param := range []struct {
+		name  string
+		value float64
+	}{
+		{"payloadMeanBytes", test.PayloadMeanBytes},
+		{"payloadStddevBytes", test.PayloadStddevBytes},
+		{"payloadParetoAlpha", test.PayloadParetoAlpha},
+	} {
+		if param.value < 0 {
+			errs = append(errs, FieldError{Path: path + "." + param.name, Message: fmt.Sprintf("must not be negative, got %v", param.value)})
+		}
+	}
+
+	if test.PayloadMaxBytes > 0 && test.PayloadMaxBytes < test.PayloadMinBytes {
+		errs = append(errs, FieldError{
+			Path:    path + ".payloadMaxBytes",
+			Message: fmt.Sprintf("must be 0 or at least payloadMinBytes (%d), got %d", test.PayloadMinBytes, test.PayloadMaxBytes),
+		})
+	}
+
+	if test.PayloadSize > 0 && ((test.PayloadMinBytes > 0 && test.PayloadMinBytes != test.PayloadSize) ||
+		(test.PayloadMaxBytes > 0 && test.PayloadMaxBytes != test.PayloadSize)) {
+		errs = append(errs, FieldError{
+			Path: path + ".payloadSize",
+			Message: fmt.Sprintf("sets both payloadMinBytes and payloadMaxBytes, so they must be 0 or the same, got %d with %d and %d",
+				test.PayloadSize, test.PayloadMinBytes, test.PayloadMaxBytes),
+		})
+	}
+
+	if test.BlockType != "" && test.BlockType != loop3_pb.BlockTypeRandomHashed && test.BlockType != loop3_pb.BlockTypeSequential {
+		errs = append(errs, FieldError{
+			Path:    path + ".blockType",
+			Message: fmt.Sprintf("must be one of %s or %s, got [%s]", loop3_pb.BlockTypeRandomHashed, loop3_pb.BlockTypeSequential, test.BlockType),
+		})
+	}
+
+	if (test.BurstOnMillis > 0) != (test.BurstOffMillis > 0) {
+		errs = append(errs, FieldError{
+			Path:    path + ".burstOnMillis",
+			Message: fmt.Sprintf("must be set along with burstOffMillis, got %d and %d", test.BurstOnMillis, test.BurstOffMillis),
+		})
+	}
+
+	return errs
+}
+
+func negativeError(path string, value int64) FieldError {
+	return FieldError{Path: path, Message: fmt.Sprintf("must not be negative, got %d", value)}
+}
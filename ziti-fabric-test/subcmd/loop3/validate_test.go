@@ -0,0 +1,77 @@
+package loop3
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadScenarioReportsEveryFieldError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenario.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+workloads:
+  - name: ok
+    concurrency: 1
+    dialer:
+      txRequests: 10
+      payloadMinBytes: 64
+  - name: bad
+    concurrency: -2
+    flowWindow: -1
+    dialer:
+      payloadMinBytes: -1
+      verifyWorkers: -4
+      blockType: md5
+      burstOnMillis: 100
+    listener:
+      payloadMinBytes: 100
+      payloadMaxBytes: 10
+      txPacing: -1s
+`), 0644))
+
+	scenario, err := LoadScenario(path)
+	assert.Nil(t, scenario)
+	require.Error(t, err)
+
+	fieldErrs, ok := err.(FieldErrors)
+	require.True(t, ok, "expected FieldErrors, got %T", err)
+
+	var paths []string
+	for _, fieldErr := range fieldErrs {
+		paths = append(paths, fieldErr.Path)
+	}
+	assert.Equal(t, []string{
+		"workloads[1].concurrency",
+		"workloads[1].flowWindow",
+		"workloads[1].dialer.payloadMinBytes",
+		"workloads[1].dialer.verifyWorkers",
+		"workloads[1].dialer.blockType",
+		"workloads[1].dialer.burstOnMillis",
+		"workloads[1].listener.txPacing",
+		"workloads[1].listener.payloadMaxBytes",
+	}, paths)
+
+	assert.Contains(t, err.Error(), "workloads[1].dialer.payloadMinBytes: must not be negative, got -1")
+	assert.Contains(t, err.Error(), "workloads[1].dialer.blockType: must be one of random-hashed or sequential, got [md5]")
+}
+
+func Test_ValidateTestAcceptsDefaults(t *testing.T) {
+	assert.Empty(t, validateTest("dialer", &Test{}))
+	assert.Empty(t, validateTest("dialer", &Test{PayloadMinBytes: 64, PayloadMaxBytes: 64, BlockType: "sequential", BurstOnMillis: 10, BurstOffMillis: 10}))
+}
+
+func Test_ValidatePayloadSize(t *testing.T) {
+	assert.Empty(t, validateTest("dialer", &Test{PayloadSize: 1024}))
+	assert.Empty(t, validateTest("dialer", &Test{PayloadSize: 1024, PayloadMinBytes: 1024, PayloadMaxBytes: 1024}))
+
+	for _, test := range []*Test{{PayloadSize: 1024, PayloadMinBytes: 64}, {PayloadSize: 1024, PayloadMaxBytes: 4096}} {
+		errs := validateTest("dialer", test)
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, "dialer.payloadSize", errs[0].Path)
+		}
+	}
+	assert.Equal(t, "dialer.payloadSize", validateTest("dialer", &Test{PayloadSize: -1})[0].Path)
+}
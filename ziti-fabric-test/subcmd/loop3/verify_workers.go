@@ -0,0 +1,78 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"sync"
+)
+
+// hashWorkers hash random hashed blocks for the verifier when a test's VerifyWorkers is more than 1. The verifier still
+// checks each block's sequence as it arrives, then hands the block to the next free worker, so hashing is the only
+// part done out of order. A mismatch fails the test from the worker, which with fail fast closes the peer, unless
+// corruption is tolerated
+type hashWorkers struct {
+	p      *protocol
+	blocks chan *RandHashedBlock
+	wg     sync.WaitGroup
+}
+
+// parallelVerify returns true if the test's blocks are hashed by hashWorkers
+func (p *protocol) parallelVerify() bool {
+	return p.test.VerifyWorkers > 1 && p.test.IsRxRandomHashed() && !p.test.ProbeMode
+}
+
+// startHashWorkers starts n workers hashing blocks for p
+func (p *protocol) startHashWorkers(n int) *hashWorkers {
+	workers := &hashWorkers{p: p, blocks: make(chan *RandHashedBlock, n)}
+	workers.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go workers.run()
+	}
+	p.phaseLogger(PhaseVerify).Infof("verifying hashes on %d workers", n)
+	return workers
+}
+
+func (workers *hashWorkers) run() {
+	defer workers.wg.Done()
+	for block := range workers.blocks {
+		if workers.p.isStopped() {
+			continue
+		}
+		if err := block.verifyHash(); err != nil {
+			workers.p.verifyFailed(int64(block.Sequence), err)
+		}
+	}
+}
+
+// verify checks the block's sequence and queues it to be hashed, waiting for a worker to be free if they're all busy.
+// Sequence errors are returned, hash mismatches are reported by the worker
+func (workers *hashWorkers) verify(block *RandHashedBlock) error {
+	if err := block.verifySequence(workers.p); err != nil {
+		return err
+	}
+	select {
+	case workers.blocks <- block:
+	case <-workers.p.stopped:
+	}
+	return nil
+}
+
+// stop waits for the workers to hash the blocks queued so far, so their errors are in before the test is summed up
+func (workers *hashWorkers) stop() {
+	close(workers.blocks)
+	workers.wg.Wait()
+}
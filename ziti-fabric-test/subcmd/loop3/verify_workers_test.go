@@ -0,0 +1,117 @@
+package loop3
+
+import (
+	"crypto/sha512"
+	"net"
+	"testing"
+
+	"github.com/openziti/ziti/ziti-fabric-test/subcmd/loop3/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func hashedBlock(sequence uint32, data []byte) *RandHashedBlock {
+	hash := sha512.Sum512(data)
+	return &RandHashedBlock{Type: BlockTypePlain, Sequence: sequence, Data: data, Hash: hash[:]}
+}
+
+func Test_VerifyWorkersVerifyInParallel(t *testing.T) {
+	req := require.New(t)
+
+	conn, peer := net.Pipe()
+	defer func() { _ = peer.Close() }()
+
+	p, err := newProtocol(conn, &protocolOptions{failFast: false})
+	req.NoError(err)
+	p.test = &loop3_pb.Test{Name: "workers", RxTimeout: 5000, VerifyWorkers: 4}
+	req.True(p.parallelVerify())
+
+	go func() {
+		for i := uint32(0); i < 100; i++ {
+			if i == 70 {
+				continue
+			}
+			block := hashedBlock(i, []byte{byte(i), 1, 2, 3})
+			if i == 40 {
+				block.Data = []byte("bad")
+			}
+			p.rxBlocks <- block
+		}
+		close(p.rxBlocks)
+	}()
+	p.verifier(make(chan struct{}))
+
+	req.False(p.isStopped())
+	req.Len(p.errors, 2, "the bad hash and the sequence gap should both be reported")
+	sequences := map[int64]string{}
+	for len(p.errors) > 0 {
+		protocolErr := (<-p.errors).(*ProtocolError)
+		sequences[protocolErr.Sequence] = protocolErr.Err.Error()
+	}
+	req.Equal(map[int64]string{40: "mismatched hashes", 71: "expected sequence [70] got sequence [71]"}, sequences)
+	req.Equal(uint64(100), p.rxSequence)
+}
+
+func Test_VerifyWorkersFailFast(t *testing.T) {
+	req := require.New(t)
+
+	conn, peer := net.Pipe()
+	defer func() { _ = peer.Close() }()
+
+	p, err := newProtocol(conn, &protocolOptions{failFast: true})
+	req.NoError(err)
+	p.test = &loop3_pb.Test{Name: "workers-fail-fast", RxTimeout: 5000, VerifyWorkers: 2}
+
+	go func() {
+		p.rxBlocks <- hashedBlock(0, []byte("good"))
+		bad := hashedBlock(1, []byte("good"))
+		bad.Data = []byte("bad")
+		p.rxBlocks <- bad
+		// the verifier stops taking blocks once a worker has failed the test
+		for i := uint32(2); ; i++ {
+			select {
+			case p.rxBlocks <- hashedBlock(i, []byte("good")):
+			case <-p.stopped:
+				return
+			}
+		}
+	}()
+	p.verifier(make(chan struct{}))
+
+	req.True(p.isStopped())
+	_, err = conn.Write([]byte{0})
+	req.Error(err, "the peer should be closed")
+	req.Len(p.errors, 1)
+
+	var protocolErr *ProtocolError
+	req.ErrorAs(p.firstError(), &protocolErr)
+	req.Equal(PhaseVerify, protocolErr.Phase)
+	req.Equal(int64(1), protocolErr.Sequence)
+}
+
+func Test_VerifyWorkersOnlyForHashedBlocks(t *testing.T) {
+	req := require.New(t)
+
+	p := &protocol{test: &loop3_pb.Test{VerifyWorkers: 4}}
+	req.True(p.parallelVerify())
+
+	p.test.VerifyWorkers = 1
+	req.False(p.parallelVerify())
+
+	p.test = &loop3_pb.Test{VerifyWorkers: 4, RxBlockType: loop3_pb.BlockTypeSequential}
+	req.False(p.parallelVerify())
+
+	p.test = &loop3_pb.Test{VerifyWorkers: 4, ProbeMode: true}
+	req.False(p.parallelVerify())
+}
+
+func Test_RunLoopbackVerifyWorkers(t *testing.T) {
+	req := require.New(t)
+
+	test := newLoopbackTest("verify-workers")
+	test.VerifyWorkers = 4
+
+	result, err := RunLoopback(test)
+	req.NoError(err)
+	req.True(result.Success, result.Message)
+	req.Equal(test.TxRequests, result.RxCount)
+}
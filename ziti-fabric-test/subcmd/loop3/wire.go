@@ -0,0 +1,68 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package loop3
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/openziti/foundation/v2/info"
+)
+
+// countingConn counts every byte read from and written to a connection, including the magic headers and lengths
+// framing each message, so protocol overhead can be told apart from the blocks themselves
+type countingConn struct {
+	io.ReadWriteCloser
+	read    int64
+	written int64
+}
+
+func newCountingConn(conn io.ReadWriteCloser) *countingConn {
+	return &countingConn{ReadWriteCloser: conn}
+}
+
+func (conn *countingConn) Read(b []byte) (int, error) {
+	n, err := conn.ReadWriteCloser.Read(b)
+	atomic.AddInt64(&conn.read, int64(n))
+	return n, err
+}
+
+func (conn *countingConn) Write(b []byte) (int, error) {
+	n, err := conn.ReadWriteCloser.Write(b)
+	atomic.AddInt64(&conn.written, int64(n))
+	return n, err
+}
+
+func (conn *countingConn) snapshot() (read int64, written int64) {
+	return atomic.LoadInt64(&conn.read), atomic.LoadInt64(&conn.written)
+}
+
+// wireSummary compares the bytes which crossed the connection with the bytes of the blocks sent and received. The
+// wire counts also cover the messages exchanged around the test, such as the test parameters and results
+func (r *Result) wireSummary() string {
+	return fmt.Sprintf("tx %s on the wire for %s of blocks (%s), rx %s on the wire for %s of blocks (%s)",
+		info.ByteCount(r.TxWireBytes), info.ByteCount(r.TxBytes), overhead(r.TxWireBytes, r.TxBytes),
+		info.ByteCount(r.RxWireBytes), info.ByteCount(r.RxBytes), overhead(r.RxWireBytes, r.RxBytes))
+}
+
+func overhead(wire, blocks int64) string {
+	if blocks == 0 {
+		return "no blocks"
+	}
+	return fmt.Sprintf("%.1f%% overhead", 100*float64(wire-blocks)/float64(blocks))
+}
@@ -0,0 +1,33 @@
+package loop3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CountingConnCountsFraming(t *testing.T) {
+	req := require.New(t)
+
+	p, err := newProtocol(&testPeer{}, nil)
+	req.NoError(err)
+	test := newLoopbackTest("wire")
+	req.NoError(p.txTest(test))
+	read := newLoopbackTest("")
+	req.NoError(p.framing.ReadMessage(read))
+
+	rx, tx := p.wire.snapshot()
+	req.Equal(tx, rx)
+	// magic header and length around the message
+	req.True(tx > int64(8), "expected framing and body to be counted, got %d", tx)
+}
+
+func Test_RunReportsWireBytes(t *testing.T) {
+	req := require.New(t)
+
+	result, peerResult := runLoopbackWithOptions(t, newLoopbackTest("wire-bytes"), nil)
+	req.True(result.TxWireBytes > result.TxBytes, "wire bytes should include framing: %s", result.wireSummary())
+	req.True(result.RxWireBytes > result.RxBytes, "wire bytes should include framing: %s", result.wireSummary())
+	// everything the dialer wrote, the test and metadata included, was read by the listener before it finished
+	req.Equal(result.TxWireBytes, peerResult.RxWireBytes)
+}
@@ -17,10 +17,21 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/openziti/ziti/common/version"
 	"github.com/openziti/ziti/ziti/cmd/common"
 	cmdHelper "github.com/openziti/ziti/ziti/cmd/helpers"
 	"github.com/openziti/ziti/ziti/constants"
+	"io"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/openziti/channel/v2"
@@ -29,25 +40,59 @@ import (
 	fabForwarder "github.com/openziti/fabric/router/forwarder"
 	foundation "github.com/openziti/transport/v2"
 	fabXweb "github.com/openziti/xweb/v2"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 )
 
 const (
-	optionVerbose      = "verbose"
-	defaultVerbose     = false
-	verboseDescription = "Enable verbose logging. Logging will be sent to stdout if the config output is sent to a file. If output is sent to stdout, logging will be sent to stderr"
-	optionOutput       = "output"
-	defaultOutput      = "stdout"
-	outputDescription  = "designated output destination for config, use \"stdout\" or a filepath."
+	optionVerbose       = "verbose"
+	defaultVerbose      = false
+	verboseDescription  = "Enable verbose logging. Logging will be sent to stdout if the config output is sent to a file. If output is sent to stdout, logging will be sent to stderr"
+	optionOutput        = "output"
+	defaultOutput       = "stdout"
+	outputDescription   = "designated output destination for config, use \"stdout\" or a filepath."
+	optionDefaults      = "defaults"
+	defaultDefaults     = ""
+	defaultsDescription = "A yaml file of flag name to value defaults, " + defaultsFileName + " in the current directory is used if present. " +
+		"Precedence is: explicit flag > " + defaultsEnvPrefix + "_<FLAG> environment variable > defaults file > built-in default"
+	defaultsFileName    = ".ziti-config-defaults.yml"
+	defaultsEnvPrefix   = "ZITI_CREATE_CONFIG"
+	optionLogFile       = "log-file"
+	defaultLogFile      = ""
+	logFileDescription  = "Also write logs to this file. It gets debug logs even without --" + optionVerbose + ", and is appended to if it exists"
+	optionStamp         = "stamp"
+	defaultStamp        = true
+	stampDescription    = "Start the config with a comment recording the ziti version, time and flags it was generated with"
+	optionFileMode      = "file-mode"
+	defaultFileMode     = "0644"
+	fileModeDescription = "The permissions, in octal, the --" + optionOutput + " file is written with whatever the umask. " +
+		"Use 0600 for configs which shouldn't be readable by other users"
 )
 
 // CreateConfigOptions the options for the create config command
 type CreateConfigOptions struct {
 	common.CommonOptions
 
-	Output       string
+	Output       string `flag:"output" affects:"where the config is written"`
 	DatabaseFile string
+	DefaultsFile string `flag:"defaults" affects:"the default of any flag it sets"`
+	LogFile      string `flag:"log-file" affects:"nothing in the config, copies the logs to a file"`
+	// Tee copies the config to stdout when it's written to a file, only the router commands offer it
+	Tee   bool `flag:"tee" affects:"nothing in the config, copies it to stdout"`
+	Stamp bool `flag:"stamp" affects:"a comment at the top of the config"`
+	// FileMode is the octal permissions of the config file
+	FileMode string `flag:"file-mode" affects:"nothing in the config, the permissions of the file"`
+	// ChecksumOutput writes the SHA-256 of the config next to it, or to stderr when it goes to stdout
+	ChecksumOutput bool `flag:"checksum-output" affects:"nothing in the config, writes its SHA-256 to a .sha256 file or stderr"`
+	// DumpValues is stderr or a file to write the resolved template values to instead of rendering the config
+	DumpValues string `flag:"dump-values" affects:"the whole config, which is replaced by the template values"`
+
+	logFile      *os.File
+	flagSources  map[string]string
+	valueSources *valueSources
 }
 
 type ConfigTemplateValues struct {
@@ -56,6 +101,8 @@ type ConfigTemplateValues struct {
 
 	Controller ControllerTemplateValues
 	Router     RouterTemplateValues
+	// Features are the optional sections enabled with --enable, which templates test with {{ if .Features.<name> }}
+	Features map[string]bool
 }
 
 type ControllerTemplateValues struct {
@@ -129,6 +176,19 @@ type RouterTemplateValues struct {
 	Wss                WSSRouterTemplateValues
 	Forwarder          RouterForwarderTemplateValues
 	Listener           RouterListenerTemplateValues
+	Metrics            RouterMetricsTemplateValues
+	Logging            RouterLoggingTemplateValues
+	// CtrlEndpoints are the host:port addresses of the controllers to connect to, rendered as a list when
+	// there is more than one. When empty the controller's advertised address and port are used.
+	CtrlEndpoints []string
+	// CtrlProbe is how the router checks the controllers are responsive, rendered only along with multiple
+	// CtrlEndpoints, as there's nothing to fail over to otherwise
+	CtrlProbe RouterCtrlProbeTemplateValues
+}
+
+type RouterCtrlProbeTemplateValues struct {
+	Interval time.Duration
+	Timeout  time.Duration
 }
 
 type EdgeRouterTemplateValues struct {
@@ -138,6 +198,16 @@ type EdgeRouterTemplateValues struct {
 	AdvertisedHost   string
 	LanInterface     string
 	ListenerBindPort string
+	// BindHost is the address the edge listener binds to
+	BindHost string
+	// WssAdvertisedPort is the port advertised for the edge listener when wss is enabled
+	WssAdvertisedPort string
+	// AdvertisedPort is the port advertised for the edge listener when peers reach it on a different port than Port,
+	// such as through NAT or a container port mapping. When empty Port is advertised
+	AdvertisedPort string
+	// Resolver is the udp://host:port the tproxy tunneler answers DNS queries on. When empty port 53 of
+	// AdvertisedHost is used
+	Resolver string
 }
 
 type WSSRouterTemplateValues struct {
@@ -166,9 +236,37 @@ type RouterListenerTemplateValues struct {
 	OutQueueSize      int
 }
 
+type RouterMetricsTemplateValues struct {
+	ReportInterval time.Duration
+}
+
+type RouterLoggingTemplateValues struct {
+	Level  string
+	Format string
+}
+
 var workingDir string
 var data = &ConfigTemplateValues{}
 
+// configTemplateFuncs are the functions available to the embedded config templates
+var configTemplateFuncs = template.FuncMap{
+	"yamlQuote":    yamlQuote,
+	"joinHostPort": net.JoinHostPort,
+}
+
+// yamlQuote renders a value as a double-quoted YAML scalar, so user supplied values containing characters such as
+// ':', '#', '*' or '@', or leading whitespace, can't change the meaning of the generated config
+func yamlQuote(value string) string {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		// encoding a string can't fail, but never emit an unquoted value
+		return strconv.Quote(value)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
 func init() {
 	zh := os.Getenv("ZITI_HOME")
 	if zh == "" {
@@ -196,6 +294,7 @@ func NewCmdCreateConfig() *cobra.Command {
 	cmd.AddCommand(NewCmdCreateConfigController())
 	cmd.AddCommand(NewCmdCreateConfigRouter())
 	cmd.AddCommand(NewCmdCreateConfigEnvironment())
+	cmd.AddCommand(NewCmdCreateConfigTopology())
 
 	return cmd
 }
@@ -204,6 +303,224 @@ func NewCmdCreateConfig() *cobra.Command {
 func (options *CreateConfigOptions) addCreateFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().BoolVarP(&options.Verbose, optionVerbose, "v", defaultVerbose, verboseDescription)
 	cmd.PersistentFlags().StringVarP(&options.Output, optionOutput, "o", defaultOutput, outputDescription)
+	cmd.PersistentFlags().StringVar(&options.DefaultsFile, optionDefaults, defaultDefaults, defaultsDescription)
+	cmd.PersistentFlags().StringVar(&options.LogFile, optionLogFile, defaultLogFile, logFileDescription)
+	cmd.PersistentFlags().BoolVar(&options.Stamp, optionStamp, defaultStamp, stampDescription)
+	cmd.PersistentFlags().StringVar(&options.FileMode, optionFileMode, defaultFileMode, fileModeDescription)
+	cmd.PersistentFlags().StringVar(&options.DumpValues, optionDumpValues, defaultDumpValues, dumpValuesDescription)
+	cmd.PersistentFlags().Lookup(optionDumpValues).NoOptDefVal = dumpValuesStderr
+	cmd.PersistentFlags().BoolVar(&options.ChecksumOutput, optionChecksumOutput, defaultChecksumOutput, checksumOutputDescription)
+}
+
+// stampTime is the generation time recorded by --stamp
+var stampTime = time.Now
+
+// stampHeader returns the comment a config starts with when --stamp is set, or an empty string. It records the ziti
+// version, the time and the flags the config was generated with, including those set from the environment or the
+// defaults file. commentPrefix starts the comment, as the environment command's output isn't always yaml.
+func (options *CreateConfigOptions) stampHeader(commentPrefix string) string {
+	if !options.Stamp {
+		return ""
+	}
+
+	header := fmt.Sprintf("%s generated by ziti %s at %s", commentPrefix, version.GetVersion(), stampTime().Format(time.RFC3339))
+	if options.Cmd != nil {
+		var flags []string
+		options.Cmd.Flags().Visit(func(flag *pflag.Flag) {
+			value := flag.Value.String()
+			if value == "" || strings.ContainsAny(value, " \t\n\"'") {
+				value = strconv.Quote(value)
+			}
+			flags = append(flags, "--"+flag.Name+"="+value)
+		})
+		if len(flags) > 0 {
+			header += " with flags " + strings.Join(flags, " ")
+		}
+	}
+	return header + "\n"
+}
+
+// setupLogging sends logs, when --verbose is set, to whichever of stdout or stderr the config isn't written to, and
+// to the --log-file when one is given
+func (options *CreateConfigOptions) setupLogging(configOnStdout bool) error {
+	var outputs []io.Writer
+	if options.Verbose {
+		// Only print log to stdout if not printing config to stdout
+		if configOnStdout {
+			outputs = append(outputs, os.Stderr)
+		} else {
+			outputs = append(outputs, os.Stdout)
+		}
+	}
+
+	if options.LogFile != "" {
+		if strings.ToLower(options.LogFile) == "stdout" || (options.Output != "" && filepath.Clean(options.LogFile) == filepath.Clean(options.Output)) {
+			return errors.Errorf("Invalid value for --%s [%s], logs can't be written to the config output", optionLogFile, options.LogFile)
+		}
+		f, err := os.OpenFile(options.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return errors.Wrapf(err, "unable to open log file: %s", options.LogFile)
+		}
+		options.logFile = f
+		outputs = append(outputs, f)
+	}
+
+	if len(outputs) == 0 {
+		return nil
+	}
+	logrus.SetLevel(logrus.DebugLevel)
+	if len(outputs) == 1 {
+		logrus.SetOutput(outputs[0])
+	} else {
+		logrus.SetOutput(io.MultiWriter(outputs...))
+	}
+	return nil
+}
+
+// closeLogging resets log output after a run completes, closing the log file if there is one
+func (options *CreateConfigOptions) closeLogging() {
+	logrus.SetOutput(os.Stdout)
+	if options.logFile != nil {
+		_ = options.logFile.Close()
+		options.logFile = nil
+	}
+}
+
+// outputWriter returns where the config is written: options.Out when the caller supplies a writer, otherwise stdout or
+// the --output file, along with stdout if --tee is set. Closing it closes the file, never stdout or options.Out, and
+// writes the --checksum-output checksum. Use abortOutput to close it when the config wasn't written in full.
+func (options *CreateConfigOptions) outputWriter() (io.WriteCloser, error) {
+	out, err := options.openOutput()
+	if err != nil || !options.ChecksumOutput {
+		return out, err
+	}
+	return &checksumWriteCloser{WriteCloser: out, hash: sha256.New(), options: options}, nil
+}
+
+// openOutput opens the writer outputWriter returns
+func (options *CreateConfigOptions) openOutput() (io.WriteCloser, error) {
+	if options.Out != nil {
+		return nopWriteCloser{options.Out}, nil
+	}
+	if strings.ToLower(options.Output) == "stdout" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+
+	// Check if the path exists, fail if it doesn't
+	basePath := filepath.Dir(options.Output) + "/"
+	if _, err := os.Stat(filepath.Dir(basePath)); os.IsNotExist(err) {
+		return nil, err
+	}
+
+	mode, err := options.fileMode()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(options.Output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create config file: %s", options.Output)
+	}
+	// the mode given to OpenFile is masked by the umask and ignored for an existing file
+	if err = f.Chmod(mode); err != nil {
+		_ = f.Close()
+		return nil, errors.Wrapf(err, "unable to set the permissions of config file: %s", options.Output)
+	}
+	logrus.Debugf("Created output file: %s", options.Output)
+
+	if options.Tee {
+		return &teeWriteCloser{Writer: io.MultiWriter(f, os.Stdout), file: f}, nil
+	}
+	return f, nil
+}
+
+// fileMode returns the --file-mode permissions, defaulting to 0644 when options weren't set up by the flags
+func (options *CreateConfigOptions) fileMode() (os.FileMode, error) {
+	if options.FileMode == "" {
+		return 0644, nil
+	}
+	mode, err := strconv.ParseUint(options.FileMode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, errors.Errorf("Invalid value for --%s [%s], must be octal permissions such as 0600 or 0644", optionFileMode, options.FileMode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// nopWriteCloser is a writer the command doesn't own, so closing it does nothing
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// teeWriteCloser writes to both a file and stdout, closing only the file
+type teeWriteCloser struct {
+	io.Writer
+	file *os.File
+}
+
+func (w *teeWriteCloser) Close() error {
+	return w.file.Close()
+}
+
+// applyDefaults sets any flag of cmd which wasn't given on the command line from the environment or the defaults
+// file, in that order. Flag values in the file are keyed by flag name, lists may be given as yaml lists.
+func (options *CreateConfigOptions) applyDefaults(cmd *cobra.Command) error {
+	v := viper.New()
+	v.SetEnvPrefix(defaultsEnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+
+	defaultsFile := options.DefaultsFile
+	if defaultsFile == "" {
+		if _, err := os.Stat(defaultsFileName); err == nil {
+			defaultsFile = defaultsFileName
+		}
+	}
+	if defaultsFile != "" {
+		v.SetConfigFile(defaultsFile)
+		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil {
+			return errors.Wrapf(err, "unable to read defaults file: %s", defaultsFile)
+		}
+		logrus.Debugf("Using flag defaults from: %s", defaultsFile)
+	}
+
+	var err error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Changed || flag.Name == optionDefaults || !v.IsSet(flag.Name) {
+			return
+		}
+
+		if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+			var values []string
+			if value, isString := v.Get(flag.Name).(string); isString {
+				values = strings.Split(value, ",")
+			} else {
+				values = v.GetStringSlice(flag.Name)
+			}
+			if err = sliceValue.Replace(values); err == nil {
+				flag.Changed = true
+			}
+		} else {
+			err = cmd.Flags().Set(flag.Name, v.GetString(flag.Name))
+		}
+
+		if err != nil {
+			err = errors.Wrapf(err, "invalid default for --%s", flag.Name)
+		} else if _, fromEnv := os.LookupEnv(defaultsEnvVar(flag.Name)); fromEnv {
+			options.recordFlagSource(flag.Name, sourceEnvironment)
+		} else {
+			options.recordFlagSource(flag.Name, sourceDefaultsFile)
+		}
+	})
+	return err
+}
+
+// defaultsEnvVar returns the environment variable applyDefaults reads the default for a flag from
+func defaultsEnvVar(flagName string) string {
+	return defaultsEnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
 }
 
 func (data *ConfigTemplateValues) populateEnvVars() {
@@ -278,6 +595,8 @@ func (data *ConfigTemplateValues) populateEnvVars() {
 
 func (data *ConfigTemplateValues) populateDefaults() {
 	data.Router.Listener.GetSessionTimeout = constants.DefaultGetSessionTimeout
+	data.Router.CtrlProbe.Interval = defaultHaCtrlProbeInterval
+	data.Router.CtrlProbe.Timeout = defaultHaCtrlProbeTimeout
 
 	data.Controller.MinQueuedConnects = channel.MinQueuedConnects
 	data.Controller.MaxQueuedConnects = channel.MaxQueuedConnects
@@ -315,6 +634,8 @@ func (data *ConfigTemplateValues) populateDefaults() {
 	data.Router.Forwarder.LinkDialWorkerCount = fabForwarder.DefaultLinkDialWorkerCount
 	data.Router.Listener.OutQueueSize = channel.DefaultOutQueueSize
 	data.Router.Listener.ConnectTimeout = channel.DefaultConnectTimeout
+	data.Router.Edge.WssAdvertisedPort = defaultWssAdvertisedPort
+	data.Router.Edge.BindHost = defaultEdgeBindHost
 }
 
 func handleVariableError(err error, varName string) {
@@ -0,0 +1,99 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	optionChecksumOutput      = "checksum-output"
+	defaultChecksumOutput     = false
+	checksumOutputDescription = "Also write the SHA-256 of the config as written, in sha256sum format, to <output>" + checksumFileSuffix +
+		", or to stderr when the config goes to stdout. Unlike --manifest, it's only the hash, for pipelines which pin the config's digest"
+	checksumFileSuffix = ".sha256"
+)
+
+// checksumWriteCloser hashes everything written to the config output, recording the checksum once it's closed
+type checksumWriteCloser struct {
+	io.WriteCloser
+	hash    hash.Hash
+	options *CreateConfigOptions
+	closed  bool
+	aborted bool
+}
+
+func (w *checksumWriteCloser) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	w.hash.Write(p[:n])
+	return n, err
+}
+
+// Close closes the output, then records the checksum of what was written to it unless the output was aborted. Closing
+// it again does nothing, so it can be closed to check for errors once the config is written as well as aborted in a defer
+func (w *checksumWriteCloser) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.WriteCloser.Close(); err != nil {
+		return errors.Wrap(err, "unable to close config output")
+	}
+	if w.aborted {
+		return nil
+	}
+	return w.options.writeChecksum(w.hash.Sum(nil))
+}
+
+// abortOutput closes an output returned by outputWriter without recording a checksum, so a config which failed part
+// way through isn't given a sidecar vouching for it. It does nothing to an output which was already closed
+func abortOutput(out io.WriteCloser) {
+	if w, ok := out.(*checksumWriteCloser); ok {
+		w.aborted = true
+	}
+	_ = out.Close()
+}
+
+// writeChecksum records checksum in the --output file's sidecar, which sha256sum -c can check, or on stderr when the
+// config didn't go to a file, so stdout is left holding only the config
+func (options *CreateConfigOptions) writeChecksum(checksum []byte) error {
+	digest := hex.EncodeToString(checksum)
+	if options.Out != nil || strings.ToLower(options.Output) == "stdout" {
+		var out io.Writer = os.Stderr
+		if options.Err != nil {
+			out = options.Err
+		}
+		_, err := fmt.Fprintf(out, "%s  -\n", digest)
+		return errors.Wrap(err, "unable to write config checksum")
+	}
+
+	path := options.Output + checksumFileSuffix
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("%s  %s\n", digest, filepath.Base(options.Output))), 0644); err != nil {
+		return errors.Wrapf(err, "unable to write checksum file: %s", path)
+	}
+	logrus.Debugf("Checksum written to: %s", path)
+	return nil
+}
@@ -25,8 +25,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"io"
 	"os"
-	"path/filepath"
 	"strings"
 	"text/template"
 	"time"
@@ -88,21 +88,16 @@ func NewCmdCreateConfigController() *cobra.Command {
 		Long:    createConfigControllerLong,
 		Example: createConfigControllerExample,
 		PreRun: func(cmd *cobra.Command, args []string) {
+			helpers2.CheckErr(controllerOptions.applyDefaults(cmd))
+
 			// Setup logging
-			var logOut *os.File
-			if controllerOptions.Verbose {
-				logrus.SetLevel(logrus.DebugLevel)
-				// Only print log to stdout if not printing config to stdout
-				if strings.ToLower(controllerOptions.Output) != "stdout" {
-					logOut = os.Stdout
-				} else {
-					logOut = os.Stderr
-				}
-				logrus.SetOutput(logOut)
-			}
+			helpers2.CheckErr(controllerOptions.setupLogging(strings.ToLower(controllerOptions.Output) == "stdout"))
 
+			controllerOptions.recordValues(data, sourceUnset)
 			data.populateEnvVars()
+			controllerOptions.recordValues(data, sourceEnvironment)
 			data.populateDefaults()
+			controllerOptions.recordValues(data, sourceDefault)
 
 			// Update controller specific values with configOptions passed in if the argument was provided or the value is currently blank
 			if data.Controller.Port == "" || controllerOptions.CtrlPort != constants.DefaultZitiControllerPort {
@@ -116,10 +111,13 @@ func NewCmdCreateConfigController() *cobra.Command {
 				data.Controller.EdgeRouterDuration = controllerOptions.EdgeRouterEnrollmentDuration
 			}
 
+			controllerOptions.recordValues(data, sourceFlag)
+
 			// process identity information
 			SetControllerIdentity(&data.Controller)
 			SetEdgeConfig(&data.Controller)
 			SetWebConfig(&data.Controller)
+			controllerOptions.recordValues(data, sourceEnvironment)
 
 		},
 		Run: func(cmd *cobra.Command, args []string) {
@@ -130,7 +128,7 @@ func NewCmdCreateConfigController() *cobra.Command {
 		},
 		PostRun: func(cmd *cobra.Command, args []string) {
 			// Reset log output after run completes
-			logrus.SetOutput(os.Stdout)
+			controllerOptions.closeLogging()
 		},
 	}
 	controllerOptions.addCreateFlags(cmd)
@@ -148,33 +146,30 @@ func (options *CreateConfigControllerOptions) addFlags(cmd *cobra.Command) {
 
 // run implements the command
 func (options *CreateConfigControllerOptions) run(data *ConfigTemplateValues) error {
+	if options.DumpValues != "" {
+		return options.dumpValues(data)
+	}
 
 	tmpl, err := template.New("controller-config").Parse(controllerConfigTemplate)
 	if err != nil {
 		return err
 	}
 
-	var f *os.File
-	if strings.ToLower(options.Output) != "stdout" {
-		// Check if the path exists, fail if it doesn't
-		basePath := filepath.Dir(options.Output) + "/"
-		if _, err := os.Stat(filepath.Dir(basePath)); os.IsNotExist(err) {
-			return err
-		}
-
-		f, err = os.Create(options.Output)
-		logrus.Debugf("Created output file: %s", options.Output)
-		if err != nil {
-			return errors.Wrapf(err, "unable to create config file: %s", options.Output)
-		}
-	} else {
-		f = os.Stdout
+	out, err := options.outputWriter()
+	if err != nil {
+		return err
 	}
-	defer func() { _ = f.Close() }()
+	defer abortOutput(out)
 
-	if err := tmpl.Execute(f, data); err != nil {
+	if _, err = io.WriteString(out, options.stampHeader("#")); err != nil {
+		return errors.Wrap(err, "unable to write config")
+	}
+	if err := tmpl.Execute(out, data); err != nil {
 		return errors.Wrap(err, "unable to execute template")
 	}
+	if err = out.Close(); err != nil {
+		return err
+	}
 
 	logrus.Debugf("Controller configuration generated successfully and written to: %s", options.Output)
 
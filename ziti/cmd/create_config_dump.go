@@ -0,0 +1,190 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+const (
+	optionDumpValues      = "dump-values"
+	defaultDumpValues     = ""
+	dumpValuesStderr      = "stderr"
+	dumpValuesDescription = "Instead of rendering the config, write the resolved template values as JSON, along with where each of them " +
+		"and each flag came from. Goes to stderr, or to the given file with --" + optionDumpValues + "=<file>"
+)
+
+// The sources a template value or flag can come from. Template values read from a ZITI_* variable are marked as from
+// the environment even when the variable is unset and its built-in fallback is used.
+const (
+	sourceUnset        = "unset"
+	sourceDefault      = "default"
+	sourceEnvironment  = "environment"
+	sourceDefaultsFile = "defaults file"
+	sourceFlag         = "flag"
+)
+
+// valueSources tracks which step of resolving the template values last changed each of them, by comparing the values
+// after each step with those after the one before
+type valueSources struct {
+	last    map[string]interface{}
+	sources map[string]string
+}
+
+// record marks every value which changed since the last call as set by source
+func (vs *valueSources) record(data *ConfigTemplateValues, source string) error {
+	current, err := flattenValues(data)
+	if err != nil {
+		return err
+	}
+	if vs.sources == nil {
+		vs.sources = map[string]string{}
+	}
+	for path, value := range current {
+		previous, seen := vs.last[path]
+		switch {
+		case seen && reflect.DeepEqual(previous, value):
+			continue
+		case !seen && reflect.ValueOf(value).IsZero():
+			vs.sources[path] = sourceUnset
+		default:
+			vs.sources[path] = source
+		}
+	}
+	vs.last = current
+	return nil
+}
+
+// flattenValues returns the template values keyed by their dotted field path, such as Router.Edge.Port, in the form
+// they're dumped in
+func flattenValues(data *ConfigTemplateValues) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encode template values")
+	}
+	var decoded map[string]interface{}
+	if err = json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, errors.Wrap(err, "unable to decode template values")
+	}
+	values := map[string]interface{}{}
+	flattenInto(values, "", decoded)
+	return values, nil
+}
+
+func flattenInto(values map[string]interface{}, prefix string, decoded map[string]interface{}) {
+	for key, value := range decoded {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenInto(values, path, nested)
+		} else if value == nil {
+			values[path] = ""
+		} else {
+			values[path] = value
+		}
+	}
+}
+
+// recordValues marks the template values changed since the last call as set by source, when --dump-values is set
+func (options *CreateConfigOptions) recordValues(data *ConfigTemplateValues, source string) {
+	if options.DumpValues == "" {
+		return
+	}
+	if options.valueSources == nil {
+		options.valueSources = &valueSources{}
+	}
+	if err := options.valueSources.record(data, source); err != nil {
+		logrus.WithError(err).Warn("unable to track where template values came from")
+	}
+}
+
+// recordFlagSource notes that applyDefaults set the flag from source
+func (options *CreateConfigOptions) recordFlagSource(name, source string) {
+	if options.flagSources == nil {
+		options.flagSources = map[string]string{}
+	}
+	options.flagSources[name] = source
+}
+
+// flagSource returns where the value of flag came from
+func (options *CreateConfigOptions) flagSource(flag *pflag.Flag) string {
+	if fromDefaults, found := options.flagSources[flag.Name]; found {
+		return fromDefaults
+	}
+	if flag.Changed {
+		return sourceFlag
+	}
+	return sourceDefault
+}
+
+type dumpedFlag struct {
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+type valuesDump struct {
+	Values  *ConfigTemplateValues `json:"values"`
+	Sources map[string]string     `json:"sources"`
+	Flags   map[string]dumpedFlag `json:"flags,omitempty"`
+}
+
+// dumpValues writes data, where each of its values came from and the value and source of each flag, to stderr or the
+// --dump-values file. Values changed since the last recorded step were set from flags by the command.
+func (options *CreateConfigOptions) dumpValues(data *ConfigTemplateValues) error {
+	options.recordValues(data, sourceFlag)
+
+	dump := &valuesDump{Values: data, Sources: map[string]string{}}
+	if options.valueSources != nil {
+		dump.Sources = options.valueSources.sources
+	}
+	if options.Cmd != nil {
+		dump.Flags = map[string]dumpedFlag{}
+		options.Cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+			dump.Flags[flag.Name] = dumpedFlag{Value: flag.Value.String(), Source: options.flagSource(flag)}
+		})
+	}
+
+	dumpJson, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to encode template values")
+	}
+	dumpJson = append(dumpJson, '\n')
+
+	if strings.ToLower(options.DumpValues) == dumpValuesStderr {
+		var out io.Writer = os.Stderr
+		if options.Err != nil {
+			out = options.Err
+		}
+		_, err = out.Write(dumpJson)
+		return errors.Wrap(err, "unable to write template values")
+	}
+	if err = os.WriteFile(options.DumpValues, dumpJson, 0644); err != nil {
+		return errors.Wrapf(err, "unable to write template values: %s", options.DumpValues)
+	}
+	logrus.Debugf("Template values written to: %s", options.DumpValues)
+	return nil
+}
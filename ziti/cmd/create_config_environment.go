@@ -22,8 +22,7 @@ import (
 	cmdhelper "github.com/openziti/ziti/ziti/cmd/helpers"
 	"github.com/openziti/ziti/ziti/cmd/templates"
 	"github.com/openziti/ziti/ziti/constants"
-	"os"
-	"path/filepath"
+	"io"
 	"runtime"
 	"strings"
 	"text/template"
@@ -83,14 +82,20 @@ func NewCmdCreateConfigEnvironment() *cobra.Command {
 		Long:    createConfigEnvironmentLong,
 		Example: createConfigEnvironmentExample,
 		PreRun: func(cmd *cobra.Command, args []string) {
+			cmdhelper.CheckErr(environmentOptions.applyDefaults(cmd))
+
+			environmentOptions.recordValues(data, sourceUnset)
 			data.populateEnvVars()
+			environmentOptions.recordValues(data, sourceEnvironment)
 			data.populateDefaults()
+			environmentOptions.recordValues(data, sourceDefault)
 			// Set router identities
 			SetZitiRouterIdentity(&data.Router, validateRouterName(""))
 			// Set up other identity info
 			SetControllerIdentity(&data.Controller)
 			SetEdgeConfig(&data.Controller)
 			SetWebConfig(&data.Controller)
+			environmentOptions.recordValues(data, sourceEnvironment)
 
 			environmentOptions.EnvVars = []EnvVar{
 				{constants.ZitiHomeVarName, constants.ZitiHomeVarDescription, data.ZitiHome},
@@ -124,8 +129,6 @@ func NewCmdCreateConfigEnvironment() *cobra.Command {
 				{constants.ZitiEdgeRouterEnrollmentDurationVarName, constants.ZitiEdgeRouterEnrollmentDurationVarDescription, data.Controller.EdgeRouterDuration.String()},
 			}
 
-			// Setup logging
-			var logOut *os.File
 			// Figure out the correct comment prefix and variable declaration command
 			if runtime.GOOS == "windows" {
 				environmentOptions.OSCommentPrefix = "rem"
@@ -134,16 +137,9 @@ func NewCmdCreateConfigEnvironment() *cobra.Command {
 				environmentOptions.OSCommentPrefix = "#"
 				environmentOptions.OSVarDeclare = "export"
 			}
-			if environmentOptions.Verbose {
-				logrus.SetLevel(logrus.DebugLevel)
-				// Only print log to stdout if not printing config to stdout
-				if strings.ToLower(environmentOptions.Output) != "stdout" {
-					logOut = os.Stdout
-				} else {
-					logOut = os.Stderr
-				}
-				logrus.SetOutput(logOut)
-			}
+
+			// Setup logging
+			cmdhelper.CheckErr(environmentOptions.setupLogging(strings.ToLower(environmentOptions.Output) == "stdout"))
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			environmentOptions.Cmd = cmd
@@ -153,7 +149,7 @@ func NewCmdCreateConfigEnvironment() *cobra.Command {
 		},
 		PostRun: func(cmd *cobra.Command, args []string) {
 			// Reset log output after run completes
-			logrus.SetOutput(os.Stdout)
+			environmentOptions.closeLogging()
 		},
 	}
 
@@ -227,34 +223,30 @@ func NewCmdCreateConfigEnvironment() *cobra.Command {
 
 // run implements the command
 func (options *CreateConfigEnvironmentOptions) run() error {
+	if options.DumpValues != "" {
+		return options.dumpValues(data)
+	}
 
 	tmpl, err := template.New("environment-config").Parse(environmentConfigTemplate)
 	if err != nil {
 		return err
 	}
 
-	var f *os.File
-	if strings.ToLower(options.Output) != "stdout" {
-		// Check if the path exists, fail if it doesn't
-		basePath := filepath.Dir(options.Output) + "/"
-		if _, err := os.Stat(filepath.Dir(basePath)); os.IsNotExist(err) {
-			logrus.Fatalf("Provided path: [%s] does not exist\n", basePath)
-			return err
-		}
-
-		f, err = os.Create(options.Output)
-		logrus.Debugf("Created output file: %s", options.Output)
-		if err != nil {
-			return errors.Wrapf(err, "unable to create config file: %s", options.Output)
-		}
-	} else {
-		f = os.Stdout
+	out, err := options.outputWriter()
+	if err != nil {
+		return err
 	}
-	defer func() { _ = f.Close() }()
+	defer abortOutput(out)
 
-	if err := tmpl.Execute(f, options); err != nil {
+	if _, err = io.WriteString(out, options.stampHeader(options.OSCommentPrefix)); err != nil {
+		return errors.Wrap(err, "unable to write config")
+	}
+	if err := tmpl.Execute(out, options); err != nil {
 		return errors.Wrap(err, "unable to execute template")
 	}
+	if err = out.Close(); err != nil {
+		return err
+	}
 
 	logrus.Debugf("Environment configuration file generated successfully and written to: %s", options.Output)
 
@@ -0,0 +1,109 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package cmd
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	optionEnable      = "enable"
+	enableDescription = "Add an optional section to the config. May be repeated, the features are: "
+
+	featureHealthChecks = "healthChecks"
+	featureMetrics      = "metrics"
+
+	// defaultFeatureMetricsInterval is the metrics report interval used when --enable metrics is given without
+	// --metrics-interval
+	defaultFeatureMetricsInterval = time.Minute
+)
+
+// configFeatures are the features --enable accepts, keyed by the name templates test with {{ if .Features.<name> }}
+var configFeatures = map[string]string{
+	featureHealthChecks: "a healthChecks section, pinging the controller every 30s",
+	featureMetrics:      "a metrics section, reporting every " + defaultFeatureMetricsInterval.String() + " unless --" + optionMetricsInterval + " is set",
+}
+
+// configFeatureNames returns the names of the known features, sorted
+func configFeatureNames() []string {
+	var names []string
+	for name := range configFeatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// featuresDescription describes --enable along with each of the features it accepts
+func featuresDescription() string {
+	var features []string
+	for _, name := range configFeatureNames() {
+		features = append(features, name+" ("+configFeatures[name]+")")
+	}
+	return enableDescription + strings.Join(features, ", ")
+}
+
+// parseFeatures checks the --enable values against the known features, so a misspelled feature fails instead of
+// quietly leaving its section out. Matching ignores case, the names returned are as the templates spell them.
+func parseFeatures(values []string) (map[string]bool, error) {
+	features := map[string]bool{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		name, found := configFeatureName(value)
+		if !found {
+			return nil, errors.Errorf("Invalid value for --%s [%s], must be one of %s", optionEnable, value, strings.Join(configFeatureNames(), ", "))
+		}
+		features[name] = true
+	}
+	return features, nil
+}
+
+// configFeatureName returns the feature named value, ignoring case, as the templates spell it
+func configFeatureName(value string) (string, bool) {
+	for known := range configFeatures {
+		if strings.EqualFold(known, value) {
+			return known, true
+		}
+	}
+	return "", false
+}
+
+// applyFeatures adds the --enable features to the template values, keeping any a --values file already enabled
+func (options *CreateConfigRouterOptions) applyFeatures(data *ConfigTemplateValues) error {
+	features, err := parseFeatures(options.Features)
+	if err != nil {
+		return err
+	}
+	if len(features) > 0 && data.Features == nil {
+		data.Features = map[string]bool{}
+	}
+	for name := range features {
+		data.Features[name] = true
+	}
+
+	if data.Features[featureMetrics] && data.Router.Metrics.ReportInterval == 0 {
+		data.Router.Metrics.ReportInterval = defaultFeatureMetricsInterval
+	}
+	return nil
+}
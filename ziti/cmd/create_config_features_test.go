@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseFeatures(t *testing.T) {
+	features, err := parseFeatures([]string{"healthchecks", " metrics", ""})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{featureHealthChecks: true, featureMetrics: true}, features)
+
+	_, err = parseFeatures([]string{"metrics", "mterics"})
+	assert.EqualError(t, err, "Invalid value for --enable [mterics], must be one of healthChecks, metrics")
+}
+
+func TestEnableFeatures(t *testing.T) {
+	values := goldenTemplateValues()
+	options := CreateConfigRouterOptions{Features: []string{featureHealthChecks}}
+	require.NoError(t, options.applyRouterOptions(values))
+
+	doc := map[string]interface{}{}
+	require.NoError(t, yaml.Unmarshal(renderRouterTemplate(t, values), &doc))
+	assert.Equal(t, map[string]interface{}{
+		"ctrlPingCheck": map[string]interface{}{"interval": "30s", "timeout": "15s", "initialDelay": "15s"},
+	}, doc["healthChecks"])
+	assert.NotContains(t, doc, "metrics")
+
+	values = goldenTemplateValues()
+	assert.Error(t, (&CreateConfigRouterOptions{Features: []string{"mterics"}}).applyRouterOptions(values))
+	assert.NotContains(t, string(renderRouterTemplate(t, values)), "healthChecks")
+}
+
+func TestEnableMetricsFeature(t *testing.T) {
+	values := goldenTemplateValues()
+	options := CreateConfigRouterOptions{Features: []string{featureMetrics}}
+	require.NoError(t, options.applyRouterOptions(values))
+	assert.Equal(t, defaultFeatureMetricsInterval, values.Router.Metrics.ReportInterval)
+	assert.Contains(t, string(renderRouterTemplate(t, values)), "metrics:\n  reportInterval: 1m0s\n")
+
+	// an explicit interval wins over the feature's
+	values = goldenTemplateValues()
+	options.MetricsInterval = "30s"
+	require.NoError(t, options.applyRouterOptions(values))
+	assert.Equal(t, 30*time.Second, values.Router.Metrics.ReportInterval)
+}
+
+func TestEnableFeaturesFromValuesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "values.yml")
+	require.NoError(t, os.WriteFile(path, []byte("Features:\n  healthChecks: true\n"), 0600))
+
+	values := goldenTemplateValues()
+	require.NoError(t, readValuesFile(path, values))
+	options := CreateConfigRouterOptions{Features: []string{featureMetrics}}
+	require.NoError(t, options.applyRouterOptions(values))
+	assert.Equal(t, map[string]bool{featureHealthChecks: true, featureMetrics: true}, values.Features)
+}
+
+func TestValuesFileFeaturesAreChecked(t *testing.T) {
+	dir := t.TempDir()
+
+	// a misspelled feature fails, as it does with --enable
+	path := filepath.Join(dir, "misspelled.yml")
+	require.NoError(t, os.WriteFile(path, []byte("Features:\n  healthChecks: true\n  mterics: true\n"), 0600))
+	err := readValuesFile(path, goldenTemplateValues())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown feature [mterics]")
+
+	// and features match ignoring case, as --enable does
+	path = filepath.Join(dir, "case.yml")
+	require.NoError(t, os.WriteFile(path, []byte("features:\n  HealthChecks: true\n  METRICS: false\n"), 0600))
+	values := goldenTemplateValues()
+	require.NoError(t, readValuesFile(path, values))
+	assert.Equal(t, map[string]bool{featureHealthChecks: true, featureMetrics: false}, values.Features)
+
+	path = filepath.Join(dir, "empty.yml")
+	require.NoError(t, os.WriteFile(path, []byte("Features:\n"), 0600))
+	require.NoError(t, readValuesFile(path, goldenTemplateValues()))
+}
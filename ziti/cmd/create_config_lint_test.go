@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// templateOnlyInCode are the template values no template renders, as they're only read by the commands themselves,
+// such as for flag defaults or the environment command's variables. Anything else a template doesn't reference is a
+// flag which does nothing.
+var templateOnlyInCode = map[string]string{
+	"Controller.Edge.AdvertisedPort": "the environment command's variable, and the port of Controller.Edge.AdvertisedHostPort",
+	"Hostname":                       "only dumped with --dump-values, kept so values files which set it still load",
+	"Router.Name":                    "the name the identity files are derived from",
+}
+
+// templateLint collects the field paths templates reference, checking each against the type the template is run with
+type templateLint struct {
+	root       reflect.Type
+	referenced map[string]bool
+	dangling   map[string]bool
+}
+
+func newTemplateLint(root interface{}) *templateLint {
+	return &templateLint{
+		root:       reflect.TypeOf(root),
+		referenced: map[string]bool{},
+		dangling:   map[string]bool{},
+	}
+}
+
+// lintTemplate checks every template tmpl defines, its blocks included, which are all run with the same values
+func (lint *templateLint) lintTemplate(tmpl *template.Template) {
+	for _, defined := range tmpl.Templates() {
+		if defined.Tree != nil {
+			lint.walk(defined.Tree.Root, "", lint.root)
+		}
+	}
+}
+
+// walk checks the fields node references, dot being the path and type of {{ . }} where node is, or a nil type when
+// it's not a template value
+func (lint *templateLint) walk(node parse.Node, dot string, dotType reflect.Type) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			lint.walk(child, dot, dotType)
+		}
+	case *parse.ActionNode:
+		lint.walk(n.Pipe, dot, dotType)
+	case *parse.TemplateNode:
+		lint.walk(n.Pipe, dot, dotType)
+	case *parse.IfNode:
+		lint.walk(n.Pipe, dot, dotType)
+		lint.walk(n.List, dot, dotType)
+		lint.walk(n.ElseList, dot, dotType)
+	case *parse.WithNode:
+		lint.walk(n.Pipe, dot, dotType)
+		path, fieldType := lint.pipeField(n.Pipe, dot, dotType)
+		lint.walk(n.List, path, fieldType)
+		lint.walk(n.ElseList, dot, dotType)
+	case *parse.RangeNode:
+		lint.walk(n.Pipe, dot, dotType)
+		path, fieldType := lint.pipeField(n.Pipe, dot, dotType)
+		if fieldType != nil && (fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Array || fieldType.Kind() == reflect.Map) {
+			lint.walk(n.List, path, fieldType.Elem())
+		} else {
+			lint.walk(n.List, "", nil)
+		}
+		lint.walk(n.ElseList, dot, dotType)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				lint.walk(arg, dot, dotType)
+			}
+		}
+	case *parse.FieldNode:
+		lint.resolve(dot, dotType, n.Ident)
+	case *parse.VariableNode:
+		// only $ is known to be the template values, other variables are left alone
+		if n.Ident[0] == "$" {
+			lint.resolve("", lint.root, n.Ident[1:])
+		}
+	case *parse.ChainNode:
+		lint.walk(n.Node, dot, dotType)
+	}
+}
+
+// pipeField returns the path and type of the value a with or range pipeline is on, when it's a single field
+func (lint *templateLint) pipeField(pipe *parse.PipeNode, dot string, dotType reflect.Type) (string, reflect.Type) {
+	if len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return "", nil
+	}
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.FieldNode:
+		return lint.resolve(dot, dotType, arg.Ident)
+	case *parse.DotNode:
+		return dot, dotType
+	}
+	return "", nil
+}
+
+// resolve notes the field path idents reaches from dot, returning its path and type. Fields which don't exist are
+// dangling, and a nil type is returned for them and for anything past a method or map key
+func (lint *templateLint) resolve(dot string, dotType reflect.Type, idents []string) (string, reflect.Type) {
+	if dotType == nil {
+		return "", nil
+	}
+	path, current := dot, dotType
+	for _, ident := range idents {
+		if current.Kind() == reflect.Pointer {
+			current = current.Elem()
+		}
+		if _, ok := reflect.PointerTo(current).MethodByName(ident); ok {
+			return "", nil
+		}
+		path = joinFieldPath(path, ident)
+		switch current.Kind() {
+		case reflect.Map:
+			lint.referenced[path] = true
+			return "", nil
+		case reflect.Struct:
+			field, ok := current.FieldByName(ident)
+			if !ok || !field.IsExported() {
+				lint.dangling[path] = true
+				return "", nil
+			}
+			lint.referenced[path] = true
+			current = field.Type
+		default:
+			lint.dangling[path] = true
+			return "", nil
+		}
+	}
+	return path, current
+}
+
+func joinFieldPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// orphaned returns the template values none of the templates linted reference. Structs count as referenced when any
+// of their fields are
+func (lint *templateLint) orphaned() []string {
+	var orphans []string
+	var visit func(path string, t reflect.Type)
+	visit = func(path string, t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fieldPath := joinFieldPath(path, field.Name)
+			if field.Type.Kind() == reflect.Struct {
+				visit(fieldPath, field.Type)
+			} else if !lint.referenced[fieldPath] {
+				orphans = append(orphans, fieldPath)
+			}
+		}
+	}
+	visit("", lint.root.Elem())
+	sort.Strings(orphans)
+	return orphans
+}
+
+func (lint *templateLint) danglingReferences() []string {
+	var dangling []string
+	for path := range lint.dangling {
+		dangling = append(dangling, path)
+	}
+	sort.Strings(dangling)
+	return dangling
+}
+
+func TestConfigTemplatesReferenceOnlyTemplateValues(t *testing.T) {
+	lint := newTemplateLint(&ConfigTemplateValues{})
+	for name, source := range map[string]string{"controller": controllerConfigTemplate, "router": routerConfigEdgeTemplate} {
+		tmpl, err := template.New(name).Funcs(configTemplateFuncs).Parse(source)
+		require.NoError(t, err)
+		lint.lintTemplate(tmpl)
+	}
+
+	assert.Empty(t, lint.danglingReferences(), "the templates reference fields ConfigTemplateValues doesn't have")
+	for path := range lint.referenced {
+		if strings.HasPrefix(path, "Features.") {
+			assert.Contains(t, configFeatures, strings.TrimPrefix(path, "Features."), "the templates test a feature --enable doesn't know")
+		}
+	}
+
+	var orphans []string
+	for _, path := range lint.orphaned() {
+		if _, ok := templateOnlyInCode[path]; !ok {
+			orphans = append(orphans, path)
+		}
+	}
+	assert.Empty(t, orphans, "no template references these values, add them to a template or to templateOnlyInCode")
+}
+
+func TestTemplateLintFindsOrphansAndDanglingReferences(t *testing.T) {
+	type values struct {
+		Name    string
+		Unused  int
+		Ports   []string
+		Timeout time.Duration
+		Nested  struct{ Host, Port string }
+	}
+
+	tmpl, err := template.New("lint").Parse(`{{ .Name }} {{ .Nmae }}
+{{- with .Nested }}{{ .Host }}{{ .Missing }}{{ $.Name.Length }}{{ end }}
+{{- range .Ports }}{{ .Anything }}{{ end }}
+{{- block "extra" . }}{{ .Timeout.Seconds }}{{ end }}`)
+	require.NoError(t, err)
+
+	lint := newTemplateLint(&values{})
+	lint.lintTemplate(tmpl)
+	assert.Equal(t, []string{"Name.Length", "Nested.Missing", "Nmae", "Ports.Anything"}, lint.danglingReferences())
+	assert.Equal(t, []string{"Nested.Port", "Unused"}, lint.orphaned())
+}
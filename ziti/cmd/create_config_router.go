@@ -17,30 +17,146 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/openziti/foundation/v2/stringz"
+	"github.com/openziti/ziti/common/version"
 	cmdhelper "github.com/openziti/ziti/ziti/cmd/helpers"
+	"net"
 	"os"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 const (
-	optionRouterName = "routerName"
+	optionRouterName           = "routerName"
+	optionMetricsInterval      = "metrics-interval"
+	defaultMetricsInterval     = ""
+	metricsIntervalDescription = "Add a metrics section to the router config, reporting metrics at the given interval (e.g. 1m)"
+	optionMetricsReportTo      = "metrics-report-to"
+	defaultMetricsReportTo     = ""
+	metricsReportToDescription = "The host:port of the controller the router reports metrics to, adding a metrics section. Routers send " +
+		"metrics over their control channel, so this is the router's controller unless --" + optionController + " is given, when it must be one of them"
+	optionTee                    = "tee"
+	defaultTee                   = false
+	teeDescription               = "Also write the config to stdout when --" + optionOutput + " is a file"
+	optionConfigLogLevel         = "config-log-level"
+	defaultConfigLogLevel        = ""
+	configLogLevelDescription    = "Add a logging section to the router config with the given level [panic|fatal|error|warn|info|debug|trace]"
+	optionConfigLogFormat        = "config-log-format"
+	defaultConfigLogFormat       = ""
+	configLogFormatDescription   = "Add a logging section to the router config with the given format [text|json]"
+	optionController             = "controller"
+	controllerDescription        = "The host:port of a controller the router should connect to. May be repeated to connect to multiple controllers in an HA deployment"
+	optionCtrlProbeInterval      = "ctrl-probe-interval"
+	defaultCtrlProbeInterval     = ""
+	ctrlProbeIntervalDescription = "How often the router probes each controller it's connected to (e.g. 5s), defaults to 10s. Only used with more than one --" + optionController
+	optionCtrlProbeTimeout       = "ctrl-probe-timeout"
+	defaultCtrlProbeTimeout      = ""
+	ctrlProbeTimeoutDescription  = "How long a controller may leave probes unanswered before the router fails over to another (e.g. 1m), defaults to 30s. Only used with more than one --" + optionController
+	optionManifest               = "manifest"
+	defaultManifest              = false
+	manifestDescription          = "Also write a JSON manifest with the config's SHA-256, the tool version, the template and the values used to generate it"
+	optionManifestFile           = "manifest-file"
+	defaultManifestFile          = ""
+	manifestFileDescription      = "Where to write the --" + optionManifest + " file, defaults to <output>" + manifestFileSuffix
+	manifestFileSuffix           = ".manifest.json"
+	optionValidate               = "validate"
+	defaultValidate              = false
+	validateDescription          = "Check the generated config against the router config schema before writing it, failing with each problem found"
+	optionMinimal                = "minimal"
+	defaultMinimal               = false
+	minimalDescription           = "Generate a short config without comments or settings the router defaults to anyway"
+	optionFull                   = "full"
+	defaultFull                  = false
+	fullDescription              = "Generate the full config with every setting and comment, which is the default. Overrides --" + optionMinimal + ", such as one set in a defaults file"
+	optionPortOffset             = "port-offset"
+	defaultPortOffset            = 0
+	portOffsetDescription        = "Add the given offset to every port the router listens on, for running several routers on one host"
+	optionDiffOnly               = "diff-only"
+	defaultDiffOnly              = false
+	diffOnlyDescription          = "Write nothing, but compare the config with the existing --" + optionOutput + " file, printing a diff and exiting with 1 if they differ or 2 if the file is missing"
+)
+
+// defaultHaCtrlProbeInterval and defaultHaCtrlProbeTimeout are the probe settings written for routers with more than
+// one controller, unless --ctrl-probe-interval or --ctrl-probe-timeout are given. A controller is failed over after
+// three missed probes
+const (
+	defaultHaCtrlProbeInterval = 10 * time.Second
+	defaultHaCtrlProbeTimeout  = 30 * time.Second
 )
 
 // CreateConfigRouterOptions the options for the router command
 type CreateConfigRouterOptions struct {
 	CreateConfigOptions
 
-	RouterName   string
-	WssEnabled   bool
-	IsPrivate    bool
-	TunnelerMode string
-	LanInterface string
+	// The flag and affects tags are shown by the explain command, affects being the part of the config the flag changes
+
+	RouterName            string   `flag:"routerName" affects:"identity (cert and key file names), edge.csr.sans"`
+	WssEnabled            bool     `flag:"wss" affects:"listeners (edge address and advertise), transport.ws"`
+	IsPrivate             bool     `flag:"private" affects:"link.listeners, commented out when private"`
+	TunnelerMode          string   `flag:"tunnelerMode" affects:"listeners (tunnel binding mode)"`
+	LanInterface          string   `flag:"lanInterface" affects:"listeners (tunnel lanIf, tproxy mode only)"`
+	Resolver              string   `flag:"resolver" affects:"listeners (tunnel resolver, tproxy mode only)"`
+	EdgeBindHost          string   `flag:"edge-bind-host" affects:"listeners (edge address)"`
+	EdgeAdvertiseHost     string   `flag:"edge-advertise-host" affects:"listeners (edge advertise), link.listeners (advertise)"`
+	EdgeListenPort        string   `flag:"edge-listen-port" affects:"listeners (edge address, and advertise unless --edge-advertise-port is set)"`
+	EdgeAdvertisePort     string   `flag:"edge-advertise-port" affects:"listeners (edge advertise)"`
+	FromCsv               string   `flag:"from-csv" affects:"which configs are written, one per CSV row"`
+	OutDir                string   `flag:"out-dir" affects:"where --from-csv configs are written"`
+	OutNameTemplate       string   `flag:"out-name-template" affects:"the file name of each --from-csv config"`
+	Strict                bool     `flag:"strict" affects:"whether --from-csv writes anything when a row is bad"`
+	EdgeListenerInterface string   `flag:"edge-listener-interface" affects:"listeners (edge address)"`
+	PreferIPv6            bool     `flag:"prefer-ipv6" affects:"listeners (edge address), with --edge-listener-interface"`
+	MetricsInterval       string   `flag:"metrics-interval" affects:"metrics, added when set"`
+	MetricsReportTo       string   `flag:"metrics-report-to" affects:"metrics, added when set, and ctrl (endpoint) unless --controller is given"`
+	ConfigLogLevel        string   `flag:"config-log-level" affects:"logging.level, added when set"`
+	ConfigLogFormat       string   `flag:"config-log-format" affects:"logging.format, added when set"`
+	Controllers           []string `flag:"controller" affects:"ctrl (endpoint or endpoints)"`
+	CtrlProbeInterval     string   `flag:"ctrl-probe-interval" affects:"ctrl.heartbeats.sendInterval, with more than one controller"`
+	CtrlProbeTimeout      string   `flag:"ctrl-probe-timeout" affects:"ctrl.heartbeats.closeUnresponsiveTimeout, with more than one controller"`
+	Manifest              bool     `flag:"manifest" affects:"nothing in the config, writes a manifest next to it"`
+	ManifestFile          string   `flag:"manifest-file" affects:"where the manifest is written"`
+	PortOffset            int      `flag:"port-offset" affects:"listeners, link.listeners (every port)"`
+	Validate              bool     `flag:"validate" affects:"nothing in the config, checks it before it's written"`
+	Minimal               bool     `flag:"minimal" affects:"the whole config, leaving out comments and defaults"`
+	Full                  bool     `flag:"full" affects:"the whole config, overriding --minimal"`
+	Profile               string   `flag:"profile" affects:"any block the profile overlay redefines"`
+	ProfileDir            string   `flag:"profile-dir" affects:"where --profile overlays are read from"`
+	DiffOnly              bool     `flag:"diff-only" affects:"nothing, compares the config with --output instead of writing it"`
+	InlinePki             bool     `flag:"inline-pki" affects:"identity (cert, server_cert, key and ca hold PEM instead of paths)"`
+	ValuesFile            string   `flag:"values" affects:"the whole config, any value the file sets"`
+	Features              []string `flag:"enable" affects:"the optional sections enabled, such as healthChecks"`
+
+	// valuesLoaded is set once ValuesFile has been read, after which only the flags given override the template values
+	valuesLoaded bool
+
+	// outputName, when set, replaces Output with a path derived from the final template values, just before writing
+	outputName func(data *ConfigTemplateValues) (string, error)
 }
 
+// ConfigManifest describes a generated config so it can be verified and reproduced later
+type ConfigManifest struct {
+	File     string                `json:"file"`
+	Sha256   string                `json:"sha256"`
+	Version  string                `json:"version"`
+	Template string                `json:"template"`
+	Values   *ConfigTemplateValues `json:"values"`
+}
+
+// configLogLevels and configLogFormats are the log settings which may be set in a generated router config
+var configLogLevels = []string{"panic", "fatal", "error", "warn", "info", "debug", "trace"}
+var configLogFormats = []string{"text", "json"}
+
 var routerOptions = CreateConfigRouterOptions{}
 
 // NewCmdCreateConfigRouter creates a command object for the "router" command
@@ -51,29 +167,38 @@ func NewCmdCreateConfigRouter() *cobra.Command {
 		Short:   "Creates a config file for specified Router name",
 		Aliases: []string{"rtr"},
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
-			// Setup logging
-			var logOut *os.File
-			if routerOptions.Verbose {
-				logrus.SetLevel(logrus.DebugLevel)
-				// Only print log to stdout if not printing config to stdout
-				if strings.ToLower(routerOptions.Output) != "stdout" {
-					logOut = os.Stdout
-				} else {
-					logOut = os.Stderr
-				}
-				logrus.SetOutput(logOut)
+			cmdhelper.CheckErr(routerOptions.applyDefaults(cmd))
+			if routerOptions.FromCsv != "" || routerOptions.ValuesFile != "" {
+				// each row names its own router, and the values file may name it
+				cmdhelper.CheckErr(cmd.Flags().SetAnnotation(optionRouterName, cobra.BashCompOneRequiredFlag, []string{"false"}))
 			}
 
+			// Setup logging
+			cmdhelper.CheckErr(routerOptions.setupLogging(strings.ToLower(routerOptions.Output) == "stdout" || routerOptions.Tee))
+
+			routerOptions.recordValues(data, sourceUnset)
 			data.populateEnvVars()
+			routerOptions.recordValues(data, sourceEnvironment)
 			data.populateDefaults()
+			routerOptions.recordValues(data, sourceDefault)
 
 			// Update router data with options passed in
 			data.Router.Name = validateRouterName(routerOptions.RouterName)
+			routerOptions.recordValues(data, sourceFlag)
 			SetZitiRouterIdentity(&data.Router, data.Router.Name)
+			routerOptions.recordValues(data, sourceEnvironment)
+
+			if routerOptions.ValuesFile != "" {
+				cmdhelper.CheckErr(routerOptions.applyValuesFile(data))
+				routerOptions.recordValues(data, sourceValuesFile)
+			}
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdhelper.CheckErr(cmd.Help())
 		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			routerOptions.closeLogging()
+		},
 	}
 
 	cmd.AddCommand(NewCmdCreateConfigRouterEdge())
@@ -86,8 +211,329 @@ func NewCmdCreateConfigRouter() *cobra.Command {
 
 func (options *CreateConfigRouterOptions) addFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().StringVarP(&options.RouterName, optionRouterName, "n", "", "name of the router")
+	cmd.PersistentFlags().StringVar(&options.MetricsInterval, optionMetricsInterval, defaultMetricsInterval, metricsIntervalDescription)
+	cmd.PersistentFlags().StringVar(&options.MetricsReportTo, optionMetricsReportTo, defaultMetricsReportTo, metricsReportToDescription)
+	cmd.PersistentFlags().BoolVar(&options.Tee, optionTee, defaultTee, teeDescription)
+	cmd.PersistentFlags().StringVar(&options.ConfigLogLevel, optionConfigLogLevel, defaultConfigLogLevel, configLogLevelDescription)
+	cmd.PersistentFlags().StringVar(&options.ConfigLogFormat, optionConfigLogFormat, defaultConfigLogFormat, configLogFormatDescription)
+	cmd.PersistentFlags().StringSliceVar(&options.Controllers, optionController, nil, controllerDescription)
+	cmd.PersistentFlags().StringVar(&options.CtrlProbeInterval, optionCtrlProbeInterval, defaultCtrlProbeInterval, ctrlProbeIntervalDescription)
+	cmd.PersistentFlags().StringVar(&options.CtrlProbeTimeout, optionCtrlProbeTimeout, defaultCtrlProbeTimeout, ctrlProbeTimeoutDescription)
+	cmd.PersistentFlags().BoolVar(&options.Manifest, optionManifest, defaultManifest, manifestDescription)
+	cmd.PersistentFlags().StringVar(&options.ManifestFile, optionManifestFile, defaultManifestFile, manifestFileDescription)
+	cmd.PersistentFlags().IntVar(&options.PortOffset, optionPortOffset, defaultPortOffset, portOffsetDescription)
+	cmd.PersistentFlags().BoolVar(&options.Validate, optionValidate, defaultValidate, validateDescription)
+	cmd.PersistentFlags().BoolVar(&options.Minimal, optionMinimal, defaultMinimal, minimalDescription)
+	cmd.PersistentFlags().BoolVar(&options.Full, optionFull, defaultFull, fullDescription)
+	cmd.PersistentFlags().StringVar(&options.Profile, optionProfile, defaultProfile, profileDescription)
+	cmd.PersistentFlags().StringVar(&options.ProfileDir, optionProfileDir, defaultProfileDir, profileDirDescription)
+	cmd.PersistentFlags().BoolVar(&options.DiffOnly, optionDiffOnly, defaultDiffOnly, diffOnlyDescription)
+	cmd.PersistentFlags().BoolVar(&options.InlinePki, optionInlinePki, defaultInlinePki, inlinePkiDescription)
+	cmd.PersistentFlags().StringVar(&options.ValuesFile, optionValues, defaultValues, valuesDescription)
+	cmd.PersistentFlags().StringSliceVar(&options.Features, optionEnable, nil, featuresDescription())
 	err := cmd.MarkPersistentFlagRequired(optionRouterName)
 	if err != nil {
 		return
 	}
 }
+
+// applyRouterOptions validates the options common to all router config types and copies them into the template values
+func (options *CreateConfigRouterOptions) applyRouterOptions(data *ConfigTemplateValues) error {
+	if options.MetricsInterval != "" {
+		interval, err := time.ParseDuration(options.MetricsInterval)
+		if err != nil {
+			return errors.Errorf("Invalid value for --%s [%s], must be a duration such as 30s or 1m", optionMetricsInterval, options.MetricsInterval)
+		}
+		if interval <= 0 {
+			return errors.Errorf("Invalid value for --%s [%s], must be greater than zero", optionMetricsInterval, options.MetricsInterval)
+		}
+		data.Router.Metrics.ReportInterval = interval
+	}
+	if err := options.applyFeatures(data); err != nil {
+		return err
+	}
+
+	if options.ConfigLogLevel != "" {
+		level := strings.ToLower(options.ConfigLogLevel)
+		if !stringz.Contains(configLogLevels, level) {
+			return errors.Errorf("Invalid value for --%s [%s], must be one of %s", optionConfigLogLevel, options.ConfigLogLevel, strings.Join(configLogLevels, ", "))
+		}
+		data.Router.Logging.Level = level
+	}
+	if options.ConfigLogFormat != "" {
+		format := strings.ToLower(options.ConfigLogFormat)
+		if !stringz.Contains(configLogFormats, format) {
+			return errors.Errorf("Invalid value for --%s [%s], must be one of %s", optionConfigLogFormat, options.ConfigLogFormat, strings.Join(configLogFormats, ", "))
+		}
+		data.Router.Logging.Format = format
+	}
+
+	if options.Manifest && options.manifestPath() == "" {
+		return errors.Errorf("--%s requires --%s when the config is written to stdout", optionManifest, optionManifestFile)
+	}
+
+	endpoints, err := parseControllerEndpoints(options.Controllers)
+	if err != nil {
+		return err
+	}
+	if len(endpoints) > 0 || options.overridesValues(optionController) {
+		data.Router.CtrlEndpoints = endpoints
+	}
+	if err = options.applyMetricsReportTo(data); err != nil {
+		return err
+	}
+	if err = options.applyCtrlProbe(data); err != nil {
+		return err
+	}
+
+	if err = options.applyInlinePki(data); err != nil {
+		return err
+	}
+
+	if options.PortOffset != 0 {
+		ports := []struct {
+			name string
+			port *string
+		}{
+			{"edge listener", &data.Router.Edge.Port},
+			{"link listener", &data.Router.Edge.ListenerBindPort},
+			{"wss advertised", &data.Router.Edge.WssAdvertisedPort},
+		}
+		if data.Router.Edge.AdvertisedPort != "" {
+			ports = append(ports, struct {
+				name string
+				port *string
+			}{"edge advertised", &data.Router.Edge.AdvertisedPort})
+		}
+		for _, p := range ports {
+			shifted, err := offsetPort(*p.port, options.PortOffset)
+			if err != nil {
+				return errors.Wrapf(err, "unable to apply --%s to the %s port", optionPortOffset, p.name)
+			}
+			*p.port = shifted
+		}
+	}
+	return nil
+}
+
+// offsetPort adds offset to port, failing if the result isn't a valid port
+func offsetPort(port string, offset int) (string, error) {
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		return "", errors.Errorf("port [%s] is not a number", port)
+	}
+	shifted := portNum + offset
+	if shifted < 1 || shifted > 65535 {
+		return "", errors.Errorf("port %d with offset %d is %d, which is outside of 1-65535", portNum, offset, shifted)
+	}
+	return strconv.Itoa(shifted), nil
+}
+
+// parseControllerEndpoints validates that each controller is a host:port and returns them in normalized form,
+// dropping duplicates while keeping the order they were given in
+func parseControllerEndpoints(controllers []string) ([]string, error) {
+	var endpoints []string
+	seen := map[string]struct{}{}
+	for _, controller := range controllers {
+		endpoint, err := parseControllerEndpoint(optionController, controller)
+		if err != nil {
+			return nil, err
+		}
+		if _, found := seen[endpoint]; found {
+			continue
+		}
+		seen[endpoint] = struct{}{}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+// parseControllerEndpoint validates that the controller given to option is a host:port and returns it in normalized form
+func parseControllerEndpoint(option string, controller string) (string, error) {
+	host, port, err := net.SplitHostPort(strings.TrimSpace(controller))
+	if err != nil || host == "" {
+		return "", errors.Errorf("Invalid value for --%s [%s], must be of the form host:port", option, controller)
+	}
+	if portNum, err := strconv.Atoi(port); err != nil || portNum < 1 || portNum > 65535 {
+		return "", errors.Errorf("Invalid value for --%s [%s], port must be between 1 and 65535", option, controller)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// applyMetricsReportTo points the router's metrics at the --metrics-report-to controller. Routers send metrics over
+// their control channel, so it becomes the router's controller when none was given and otherwise has to be one of them.
+// The metrics section is added at the default interval unless --metrics-interval is set
+func (options *CreateConfigRouterOptions) applyMetricsReportTo(data *ConfigTemplateValues) error {
+	if options.MetricsReportTo == "" {
+		return nil
+	}
+	endpoint, err := parseControllerEndpoint(optionMetricsReportTo, options.MetricsReportTo)
+	if err != nil {
+		return err
+	}
+	if len(data.Router.CtrlEndpoints) == 0 {
+		data.Router.CtrlEndpoints = []string{endpoint}
+	} else if !stringz.Contains(data.Router.CtrlEndpoints, endpoint) {
+		return errors.Errorf("Invalid value for --%s [%s], metrics can only be reported to a controller the router connects to: %s",
+			optionMetricsReportTo, options.MetricsReportTo, strings.Join(data.Router.CtrlEndpoints, ", "))
+	}
+	if data.Router.Metrics.ReportInterval == 0 {
+		data.Router.Metrics.ReportInterval = defaultFeatureMetricsInterval
+	}
+	return nil
+}
+
+// applyCtrlProbe validates --ctrl-probe-interval and --ctrl-probe-timeout and copies them into the template values.
+// The probe settings are only rendered for HA routers, so only those need the interval to be shorter than the timeout
+func (options *CreateConfigRouterOptions) applyCtrlProbe(data *ConfigTemplateValues) error {
+	probes := []struct {
+		option string
+		value  string
+		target *time.Duration
+	}{
+		{optionCtrlProbeInterval, options.CtrlProbeInterval, &data.Router.CtrlProbe.Interval},
+		{optionCtrlProbeTimeout, options.CtrlProbeTimeout, &data.Router.CtrlProbe.Timeout},
+	}
+	for _, probe := range probes {
+		if probe.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(probe.value)
+		if err != nil {
+			return errors.Errorf("Invalid value for --%s [%s], must be a duration such as 10s or 1m", probe.option, probe.value)
+		}
+		if duration <= 0 {
+			return errors.Errorf("Invalid value for --%s [%s], must be greater than zero", probe.option, probe.value)
+		}
+		*probe.target = duration
+	}
+
+	if probe := data.Router.CtrlProbe; len(data.Router.CtrlEndpoints) > 1 && probe.Interval >= probe.Timeout {
+		return errors.Errorf("--%s [%s] must be shorter than --%s [%s], or a controller is failed over between two probes",
+			optionCtrlProbeInterval, probe.Interval, optionCtrlProbeTimeout, probe.Timeout)
+	}
+	return nil
+}
+
+// isMinimal returns true if a minimal config should be generated, --full wins if both are set
+func (options *CreateConfigRouterOptions) isMinimal() bool {
+	return options.Minimal && !options.Full
+}
+
+// writeRouterConfig renders tmpl with data, checks the result against the router config schema when --validate is set,
+// then writes it to the output along with the manifest if one was asked for. Nothing is written if either step fails.
+// With --dump-values only the values are written, and with --diff-only nothing is.
+func (options *CreateConfigRouterOptions) writeRouterConfig(tmpl *template.Template, data *ConfigTemplateValues) error {
+	if options.DumpValues != "" {
+		return options.dumpValues(data)
+	}
+
+	rendered := &bytes.Buffer{}
+	if err := tmpl.Execute(rendered, data); err != nil {
+		return errors.Wrap(err, "unable to execute template")
+	}
+	config := rendered.Bytes()
+
+	if err := options.checkProfileConfig(config); err != nil {
+		return err
+	}
+
+	if options.isMinimal() {
+		minimal, err := minimizeRouterConfig(config)
+		if err != nil {
+			return err
+		}
+		config = minimal
+	}
+
+	if options.Validate {
+		if err := validateRouterConfig(config); err != nil {
+			return err
+		}
+		logrus.Debug("Generated config passed validation")
+	}
+
+	if options.DiffOnly {
+		return options.diffConfig(config)
+	}
+
+	// the stamp is added last, so it isn't stripped by --minimal, but before the checksum so the manifest matches the file
+	config = append([]byte(options.stampHeader("#")), config...)
+
+	out, err := options.outputWriter()
+	if err != nil {
+		return err
+	}
+	defer abortOutput(out)
+
+	if _, err = out.Write(config); err != nil {
+		return errors.Wrap(err, "unable to write config")
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+
+	if options.Manifest {
+		checksum := sha256.Sum256(config)
+		if err = options.writeManifest(tmpl.Name(), checksum[:], data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// manifestPath returns where the manifest should be written, or an empty string if there's no default for the output
+func (options *CreateConfigRouterOptions) manifestPath() string {
+	if options.ManifestFile != "" {
+		return options.ManifestFile
+	}
+	if strings.ToLower(options.Output) == "stdout" {
+		return ""
+	}
+	return options.Output + manifestFileSuffix
+}
+
+// writeManifest writes the manifest for a config rendered from templateName with the given checksum
+func (options *CreateConfigRouterOptions) writeManifest(templateName string, checksum []byte, data *ConfigTemplateValues) error {
+	manifest := &ConfigManifest{
+		File:     options.Output,
+		Sha256:   hex.EncodeToString(checksum),
+		Version:  version.GetVersion(),
+		Template: templateName,
+		Values:   data,
+	}
+
+	manifestJson, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to encode manifest")
+	}
+
+	// with --inline-pki the values hold the private key, so the manifest is as sensitive as the config
+	mode := os.FileMode(0644)
+	if options.InlinePki {
+		if mode, err = options.fileMode(); err != nil {
+			return err
+		}
+	}
+
+	path := options.manifestPath()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return errors.Wrapf(err, "unable to write manifest file: %s", path)
+	}
+	// as for the config, the mode given to OpenFile is ignored for an existing file, which is tightened before the
+	// manifest is written to it
+	if err = f.Chmod(mode); err != nil {
+		_ = f.Close()
+		return errors.Wrapf(err, "unable to set the permissions of manifest file: %s", path)
+	}
+	if _, err = f.Write(append(manifestJson, '\n')); err != nil {
+		_ = f.Close()
+		return errors.Wrapf(err, "unable to write manifest file: %s", path)
+	}
+	if err = f.Close(); err != nil {
+		return errors.Wrapf(err, "unable to write manifest file: %s", path)
+	}
+	logrus.Debugf("Manifest written to: %s", path)
+	return nil
+}
@@ -0,0 +1,292 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	optionFromCsv      = "from-csv"
+	defaultFromCsv     = ""
+	fromCsvDescription = "Create a config for each router in this CSV file instead of a single one. Each row is " +
+		"name,advertiseHost,port,private, where all but the name may be left empty to use the usual defaults. A header row and # comments are allowed"
+	optionOutDir       = "out-dir"
+	defaultOutDir      = "."
+	outDirDescription  = "The directory --" + optionFromCsv + " writes <name>.yml, or the --out-name-template path, to for each router, which is created if it doesn't exist"
+	optionStrict       = "strict"
+	defaultStrict      = false
+	strictDescription  = "With --" + optionFromCsv + ", write nothing if any row is malformed and stop at the first config which can't be created, instead of skipping them"
+	routerCsvFileExt   = ".yml"
+	routerCsvNameField = "name"
+
+	optionOutNameTemplate      = "out-name-template"
+	defaultOutNameTemplate     = ""
+	outNameTemplateDescription = "A Go template for the path of each --" + optionFromCsv + " config within --" + optionOutDir + ", run with the " +
+		"row's template values (see --" + optionDumpValues + "), such as '{{ .Router.Name }}-{{ .Router.Edge.AdvertisedHost }}.yml'. Defaults to <name>.yml"
+)
+
+// routerCsvRow is a router to create a config for, read from a line of the --from-csv file
+type routerCsvRow struct {
+	line          int
+	name          string
+	advertiseHost string
+	port          string
+	private       bool
+}
+
+// routerCsvProblem is a row which couldn't be read, or whose config couldn't be created
+type routerCsvProblem struct {
+	line int
+	name string
+	err  error
+}
+
+func (problem routerCsvProblem) String() string {
+	if problem.name == "" {
+		return fmt.Sprintf("line %d: %v", problem.line, problem.err)
+	}
+	return fmt.Sprintf("line %d (%s): %v", problem.line, problem.name, problem.err)
+}
+
+// readRouterCsv reads the routers in r, returning the rows which could be read along with a problem for each which
+// couldn't, so they can be skipped
+func readRouterCsv(r io.Reader) ([]*routerCsvRow, []routerCsvProblem, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows []*routerCsvRow
+	var problems []routerCsvProblem
+	names := map[string]int{}
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				problems = append(problems, routerCsvProblem{line: parseErr.Line, err: parseErr.Err})
+				continue
+			}
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), routerCsvNameField) {
+			continue
+		}
+
+		row, err := parseRouterCsvRecord(line, record)
+		if err == nil {
+			if previous, found := names[row.name]; found {
+				err = errors.Errorf("router %s is already on line %d", row.name, previous)
+			}
+		}
+		if err != nil {
+			problems = append(problems, routerCsvProblem{line: line, name: strings.TrimSpace(record[0]), err: err})
+			continue
+		}
+		names[row.name] = line
+		rows = append(rows, row)
+	}
+	return rows, problems, nil
+}
+
+func parseRouterCsvRecord(line int, record []string) (*routerCsvRow, error) {
+	if len(record) > 4 {
+		return nil, errors.Errorf("expected at most 4 fields (name,advertiseHost,port,private), got %d", len(record))
+	}
+	fields := make([]string, 4)
+	for i, field := range record {
+		fields[i] = strings.TrimSpace(field)
+	}
+
+	row := &routerCsvRow{line: line, name: fields[0], advertiseHost: fields[1], port: fields[2]}
+	if row.name == "" {
+		return nil, errors.New("the router name is missing")
+	}
+	if strings.ContainsAny(row.name, `/\`) || row.name == "." || row.name == ".." {
+		return nil, errors.Errorf("invalid router name [%s], it's used as the file name", row.name)
+	}
+	if row.port != "" {
+		if port, err := strconv.Atoi(row.port); err != nil || port < 1 || port > 65535 {
+			return nil, errors.Errorf("invalid port [%s], must be between 1 and 65535", row.port)
+		}
+	}
+	if fields[3] != "" {
+		private, err := strconv.ParseBool(fields[3])
+		if err != nil {
+			return nil, errors.Errorf("invalid private value [%s], must be true or false", fields[3])
+		}
+		row.private = private
+	}
+	return row, nil
+}
+
+// runEdgeRoutersFromCsv creates a config in --out-dir for each router in the --from-csv file, each rendered by
+// runEdgeRouter from a copy of base with the row's values applied, then prints a summary of what was created
+func (options *CreateConfigRouterOptions) runEdgeRoutersFromCsv(base *ConfigTemplateValues, out io.Writer) error {
+	if options.DumpValues != "" || options.ManifestFile != "" {
+		return errors.Errorf("--%s and --%s can't be used with --%s, as there's one config per router", optionDumpValues, optionManifestFile, optionFromCsv)
+	}
+	if options.DiffOnly {
+		return errors.Errorf("--%s can't be used with --%s", optionDiffOnly, optionFromCsv)
+	}
+
+	f, err := os.Open(options.FromCsv)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open router CSV file: %s", options.FromCsv)
+	}
+	defer func() { _ = f.Close() }()
+
+	rows, problems, err := readRouterCsv(f)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read router CSV file: %s", options.FromCsv)
+	}
+	if options.Strict && len(problems) > 0 {
+		return errors.Errorf("%d malformed rows in %s, nothing was created:\n  %s", len(problems), options.FromCsv, joinProblems(problems))
+	}
+
+	nameTmpl, err := options.outNameTemplate()
+	if err != nil {
+		return err
+	}
+
+	dir, err := filepath.Abs(options.OutDir)
+	if err != nil {
+		return errors.Wrapf(err, "invalid output directory: %s", options.OutDir)
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "unable to create output directory: %s", dir)
+	}
+
+	created := 0
+	outputs := map[string]*routerCsvRow{}
+	for _, row := range rows {
+		if err = options.runEdgeRouterFromCsvRow(base, row, dir, nameTmpl, outputs); err != nil {
+			problems = append(problems, routerCsvProblem{line: row.line, name: row.name, err: err})
+			if options.Strict {
+				return errors.Wrapf(err, "unable to create the config for router %s on line %d", row.name, row.line)
+			}
+			continue
+		}
+		created++
+	}
+
+	_, _ = fmt.Fprintf(out, "Created %d router configs in %s, %d failed\n", created, dir, len(problems))
+	if len(problems) > 0 {
+		_, _ = fmt.Fprintf(out, "  %s\n", joinProblems(problems))
+		return errors.Errorf("%d rows of %s failed", len(problems), options.FromCsv)
+	}
+	return nil
+}
+
+// runEdgeRouterFromCsvRow creates the config for row, named by nameTmpl when it's set. outputs holds the row each
+// templated name was given to, so two rows naming the same file is an error rather than one overwriting the other
+func (options *CreateConfigRouterOptions) runEdgeRouterFromCsvRow(base *ConfigTemplateValues, row *routerCsvRow, dir string, nameTmpl *template.Template, outputs map[string]*routerCsvRow) error {
+	values := *base
+	values.Router.Name = row.name
+	SetZitiRouterIdentity(&values.Router, row.name)
+
+	rowOptions := *options
+	rowOptions.Out = nil
+	rowOptions.Output = filepath.Join(dir, row.name+routerCsvFileExt)
+	if nameTmpl != nil {
+		rowOptions.outputName = func(data *ConfigTemplateValues) (string, error) {
+			name, err := renderOutName(nameTmpl, data)
+			if err != nil {
+				return "", err
+			}
+			output := filepath.Join(dir, name)
+			if previous, found := outputs[output]; found {
+				return "", errors.Errorf("%s is already the config for router %s on line %d", name, previous.name, previous.line)
+			}
+			outputs[output] = row
+			if err = os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+				return "", errors.Wrapf(err, "unable to create output directory: %s", filepath.Dir(output))
+			}
+			return output, nil
+		}
+	}
+	rowOptions.Tee = false
+	rowOptions.IsPrivate = row.private
+	if row.port != "" {
+		rowOptions.EdgeListenPort = row.port
+	}
+	if row.advertiseHost != "" {
+		rowOptions.EdgeAdvertiseHost = row.advertiseHost
+	}
+	if err := rowOptions.runEdgeRouter(&values); err != nil {
+		return err
+	}
+	logrus.Debugf("Created the config for router %s from line %d", row.name, row.line)
+	return nil
+}
+
+// outNameTemplate parses --out-name-template, returning nil when it isn't set. Unknown values are an error rather than
+// rendering as <no value>, so a typo can't name every config the same
+func (options *CreateConfigRouterOptions) outNameTemplate() (*template.Template, error) {
+	if options.OutNameTemplate == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(optionOutNameTemplate).Funcs(configTemplateFuncs).Option("missingkey=error").Parse(options.OutNameTemplate)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid --%s", optionOutNameTemplate)
+	}
+	return tmpl, nil
+}
+
+// renderOutName runs tmpl with data, returning the path it gives relative to --out-dir. Paths which are empty, absolute
+// or reach outside --out-dir are rejected
+func renderOutName(tmpl *template.Template, data *ConfigTemplateValues) (string, error) {
+	rendered := &strings.Builder{}
+	if err := tmpl.Execute(rendered, data); err != nil {
+		return "", errors.Wrapf(err, "unable to execute --%s", optionOutNameTemplate)
+	}
+	name := strings.TrimSpace(rendered.String())
+	if name == "" {
+		return "", errors.Errorf("--%s gave an empty file name", optionOutNameTemplate)
+	}
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) {
+		return "", errors.Errorf("--%s gave [%s], which must be relative to --%s", optionOutNameTemplate, name, optionOutDir)
+	}
+	clean := filepath.Clean(name)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) || strings.HasSuffix(name, "/") || strings.HasSuffix(name, string(filepath.Separator)) {
+		return "", errors.Errorf("--%s gave [%s], which isn't a file within --%s", optionOutNameTemplate, name, optionOutDir)
+	}
+	return clean, nil
+}
+
+func joinProblems(problems []routerCsvProblem) string {
+	var lines []string
+	for _, problem := range problems {
+		lines = append(lines, problem.String())
+	}
+	return strings.Join(lines, "\n  ")
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const testRoutersCsv = `name,advertiseHost,port,private
+# lab routers
+edge-1,edge1.example.org,3022,false
+edge-2,,4022,
+edge-3,edge3.example.org,,true
+`
+
+func writeRoutersCsv(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "routers.csv")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func runRoutersFromCsv(t *testing.T, args ...string) (string, error) {
+	clearOptionsAndTemplateData()
+	cmd := NewCmdCreateConfigRouter()
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetArgs(append([]string{"edge"}, args...))
+
+	var err error
+	captureOutput(func() {
+		cmd.PersistentPreRun(cmd, nil)
+		edge, _, findErr := cmd.Find([]string{"edge"})
+		require.NoError(t, findErr)
+		require.NoError(t, edge.ParseFlags(args))
+		routerOptions.Cmd = edge
+		err = routerOptions.runEdgeRoutersFromCsv(data, out)
+	})
+	return out.String(), err
+}
+
+func readCsvRouterConfig(t *testing.T, path string) RouterConfig {
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	config := RouterConfig{}
+	require.NoError(t, yaml.Unmarshal(raw, &config))
+	return config
+}
+
+func TestRoutersFromCsv(t *testing.T) {
+	dir := t.TempDir()
+	summary, err := runRoutersFromCsv(t, "--from-csv", writeRoutersCsv(t, testRoutersCsv), "--out-dir", dir)
+	require.NoError(t, err)
+	assert.Contains(t, summary, "Created 3 router configs in "+dir+", 0 failed")
+
+	config := readCsvRouterConfig(t, filepath.Join(dir, "edge-1.yml"))
+	assert.Equal(t, "tls:0.0.0.0:3022", config.Listeners[0].Address)
+	assert.Equal(t, "edge1.example.org:3022", config.Listeners[0].Options.Advertise)
+	assert.Equal(t, "tls:edge1.example.org:10080", config.Link.Listeners[0].Advertise)
+	assert.True(t, strings.HasSuffix(config.Identity.Cert, "/edge-1.cert"), config.Identity.Cert)
+
+	config = readCsvRouterConfig(t, filepath.Join(dir, "edge-2.yml"))
+	assert.Equal(t, "tls:0.0.0.0:4022", config.Listeners[0].Address)
+	assert.Equal(t, testHostname+":4022", config.Listeners[0].Options.Advertise)
+
+	raw, err := os.ReadFile(filepath.Join(dir, "edge-3.yml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "#      advertise:        \"tls:edge3.example.org:10080\"", "private routers have no link listener")
+}
+
+func TestRoutersFromCsvSkipsMalformedRows(t *testing.T) {
+	dir := t.TempDir()
+	csv := testRoutersCsv + "edge-4,,not-a-port,\n,missing.example.org\nedge-1,again.example.org\nedge-5,,,maybe\n"
+	summary, err := runRoutersFromCsv(t, "--from-csv", writeRoutersCsv(t, csv), "--out-dir", dir)
+	require.Error(t, err)
+	assert.Contains(t, summary, "Created 3 router configs in "+dir+", 4 failed")
+	assert.Contains(t, summary, "line 6 (edge-4): invalid port [not-a-port]")
+	assert.Contains(t, summary, "line 7: the router name is missing")
+	assert.Contains(t, summary, "line 8 (edge-1): router edge-1 is already on line 3")
+	assert.Contains(t, summary, "line 9 (edge-5): invalid private value [maybe]")
+	assert.FileExists(t, filepath.Join(dir, "edge-3.yml"))
+	assert.NoFileExists(t, filepath.Join(dir, "edge-4.yml"))
+}
+
+func TestRoutersFromCsvStrict(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "configs")
+	csv := testRoutersCsv + "edge-4,,not-a-port,\n"
+	_, err := runRoutersFromCsv(t, "--from-csv", writeRoutersCsv(t, csv), "--out-dir", dir, "--strict")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 6 (edge-4): invalid port [not-a-port]")
+	assert.NoDirExists(t, dir, "nothing is written when a row is malformed")
+}
+
+func TestRoutersFromCsvOutNameTemplate(t *testing.T) {
+	dir := t.TempDir()
+	summary, err := runRoutersFromCsv(t, "--from-csv", writeRoutersCsv(t, testRoutersCsv), "--out-dir", dir,
+		"--out-name-template", "{{ .Router.Edge.Port }}/{{ .Router.Name }}-{{ if .Router.IsPrivate }}private{{ else }}public{{ end }}.yml")
+	require.NoError(t, err)
+	assert.Contains(t, summary, "Created 3 router configs in "+dir+", 0 failed")
+
+	config := readCsvRouterConfig(t, filepath.Join(dir, "3022", "edge-1-public.yml"))
+	assert.Equal(t, "edge1.example.org:3022", config.Listeners[0].Options.Advertise)
+	assert.FileExists(t, filepath.Join(dir, "4022", "edge-2-public.yml"))
+	assert.FileExists(t, filepath.Join(dir, "3022", "edge-3-private.yml"), "the default port")
+	assert.NoFileExists(t, filepath.Join(dir, "edge-1.yml"))
+}
+
+func TestRoutersFromCsvOutNameTemplateCollision(t *testing.T) {
+	dir := t.TempDir()
+	summary, err := runRoutersFromCsv(t, "--from-csv", writeRoutersCsv(t, testRoutersCsv), "--out-dir", dir,
+		"--out-name-template", "{{ .Router.IsPrivate }}.yml")
+	require.Error(t, err)
+	assert.Contains(t, summary, "Created 2 router configs in "+dir+", 1 failed")
+	assert.Contains(t, summary, "line 4 (edge-2): false.yml is already the config for router edge-1 on line 3")
+	assert.FileExists(t, filepath.Join(dir, "true.yml"))
+}
+
+func TestRoutersFromCsvOutNameTemplateInvalid(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "configs")
+	_, err := runRoutersFromCsv(t, "--from-csv", writeRoutersCsv(t, testRoutersCsv), "--out-dir", dir,
+		"--out-name-template", "{{ .Router.Name }")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid --out-name-template")
+	assert.NoDirExists(t, dir, "nothing is written when the template can't be parsed")
+}
+
+func TestRenderOutName(t *testing.T) {
+	values := &ConfigTemplateValues{}
+	values.Router.Name = "edge-1"
+	render := func(text string) (string, error) {
+		tmpl, err := (&CreateConfigRouterOptions{OutNameTemplate: text}).outNameTemplate()
+		require.NoError(t, err)
+		return renderOutName(tmpl, values)
+	}
+
+	name, err := render("{{ .Router.Name }}.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "edge-1.yml", name)
+
+	name, err = render("lab/./{{ .Router.Name }}.yml")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("lab", "edge-1.yml"), name)
+
+	name, err = render("lab/../{{ .Router.Name }}.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "edge-1.yml", name, "staying within --out-dir is fine")
+
+	for _, text := range []string{" ", "{{ .Router.Missing }}", "../{{ .Router.Name }}.yml", "lab/../../x.yml", "/etc/{{ .Router.Name }}.yml", "..", ".", "lab/"} {
+		_, err = render(text)
+		assert.Error(t, err, text)
+	}
+}
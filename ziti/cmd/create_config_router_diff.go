@@ -0,0 +1,91 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	cmdhelper "github.com/openziti/ziti/ziti/cmd/helpers"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Exit codes of --diff-only, so a CI step can tell a config which has drifted from one which was never deployed
+const (
+	diffExitDrift   = 1
+	diffExitMissing = 2
+)
+
+// stampPrefix starts the --stamp header of a router config, which records when it was generated and so always differs
+const stampPrefix = "# generated by ziti "
+
+// diffConfig compares config with the --output file, writing a unified diff to options.Out, or stdout, if they
+// differ. Nothing is written when they match. The stamp header of the file is ignored, as config is rendered without
+// one. A mismatch or a missing file is returned as an ExitError with diffExitDrift or diffExitMissing.
+func (options *CreateConfigRouterOptions) diffConfig(config []byte) error {
+	if options.Output == "" || strings.ToLower(options.Output) == "stdout" {
+		return errors.Errorf("--%s requires --%s to be the config file to compare with", optionDiffOnly, optionOutput)
+	}
+
+	existing, err := os.ReadFile(options.Output)
+	if os.IsNotExist(err) {
+		return &cmdhelper.ExitError{Code: diffExitMissing, Message: fmt.Sprintf("config file %s does not exist", options.Output)}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to read config file: %s", options.Output)
+	}
+	existing = stripStampHeader(existing)
+
+	if bytes.Equal(existing, config) {
+		return nil
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(existing)),
+		B:        difflib.SplitLines(string(config)),
+		FromFile: options.Output,
+		ToFile:   "generated",
+		Context:  3,
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to diff config")
+	}
+
+	var out io.Writer = os.Stdout
+	if options.Out != nil {
+		out = options.Out
+	}
+	if _, err = io.WriteString(out, diff); err != nil {
+		return errors.Wrap(err, "unable to write diff")
+	}
+	return &cmdhelper.ExitError{Code: diffExitDrift}
+}
+
+// stripStampHeader returns config without the --stamp header it starts with, if it has one
+func stripStampHeader(config []byte) []byte {
+	if !bytes.HasPrefix(config, []byte(stampPrefix)) {
+		return config
+	}
+	if i := bytes.IndexByte(config, '\n'); i >= 0 {
+		return config[i+1:]
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cmdhelper "github.com/openziti/ziti/ziti/cmd/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func diffEdgeRouter(t *testing.T, output string, configure func(options *CreateConfigRouterOptions)) (string, error) {
+	out := &bytes.Buffer{}
+	options := &CreateConfigRouterOptions{TunnelerMode: defaultTunnelerMode}
+	options.Output = output
+	options.Out = out
+	if configure != nil {
+		configure(options)
+	}
+	err := options.runEdgeRouter(goldenTemplateValues())
+	return out.String(), err
+}
+
+func exitCode(t *testing.T, err error) int {
+	exitErr, ok := err.(*cmdhelper.ExitError)
+	require.True(t, ok, "expected an ExitError, got %v", err)
+	return exitErr.Code
+}
+
+func TestDiffOnlyMatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "router.yml")
+	config, err := diffEdgeRouter(t, "", func(options *CreateConfigRouterOptions) { options.Stamp = true })
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(config, stampPrefix))
+	require.NoError(t, os.WriteFile(path, []byte(config), 0644))
+
+	diff, err := diffEdgeRouter(t, path, func(options *CreateConfigRouterOptions) {
+		options.DiffOnly = true
+		options.Stamp = true
+	})
+	require.NoError(t, err, "the stamp header is ignored")
+	assert.Empty(t, diff)
+}
+
+func TestDiffOnlyDrift(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "router.yml")
+	config, err := diffEdgeRouter(t, "", nil)
+	require.NoError(t, err)
+	drifted := strings.Replace(config, "tls:0.0.0.0:3022", "tls:0.0.0.0:4022", 1)
+	require.NoError(t, os.WriteFile(path, []byte(drifted), 0644))
+
+	diff, err := diffEdgeRouter(t, path, func(options *CreateConfigRouterOptions) {
+		options.DiffOnly = true
+		options.Manifest = true
+	})
+	require.Error(t, err)
+	assert.Equal(t, diffExitDrift, exitCode(t, err))
+	assert.Contains(t, diff, "--- "+path+"\n")
+	assert.Contains(t, diff, "+++ generated\n")
+	assert.Contains(t, diff, "-    address: \"tls:0.0.0.0:4022\"\n")
+	assert.Contains(t, diff, "+    address: \"tls:0.0.0.0:3022\"\n")
+
+	unchanged, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, drifted, string(unchanged))
+	assert.NoFileExists(t, path+manifestFileSuffix)
+}
+
+func TestDiffOnlyMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "router.yml")
+	diff, err := diffEdgeRouter(t, path, func(options *CreateConfigRouterOptions) { options.DiffOnly = true })
+	require.Error(t, err)
+	assert.Equal(t, diffExitMissing, exitCode(t, err))
+	assert.Empty(t, diff)
+	assert.NoFileExists(t, path)
+
+	_, err = diffEdgeRouter(t, "stdout", func(options *CreateConfigRouterOptions) { options.DiffOnly = true })
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--diff-only requires --output")
+}
@@ -20,10 +20,11 @@ import (
 	_ "embed"
 	cmdhelper "github.com/openziti/ziti/ziti/cmd/helpers"
 	"github.com/openziti/ziti/ziti/cmd/templates"
-	"os"
-	"path/filepath"
+	"github.com/openziti/ziti/ziti/constants"
+	"net"
+	"net/url"
+	"strconv"
 	"strings"
-	"text/template"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -31,21 +32,48 @@ import (
 )
 
 const (
-	optionWSS               = "wss"
-	defaultWSS              = false
-	wssDescription          = "Create an edge router config with wss enabled"
-	optionPrivate           = "private"
-	defaultPrivate          = false
-	privateDescription      = "Create a private router config"
-	tproxyTunMode           = "tproxy"
-	hostTunMode             = "host"
-	noneTunMode             = "none"
-	optionTunnelerMode      = "tunnelerMode"
-	defaultTunnelerMode     = hostTunMode
-	tunnelerModeDescription = "Specify tunneler mode \"" + noneTunMode + "\", \"" + hostTunMode + "\", or \"" + tproxyTunMode + "\""
-	optionLanInterface      = "lanInterface"
-	defaultLanInterface     = ""
-	lanInterfaceDescription = "The interface on host of the router to insert iptables ingress filter rules"
+	optionWSS                = "wss"
+	defaultWSS               = false
+	wssDescription           = "Create an edge router config with wss enabled"
+	optionPrivate            = "private"
+	defaultPrivate           = false
+	privateDescription       = "Create a private router config"
+	tproxyTunMode            = "tproxy"
+	hostTunMode              = "host"
+	noneTunMode              = "none"
+	optionTunnelerMode       = "tunnelerMode"
+	defaultTunnelerMode      = hostTunMode
+	tunnelerModeDescription  = "Specify tunneler mode \"" + noneTunMode + "\", \"" + hostTunMode + "\", or \"" + tproxyTunMode + "\""
+	optionLanInterface       = "lanInterface"
+	defaultLanInterface      = ""
+	lanInterfaceDescription  = "The interface on host of the router to insert iptables ingress filter rules"
+	optionResolver           = "resolver"
+	defaultResolver          = ""
+	resolverDescription      = "The address, as udp://host:port or host:port, the " + tproxyTunMode + " tunneler answers DNS queries for intercepted names on. Only used with --" + optionTunnelerMode + " " + tproxyTunMode + ", defaults to port 53 of the edge advertise host"
+	defaultWssAdvertisedPort = "3023"
+
+	optionEdgeBindHost           = "edge-bind-host"
+	defaultEdgeBindHost          = "0.0.0.0"
+	edgeBindHostDescription      = "The address the edge listener binds to"
+	optionEdgeAdvertiseHost      = "edge-advertise-host"
+	defaultEdgeAdvertiseHost     = ""
+	edgeAdvertiseHostDescription = "The address the router advertises for its edge and link listeners. Defaults to the bind address " +
+		"when that's a specific address, otherwise to the resolved hostname. Must be a specific address when binding to all of them"
+	optionEdgeListenPort         = "edge-listen-port"
+	defaultEdgeListenPort        = ""
+	edgeListenPortDescription    = "The port the edge listener binds to. Defaults to $" + constants.ZitiEdgeRouterPortVarName + " or " + constants.DefaultZitiEdgeRouterPort
+	optionEdgeAdvertisePort      = "edge-advertise-port"
+	defaultEdgeAdvertisePort     = ""
+	edgeAdvertisePortDescription = "The port the router advertises for its edge listener, when peers reach it on a different port " +
+		"than it listens on, such as through NAT or a container port mapping. Defaults to the listen port, or " + defaultWssAdvertisedPort + " with --" + optionWSS
+	optionEdgeListenerInterface      = "edge-listener-interface"
+	defaultEdgeListenerInterface     = ""
+	edgeListenerInterfaceDescription = "Bind the edge listener to the address of this network interface, looked up when the config is generated. " +
+		"The first IPv4 address which isn't link-local is used, see --" + optionPreferIPv6
+	optionPreferIPv6      = "prefer-ipv6"
+	defaultPreferIPv6     = false
+	preferIPv6Description = "Use the first IPv6 address of the --" + optionEdgeListenerInterface + " instead, " +
+		"falling back to IPv4 if it has none. Either way the other family is used if the preferred one is missing"
 )
 
 var (
@@ -56,6 +84,12 @@ var (
 	createConfigRouterEdgeExample = templates.Examples(`
 		# Create the edge router config for a router named my_router
 		ziti create config router edge --routerName my_router
+
+		# Create a config in ./configs for each router in routers.csv, with rows of name,advertiseHost,port,private
+		ziti create config router edge --from-csv routers.csv --out-dir ./configs
+
+		# Create the config with the blocks redefined by ./profiles/dev.yml, such as {{ define "logging" }}...{{ end }}
+		ziti create config router edge --routerName my_router --profile dev
 	`)
 )
 
@@ -71,15 +105,13 @@ func NewCmdCreateConfigRouterEdge() *cobra.Command {
 		Aliases: []string{"edge"},
 		Long:    createConfigRouterEdgeLong,
 		Example: createConfigRouterEdgeExample,
-		PreRun: func(cmd *cobra.Command, args []string) {
-			data.Router.IsWss = routerOptions.WssEnabled
-			data.Router.IsPrivate = routerOptions.IsPrivate
-			data.Router.TunnelerMode = routerOptions.TunnelerMode
-			data.Router.Edge.LanInterface = routerOptions.LanInterface
-		},
 		Run: func(cmd *cobra.Command, args []string) {
 			routerOptions.Cmd = cmd
 			routerOptions.Args = args
+			if routerOptions.FromCsv != "" {
+				cmdhelper.CheckErr(routerOptions.runEdgeRoutersFromCsv(data, cmd.OutOrStdout()))
+				return
+			}
 			err := routerOptions.runEdgeRouter(data)
 			cmdhelper.CheckErr(err)
 		},
@@ -87,6 +119,13 @@ func NewCmdCreateConfigRouterEdge() *cobra.Command {
 
 	routerOptions.addCreateFlags(cmd)
 	routerOptions.addEdgeFlags(cmd)
+	cmd.Flags().StringVar(&routerOptions.FromCsv, optionFromCsv, defaultFromCsv, fromCsvDescription)
+	cmd.Flags().StringVar(&routerOptions.OutDir, optionOutDir, defaultOutDir, outDirDescription)
+	cmd.Flags().StringVar(&routerOptions.OutNameTemplate, optionOutNameTemplate, defaultOutNameTemplate, outNameTemplateDescription)
+	cmd.Flags().BoolVar(&routerOptions.Strict, optionStrict, defaultStrict, strictDescription)
+
+	cmd.AddCommand(NewCmdCreateConfigRouterPatch())
+	cmd.AddCommand(NewCmdCreateConfigRouterExplain(cmd.Flags()))
 
 	return cmd
 }
@@ -96,6 +135,13 @@ func (options *CreateConfigRouterOptions) addEdgeFlags(cmd *cobra.Command) {
 	cmd.Flags().BoolVar(&options.IsPrivate, optionPrivate, defaultPrivate, privateDescription)
 	cmd.PersistentFlags().StringVarP(&options.TunnelerMode, optionTunnelerMode, "", defaultTunnelerMode, tunnelerModeDescription)
 	cmd.PersistentFlags().StringVarP(&options.LanInterface, optionLanInterface, "", defaultLanInterface, lanInterfaceDescription)
+	cmd.PersistentFlags().StringVar(&options.Resolver, optionResolver, defaultResolver, resolverDescription)
+	cmd.PersistentFlags().StringVar(&options.EdgeBindHost, optionEdgeBindHost, defaultEdgeBindHost, edgeBindHostDescription)
+	cmd.PersistentFlags().StringVar(&options.EdgeAdvertiseHost, optionEdgeAdvertiseHost, defaultEdgeAdvertiseHost, edgeAdvertiseHostDescription)
+	cmd.PersistentFlags().StringVar(&options.EdgeListenPort, optionEdgeListenPort, defaultEdgeListenPort, edgeListenPortDescription)
+	cmd.PersistentFlags().StringVar(&options.EdgeAdvertisePort, optionEdgeAdvertisePort, defaultEdgeAdvertisePort, edgeAdvertisePortDescription)
+	cmd.PersistentFlags().StringVar(&options.EdgeListenerInterface, optionEdgeListenerInterface, defaultEdgeListenerInterface, edgeListenerInterfaceDescription)
+	cmd.PersistentFlags().BoolVar(&options.PreferIPv6, optionPreferIPv6, defaultPreferIPv6, preferIPv6Description)
 	cmd.PersistentFlags().StringVarP(&options.RouterName, optionRouterName, "n", "", "name of the router")
 	err := cmd.MarkPersistentFlagRequired(optionRouterName)
 	if err != nil {
@@ -103,46 +149,245 @@ func (options *CreateConfigRouterOptions) addEdgeFlags(cmd *cobra.Command) {
 	}
 }
 
-// run implements the command
+// runEdgeRouter implements the command
 func (options *CreateConfigRouterOptions) runEdgeRouter(data *ConfigTemplateValues) error {
+	if options.overridesValues(optionWSS) {
+		data.Router.IsWss = options.WssEnabled
+	}
+	if options.overridesValues(optionPrivate) {
+		data.Router.IsPrivate = options.IsPrivate
+	}
+	if options.overridesValues(optionTunnelerMode) || data.Router.TunnelerMode == "" {
+		data.Router.TunnelerMode = options.TunnelerMode
+	}
+	if options.overridesValues(optionLanInterface) {
+		data.Router.Edge.LanInterface = options.LanInterface
+	}
+
 	// Ensure private and wss are not both used
-	if options.IsPrivate && options.WssEnabled {
+	if data.Router.IsPrivate && data.Router.IsWss {
 		return errors.New("Flags for private and wss configs are mutually exclusive. You must choose private or wss, not both")
 	}
 
 	// Make sure the tunneler mode is valid
-	if options.TunnelerMode != hostTunMode && options.TunnelerMode != tproxyTunMode && options.TunnelerMode != noneTunMode {
-		return errors.New("Unknown tunneler mode [" + options.TunnelerMode + "] provided, should be \"" + noneTunMode + "\", \"" + hostTunMode + "\", or \"" + tproxyTunMode + "\"")
+	if mode := data.Router.TunnelerMode; mode != hostTunMode && mode != tproxyTunMode && mode != noneTunMode {
+		return errors.New("Unknown tunneler mode [" + mode + "] provided, should be \"" + noneTunMode + "\", \"" + hostTunMode + "\", or \"" + tproxyTunMode + "\"")
+	}
+	if options.Resolver != "" {
+		if data.Router.TunnelerMode != tproxyTunMode {
+			return errors.Errorf("--%s is only used with --%s %s, the tunneler mode is %s", optionResolver, optionTunnelerMode, tproxyTunMode, data.Router.TunnelerMode)
+		}
+		resolver, err := parseResolver(options.Resolver)
+		if err != nil {
+			return err
+		}
+		data.Router.Edge.Resolver = resolver
 	}
 
-	tmpl, err := template.New("edge-router-config").Parse(routerConfigEdgeTemplate)
-	if err != nil {
+	if err := options.applyEdgeHosts(data); err != nil {
 		return err
 	}
 
-	var f *os.File
-	if strings.ToLower(options.Output) != "stdout" {
-		// Check if the path exists, fail if it doesn't
-		basePath := filepath.Dir(options.Output) + "/"
-		if _, err := os.Stat(filepath.Dir(basePath)); os.IsNotExist(err) {
-			return err
-		}
+	if err := options.applyEdgePorts(data); err != nil {
+		return err
+	}
+
+	if err := options.applyRouterOptions(data); err != nil {
+		return err
+	}
 
-		f, err = os.Create(options.Output)
-		logrus.Debugf("Created output file: %s", options.Output)
+	if options.outputName != nil {
+		output, err := options.outputName(data)
 		if err != nil {
-			return errors.Wrapf(err, "unable to create config file: %s", options.Output)
+			return err
 		}
-	} else {
-		f = os.Stdout
+		options.Output = output
 	}
-	defer func() { _ = f.Close() }()
 
-	if err := tmpl.Execute(f, data); err != nil {
-		return errors.Wrap(err, "unable to execute template")
+	tmpl, err := options.routerTemplate("edge-router-config", routerConfigEdgeTemplate)
+	if err != nil {
+		return err
+	}
+
+	if err = options.writeRouterConfig(tmpl, data); err != nil {
+		return err
 	}
 
 	logrus.Debugf("Edge Router configuration generated successfully and written to: %s", options.Output)
 
 	return nil
 }
+
+// applyEdgeHosts sets the address the edge listener binds to and the one advertised for it. Unless it's given, the
+// advertised address follows a specific bind address, and is otherwise left as resolved from the environment. An address
+// which binds to every interface can't be advertised, as peers couldn't use it to reach the router. Without any of the
+// edge host options, as when the topology command sets the values itself, the values are left alone.
+func (options *CreateConfigRouterOptions) applyEdgeHosts(data *ConfigTemplateValues) error {
+	if options.EdgeBindHost == "" && options.EdgeListenerInterface == "" && options.EdgeAdvertiseHost == "" {
+		return nil
+	}
+
+	bindHost, err := options.edgeBindHost()
+	if err != nil {
+		return err
+	}
+	if bindHost != "" {
+		data.Router.Edge.BindHost = bindHost
+	}
+
+	if options.EdgeAdvertiseHost != "" {
+		data.Router.Edge.AdvertisedHost = options.EdgeAdvertiseHost
+	} else if bindHost != "" && !isWildcardHost(bindHost) {
+		data.Router.Edge.AdvertisedHost = bindHost
+	}
+
+	if isWildcardHost(data.Router.Edge.AdvertisedHost) && (options.EdgeAdvertiseHost != "" || isWildcardHost(data.Router.Edge.BindHost)) {
+		return errors.Errorf("Invalid value for --%s [%s], the edge listener binds to all addresses with --%s %s so it must advertise a specific "+
+			"address or hostname peers can reach it on", optionEdgeAdvertiseHost, data.Router.Edge.AdvertisedHost, optionEdgeBindHost, data.Router.Edge.BindHost)
+	}
+	return nil
+}
+
+// applyEdgePorts sets the port the edge listener binds to and the one advertised for it, which is only set when it
+// differs from the listen port. Without either option the values are left alone.
+func (options *CreateConfigRouterOptions) applyEdgePorts(data *ConfigTemplateValues) error {
+	if options.EdgeListenPort != "" {
+		if err := validateEdgePort(optionEdgeListenPort, options.EdgeListenPort); err != nil {
+			return err
+		}
+		data.Router.Edge.Port = options.EdgeListenPort
+	}
+	if options.EdgeAdvertisePort != "" {
+		if err := validateEdgePort(optionEdgeAdvertisePort, options.EdgeAdvertisePort); err != nil {
+			return err
+		}
+		data.Router.Edge.AdvertisedPort = options.EdgeAdvertisePort
+	}
+	return nil
+}
+
+// parseResolver checks the --resolver address, returning it as the udp://host:port the tunneler expects. A plain
+// host:port is taken to be udp, as that's the only scheme the resolver supports
+func parseResolver(value string) (string, error) {
+	address := value
+	if !strings.Contains(address, "://") {
+		address = "udp://" + address
+	}
+	resolver, err := url.Parse(address)
+	if err != nil || resolver.Scheme != "udp" || resolver.Path != "" || resolver.RawQuery != "" || resolver.User != nil {
+		return "", errors.Errorf("Invalid value for --%s [%s], must be of the form udp://host:port", optionResolver, value)
+	}
+	if resolver.Hostname() == "" || isWildcardHost(resolver.Hostname()) {
+		return "", errors.Errorf("Invalid value for --%s [%s], must be a specific address the tunneler can listen on", optionResolver, value)
+	}
+	if port, err := strconv.Atoi(resolver.Port()); err != nil || port < 1 || port > 65535 {
+		return "", errors.Errorf("Invalid value for --%s [%s], port must be between 1 and 65535", optionResolver, value)
+	}
+	return "udp://" + net.JoinHostPort(resolver.Hostname(), resolver.Port()), nil
+}
+
+// validateEdgePort checks that the port given for option is a number between 1 and 65535
+func validateEdgePort(option, port string) error {
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		return errors.Errorf("Invalid value for --%s [%s], must be a number", option, port)
+	}
+	if portNum == 0 {
+		return errors.Errorf("Invalid value for --%s [0], peers can't reach the router on port 0", option)
+	}
+	if portNum < 1 || portNum > 65535 {
+		return errors.Errorf("Invalid value for --%s [%s], port must be between 1 and 65535", option, port)
+	}
+	return nil
+}
+
+// isWildcardHost returns true if host is empty or an address which binds to every interface, such as 0.0.0.0 or ::
+func isWildcardHost(host string) bool {
+	if host == "" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified()
+}
+
+// edgeBindHost returns the address the edge listener should bind to, from --edge-listener-interface if it's set,
+// otherwise from --edge-bind-host. An empty result leaves the template's default in place.
+func (options *CreateConfigRouterOptions) edgeBindHost() (string, error) {
+	if options.EdgeListenerInterface == "" {
+		return options.EdgeBindHost, nil
+	}
+	// the default bind host is also a valid one to give, so an explicit --edge-bind-host is told apart by the flag being set
+	explicitBindHost := options.Cmd != nil && options.Cmd.Flags().Changed(optionEdgeBindHost)
+	if explicitBindHost || (options.EdgeBindHost != "" && options.EdgeBindHost != defaultEdgeBindHost) {
+		return "", errors.Errorf("--%s and --%s are mutually exclusive", optionEdgeBindHost, optionEdgeListenerInterface)
+	}
+
+	iface, err := net.InterfaceByName(options.EdgeListenerInterface)
+	if err != nil {
+		return "", errors.Errorf("Invalid value for --%s [%s], no such network interface. Available interfaces are: %s",
+			optionEdgeListenerInterface, options.EdgeListenerInterface, strings.Join(interfaceNames(), ", "))
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get the addresses of network interface %s", iface.Name)
+	}
+	ip, err := selectInterfaceAddress(addrs, options.PreferIPv6)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to bind the edge listener to network interface %s", iface.Name)
+	}
+	logrus.Debugf("Edge listener bound to %s, the address of network interface %s", ip, iface.Name)
+	return ip.String(), nil
+}
+
+// selectInterfaceAddress picks the address to bind to from an interface's addresses: the first IPv4 address, or the
+// first IPv6 address if preferIPv6 is set, falling back to the other family. Link-local addresses are never used, as
+// they're only reachable from the same network segment.
+func selectInterfaceAddress(addrs []net.Addr, preferIPv6 bool) (net.IP, error) {
+	var firstIPv4, firstIPv6 net.IP
+	for _, addr := range addrs {
+		var ip net.IP
+		switch a := addr.(type) {
+		case *net.IPNet:
+			ip = a.IP
+		case *net.IPAddr:
+			ip = a.IP
+		default:
+			continue
+		}
+		if ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+			continue
+		}
+		if ip.To4() != nil {
+			if firstIPv4 == nil {
+				firstIPv4 = ip.To4()
+			}
+		} else if firstIPv6 == nil {
+			firstIPv6 = ip
+		}
+	}
+
+	preferred, fallback := firstIPv4, firstIPv6
+	if preferIPv6 {
+		preferred, fallback = firstIPv6, firstIPv4
+	}
+	if preferred != nil {
+		return preferred, nil
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, errors.New("it has no address which isn't link-local")
+}
+
+// interfaceNames returns the names of the host's network interfaces, for error messages
+func interfaceNames() []string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, iface := range ifaces {
+		names = append(names, iface.Name)
+	}
+	return names
+}
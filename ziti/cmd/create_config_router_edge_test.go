@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"github.com/openziti/ziti/ziti/constants"
 	"github.com/stretchr/testify/assert"
+	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -160,6 +165,47 @@ func TestTunnelerInvalidMode(t *testing.T) {
 	assert.EqualError(t, err, expectedErrorMsg, "Error does not match, expected %s but got %s", expectedErrorMsg, err)
 }
 
+func TestTunnelerTproxyResolver(t *testing.T) {
+	clearOptionsAndTemplateData()
+	routerOptions.Output = defaultOutput
+	config := createRouterConfig([]string{"edge", "--routerName", "myRouter", "--tunnelerMode", tproxyTunMode, "--resolver", "10.0.0.2:5353"})
+	resolvers := 0
+	for _, listener := range config.Listeners {
+		if listener.Binding == "tunnel" {
+			assert.Equal(t, "udp://10.0.0.2:5353", listener.Options.Resolver)
+			resolvers++
+		}
+	}
+	assert.Equal(t, 1, resolvers)
+
+	// without --resolver the advertised host answers
+	clearOptionsAndTemplateData()
+	routerOptions.Output = defaultOutput
+	config = createRouterConfig([]string{"edge", "--routerName", "myRouter", "--tunnelerMode", tproxyTunMode, "--edge-advertise-host", "router.example.org"})
+	for _, listener := range config.Listeners {
+		if listener.Binding == "tunnel" {
+			assert.Equal(t, "udp://router.example.org:53", listener.Options.Resolver)
+		}
+	}
+}
+
+func TestTunnelerInvalidResolver(t *testing.T) {
+	for _, resolver := range []string{"10.0.0.2", "tcp://10.0.0.2:53", "udp://10.0.0.2:0", "udp://:53", "0.0.0.0:53", "udp://10.0.0.2:53/dns"} {
+		_, err := parseResolver(resolver)
+		assert.Error(t, err, "expected resolver [%s] to be rejected", resolver)
+	}
+
+	resolver, err := parseResolver("udp://[fd00::2]:53")
+	assert.NoError(t, err)
+	assert.Equal(t, "udp://[fd00::2]:53", resolver)
+
+	clearOptionsAndTemplateData()
+	routerOptions.Output = defaultOutput
+	routerOptions.TunnelerMode = hostTunMode
+	routerOptions.Resolver = "10.0.0.2:53"
+	assert.EqualError(t, routerOptions.runEdgeRouter(&ConfigTemplateValues{}), "--resolver is only used with --tunnelerMode tproxy, the tunneler mode is host")
+}
+
 func TestPrivateEdgeRouterNotAdvertising(t *testing.T) {
 	clearOptionsAndTemplateData()
 
@@ -321,3 +367,236 @@ func TestEdgeRouterIPOverrideIsConsumed(t *testing.T) {
 	}
 	assert.True(t, found, "Expected value not found; expected to find value of "+constants.ZitiEdgeRouterIPOverrideVarName+" in edge router config output.")
 }
+
+func TestEdgeRouterTeeWritesFileAndStdout(t *testing.T) {
+	clearOptionsAndTemplateData()
+	outputFile := filepath.Join(t.TempDir(), "router.yml")
+
+	cmd := NewCmdCreateConfigRouter()
+	cmd.SetArgs([]string{"edge", "--routerName", "myRouter", "--output", outputFile, "--tee"})
+	output := captureOutput(func() {
+		_ = cmd.Execute()
+	})
+
+	written, err := os.ReadFile(outputFile)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, output)
+	assert.Equal(t, string(written), output)
+}
+
+func TestEdgeRouterWithoutTeeOnlyWritesFile(t *testing.T) {
+	clearOptionsAndTemplateData()
+	outputFile := filepath.Join(t.TempDir(), "router.yml")
+
+	cmd := NewCmdCreateConfigRouter()
+	cmd.SetArgs([]string{"edge", "--routerName", "myRouter", "--output", outputFile})
+	output := captureOutput(func() {
+		_ = cmd.Execute()
+	})
+
+	written, err := os.ReadFile(outputFile)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, written)
+	assert.Empty(t, output)
+}
+
+func TestEdgeRouterManifest(t *testing.T) {
+	clearOptionsAndTemplateData()
+	outputFile := filepath.Join(t.TempDir(), "router.yml")
+
+	cmd := NewCmdCreateConfigRouter()
+	cmd.SetArgs([]string{"edge", "--routerName", "myRouter", "--output", outputFile, "--manifest"})
+	_ = captureOutput(func() {
+		_ = cmd.Execute()
+	})
+
+	written, err := os.ReadFile(outputFile)
+	assert.NoError(t, err)
+	manifestJson, err := os.ReadFile(outputFile + manifestFileSuffix)
+	assert.NoError(t, err)
+
+	manifest := &ConfigManifest{}
+	assert.NoError(t, json.Unmarshal(manifestJson, manifest))
+	checksum := sha256.Sum256(written)
+	assert.Equal(t, hex.EncodeToString(checksum[:]), manifest.Sha256)
+	assert.Equal(t, outputFile, manifest.File)
+	assert.Equal(t, "edge-router-config", manifest.Template)
+	assert.NotEmpty(t, manifest.Version)
+	assert.Equal(t, "myRouter", manifest.Values.Router.Name)
+}
+
+func TestEdgeRouterManifestModeAppliesToExistingFile(t *testing.T) {
+	options := CreateConfigRouterOptions{InlinePki: true}
+	options.Output = filepath.Join(t.TempDir(), "router.yml")
+	options.FileMode = "0600"
+	path := options.manifestPath()
+	assert.NoError(t, os.WriteFile(path, []byte("{}"), 0644))
+	assert.NoError(t, os.Chmod(path, 0644))
+
+	assert.NoError(t, options.writeManifest("edge-router-config", []byte{1}, &ConfigTemplateValues{}))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "the manifest holds the private key, so its old mode mustn't be kept")
+}
+
+func TestEdgeRouterManifestToStdoutNeedsManifestFile(t *testing.T) {
+	options := CreateConfigRouterOptions{Manifest: true}
+	options.Output = "stdout"
+	assert.Error(t, options.applyRouterOptions(&ConfigTemplateValues{}))
+
+	options.ManifestFile = filepath.Join(t.TempDir(), "router.manifest.json")
+	assert.NoError(t, options.applyRouterOptions(&ConfigTemplateValues{}))
+}
+
+func TestEdgeBindHost(t *testing.T) {
+	clearOptionsAndTemplateData()
+	config := createRouterConfig([]string{"edge", "--routerName", "myRouter"})
+	assert.Equal(t, "tls:0.0.0.0:3022", config.Listeners[0].Address)
+
+	clearOptionsAndTemplateData()
+	assert.Equal(t, testHostname+":3022", config.Listeners[0].Options.Advertise)
+
+	// a specific bind address is advertised too, unless told otherwise
+	clearOptionsAndTemplateData()
+	config = createRouterConfig([]string{"edge", "--routerName", "myRouter", "--" + optionEdgeBindHost, "fd00::10"})
+	assert.Equal(t, "tls:[fd00::10]:3022", config.Listeners[0].Address)
+	assert.Equal(t, "[fd00::10]:3022", config.Listeners[0].Options.Advertise)
+	assert.Equal(t, "tls:[fd00::10]:10080", config.Link.Listeners[0].Advertise)
+}
+
+func TestEdgeAdvertiseHost(t *testing.T) {
+	clearOptionsAndTemplateData()
+	config := createRouterConfig([]string{"edge", "--routerName", "myRouter", "--" + optionEdgeAdvertiseHost, "router.example.org"})
+	assert.Equal(t, "tls:0.0.0.0:3022", config.Listeners[0].Address)
+	assert.Equal(t, "router.example.org:3022", config.Listeners[0].Options.Advertise)
+	assert.Equal(t, "tls:0.0.0.0:10080", config.Link.Listeners[0].Bind)
+	assert.Equal(t, "tls:router.example.org:10080", config.Link.Listeners[0].Advertise)
+
+	clearOptionsAndTemplateData()
+	config = createRouterConfig([]string{"edge", "--routerName", "myRouter", "--" + optionEdgeBindHost, "10.0.0.5", "--" + optionEdgeAdvertiseHost, "router.example.org"})
+	assert.Equal(t, "tls:10.0.0.5:3022", config.Listeners[0].Address)
+	assert.Equal(t, "router.example.org:3022", config.Listeners[0].Options.Advertise)
+}
+
+func TestEdgeAdvertiseHostMustBeSpecific(t *testing.T) {
+	for _, advertise := range []string{"0.0.0.0", "::"} {
+		options := &CreateConfigRouterOptions{EdgeBindHost: defaultEdgeBindHost, EdgeAdvertiseHost: advertise}
+		values := &ConfigTemplateValues{}
+		values.Router.Edge.AdvertisedHost = "router.example.org"
+		assert.ErrorContains(t, options.applyEdgeHosts(values), "must advertise a specific address", advertise)
+	}
+
+	// nothing to advertise when binding to every address
+	options := &CreateConfigRouterOptions{EdgeBindHost: "::"}
+	assert.ErrorContains(t, options.applyEdgeHosts(&ConfigTemplateValues{}), "must advertise a specific address")
+
+	// a specific bind address can be advertised as it is
+	options = &CreateConfigRouterOptions{EdgeBindHost: "10.0.0.5"}
+	values := &ConfigTemplateValues{}
+	assert.NoError(t, options.applyEdgeHosts(values))
+	assert.Equal(t, "10.0.0.5", values.Router.Edge.AdvertisedHost)
+}
+
+func TestEdgeListenerInterface(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	assert.NoError(t, err)
+	loopback := ""
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			loopback = iface.Name
+			break
+		}
+	}
+	if loopback == "" {
+		t.Skip("no loopback interface")
+	}
+
+	clearOptionsAndTemplateData()
+	config := createRouterConfig([]string{"edge", "--routerName", "myRouter", "--" + optionEdgeListenerInterface, loopback})
+	assert.Equal(t, "tls:127.0.0.1:3022", config.Listeners[0].Address)
+	assert.Equal(t, "127.0.0.1:3022", config.Listeners[0].Options.Advertise)
+}
+
+func TestEdgeListenerInterfaceErrors(t *testing.T) {
+	options := &CreateConfigRouterOptions{EdgeListenerInterface: "no-such-interface0"}
+	_, err := options.edgeBindHost()
+	assert.ErrorContains(t, err, "no such network interface")
+
+	options = &CreateConfigRouterOptions{EdgeListenerInterface: "lo", EdgeBindHost: "10.0.0.1"}
+	_, err = options.edgeBindHost()
+	assert.ErrorContains(t, err, "mutually exclusive")
+}
+
+func TestEdgeListenerInterfaceRejectsExplicitDefaultBindHost(t *testing.T) {
+	clearOptionsAndTemplateData()
+	cmd := NewCmdCreateConfigRouterEdge()
+	assert.NoError(t, cmd.ParseFlags([]string{"--" + optionEdgeBindHost, defaultEdgeBindHost, "--" + optionEdgeListenerInterface, "lo"}))
+	routerOptions.Cmd = cmd
+
+	_, err := routerOptions.edgeBindHost()
+	assert.ErrorContains(t, err, "mutually exclusive", "--%s %s was given, so it can't be ignored", optionEdgeBindHost, defaultEdgeBindHost)
+}
+
+func TestSelectInterfaceAddress(t *testing.T) {
+	ipNet := func(cidr string) net.Addr {
+		ip, network, err := net.ParseCIDR(cidr)
+		assert.NoError(t, err)
+		network.IP = ip
+		return network
+	}
+	addrs := []net.Addr{
+		ipNet("fe80::1/64"),
+		ipNet("169.254.10.1/16"),
+		ipNet("fd00::10/64"),
+		ipNet("192.168.1.20/24"),
+		ipNet("10.0.0.5/8"),
+	}
+
+	ip, err := selectInterfaceAddress(addrs, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.1.20", ip.String())
+
+	ip, err = selectInterfaceAddress(addrs, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "fd00::10", ip.String())
+
+	// the other family is used when the preferred one is missing
+	ip, err = selectInterfaceAddress(addrs[:3], false)
+	assert.NoError(t, err)
+	assert.Equal(t, "fd00::10", ip.String())
+
+	_, err = selectInterfaceAddress(addrs[:2], false)
+	assert.Error(t, err)
+}
+
+func TestEdgeListenAndAdvertisePorts(t *testing.T) {
+	clearOptionsAndTemplateData()
+	config := createRouterConfig([]string{"edge", "--routerName", "myRouter", "--" + optionEdgeListenPort, "4022"})
+	assert.Equal(t, "tls:0.0.0.0:4022", config.Listeners[0].Address)
+	assert.Equal(t, testHostname+":4022", config.Listeners[0].Options.Advertise)
+
+	clearOptionsAndTemplateData()
+	config = createRouterConfig([]string{"edge", "--routerName", "myRouter", "--" + optionEdgeListenPort, "4022", "--" + optionEdgeAdvertisePort, "443"})
+	assert.Equal(t, "tls:0.0.0.0:4022", config.Listeners[0].Address)
+	assert.Equal(t, testHostname+":443", config.Listeners[0].Options.Advertise)
+
+	// the advertised port is shifted along with the rest
+	clearOptionsAndTemplateData()
+	config = createRouterConfig([]string{"edge", "--routerName", "myRouter", "--" + optionEdgeAdvertisePort, "8443", "--" + optionPortOffset, "10"})
+	assert.Equal(t, "tls:0.0.0.0:3032", config.Listeners[0].Address)
+	assert.Equal(t, testHostname+":8453", config.Listeners[0].Options.Advertise)
+}
+
+func TestEdgePortsMustBeValid(t *testing.T) {
+	for _, test := range []struct {
+		options  CreateConfigRouterOptions
+		expected string
+	}{
+		{CreateConfigRouterOptions{EdgeListenPort: "http"}, "--edge-listen-port [http], must be a number"},
+		{CreateConfigRouterOptions{EdgeListenPort: "70000"}, "--edge-listen-port [70000], port must be between 1 and 65535"},
+		{CreateConfigRouterOptions{EdgeAdvertisePort: "0"}, "--edge-advertise-port [0], peers can't reach the router on port 0"},
+		{CreateConfigRouterOptions{EdgeAdvertisePort: "-1"}, "--edge-advertise-port [-1], port must be between 1 and 65535"},
+	} {
+		assert.ErrorContains(t, test.options.applyEdgePorts(&ConfigTemplateValues{}), test.expected)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"text/tabwriter"
+
+	cmdhelper "github.com/openziti/ziti/ziti/cmd/helpers"
+	"github.com/openziti/ziti/ziti/cmd/templates"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	explainFlagTag    = "flag"
+	explainAffectsTag = "affects"
+)
+
+var (
+	createConfigRouterExplainLong = templates.LongDesc(`
+		Lists the flags of the edge router config command with their current value, where that value came from and
+		the part of the generated config each of them affects. Flags given to explain, along with any defaults file or
+		environment variables, are applied as they would be when generating the config.
+`)
+
+	createConfigRouterExplainExample = templates.Examples(`
+		# Show what each flag of the edge router config does
+		ziti create config router edge explain
+
+		# Show the values and the affected sections for a wss router
+		ziti create config router edge explain --wss --edge-advertise-host router.example.org
+	`)
+)
+
+// NewCmdCreateConfigRouterExplain creates a command object for the "explain" command, which takes the same flags as
+// the command it explains, given by flags
+func NewCmdCreateConfigRouterExplain(flags *pflag.FlagSet) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "explain",
+		Short:   "Show which part of the edge router config each flag affects",
+		Long:    createConfigRouterExplainLong,
+		Example: createConfigRouterExplainExample,
+		Args:    cobra.NoArgs,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			// nothing is generated, so there's no need for a router name
+			_ = cmd.Flags().SetAnnotation(optionRouterName, cobra.BashCompOneRequiredFlag, []string{"false"})
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			routerOptions.Cmd = cmd
+			routerOptions.Args = args
+			cmdhelper.CheckErr(routerOptions.explain(cmd.OutOrStdout()))
+		},
+	}
+	cmd.Flags().AddFlagSet(flags)
+	return cmd
+}
+
+// explainedFlag is a flag along with the part of the config it affects
+type explainedFlag struct {
+	name     string
+	value    string
+	defValue string
+	source   string
+	affects  string
+}
+
+// explainFlags returns the flags of the command annotated on the fields of options, in field order. A field is
+// annotated with the name of its flag in a flag tag and the part of the config it affects in an affects tag. Fields of
+// embedded structs are included, and fields with no flag on the command are left out.
+func (options *CreateConfigOptions) explainFlags(fields interface{}) []explainedFlag {
+	var explained []explainedFlag
+	var visit func(t reflect.Type)
+	visit = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				visit(field.Type)
+				continue
+			}
+			name, found := field.Tag.Lookup(explainFlagTag)
+			if !found {
+				continue
+			}
+			flag := options.Cmd.Flags().Lookup(name)
+			if flag == nil {
+				continue
+			}
+			explained = append(explained, explainedFlag{
+				name:     flag.Name,
+				value:    flag.Value.String(),
+				defValue: flag.DefValue,
+				source:   options.flagSource(flag),
+				affects:  field.Tag.Get(explainAffectsTag),
+			})
+		}
+	}
+	visit(reflect.Indirect(reflect.ValueOf(fields)).Type())
+	return explained
+}
+
+// explain writes a table of the command's flags and the part of the config each affects to out
+func (options *CreateConfigRouterOptions) explain(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "FLAG\tVALUE\tDEFAULT\tSOURCE\tAFFECTS")
+	for _, flag := range options.explainFlags(options) {
+		_, _ = fmt.Fprintf(w, "--%s\t%s\t%s\t%s\t%s\n", flag.name, emptyAsDash(flag.value), emptyAsDash(flag.defValue), flag.source, flag.affects)
+	}
+	return w.Flush()
+}
+
+func emptyAsDash(value string) string {
+	if value == "" || value == "[]" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runExplain(t *testing.T, args ...string) string {
+	clearOptionsAndTemplateData()
+	cmd := NewCmdCreateConfigRouter()
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetArgs(append([]string{"edge", "explain"}, args...))
+	captureOutput(func() {
+		require.NoError(t, cmd.Execute())
+	})
+	return out.String()
+}
+
+func TestExplainShowsValuesSourcesAndSections(t *testing.T) {
+	envVar := defaultsEnvVar(optionTunnelerMode)
+	require.NoError(t, os.Setenv(envVar, tproxyTunMode))
+	defer func() { _ = os.Unsetenv(envVar) }()
+
+	explained := runExplain(t, "--wss", "--metrics-interval", "1m")
+
+	assert.Regexp(t, `(?m)^FLAG\s+VALUE\s+DEFAULT\s+SOURCE\s+AFFECTS$`, explained)
+	assert.Regexp(t, `(?m)^--wss\s+true\s+false\s+flag\s+listeners \(edge address and advertise\), transport\.ws$`, explained)
+	assert.Regexp(t, `(?m)^--metrics-interval\s+1m\s+-\s+flag\s+metrics, added when set$`, explained)
+	assert.Regexp(t, `(?m)^--tunnelerMode\s+tproxy\s+host\s+environment\s+`, explained)
+	assert.Regexp(t, `(?m)^--private\s+false\s+false\s+default\s+link\.listeners`, explained)
+	assert.Regexp(t, `(?m)^--output\s+stdout\s+stdout\s+default\s+where the config is written$`, explained)
+}
+
+func TestExplainCoversEveryEdgeFlag(t *testing.T) {
+	explained := runExplain(t)
+
+	clearOptionsAndTemplateData()
+	cmd := NewCmdCreateConfigRouter()
+	edge, _, err := cmd.Find([]string{"edge"})
+	require.NoError(t, err)
+
+	// help and verbose don't change what's generated
+	var missing []string
+	check := func(flag *pflag.Flag) {
+		if flag.Name == "help" || flag.Name == optionVerbose {
+			return
+		}
+		if !regexp.MustCompile(`(?m)^--` + regexp.QuoteMeta(flag.Name) + `\s`).MatchString(explained) {
+			missing = append(missing, flag.Name)
+		}
+	}
+	edge.LocalFlags().VisitAll(check)
+	edge.InheritedFlags().VisitAll(check)
+	assert.Empty(t, missing, "flags without flag and affects tags on their options field")
+}
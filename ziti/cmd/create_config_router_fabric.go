@@ -20,13 +20,8 @@ import (
 	_ "embed"
 	cmdhelper "github.com/openziti/ziti/ziti/cmd/helpers"
 	"github.com/openziti/ziti/ziti/cmd/templates"
-	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	"os"
-	"path/filepath"
-	"strings"
-	"text/template"
 )
 
 var (
@@ -79,32 +74,17 @@ func (options *CreateConfigRouterOptions) addFabricFlags(cmd *cobra.Command) {
 
 // run implements the command
 func (options *CreateConfigRouterOptions) runFabricRouter(data *ConfigTemplateValues) error {
-
-	tmpl, err := template.New("fabric-router-config").Parse(routerConfigFabricTemplate)
-	if err != nil {
+	if err := options.applyRouterOptions(data); err != nil {
 		return err
 	}
 
-	var f *os.File
-	if strings.ToLower(options.Output) != "stdout" {
-		// Check if the path exists, fail if it doesn't
-		basePath := filepath.Dir(options.Output) + "/"
-		if _, err := os.Stat(filepath.Dir(basePath)); os.IsNotExist(err) {
-			return err
-		}
-
-		f, err = os.Create(options.Output)
-		logrus.Debugf("Created output file: %s", options.Output)
-		if err != nil {
-			return errors.Wrapf(err, "unable to create config file: %s", options.Output)
-		}
-	} else {
-		f = os.Stdout
+	tmpl, err := options.routerTemplate("fabric-router-config", routerConfigFabricTemplate)
+	if err != nil {
+		return err
 	}
-	defer func() { _ = f.Close() }()
 
-	if err := tmpl.Execute(f, data); err != nil {
-		return errors.Wrap(err, "unable to execute template")
+	if err = options.writeRouterConfig(tmpl, data); err != nil {
+		return err
 	}
 
 	logrus.Debugf("Fabric Router configuration generated successfully and written to: %s", options.Output)
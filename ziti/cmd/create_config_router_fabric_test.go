@@ -9,7 +9,7 @@ import (
 	"time"
 )
 
-var TEST_ROUTER_LISTENER_PORT = 10080
+var TEST_ROUTER_LISTENER_PORT = "10080"
 
 func TestExecuteCreateConfigRouterFabricHasNonBlankTemplateValues(t *testing.T) {
 	routerName := "MyFabricRouter"
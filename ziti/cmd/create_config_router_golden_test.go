@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// goldenTemplateValues returns template values which don't depend on the host or environment the tests run on
+func goldenTemplateValues() *ConfigTemplateValues {
+	values := &ConfigTemplateValues{
+		ZitiHome: "/ziti/home",
+		Hostname: "golden-host",
+	}
+	values.populateDefaults()
+	values.Controller.AdvertisedAddress = "ctrl.example.org"
+	values.Controller.Port = "6262"
+	values.Router.Name = "golden-router"
+	values.Router.IdentityCert = "/ziti/home/golden-router.cert"
+	values.Router.IdentityServerCert = "/ziti/home/golden-router.server.chain.cert"
+	values.Router.IdentityKey = "/ziti/home/golden-router.key"
+	values.Router.IdentityCA = "/ziti/home/golden-router.cas"
+	values.Router.TunnelerMode = defaultTunnelerMode
+	values.Router.Edge.Hostname = "router.example.org"
+	values.Router.Edge.AdvertisedHost = "router.example.org"
+	values.Router.Edge.Port = "3022"
+	values.Router.Edge.ListenerBindPort = "10080"
+	return values
+}
+
+func renderRouterTemplate(t *testing.T, values *ConfigTemplateValues) []byte {
+	tmpl, err := template.New("edge-router-config").Funcs(configTemplateFuncs).Parse(routerConfigEdgeTemplate)
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, tmpl.Execute(buf, values))
+	return buf.Bytes()
+}
+
+// assertGolden compares actual with testdata/<name>, rewriting the file instead when run with -update
+func assertGolden(t *testing.T, name string, actual []byte) {
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, actual, 0644))
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "golden file missing, run the tests with -update to create it")
+	assert.Equal(t, string(expected), string(actual), "output differs from %s, run the tests with -update if the change is intended", path)
+}
+
+func TestEdgeRouterMetricsGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		options CreateConfigRouterOptions
+	}{
+		{"router_edge_no_metrics.golden.yml", CreateConfigRouterOptions{}},
+		{"router_edge_metrics.golden.yml", CreateConfigRouterOptions{MetricsInterval: "30s"}},
+		{"router_edge_metrics_report_to.golden.yml", CreateConfigRouterOptions{MetricsInterval: "30s", MetricsReportTo: "metrics.example.org:6262"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values := goldenTemplateValues()
+			require.NoError(t, test.options.applyRouterOptions(values))
+			assertGolden(t, test.name, renderRouterTemplate(t, values))
+		})
+	}
+}
+
+func TestEdgeRouterInvalidMetricsInterval(t *testing.T) {
+	for _, interval := range []string{"often", "10", "-1m", "0s"} {
+		options := CreateConfigRouterOptions{MetricsInterval: interval}
+		assert.Error(t, options.applyRouterOptions(&ConfigTemplateValues{}), "expected interval [%s] to be rejected", interval)
+	}
+}
+
+func TestEdgeRouterMetricsFlags(t *testing.T) {
+	clearOptionsAndTemplateData()
+
+	config := createRouterConfig([]string{"edge", "--routerName", "myRouter", "--metrics-interval", "2m"})
+	assert.Equal(t, "2m0s", config.Metrics.ReportInterval)
+
+	clearOptionsAndTemplateData()
+	config = createRouterConfig([]string{"edge", "--routerName", "myRouter"})
+	assert.Equal(t, "", config.Metrics.ReportInterval)
+}
+
+func TestEdgeRouterMetricsReportTo(t *testing.T) {
+	clearOptionsAndTemplateData()
+	config := createRouterConfig([]string{"edge", "--routerName", "myRouter", "--metrics-report-to", "metrics.example.org:6262"})
+	assert.Equal(t, "tls:metrics.example.org:6262", config.Ctrl.Endpoint)
+	assert.Equal(t, defaultFeatureMetricsInterval.String(), config.Metrics.ReportInterval)
+
+	clearOptionsAndTemplateData()
+	config = createRouterConfig([]string{"edge", "--routerName", "myRouter", "--controller", "ctrl1.example.org:6262",
+		"--controller", "ctrl2.example.org:6262", "--metrics-report-to", "ctrl2.example.org:6262", "--metrics-interval", "2m"})
+	assert.Equal(t, []string{"tls:ctrl1.example.org:6262", "tls:ctrl2.example.org:6262"}, config.Ctrl.Endpoints)
+	assert.Equal(t, "2m0s", config.Metrics.ReportInterval)
+}
+
+func TestEdgeRouterInvalidMetricsReportTo(t *testing.T) {
+	for _, reportTo := range []string{"metrics.example.org", ":6262", "metrics.example.org:0"} {
+		options := CreateConfigRouterOptions{MetricsReportTo: reportTo}
+		assert.Error(t, options.applyRouterOptions(goldenTemplateValues()), "expected [%s] to be rejected", reportTo)
+	}
+
+	options := CreateConfigRouterOptions{Controllers: []string{"ctrl1.example.org:6262"}, MetricsReportTo: "metrics.example.org:6262"}
+	err := options.applyRouterOptions(goldenTemplateValues())
+	assert.ErrorContains(t, err, "a controller the router connects to", "metrics can't go to a controller the router isn't connected to")
+}
+
+func TestEdgeRouterLoggingGolden(t *testing.T) {
+	for _, level := range configLogLevels {
+		for _, format := range configLogFormats {
+			name := fmt.Sprintf("router_edge_logging_%s_%s.golden.yml", level, format)
+			t.Run(name, func(t *testing.T) {
+				values := goldenTemplateValues()
+				options := CreateConfigRouterOptions{ConfigLogLevel: level, ConfigLogFormat: format}
+				require.NoError(t, options.applyRouterOptions(values))
+				assertGolden(t, name, renderRouterTemplate(t, values))
+			})
+		}
+	}
+}
+
+func TestEdgeRouterInvalidConfigLogging(t *testing.T) {
+	for _, level := range []string{"verbose", "warning", "5"} {
+		options := CreateConfigRouterOptions{ConfigLogLevel: level}
+		assert.Error(t, options.applyRouterOptions(&ConfigTemplateValues{}), "expected level [%s] to be rejected", level)
+	}
+	for _, format := range []string{"pfxlog", "xml"} {
+		options := CreateConfigRouterOptions{ConfigLogFormat: format}
+		assert.Error(t, options.applyRouterOptions(&ConfigTemplateValues{}), "expected format [%s] to be rejected", format)
+	}
+}
+
+func TestEdgeRouterControllersGolden(t *testing.T) {
+	tests := []struct {
+		name        string
+		controllers []string
+	}{
+		// a single controller must render exactly as the default endpoint does
+		{"router_edge_no_metrics.golden.yml", []string{"ctrl.example.org:6262"}},
+		{"router_edge_ha_controllers.golden.yml", []string{"ctrl1.example.org:6262", "ctrl2.example.org:6262", "ctrl1.example.org:6262", "[::1]:6262"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values := goldenTemplateValues()
+			options := CreateConfigRouterOptions{Controllers: test.controllers}
+			require.NoError(t, options.applyRouterOptions(values))
+			assertGolden(t, test.name, renderRouterTemplate(t, values))
+		})
+	}
+}
+
+func TestEdgeRouterCtrlProbe(t *testing.T) {
+	ha := []string{"ctrl1.example.org:6262", "ctrl2.example.org:6262"}
+
+	values := goldenTemplateValues()
+	options := CreateConfigRouterOptions{Controllers: ha, CtrlProbeInterval: "5s", CtrlProbeTimeout: "1m"}
+	require.NoError(t, options.applyRouterOptions(values))
+	config := renderRouterTemplate(t, values)
+	assert.Contains(t, string(config), "    sendInterval:             5s\n    closeUnresponsiveTimeout: 1m0s\n")
+	assert.NoError(t, validateRouterConfig(config))
+
+	// nothing to fail over to with a single controller, so there's nothing to render or to compare
+	values = goldenTemplateValues()
+	options = CreateConfigRouterOptions{Controllers: ha[:1], CtrlProbeInterval: "1m"}
+	require.NoError(t, options.applyRouterOptions(values))
+	assert.NotContains(t, string(renderRouterTemplate(t, values)), "heartbeats")
+
+	for _, probe := range [][2]string{{"often", ""}, {"", "10"}, {"-5s", ""}, {"", "0s"}, {"30s", ""}, {"1m", "45s"}} {
+		options = CreateConfigRouterOptions{Controllers: ha, CtrlProbeInterval: probe[0], CtrlProbeTimeout: probe[1]}
+		assert.Error(t, options.applyRouterOptions(goldenTemplateValues()), "expected interval [%s] and timeout [%s] to be rejected", probe[0], probe[1])
+	}
+}
+
+func TestParseControllerEndpoints(t *testing.T) {
+	endpoints, err := parseControllerEndpoints([]string{"a.example.org:1280", " b.example.org:1280 ", "a.example.org:1280", "::1"})
+	assert.Error(t, err)
+	assert.Nil(t, endpoints)
+
+	endpoints, err = parseControllerEndpoints([]string{"a.example.org:1280", " b.example.org:1280 ", "a.example.org:1280"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.example.org:1280", "b.example.org:1280"}, endpoints)
+
+	for _, controller := range []string{"ctrl.example.org", ":6262", "ctrl.example.org:0", "ctrl.example.org:http", "ctrl.example.org:70000"} {
+		_, err = parseControllerEndpoints([]string{controller})
+		assert.Error(t, err, "expected controller [%s] to be rejected", controller)
+	}
+}
+
+func TestEdgeRouterControllerFlags(t *testing.T) {
+	clearOptionsAndTemplateData()
+	config := createRouterConfig([]string{"edge", "--routerName", "myRouter", "--controller", "ctrl1.example.org:6262", "--controller", "ctrl2.example.org:6262"})
+	assert.Equal(t, "", config.Ctrl.Endpoint)
+	assert.Equal(t, []string{"tls:ctrl1.example.org:6262", "tls:ctrl2.example.org:6262"}, config.Ctrl.Endpoints)
+	assert.Equal(t, "10s", config.Ctrl.Heartbeats.SendInterval)
+	assert.Equal(t, "30s", config.Ctrl.Heartbeats.CloseUnresponsiveTimeout)
+
+	clearOptionsAndTemplateData()
+	config = createRouterConfig([]string{"edge", "--routerName", "myRouter", "--controller", "ctrl1.example.org:6262", "--controller", "ctrl2.example.org:6262",
+		"--ctrl-probe-interval", "15s", "--ctrl-probe-timeout", "2m"})
+	assert.Equal(t, "15s", config.Ctrl.Heartbeats.SendInterval)
+	assert.Equal(t, "2m0s", config.Ctrl.Heartbeats.CloseUnresponsiveTimeout)
+
+	clearOptionsAndTemplateData()
+	config = createRouterConfig([]string{"edge", "--routerName", "myRouter", "--controller", "ctrl1.example.org:6262"})
+	assert.Equal(t, "tls:ctrl1.example.org:6262", config.Ctrl.Endpoint)
+	assert.Empty(t, config.Ctrl.Endpoints)
+}
+
+func TestEdgeRouterPortOffsetGolden(t *testing.T) {
+	values := goldenTemplateValues()
+	options := CreateConfigRouterOptions{PortOffset: 100}
+	require.NoError(t, options.applyRouterOptions(values))
+	assert.Equal(t, "3122", values.Router.Edge.Port)
+	assert.Equal(t, "10180", values.Router.Edge.ListenerBindPort)
+	assert.Equal(t, "3123", values.Router.Edge.WssAdvertisedPort)
+	assert.Equal(t, "6262", values.Controller.Port, "the controller's port must not be offset")
+	assertGolden(t, "router_edge_port_offset.golden.yml", renderRouterTemplate(t, values))
+}
+
+func TestEdgeRouterPortOffsetOutOfRange(t *testing.T) {
+	for _, offset := range []int{60000, -3022} {
+		options := CreateConfigRouterOptions{PortOffset: offset}
+		assert.Error(t, options.applyRouterOptions(goldenTemplateValues()), "expected offset %d to be rejected", offset)
+	}
+}
+
+func TestEdgeRouterRunGolden(t *testing.T) {
+	variants := []struct {
+		name    string
+		wss     bool
+		private bool
+	}{
+		{"default", false, false},
+		{"wss", true, false},
+		{"private", false, true},
+	}
+	routerNames := []string{"golden-router", "edge.example.org", "router #1"}
+
+	for _, routerName := range routerNames {
+		for _, variant := range variants {
+			name := fmt.Sprintf("router_edge_run_%s_%s.golden.yml", goldenSlug(routerName), variant.name)
+			t.Run(name, func(t *testing.T) {
+				values := goldenTemplateValues()
+				values.Router.Name = routerName
+				values.Router.IdentityCert = "/ziti/home/" + routerName + ".cert"
+				values.Router.IdentityServerCert = "/ziti/home/" + routerName + ".server.chain.cert"
+				values.Router.IdentityKey = "/ziti/home/" + routerName + ".key"
+				values.Router.IdentityCA = "/ziti/home/" + routerName + ".cas"
+
+				out := &bytes.Buffer{}
+				options := &CreateConfigRouterOptions{
+					WssEnabled:   variant.wss,
+					IsPrivate:    variant.private,
+					TunnelerMode: defaultTunnelerMode,
+				}
+				options.Out = out
+				require.NoError(t, options.runEdgeRouter(values))
+				assertGolden(t, name, out.Bytes())
+			})
+		}
+	}
+}
+
+func TestEdgeRouterWssAdvertisePortGolden(t *testing.T) {
+	out := &bytes.Buffer{}
+	options := &CreateConfigRouterOptions{WssEnabled: true, TunnelerMode: defaultTunnelerMode, EdgeAdvertisePort: "443"}
+	options.Out = out
+	require.NoError(t, options.runEdgeRouter(goldenTemplateValues()))
+	assert.Contains(t, out.String(), `advertise: "router.example.org:443"`, "--edge-advertise-port wins over the wss advertised port")
+	assertGolden(t, "router_edge_wss_advertise_port.golden.yml", out.Bytes())
+}
+
+func TestEdgeRouterRunWssAndPrivate(t *testing.T) {
+	out := &bytes.Buffer{}
+	options := &CreateConfigRouterOptions{WssEnabled: true, IsPrivate: true, TunnelerMode: defaultTunnelerMode}
+	options.Out = out
+	assert.Error(t, options.runEdgeRouter(goldenTemplateValues()))
+	assert.Zero(t, out.Len(), "nothing should be written when the options are rejected")
+}
+
+// goldenSlug turns a router name into something usable in a golden file name
+func goldenSlug(name string) string {
+	slug := []rune(name)
+	for i, r := range slug {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9') {
+			slug[i] = '-'
+		}
+	}
+	return string(slug)
+}
@@ -0,0 +1,125 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// routerConfigDefaults returns the settings in the router template which the router falls back to on its own when
+// they're missing, keyed by their path in the config, with [] standing for every item of a list. The values are
+// rendered the way the template renders them.
+func routerConfigDefaults() map[string]string {
+	defaults := &ConfigTemplateValues{}
+	defaults.populateDefaults()
+	router := defaults.Router
+
+	return map[string]string{
+		"link.listeners[].options.outQueueSize": strconv.Itoa(router.Listener.OutQueueSize),
+		"listeners[].options.connectTimeoutMs":  fmt.Sprint(router.Listener.ConnectTimeout.Milliseconds()),
+		"listeners[].options.getSessionTimeout": fmt.Sprint(router.Listener.GetSessionTimeout.Seconds()),
+		"transport.ws.writeTimeout":             fmt.Sprint(router.Wss.WriteTimeout.Seconds()),
+		"transport.ws.readTimeout":              fmt.Sprint(router.Wss.ReadTimeout.Seconds()),
+		"transport.ws.idleTimeout":              fmt.Sprint(router.Wss.IdleTimeout.Seconds()),
+		"transport.ws.pongTimeout":              fmt.Sprint(router.Wss.PongTimeout.Seconds()),
+		"transport.ws.pingInterval":             fmt.Sprint(router.Wss.PingInterval.Seconds()),
+		"transport.ws.handshakeTimeout":         fmt.Sprint(router.Wss.HandshakeTimeout.Seconds()),
+		"transport.ws.readBufferSize":           strconv.Itoa(router.Wss.ReadBufferSize),
+		"transport.ws.writeBufferSize":          strconv.Itoa(router.Wss.WriteBufferSize),
+		"transport.ws.enableCompression":        strconv.FormatBool(router.Wss.EnableCompression),
+		"forwarder.latencyProbeInterval":        fmt.Sprint(router.Forwarder.LatencyProbeInterval.Seconds()),
+		"forwarder.xgressDialQueueLength":       strconv.Itoa(router.Forwarder.XgressDialQueueLength),
+		"forwarder.xgressDialWorkerCount":       strconv.Itoa(router.Forwarder.XgressDialWorkerCount),
+		"forwarder.linkDialQueueLength":         strconv.Itoa(router.Forwarder.LinkDialQueueLength),
+		"forwarder.linkDialWorkerCount":         strconv.Itoa(router.Forwarder.LinkDialWorkerCount),
+	}
+}
+
+// minimizeRouterConfig strips a rendered router config down to what a person needs to review: comments, including
+// the commented out sections, are removed, as are settings equal to the defaults the router would use anyway and any
+// sections left empty by that
+func minimizeRouterConfig(config []byte) ([]byte, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(config, doc); err != nil {
+		return nil, errors.Wrap(err, "unable to parse the generated config")
+	}
+
+	pruneDefaults(doc, "", routerConfigDefaults())
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, errors.Wrap(err, "unable to encode the minimal config")
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, errors.Wrap(err, "unable to encode the minimal config")
+	}
+	return buf.Bytes(), nil
+}
+
+// pruneDefaults removes the comments from node and its children, and the entries of any mapping which match defaults.
+// It returns true if node is a collection which was left empty.
+func pruneDefaults(node *yaml.Node, path string, defaults map[string]string) bool {
+	node.HeadComment = ""
+	node.LineComment = ""
+	node.FootComment = ""
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			pruneDefaults(child, path, defaults)
+		}
+	case yaml.SequenceNode:
+		var kept []*yaml.Node
+		for _, item := range node.Content {
+			if !pruneDefaults(item, path+"[]", defaults) {
+				kept = append(kept, item)
+			}
+		}
+		node.Content = kept
+		return len(kept) == 0
+	case yaml.MappingNode:
+		var kept []*yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			childPath := key.Value
+			if path != "" {
+				childPath = path + "." + key.Value
+			}
+			key.HeadComment = ""
+			key.LineComment = ""
+			key.FootComment = ""
+
+			if defaultValue, found := defaults[childPath]; found && value.Kind == yaml.ScalarNode && value.Value == defaultValue {
+				continue
+			}
+			if pruneDefaults(value, childPath, defaults) {
+				continue
+			}
+			kept = append(kept, key, value)
+		}
+		node.Content = kept
+		return len(kept) == 0
+	}
+	return false
+}
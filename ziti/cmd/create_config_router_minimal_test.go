@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEdgeRouterMinimalGolden(t *testing.T) {
+	for _, wss := range []bool{false, true} {
+		name := "router_edge_minimal.golden.yml"
+		if wss {
+			name = "router_edge_minimal_wss.golden.yml"
+		}
+		t.Run(name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			options := &CreateConfigRouterOptions{TunnelerMode: defaultTunnelerMode, WssEnabled: wss, Minimal: true}
+			options.Out = out
+			require.NoError(t, options.runEdgeRouter(goldenTemplateValues()))
+			assertGolden(t, name, out.Bytes())
+		})
+	}
+}
+
+func TestMinimalConfigIsStillValid(t *testing.T) {
+	for _, fabric := range []bool{false, true} {
+		values := goldenTemplateValues()
+		values.Router.IsFabric = fabric
+		out := &bytes.Buffer{}
+		options := &CreateConfigRouterOptions{TunnelerMode: defaultTunnelerMode, Minimal: true, Validate: true}
+		options.Out = out
+		if fabric {
+			require.NoError(t, options.runFabricRouter(values))
+		} else {
+			require.NoError(t, options.runEdgeRouter(values))
+		}
+
+		config := RouterConfig{}
+		require.NoError(t, yaml.Unmarshal(out.Bytes(), &config))
+		assert.Equal(t, "3", config.V)
+		assert.Equal(t, "/ziti/home/golden-router.cert", config.Identity.Cert)
+		assert.Equal(t, "tls:ctrl.example.org:6262", config.Ctrl.Endpoint)
+		assert.Equal(t, Forwarder{}, config.Forwarder)
+		assert.NotContains(t, out.String(), "#")
+	}
+}
+
+func TestMinimalKeepsSettingsWhichArentDefaults(t *testing.T) {
+	values := goldenTemplateValues()
+	values.Router.Forwarder.LinkDialWorkerCount = 64
+	values.Router.Listener.ConnectTimeout = 3 * time.Second
+
+	out := &bytes.Buffer{}
+	options := &CreateConfigRouterOptions{TunnelerMode: defaultTunnelerMode, Minimal: true}
+	options.Out = out
+	require.NoError(t, options.runEdgeRouter(values))
+
+	config := RouterConfig{}
+	require.NoError(t, yaml.Unmarshal(out.Bytes(), &config))
+	assert.Equal(t, 64, config.Forwarder.LinkDialWorkerCount)
+	assert.Equal(t, 0, config.Forwarder.LinkDialQueueLength)
+	assert.Equal(t, 3000, config.Listeners[0].Options.ConnectTimeout)
+	assert.Equal(t, 0, config.Listeners[0].Options.GetSessionTimeout)
+}
+
+func TestFullOverridesMinimal(t *testing.T) {
+	clearOptionsAndTemplateData()
+	config := createRouterConfig([]string{"edge", "--routerName", "myRouter", "--minimal"})
+	assert.Equal(t, Forwarder{}, config.Forwarder)
+
+	clearOptionsAndTemplateData()
+	config = createRouterConfig([]string{"edge", "--routerName", "myRouter", "--minimal", "--full"})
+	assert.NotEqual(t, Forwarder{}, config.Forwarder)
+}
@@ -0,0 +1,271 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+
+	cmdhelper "github.com/openziti/ziti/ziti/cmd/helpers"
+	"github.com/openziti/ziti/ziti/cmd/templates"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	optionSet                  = "set"
+	setDescription             = "Set the value at a dotted path, e.g. link.listeners.0.options.outQueueSize=8. May be repeated"
+	optionCreateMissing        = "create-missing"
+	defaultCreateMissing       = false
+	createMissingDescription   = "Create any mappings along a --" + optionSet + " path which don't exist, instead of failing"
+	patchYamlIndent            = 2
+	yamlTagString              = "!!str"
+	yamlTagInt                 = "!!int"
+	yamlTagFloat               = "!!float"
+	yamlTagNull                = "!!null"
+	yamlTagMap                 = "!!map"
+	patchOutputDescriptionNote = "Defaults to rewriting the patched file in place"
+)
+
+var (
+	createConfigRouterPatchLong = templates.LongDesc(`
+		Updates individual values in an existing router config, leaving the rest of the file as it is.
+		The patched config is written back to the file unless --output is given.
+`)
+
+	createConfigRouterPatchExample = templates.Examples(`
+		# Change the port the edge listener advertises
+		ziti create config router edge patch --set listeners.0.options.advertise=router.example.org:443 router.yml
+
+		# Add a metrics section to a config which doesn't have one
+		ziti create config router edge patch --create-missing --set metrics.reportInterval=1m router.yml
+	`)
+)
+
+// CreateConfigRouterPatchOptions the options for the router patch command
+type CreateConfigRouterPatchOptions struct {
+	CreateConfigOptions
+
+	Sets          []string
+	CreateMissing bool
+}
+
+// NewCmdCreateConfigRouterPatch creates a command object for the "patch" command
+func NewCmdCreateConfigRouterPatch() *cobra.Command {
+	options := &CreateConfigRouterPatchOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "patch <config file>",
+		Short:   "Update values in an existing router config",
+		Long:    createConfigRouterPatchLong,
+		Example: createConfigRouterPatchExample,
+		Args:    cobra.ExactArgs(1),
+		// Replaces the router command's pre-run, a patch works on an existing config so there is nothing to populate
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			cmdhelper.CheckErr(options.setupLogging(true))
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			options.closeLogging()
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			// the router name is required when generating a config, but a patch doesn't need one
+			_ = cmd.Flags().SetAnnotation(optionRouterName, cobra.BashCompOneRequiredFlag, []string{"false"})
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			options.Cmd = cmd
+			options.Args = args
+			err := options.run()
+			cmdhelper.CheckErr(err)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&options.Verbose, optionVerbose, "v", defaultVerbose, verboseDescription)
+	cmd.Flags().StringVarP(&options.Output, optionOutput, "o", defaultOutput, outputDescription+" "+patchOutputDescriptionNote)
+	cmd.Flags().StringVar(&options.LogFile, optionLogFile, defaultLogFile, logFileDescription)
+	cmd.Flags().StringArrayVar(&options.Sets, optionSet, nil, setDescription)
+	cmd.Flags().BoolVar(&options.CreateMissing, optionCreateMissing, defaultCreateMissing, createMissingDescription)
+
+	return cmd
+}
+
+// run implements the command
+func (options *CreateConfigRouterPatchOptions) run() error {
+	if len(options.Sets) == 0 {
+		return errors.Errorf("Nothing to patch, at least one --%s is required", optionSet)
+	}
+
+	path := options.Args[0]
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read config file: %s", path)
+	}
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read config file: %s", path)
+	}
+
+	patched, err := patchConfig(source, options.Sets, options.CreateMissing)
+	if err != nil {
+		return errors.Wrapf(err, "unable to patch config file: %s", path)
+	}
+
+	if !options.Cmd.Flags().Changed(optionOutput) {
+		if err = os.WriteFile(path, patched, info.Mode().Perm()); err != nil {
+			return errors.Wrapf(err, "unable to write config file: %s", path)
+		}
+		logrus.Debugf("Patched config written to: %s", path)
+		return nil
+	}
+
+	if strings.ToLower(options.Output) == "stdout" {
+		_, err = os.Stdout.Write(patched)
+		return err
+	}
+	if err = os.WriteFile(options.Output, patched, info.Mode().Perm()); err != nil {
+		return errors.Wrapf(err, "unable to write config file: %s", options.Output)
+	}
+	logrus.Debugf("Patched config written to: %s", options.Output)
+	return nil
+}
+
+// patchConfig applies each path=value assignment to the YAML document in source and returns the re-encoded document
+func patchConfig(source []byte, sets []string, createMissing bool) ([]byte, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(source, doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, errors.New("config is empty")
+	}
+
+	for _, set := range sets {
+		path, value, found := strings.Cut(set, "=")
+		if !found || strings.TrimSpace(path) == "" {
+			return nil, errors.Errorf("Invalid value for --%s [%s], must be of the form path=value", optionSet, set)
+		}
+		if err := setNodeValue(doc.Content[0], strings.Split(strings.TrimSpace(path), "."), value, createMissing); err != nil {
+			return nil, errors.Wrapf(err, "unable to set [%s]", path)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(patchYamlIndent)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// setNodeValue walks path from node, mapping keys by name and sequence entries by index, and sets the value found there
+func setNodeValue(node *yaml.Node, path []string, value string, createMissing bool) error {
+	for i, segment := range path {
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if node.Content[j].Value == segment {
+					next = node.Content[j+1]
+					break
+				}
+			}
+			if next == nil {
+				if !createMissing {
+					return errors.Errorf("path [%s] does not exist", strings.Join(path[:i+1], "."))
+				}
+				if i == len(path)-1 {
+					next = &yaml.Node{Kind: yaml.ScalarNode, Tag: yamlTagNull}
+				} else {
+					next = &yaml.Node{Kind: yaml.MappingNode, Tag: yamlTagMap}
+				}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: yamlTagString, Value: segment}, next)
+			}
+		case yaml.SequenceNode:
+			idx, err := strconv.Atoi(segment)
+			if err != nil || idx < 0 || idx >= len(node.Content) {
+				return errors.Errorf("path [%s] does not exist, [%s] is a list with %d entries", strings.Join(path[:i+1], "."), strings.Join(path[:i], "."), len(node.Content))
+			}
+			next = node.Content[idx]
+		default:
+			return errors.Errorf("path [%s] does not exist, [%s] is not a mapping or list", strings.Join(path[:i+1], "."), strings.Join(path[:i], "."))
+		}
+		node = next
+	}
+
+	return assignNodeValue(node, value)
+}
+
+// assignNodeValue replaces the contents of node with value. Values are coerced to the type already at node, so a
+// string stays a string even if value looks like a number, and a number can't be replaced with something that isn't
+// one. New nodes take the type value would have in YAML.
+func assignNodeValue(node *yaml.Node, value string) error {
+	parsed := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(value), parsed); err != nil {
+		return errors.Wrapf(err, "invalid value [%s]", value)
+	}
+	replacement := &yaml.Node{Kind: yaml.ScalarNode, Tag: yamlTagNull, Value: ""}
+	if len(parsed.Content) > 0 {
+		replacement = parsed.Content[0]
+	}
+
+	// nulls, including those created for missing paths, take on whatever type the new value has
+	if node.ShortTag() == yamlTagNull {
+		*node = *replacement
+		return nil
+	}
+
+	if node.Kind != replacement.Kind {
+		return errors.Errorf("cannot replace a %s with [%s]", describeNode(node), value)
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		node.Content = replacement.Content
+		return nil
+	}
+
+	switch {
+	case node.ShortTag() == yamlTagString:
+		node.Value = value
+		if replacement.ShortTag() != yamlTagString && node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) == 0 {
+			// keep the value a string when it would otherwise be read back as another type
+			node.Style = yaml.DoubleQuotedStyle
+		}
+	case node.ShortTag() == replacement.ShortTag(), node.ShortTag() == yamlTagFloat && replacement.ShortTag() == yamlTagInt:
+		node.Value = replacement.Value
+	default:
+		return errors.Errorf("cannot replace a %s with [%s]", describeNode(node), value)
+	}
+	return nil
+}
+
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.SequenceNode:
+		return "list"
+	default:
+		return strings.TrimPrefix(node.ShortTag(), "!!") + " value"
+	}
+}
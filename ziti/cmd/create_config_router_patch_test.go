@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const patchTestConfig = `v: 3
+
+# the controller this router connects to
+ctrl:
+  endpoint: "tls:ctrl.example.org:6262"
+
+link:
+  listeners:
+    - binding: transport
+      bind: "tls:0.0.0.0:10080"
+      options:
+        outQueueSize: 4
+
+forwarder:
+  latencyProbeInterval: 10
+  xgressDialQueueLength: 1000
+`
+
+func patchAndDecode(t *testing.T, createMissing bool, sets ...string) map[string]interface{} {
+	patched, err := patchConfig([]byte(patchTestConfig), sets, createMissing)
+	require.NoError(t, err)
+
+	result := map[string]interface{}{}
+	require.NoError(t, yaml.Unmarshal(patched, &result))
+	return result
+}
+
+func TestPatchConfigSetsExistingValues(t *testing.T) {
+	result := patchAndDecode(t, false,
+		"ctrl.endpoint=tls:ctrl2.example.org:6262",
+		"link.listeners.0.options.outQueueSize=16",
+		"forwarder.latencyProbeInterval=30")
+
+	assert.Equal(t, "tls:ctrl2.example.org:6262", result["ctrl"].(map[string]interface{})["endpoint"])
+	listener := result["link"].(map[string]interface{})["listeners"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, 16, listener["options"].(map[string]interface{})["outQueueSize"])
+	assert.Equal(t, "tls:0.0.0.0:10080", listener["bind"])
+	assert.Equal(t, 30, result["forwarder"].(map[string]interface{})["latencyProbeInterval"])
+	assert.Equal(t, 1000, result["forwarder"].(map[string]interface{})["xgressDialQueueLength"])
+}
+
+func TestPatchConfigPreservesComments(t *testing.T) {
+	patched, err := patchConfig([]byte(patchTestConfig), []string{"v=3"}, false)
+	require.NoError(t, err)
+	assert.Contains(t, string(patched), "# the controller this router connects to")
+}
+
+func TestPatchConfigMissingPath(t *testing.T) {
+	for _, set := range []string{"metrics.reportInterval=1m", "link.listeners.1.bind=tls:0.0.0.0:1", "v.major=3", "ctrl.endpoint", "=3"} {
+		_, err := patchConfig([]byte(patchTestConfig), []string{set}, false)
+		assert.Error(t, err, "expected [%s] to fail", set)
+	}
+}
+
+func TestPatchConfigCreatesNestedPaths(t *testing.T) {
+	result := patchAndDecode(t, true,
+		"metrics.reportInterval=1m",
+		"metrics.messageQueueSize=10",
+		"healthChecks.ctrlPingCheck.interval=30s",
+		"link.listeners.0.options.maxQueuedConnects=100")
+
+	metrics := result["metrics"].(map[string]interface{})
+	assert.Equal(t, "1m", metrics["reportInterval"])
+	assert.Equal(t, 10, metrics["messageQueueSize"])
+	ping := result["healthChecks"].(map[string]interface{})["ctrlPingCheck"].(map[string]interface{})
+	assert.Equal(t, "30s", ping["interval"])
+	listener := result["link"].(map[string]interface{})["listeners"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, 100, listener["options"].(map[string]interface{})["maxQueuedConnects"])
+
+	// list entries are never created
+	_, err := patchConfig([]byte(patchTestConfig), []string{"link.listeners.1.bind=tls:0.0.0.0:1"}, true)
+	assert.Error(t, err)
+}
+
+func TestPatchConfigCoercesTypes(t *testing.T) {
+	// strings stay strings, even when the new value looks like another type
+	patched, err := patchConfig([]byte(patchTestConfig), []string{"ctrl.endpoint=6262"}, false)
+	require.NoError(t, err)
+	result := map[string]interface{}{}
+	require.NoError(t, yaml.Unmarshal(patched, &result))
+	assert.Equal(t, "6262", result["ctrl"].(map[string]interface{})["endpoint"])
+
+	// numbers must stay numbers
+	_, err = patchConfig([]byte(patchTestConfig), []string{"forwarder.latencyProbeInterval=soon"}, false)
+	assert.Error(t, err)
+
+	// mappings can't be replaced by scalars
+	_, err = patchConfig([]byte(patchTestConfig), []string{"forwarder=off"}, false)
+	assert.Error(t, err)
+
+	// new values take the type they'd have in yaml
+	result = patchAndDecode(t, true, "a.int=5", "a.bool=true", "a.float=1.5", "a.string=hello", "a.quoted=\"5\"")
+	a := result["a"].(map[string]interface{})
+	assert.Equal(t, 5, a["int"])
+	assert.Equal(t, true, a["bool"])
+	assert.Equal(t, 1.5, a["float"])
+	assert.Equal(t, "hello", a["string"])
+	assert.Equal(t, "5", a["quoted"])
+}
+
+func TestPatchCommandRewritesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "router.yml")
+	require.NoError(t, os.WriteFile(path, []byte(patchTestConfig), 0600))
+
+	cmd := NewCmdCreateConfigRouterPatch()
+	cmd.SetArgs([]string{"--set", "forwarder.latencyProbeInterval=30", "--set", "ctrl.endpoint=tls:ctrl2.example.org:6262", path})
+	require.NoError(t, cmd.Execute())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	patched, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(patched), "latencyProbeInterval: 30")
+	assert.Contains(t, string(patched), "endpoint: \"tls:ctrl2.example.org:6262\"")
+}
@@ -0,0 +1,100 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/pem"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	optionInlinePki      = "inline-pki"
+	defaultInlinePki     = false
+	inlinePkiDescription = "Embed the PEM of the identity cert, server cert, key and CA in the config instead of referring to their files, " +
+		"for platforms which can't mount them. The config then holds the private key, so it's written with --" + optionFileMode + " 0600 unless that's set"
+
+	// inlinePemPrefix marks an identity value as PEM rather than a file path
+	inlinePemPrefix = "pem:"
+
+	// inlineFileMode is the --file-mode used for configs holding the private key when none is given
+	inlineFileMode = "0600"
+)
+
+// applyInlinePki replaces the identity file paths with the PEM they hold. Every file has to exist and hold nothing
+// but PEM blocks. A config with the key inlined is as sensitive as the key itself, so it's only readable by its owner
+// unless --file-mode says otherwise, which is warned about.
+func (options *CreateConfigRouterOptions) applyInlinePki(data *ConfigTemplateValues) error {
+	if !options.InlinePki {
+		return nil
+	}
+
+	files := []struct {
+		name string
+		path *string
+	}{
+		{"identity cert", &data.Router.IdentityCert},
+		{"identity server cert", &data.Router.IdentityServerCert},
+		{"identity key", &data.Router.IdentityKey},
+		{"identity CA", &data.Router.IdentityCA},
+	}
+	for _, file := range files {
+		pemBytes, err := readPemFile(*file.path)
+		if err != nil {
+			return errors.Wrapf(err, "unable to inline the %s for --%s", file.name, optionInlinePki)
+		}
+		*file.path = inlinePemPrefix + string(pemBytes)
+	}
+
+	if options.Cmd == nil || !options.Cmd.Flags().Changed(optionFileMode) {
+		options.FileMode = inlineFileMode
+	}
+	logrus.Warnf("--%s embeds the router's private key in the config, and in its manifest. Treat them as secrets: don't commit it, "+
+		"log it or share it", optionInlinePki)
+	if mode, err := options.fileMode(); err == nil && mode&0077 != 0 {
+		logrus.Warnf("--%s %s lets users other than the owner read the config and the private key in it, consider %s",
+			optionFileMode, options.FileMode, inlineFileMode)
+	}
+	return nil
+}
+
+// readPemFile returns the contents of path, failing if it's missing, empty or holds anything other than PEM blocks
+func readPemFile(path string) ([]byte, error) {
+	if path == "" {
+		return nil, errors.New("no file set")
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := bytes.TrimSpace(contents)
+	if len(rest) == 0 {
+		return nil, errors.Errorf("%s is empty", path)
+	}
+	for len(rest) > 0 {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			return nil, errors.Errorf("%s is not a valid PEM file", path)
+		}
+		rest = bytes.TrimSpace(rest)
+	}
+	return contents, nil
+}
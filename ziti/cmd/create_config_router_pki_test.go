@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openziti/ziti/ziti/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+// writePemFiles writes a PEM file for each of the router's identity files to dir, setting the environment variables
+// which point the router config at them, and returns their contents by variable name
+func writePemFiles(t *testing.T, dir string) map[string]string {
+	contents := map[string]string{}
+	for i, varName := range []string{constants.ZitiRouterIdentityCertVarName, constants.ZitiRouterIdentityServerCertVarName,
+		constants.ZitiRouterIdentityKeyVarName, constants.ZitiRouterIdentityCAVarName} {
+		blockType := "CERTIFICATE"
+		if varName == constants.ZitiRouterIdentityKeyVarName {
+			blockType = "EC PRIVATE KEY"
+		}
+		pemBytes := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: []byte{byte(i), 1, 2, 3}})
+		path := filepath.Join(dir, varName+".pem")
+		assert.NoError(t, os.WriteFile(path, pemBytes, 0600))
+		t.Setenv(varName, path)
+		contents[varName] = string(pemBytes)
+	}
+	return contents
+}
+
+func TestEdgeRouterInlinePki(t *testing.T) {
+	clearOptionsAndTemplateData()
+	dir := t.TempDir()
+	contents := writePemFiles(t, dir)
+
+	config := createRouterConfig([]string{"edge", "--routerName", "myRouter", "--" + optionInlinePki})
+	assert.Equal(t, "pem:"+contents[constants.ZitiRouterIdentityCertVarName], config.Identity.Cert)
+	assert.Equal(t, "pem:"+contents[constants.ZitiRouterIdentityServerCertVarName], config.Identity.Server_cert)
+	assert.Equal(t, "pem:"+contents[constants.ZitiRouterIdentityKeyVarName], config.Identity.Key)
+	assert.Equal(t, "pem:"+contents[constants.ZitiRouterIdentityCAVarName], config.Identity.Ca)
+
+	// without the flag the paths are kept
+	clearOptionsAndTemplateData()
+	writePemFiles(t, dir)
+	config = createRouterConfig([]string{"edge", "--routerName", "myRouter"})
+	assert.Equal(t, filepath.Join(dir, constants.ZitiRouterIdentityKeyVarName+".pem"), config.Identity.Key)
+}
+
+func TestInlinePkiFileMode(t *testing.T) {
+	dir := t.TempDir()
+	writePemFiles(t, dir)
+
+	values := &ConfigTemplateValues{}
+	SetZitiRouterIdentity(&values.Router, "myRouter")
+	options := &CreateConfigRouterOptions{InlinePki: true}
+	options.FileMode = "0644"
+	assert.NoError(t, options.applyInlinePki(values))
+	assert.Equal(t, inlineFileMode, options.FileMode)
+}
+
+func TestInlinePkiNeedsPemFiles(t *testing.T) {
+	dir := t.TempDir()
+	notPem := filepath.Join(dir, "cert.txt")
+	assert.NoError(t, os.WriteFile(notPem, []byte("not a cert"), 0600))
+	empty := filepath.Join(dir, "empty.pem")
+	assert.NoError(t, os.WriteFile(empty, nil, 0600))
+	trailing := filepath.Join(dir, "trailing.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1, 2, 3}})
+	assert.NoError(t, os.WriteFile(trailing, append(pemBytes, "junk"...), 0600))
+
+	for path, expected := range map[string]string{
+		notPem:                            "is not a valid PEM file",
+		empty:                             "is empty",
+		trailing:                          "is not a valid PEM file",
+		filepath.Join(dir, "missing.pem"): "no such file",
+	} {
+		values := &ConfigTemplateValues{}
+		values.Router.IdentityCert = path
+		options := &CreateConfigRouterOptions{InlinePki: true}
+		assert.ErrorContains(t, options.applyInlinePki(values), expected, path)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	optionProfile         = "profile"
+	defaultProfile        = ""
+	profileDescription    = "Render the config with the named overlay from --" + optionProfileDir + ", such as dev, staging or prod, which can redefine blocks of the built-in router template"
+	optionProfileDir      = "profile-dir"
+	defaultProfileDir     = "profiles"
+	profileDirDescription = "The directory --" + optionProfile + " overlays are read from, as <profile>" + profileFileExt
+	profileFileExt        = ".yml"
+
+	// baseTemplateName is what overlays include the built-in router template as, with {{ template "base" . }}
+	baseTemplateName = "base"
+)
+
+// routerTemplate parses the built-in router template as name. With --profile, the overlay is parsed on top of it and
+// rendered instead. The overlay redefines blocks of the router template with {{ define "<block>" }}, and may add
+// content around {{ template "base" . }}. An overlay which only redefines blocks renders the base with them.
+func (options *CreateConfigRouterOptions) routerTemplate(name, base string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(configTemplateFuncs).Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	if options.Profile == "" {
+		return tmpl, nil
+	}
+
+	if _, err = tmpl.AddParseTree(baseTemplateName, tmpl.Tree); err != nil {
+		return nil, errors.Wrap(err, "unable to add the base router template")
+	}
+	blocks := map[string]struct{}{}
+	for _, defined := range tmpl.Templates() {
+		blocks[defined.Name()] = struct{}{}
+	}
+
+	path := filepath.Join(options.ProfileDir, options.Profile+profileFileExt)
+	overlay, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read profile %s", options.Profile)
+	}
+	profile, err := tmpl.New(name + "/" + options.Profile).Parse(string(overlay))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse profile %s", path)
+	}
+	if profile.Tree == nil || len(profile.Tree.Root.Nodes) == 0 {
+		if profile, err = profile.Parse(`{{ template "` + baseTemplateName + `" . }}`); err != nil {
+			return nil, err
+		}
+	}
+
+	// blocks the router template doesn't have are never rendered, which is most likely a typo
+	var unknown []string
+	for _, defined := range tmpl.Templates() {
+		if _, found := blocks[defined.Name()]; !found && defined != profile {
+			unknown = append(unknown, defined.Name())
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		logrus.Warnf("profile %s defines [%s], which the router template has no blocks for", options.Profile, strings.Join(unknown, ", "))
+	}
+	logrus.Debugf("Using profile %s from %s", options.Profile, path)
+	return profile, nil
+}
+
+// checkProfileConfig makes sure a config rendered with --profile is still valid yaml, as overlays can easily break the
+// indentation of the blocks around the ones they redefine
+func (options *CreateConfigRouterOptions) checkProfileConfig(config []byte) error {
+	if options.Profile == "" {
+		return nil
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(config, &doc); err != nil {
+		return errors.Wrapf(err, "config rendered with profile %s is not valid yaml", options.Profile)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func writeProfile(t *testing.T, dir, name, overlay string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+profileFileExt), []byte(overlay), 0644))
+}
+
+func renderWithProfile(t *testing.T, dir, profile string) ([]byte, error) {
+	out := &bytes.Buffer{}
+	options := &CreateConfigRouterOptions{TunnelerMode: defaultTunnelerMode, Profile: profile, ProfileDir: dir}
+	options.Out = out
+	err := options.runEdgeRouter(goldenTemplateValues())
+	return out.Bytes(), err
+}
+
+func TestProfileRedefinesBlocks(t *testing.T) {
+	dir := t.TempDir()
+	writeProfile(t, dir, "dev", `{{ define "logging" }}
+
+logging:
+  level: debug{{ end }}
+{{- define "extra" }}
+
+healthChecks:
+  ctrlPingCheck:
+    interval: 30s{{ end }}`)
+
+	rendered, err := renderWithProfile(t, dir, "dev")
+	require.NoError(t, err)
+
+	config := RouterConfig{}
+	require.NoError(t, yaml.Unmarshal(rendered, &config))
+	assert.Equal(t, "tls:0.0.0.0:3022", config.Listeners[0].Address, "blocks which aren't redefined come from the base")
+
+	doc := map[string]interface{}{}
+	require.NoError(t, yaml.Unmarshal(rendered, &doc))
+	assert.Equal(t, map[string]interface{}{"level": "debug"}, doc["logging"])
+	assert.Contains(t, doc, "healthChecks")
+
+	base, err := renderWithProfile(t, dir, "")
+	require.NoError(t, err)
+	assert.NotContains(t, string(base), "healthChecks")
+	assert.NotContains(t, string(base), "logging:")
+}
+
+func TestProfileWrapsBase(t *testing.T) {
+	dir := t.TempDir()
+	writeProfile(t, dir, "prod", `# prod router {{ .Router.Name }}
+{{ template "base" . }}`)
+
+	rendered, err := renderWithProfile(t, dir, "prod")
+	require.NoError(t, err)
+	assert.Contains(t, string(rendered), "# prod router "+goldenTemplateValues().Router.Name+"\n")
+	assert.Contains(t, string(rendered), "\nv: 3\n")
+}
+
+func TestProfileErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := renderWithProfile(t, dir, "missing")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to read profile missing")
+
+	writeProfile(t, dir, "unparsable", `{{ define "ctrl" }}`)
+	_, err = renderWithProfile(t, dir, "unparsable")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to parse profile")
+
+	writeProfile(t, dir, "broken", `{{ define "ctrl" }}ctrl:
+endpoint: [{{ end }}`)
+	rendered, err := renderWithProfile(t, dir, "broken")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "config rendered with profile broken is not valid yaml")
+	assert.Empty(t, rendered, "nothing should be written when the merged config doesn't parse")
+}
@@ -21,10 +21,18 @@ type RouterConfig struct {
 	Edge      RouterEdge `yaml:"edge"`
 	Transport Transport  `yaml:"transport"`
 	Forwarder Forwarder  `yaml:"forwarder"`
+	Metrics   Metrics    `yaml:"metrics"`
 }
 
 type RouterCtrl struct {
-	Endpoint string `yaml:"endpoint"`
+	Endpoint   string         `yaml:"endpoint"`
+	Endpoints  []string       `yaml:"endpoints"`
+	Heartbeats CtrlHeartbeats `yaml:"heartbeats"`
+}
+
+type CtrlHeartbeats struct {
+	SendInterval             string `yaml:"sendInterval"`
+	CloseUnresponsiveTimeout string `yaml:"closeUnresponsiveTimeout"`
 }
 
 type Link struct {
@@ -47,13 +55,13 @@ type Listener struct {
 }
 
 type ListenerOptions struct {
-	Advertise         string   `yaml:"advertise"`
-	ConnectTimeout    int      `yaml:"connectTimeoutMs"`
-	GetSessionTimeout int      `yaml:"getSessionTimeout"`
-	Mode              string   `yaml:"mode"`
-	Resolver          string   `yaml:"resolver"`
-	LanIf             []string `yaml:"lanIf"`
-	OutQueueSize      string   `yaml:"outQueueSize"`
+	Advertise         string `yaml:"advertise"`
+	ConnectTimeout    int    `yaml:"connectTimeoutMs"`
+	GetSessionTimeout int    `yaml:"getSessionTimeout"`
+	Mode              string `yaml:"mode"`
+	Resolver          string `yaml:"resolver"`
+	LanIf             string `yaml:"lanIf"`
+	OutQueueSize      string `yaml:"outQueueSize"`
 }
 
 type RouterEdge struct {
@@ -100,6 +108,10 @@ type Forwarder struct {
 	LinkDialWorkerCount   int `yaml:"linkDialWorkerCount"`
 }
 
+type Metrics struct {
+	ReportInterval string `yaml:"reportInterval"`
+}
+
 /* END Controller config template structure */
 
 func createRouterConfig(args []string) RouterConfig {
@@ -0,0 +1,77 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package cmd
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// routerConfigSchema describes the router configs generated from the router template. It's stricter than the router
+// itself in places, such as requiring a host in every address, to catch templates rendered with missing values.
+//
+//go:embed config_templates/router.schema.json
+var routerConfigSchema string
+
+// validateRouterConfig checks a rendered router config against routerConfigSchema, returning an error which lists
+// every violation along with the JSON path it was found at
+func validateRouterConfig(config []byte) error {
+	var doc interface{}
+	if err := yaml.Unmarshal(config, &doc); err != nil {
+		return errors.Wrap(err, "generated config is not valid yaml")
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewStringLoader(routerConfigSchema), gojsonschema.NewGoLoader(doc))
+	if err != nil {
+		return errors.Wrap(err, "unable to validate the generated config")
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	var violations []string
+	for _, violation := range result.Errors() {
+		// the violations inside a failed if/then/else branch are reported on their own, which is more useful
+		if violation.Type() == "condition_then" || violation.Type() == "condition_else" {
+			continue
+		}
+		violations = append(violations, fmt.Sprintf("%s: %s", schemaFieldPath(violation.Field()), violation.Description()))
+	}
+	return errors.Errorf("generated config failed validation:\n  %s", strings.Join(violations, "\n  "))
+}
+
+// schemaFieldPath converts a gojsonschema field, such as ctrl.endpoints.1, to a JSON path, such as $.ctrl.endpoints[1]
+func schemaFieldPath(field string) string {
+	if field == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+		return "$"
+	}
+	path := "$"
+	for _, segment := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(segment); err == nil {
+			path += "[" + segment + "]"
+		} else {
+			path += "." + segment
+		}
+	}
+	return path
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratedRouterConfigsPassValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		fabric  bool
+		options CreateConfigRouterOptions
+	}{
+		{"edge", false, CreateConfigRouterOptions{TunnelerMode: defaultTunnelerMode}},
+		{"edge wss", false, CreateConfigRouterOptions{TunnelerMode: defaultTunnelerMode, WssEnabled: true}},
+		{"edge private", false, CreateConfigRouterOptions{TunnelerMode: defaultTunnelerMode, IsPrivate: true}},
+		{"edge tproxy", false, CreateConfigRouterOptions{TunnelerMode: tproxyTunMode, LanInterface: "eth0"}},
+		{"edge no tunneler", false, CreateConfigRouterOptions{TunnelerMode: noneTunMode}},
+		{"edge metrics and logging", false, CreateConfigRouterOptions{TunnelerMode: defaultTunnelerMode, MetricsInterval: "2m", ConfigLogLevel: "debug", ConfigLogFormat: "json"}},
+		{"edge ha controllers", false, CreateConfigRouterOptions{TunnelerMode: defaultTunnelerMode, Controllers: []string{"ctrl1.example.org:6262", "[::1]:6262"}}},
+		{"fabric", true, CreateConfigRouterOptions{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values := goldenTemplateValues()
+			values.Router.IsFabric = test.fabric
+			out := &bytes.Buffer{}
+			options := test.options
+			options.Validate = true
+			options.Out = out
+			if test.fabric {
+				require.NoError(t, options.runFabricRouter(values))
+			} else {
+				require.NoError(t, options.runEdgeRouter(values))
+			}
+			assert.NotZero(t, out.Len())
+		})
+	}
+}
+
+func TestValidateReportsEachViolationWithItsPath(t *testing.T) {
+	values := goldenTemplateValues()
+	values.Router.Edge.AdvertisedHost = ""
+	values.Router.IdentityKey = ""
+
+	out := &bytes.Buffer{}
+	options := &CreateConfigRouterOptions{TunnelerMode: defaultTunnelerMode, Validate: true}
+	options.Out = out
+	err := options.runEdgeRouter(values)
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), "$.identity.key:")
+	assert.Contains(t, err.Error(), "$.link.listeners[0].advertise:")
+	assert.Contains(t, err.Error(), "$.listeners[0].options.advertise:")
+	assert.Zero(t, out.Len(), "nothing should be written when validation fails")
+}
+
+func TestValidateIsOffByDefault(t *testing.T) {
+	values := goldenTemplateValues()
+	values.Router.Edge.AdvertisedHost = ""
+
+	out := &bytes.Buffer{}
+	options := &CreateConfigRouterOptions{TunnelerMode: defaultTunnelerMode}
+	options.Out = out
+	require.NoError(t, options.runEdgeRouter(values))
+	assert.NotZero(t, out.Len())
+}
+
+func TestValidateRejectsInvalidYaml(t *testing.T) {
+	assert.Error(t, validateRouterConfig([]byte("v: [3")))
+}
+
+func TestSchemaFieldPath(t *testing.T) {
+	assert.Equal(t, "$", schemaFieldPath("(root)"))
+	assert.Equal(t, "$.ctrl.endpoints[1]", schemaFieldPath("ctrl.endpoints.1"))
+}
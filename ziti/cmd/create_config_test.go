@@ -2,12 +2,20 @@ package cmd
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"github.com/openziti/ziti/common/version"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func getZitiEnvironmentVariables() []string {
@@ -234,3 +242,335 @@ func captureOutput(function func()) string {
 	_, _ = io.Copy(&buffer, r)
 	return buffer.String()
 }
+
+func TestYamlQuoteRoundTrips(t *testing.T) {
+	values := []string{"foo: bar", "*anchor", "&anchor", "  leadingspace", "trailingspace ", "@router", "# comment", "with \"quotes\"", "tab\tand\nnewline", "<html>&", "", "plain"}
+
+	for _, value := range values {
+		var decoded map[string]string
+		err := yaml.Unmarshal([]byte("key: "+yamlQuote(value)), &decoded)
+		assert.NoError(t, err, "value [%s] produced invalid yaml", value)
+		assert.Equal(t, value, decoded["key"])
+	}
+}
+
+func TestRouterConfigQuotesUserSuppliedValues(t *testing.T) {
+	values := goldenTemplateValues()
+	values.Router.IdentityCert = "foo: bar"
+	values.Router.IdentityKey = "*anchor"
+	values.Router.Edge.Hostname = "  leadingspace"
+	values.Router.Edge.AdvertisedHost = "@router"
+	values.Router.Edge.IPOverride = "# not a comment"
+
+	config := RouterConfig{}
+	assert.NoError(t, yaml.Unmarshal(renderRouterTemplate(t, values), &config))
+
+	assert.Equal(t, "foo: bar", config.Identity.Cert)
+	assert.Equal(t, "*anchor", config.Identity.Key)
+	assert.Equal(t, []string{"  leadingspace", "localhost"}, config.Edge.Csr.Sans.Dns)
+	assert.Equal(t, []string{"127.0.0.1", "# not a comment"}, config.Edge.Csr.Sans.Ip)
+	for _, listener := range config.Listeners {
+		if listener.Binding == "edge" {
+			assert.Equal(t, "@router:3022", listener.Options.Advertise)
+		}
+	}
+}
+
+func writeDefaultsFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "defaults.yml")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultsFileSeedsFlags(t *testing.T) {
+	defaults := writeDefaultsFile(t, `
+routerName: fromDefaults
+metrics-interval: 5m
+config-log-format: json
+controller:
+  - ctrl1.example.org:6262
+  - ctrl2.example.org:6262
+`)
+
+	clearOptionsAndTemplateData()
+	config := createRouterConfig([]string{"edge", "--defaults", defaults})
+	assert.Equal(t, "5m0s", config.Metrics.ReportInterval)
+	assert.Equal(t, "json", routerOptions.ConfigLogFormat)
+	assert.Equal(t, []string{"tls:ctrl1.example.org:6262", "tls:ctrl2.example.org:6262"}, config.Ctrl.Endpoints)
+	assert.Equal(t, "fromDefaults", routerOptions.RouterName)
+}
+
+func TestDefaultsPrecedence(t *testing.T) {
+	defaults := writeDefaultsFile(t, `
+metrics-interval: 5m
+config-log-format: json
+`)
+
+	// the environment overrides the file
+	t.Setenv(defaultsEnvPrefix+"_METRICS_INTERVAL", "2m")
+	clearOptionsAndTemplateData()
+	config := createRouterConfig([]string{"edge", "--routerName", "myRouter", "--defaults", defaults})
+	assert.Equal(t, "2m0s", config.Metrics.ReportInterval)
+	assert.Equal(t, "json", routerOptions.ConfigLogFormat)
+
+	// an explicit flag overrides both
+	clearOptionsAndTemplateData()
+	config = createRouterConfig([]string{"edge", "--routerName", "myRouter", "--defaults", defaults, "--metrics-interval", "30s"})
+	assert.Equal(t, "30s", config.Metrics.ReportInterval)
+	assert.Equal(t, "json", routerOptions.ConfigLogFormat)
+}
+
+func TestDefaultsFileMissing(t *testing.T) {
+	cmd := NewCmdCreateConfigRouter()
+	options := CreateConfigOptions{DefaultsFile: filepath.Join(t.TempDir(), "missing.yml")}
+	assert.Error(t, options.applyDefaults(cmd))
+}
+
+func TestLogFileKeepsLogsOutOfStdoutConfig(t *testing.T) {
+	level := logrus.GetLevel()
+	t.Cleanup(func() { logrus.SetLevel(level) })
+
+	logFile := filepath.Join(t.TempDir(), "create-config.log")
+
+	clearOptionsAndTemplateData()
+	config := createRouterConfig([]string{"edge", "--routerName", "myRouter", "--verbose", "--log-file", logFile})
+	assert.Equal(t, "3", config.V, "the config on stdout should still parse")
+
+	logs, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(logs), "Edge Router configuration generated successfully")
+
+	// without --verbose the log file still gets debug logs
+	clearOptionsAndTemplateData()
+	_ = createRouterConfig([]string{"edge", "--routerName", "otherRouter", "--log-file", logFile})
+	logs, err = os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(logs), "Edge Router configuration generated successfully"))
+}
+
+func TestLogFileCannotBeTheOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "router.yml")
+	for _, options := range []CreateConfigOptions{{Output: path, LogFile: path}, {Output: path, LogFile: "stdout"}} {
+		assert.Error(t, options.setupLogging(false))
+	}
+}
+
+func TestOutputWriterUsesInjectedWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	options := &CreateConfigOptions{Output: filepath.Join(t.TempDir(), "unused.yml")}
+	options.Out = buf
+
+	out, err := options.outputWriter()
+	assert.NoError(t, err)
+	_, _ = io.WriteString(out, "config")
+	assert.NoError(t, out.Close())
+
+	assert.Equal(t, "config", buf.String())
+	assert.NoFileExists(t, options.Output)
+}
+
+func TestOutputWriterTeesFileToStdout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "router.yml")
+	options := &CreateConfigOptions{Output: path, Tee: true}
+
+	stdout := captureOutput(func() {
+		out, err := options.outputWriter()
+		assert.NoError(t, err)
+		_, _ = io.WriteString(out, "config")
+		assert.NoError(t, out.Close())
+	})
+
+	assert.Equal(t, "config", stdout)
+	written, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "config", string(written))
+}
+
+func TestOutputWriterLeavesStdoutOpen(t *testing.T) {
+	options := &CreateConfigOptions{Output: "stdout"}
+
+	stdout := captureOutput(func() {
+		out, err := options.outputWriter()
+		assert.NoError(t, err)
+		assert.NoError(t, out.Close())
+		_, err = io.WriteString(os.Stdout, "still open")
+		assert.NoError(t, err)
+	})
+
+	assert.Equal(t, "still open", stdout)
+}
+
+func TestChecksumOutputWritesSidecar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "router.yml")
+	clearOptionsAndTemplateData()
+	cmd := NewCmdCreateConfigRouter()
+	cmd.SetArgs([]string{"edge", "--routerName", "myRouter", "--output", path, "--" + optionChecksumOutput})
+	assert.NoError(t, cmd.Execute())
+
+	written, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	sidecar, err := os.ReadFile(path + checksumFileSuffix)
+	assert.NoError(t, err)
+	checksum := sha256.Sum256(written)
+	assert.Equal(t, hex.EncodeToString(checksum[:])+"  router.yml\n", string(sidecar))
+}
+
+func TestChecksumOutputGoesToStderrWithStdout(t *testing.T) {
+	clearOptionsAndTemplateData()
+	errOut := &bytes.Buffer{}
+	routerOptions.Err = errOut
+	cmd := NewCmdCreateConfigRouter()
+	cmd.SetArgs([]string{"edge", "--routerName", "myRouter", "--" + optionChecksumOutput})
+	output := captureOutput(func() {
+		assert.NoError(t, cmd.Execute())
+	})
+
+	checksum := sha256.Sum256([]byte(output))
+	assert.Equal(t, hex.EncodeToString(checksum[:])+"  -\n", errOut.String())
+	assert.NotContains(t, output, hex.EncodeToString(checksum[:]), "stdout only holds the config")
+}
+
+func TestChecksumOutputClosesOnce(t *testing.T) {
+	buf, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	options := &CreateConfigOptions{ChecksumOutput: true}
+	options.Out = buf
+	options.Err = errOut
+
+	out, err := options.outputWriter()
+	assert.NoError(t, err)
+	_, _ = io.WriteString(out, "config")
+	assert.NoError(t, out.Close())
+	assert.NoError(t, out.Close())
+
+	checksum := sha256.Sum256([]byte("config"))
+	assert.Equal(t, hex.EncodeToString(checksum[:])+"  -\n", errOut.String())
+}
+
+func TestChecksumOutputSkippedWhenAborted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "router.yml")
+	options := &CreateConfigOptions{ChecksumOutput: true, Output: path}
+
+	out, err := options.outputWriter()
+	assert.NoError(t, err)
+	_, _ = io.WriteString(out, "half a con")
+	abortOutput(out)
+
+	_, err = os.Stat(path + checksumFileSuffix)
+	assert.True(t, os.IsNotExist(err), "a config which failed to render mustn't get a sidecar")
+	assert.NoError(t, out.Close(), "closing an aborted output again does nothing")
+	_, err = os.Stat(path + checksumFileSuffix)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStampHeaderRecordsGeneration(t *testing.T) {
+	t.Cleanup(func() { stampTime = time.Now })
+	stampTime = func() time.Time { return time.Date(2023, 3, 14, 15, 9, 26, 0, time.UTC) }
+
+	path := filepath.Join(t.TempDir(), "router.yml")
+	clearOptionsAndTemplateData()
+	cmd := NewCmdCreateConfigRouter()
+	cmd.SetArgs([]string{"edge", "--routerName", "myRouter", "--output", path, "--manifest", "--controller", "ctrl:6262"})
+	assert.NoError(t, cmd.Execute())
+
+	written, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	header, _, _ := strings.Cut(string(written), "\n")
+	assert.Equal(t, fmt.Sprintf(`# generated by ziti %s at 2023-03-14T15:09:26Z with flags --controller=[ctrl:6262] --manifest=true --output=%s --routerName=myRouter`,
+		version.GetVersion(), path), header)
+
+	manifestJson, err := os.ReadFile(path + manifestFileSuffix)
+	assert.NoError(t, err)
+	manifest := &ConfigManifest{}
+	assert.NoError(t, json.Unmarshal(manifestJson, manifest))
+	checksum := sha256.Sum256(written)
+	assert.Equal(t, hex.EncodeToString(checksum[:]), manifest.Sha256, "the manifest should cover the stamp")
+}
+
+func TestStampHeaderCanBeDisabled(t *testing.T) {
+	clearOptionsAndTemplateData()
+	cmd := NewCmdCreateConfigRouter()
+	cmd.SetArgs([]string{"edge", "--routerName", "myRouter", "--stamp=false"})
+	output := captureOutput(func() {
+		assert.NoError(t, cmd.Execute())
+	})
+	assert.NotContains(t, output, "generated by ziti")
+
+	options := &CreateConfigOptions{}
+	assert.Empty(t, options.stampHeader("#"))
+}
+
+func TestDumpValuesRecordsSources(t *testing.T) {
+	dumpFile := filepath.Join(t.TempDir(), "values.json")
+
+	t.Setenv(defaultsEnvPrefix+"_METRICS_INTERVAL", "2m")
+	clearOptionsAndTemplateData()
+	t.Setenv("ZITI_CTRL_PORT", "7777")
+	output := captureOutput(func() {
+		cmd := NewCmdCreateConfigRouter()
+		cmd.SetArgs([]string{"edge", "--routerName", "myRouter", "--" + optionDumpValues + "=" + dumpFile})
+		assert.NoError(t, cmd.Execute())
+	})
+	assert.Empty(t, output, "no config is rendered when dumping values")
+
+	raw, err := os.ReadFile(dumpFile)
+	assert.NoError(t, err)
+	dump := valuesDump{}
+	assert.NoError(t, json.Unmarshal(raw, &dump))
+
+	assert.Equal(t, "myRouter", dump.Values.Router.Name)
+	assert.Equal(t, "7777", dump.Values.Controller.Port)
+	assert.Equal(t, 2*time.Minute, dump.Values.Router.Metrics.ReportInterval)
+
+	assert.Equal(t, sourceFlag, dump.Sources["Router.Name"])
+	assert.Equal(t, sourceEnvironment, dump.Sources["Controller.Port"])
+	assert.Equal(t, sourceDefault, dump.Sources["Router.Wss.ReadBufferSize"])
+	assert.Equal(t, sourceFlag, dump.Sources["Router.Metrics.ReportInterval"])
+	assert.Equal(t, sourceUnset, dump.Sources["Router.Edge.IPOverride"])
+
+	assert.Equal(t, dumpedFlag{Value: "myRouter", Source: sourceFlag}, dump.Flags[optionRouterName])
+	assert.Equal(t, dumpedFlag{Value: "2m", Source: sourceEnvironment}, dump.Flags[optionMetricsInterval])
+	assert.Equal(t, sourceDefault, dump.Flags[optionConfigLogFormat].Source)
+}
+
+func TestDumpValuesDefaultsToStderr(t *testing.T) {
+	clearOptionsAndTemplateData()
+	errOut := &bytes.Buffer{}
+	routerOptions.Err = errOut
+	cmd := NewCmdCreateConfigRouter()
+	cmd.SetArgs([]string{"edge", "--routerName", "myRouter", "--" + optionDumpValues})
+	output := captureOutput(func() {
+		assert.NoError(t, cmd.Execute())
+	})
+	assert.Empty(t, output)
+
+	dump := valuesDump{}
+	assert.NoError(t, json.Unmarshal(errOut.Bytes(), &dump))
+	assert.Equal(t, "myRouter", dump.Values.Router.Name)
+}
+
+func TestOutputWriterUsesFileMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "router.yml")
+	// an existing file gets the requested mode too
+	assert.NoError(t, os.WriteFile(path, []byte("old"), 0666))
+
+	options := &CreateConfigOptions{Output: path, FileMode: "0600"}
+	out, err := options.outputWriter()
+	assert.NoError(t, err)
+	_, _ = io.WriteString(out, "config")
+	assert.NoError(t, out.Close())
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	written, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "config", string(written))
+
+	for _, mode := range []string{"rw-r--r--", "0888", "1777"} {
+		options = &CreateConfigOptions{Output: path, FileMode: mode}
+		_, err = options.outputWriter()
+		assert.ErrorContains(t, err, "Invalid value for --file-mode", mode)
+	}
+}
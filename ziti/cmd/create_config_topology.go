@@ -0,0 +1,289 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	cmdhelper "github.com/openziti/ziti/ziti/cmd/helpers"
+	"github.com/openziti/ziti/ziti/cmd/templates"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	optionRouters                  = "routers"
+	defaultRouters                 = 1
+	routersDescription             = "The number of edge routers to create configs for"
+	optionControllerHost           = "controller-host"
+	controllerHostDescription      = "The address the controller advertises, which every router is configured to connect to"
+	optionRouterHost               = "router-host"
+	defaultRouterHost              = ""
+	routerHostDescription          = "The address the routers advertise, defaults to the --" + optionControllerHost
+	optionRouterPrefix             = "router-prefix"
+	defaultRouterPrefix            = "router"
+	routerPrefixDescription        = "Routers are named <prefix>1 to <prefix>N"
+	optionRouterPortStep           = "router-port-step"
+	defaultRouterPortStep          = 10
+	routerPortStepDescription      = "How far apart each router's ports are. Router N listens on its ports offset by (N-1) times this"
+	optionComposeHints             = "compose-hints"
+	defaultComposeHints            = false
+	composeHintsDescription        = "Also write " + topologyComposeHintsFile + ", a docker-compose style outline of the services and the ports they use"
+	defaultTopologyOutput          = "topology"
+	topologyOutputDescription      = "The directory to write the configs to, which is created if it doesn't exist"
+	topologyControllerFile         = "controller.yml"
+	topologyComposeHintsFile       = "docker-compose.hints.yml"
+	topologyComposeHintsFileHeader = "# Generated by ziti create config topology. This is an outline, not a working compose file: add an image\n" +
+		"# with the ziti binary to each service and create the identities referenced by each config before using it.\n"
+)
+
+var (
+	createConfigTopologyLong = templates.LongDesc(`
+		Creates a directory of configs for a controller and a number of edge routers which connect to it.
+		Each router gets a unique name and its own ports, so all of them can run on one host.
+`)
+
+	createConfigTopologyExample = templates.Examples(`
+		# Create configs for a controller and three routers in ./topology
+		ziti create config topology --routers 3 --controller-host ctrl.example.org
+
+		# Also write a docker-compose style outline of the services
+		ziti create config topology --routers 3 --controller-host ctrl.example.org --compose-hints --output lab
+	`)
+)
+
+// CreateConfigTopologyOptions the options for the topology command
+type CreateConfigTopologyOptions struct {
+	CreateConfigOptions
+
+	Routers        int
+	ControllerHost string
+	RouterHost     string
+	RouterPrefix   string
+	RouterPortStep int
+	ComposeHints   bool
+}
+
+// topologyComponent is a config created by the topology command, and the ports the component listens on
+type topologyComponent struct {
+	Kind  string
+	Name  string
+	File  string
+	Ports []string
+}
+
+// NewCmdCreateConfigTopology creates a command object for the "topology" command
+func NewCmdCreateConfigTopology() *cobra.Command {
+	options := &CreateConfigTopologyOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "topology",
+		Short:   "Create configs for a controller and several routers",
+		Long:    createConfigTopologyLong,
+		Example: createConfigTopologyExample,
+		Args:    cobra.NoArgs,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			cmdhelper.CheckErr(options.applyDefaults(cmd))
+			// the config goes to files, but the summary goes to stdout
+			cmdhelper.CheckErr(options.setupLogging(true))
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			options.Cmd = cmd
+			options.Args = args
+			err := options.run()
+			cmdhelper.CheckErr(err)
+		},
+		PostRun: func(cmd *cobra.Command, args []string) {
+			options.closeLogging()
+		},
+	}
+
+	cmd.Flags().BoolVarP(&options.Verbose, optionVerbose, "v", defaultVerbose, verboseDescription)
+	cmd.Flags().StringVarP(&options.Output, optionOutput, "o", defaultTopologyOutput, topologyOutputDescription)
+	cmd.Flags().StringVar(&options.DefaultsFile, optionDefaults, defaultDefaults, defaultsDescription)
+	cmd.Flags().StringVar(&options.LogFile, optionLogFile, defaultLogFile, logFileDescription)
+	cmd.Flags().BoolVar(&options.Stamp, optionStamp, defaultStamp, stampDescription)
+	cmd.Flags().IntVar(&options.Routers, optionRouters, defaultRouters, routersDescription)
+	cmd.Flags().StringVar(&options.ControllerHost, optionControllerHost, "", controllerHostDescription)
+	cmd.Flags().StringVar(&options.RouterHost, optionRouterHost, defaultRouterHost, routerHostDescription)
+	cmd.Flags().StringVar(&options.RouterPrefix, optionRouterPrefix, defaultRouterPrefix, routerPrefixDescription)
+	cmd.Flags().IntVar(&options.RouterPortStep, optionRouterPortStep, defaultRouterPortStep, routerPortStepDescription)
+	cmd.Flags().BoolVar(&options.ComposeHints, optionComposeHints, defaultComposeHints, composeHintsDescription)
+	_ = cmd.MarkFlagRequired(optionControllerHost)
+
+	return cmd
+}
+
+// run implements the command
+func (options *CreateConfigTopologyOptions) run() error {
+	if options.Routers < 1 {
+		return errors.Errorf("Invalid value for --%s [%d], must be at least 1", optionRouters, options.Routers)
+	}
+	if options.RouterPortStep < 1 {
+		return errors.Errorf("Invalid value for --%s [%d], must be at least 1", optionRouterPortStep, options.RouterPortStep)
+	}
+	if options.ControllerHost == "" {
+		return errors.Errorf("--%s is required", optionControllerHost)
+	}
+
+	base := options.baseTemplateValues()
+
+	// check the last router's ports up front, rather than leaving a partial topology behind
+	maxOffset := (options.Routers - 1) * options.RouterPortStep
+	for _, port := range []string{base.Router.Edge.Port, base.Router.Edge.ListenerBindPort, base.Router.Edge.WssAdvertisedPort} {
+		if _, err := offsetPort(port, maxOffset); err != nil {
+			return errors.Wrapf(err, "too many routers for --%s %d", optionRouterPortStep, options.RouterPortStep)
+		}
+	}
+
+	dir, err := filepath.Abs(options.Output)
+	if err != nil {
+		return errors.Wrapf(err, "invalid output directory: %s", options.Output)
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "unable to create output directory: %s", dir)
+	}
+
+	var components []topologyComponent
+
+	controllerValues := *base
+	controllerOptions := &CreateConfigControllerOptions{}
+	controllerOptions.Output = filepath.Join(dir, topologyControllerFile)
+	controllerOptions.Stamp = options.Stamp
+	controllerOptions.Cmd = options.Cmd
+	if err = controllerOptions.run(&controllerValues); err != nil {
+		return errors.Wrap(err, "unable to create the controller config")
+	}
+	components = append(components, topologyComponent{
+		Kind:  "controller",
+		Name:  controllerValues.Controller.Name,
+		File:  controllerOptions.Output,
+		Ports: []string{controllerValues.Controller.Port, controllerValues.Controller.Edge.AdvertisedPort},
+	})
+
+	controllerEndpoint := net.JoinHostPort(options.ControllerHost, base.Controller.Port)
+	for i := 0; i < options.Routers; i++ {
+		name := fmt.Sprintf("%s%d", options.RouterPrefix, i+1)
+		routerValues := *base
+		routerValues.Router.Name = name
+		routerValues.Router.TunnelerMode = defaultTunnelerMode
+		routerValues.Router.IdentityCert = filepath.Join(dir, name+".cert")
+		routerValues.Router.IdentityServerCert = filepath.Join(dir, name+".server.chain.cert")
+		routerValues.Router.IdentityKey = filepath.Join(dir, name+".key")
+		routerValues.Router.IdentityCA = filepath.Join(dir, name+".cas")
+
+		routerOpts := &CreateConfigRouterOptions{
+			TunnelerMode: defaultTunnelerMode,
+			Controllers:  []string{controllerEndpoint},
+			PortOffset:   i * options.RouterPortStep,
+		}
+		routerOpts.Output = filepath.Join(dir, name+".yml")
+		routerOpts.Stamp = options.Stamp
+		routerOpts.Cmd = options.Cmd
+		if err = routerOpts.runEdgeRouter(&routerValues); err != nil {
+			return errors.Wrapf(err, "unable to create the config for router %s", name)
+		}
+		components = append(components, topologyComponent{
+			Kind:  "router",
+			Name:  name,
+			File:  routerOpts.Output,
+			Ports: []string{routerValues.Router.Edge.Port, routerValues.Router.Edge.ListenerBindPort},
+		})
+	}
+
+	if options.ComposeHints {
+		if err = writeComposeHints(filepath.Join(dir, topologyComposeHintsFile), components); err != nil {
+			return err
+		}
+	}
+
+	printTopologySummary(options.Cmd.OutOrStdout(), dir, components, options.ComposeHints)
+	return nil
+}
+
+// baseTemplateValues returns the values shared by every config in the topology
+func (options *CreateConfigTopologyOptions) baseTemplateValues() *ConfigTemplateValues {
+	values := &ConfigTemplateValues{}
+	values.populateEnvVars()
+	values.populateDefaults()
+
+	values.Controller.AdvertisedAddress = options.ControllerHost
+	values.Controller.Edge.AdvertisedHostPort = net.JoinHostPort(options.ControllerHost, values.Controller.Edge.AdvertisedPort)
+	SetControllerIdentity(&values.Controller)
+	SetEdgeConfig(&values.Controller)
+	SetWebConfig(&values.Controller)
+
+	routerHost := options.RouterHost
+	if routerHost == "" {
+		routerHost = options.ControllerHost
+	}
+	values.Router.Edge.Hostname = routerHost
+	values.Router.Edge.AdvertisedHost = routerHost
+	values.Router.Edge.WssAdvertisedPort = defaultWssAdvertisedPort
+	return values
+}
+
+type composeHints struct {
+	Services map[string]composeService `yaml:"services"`
+}
+
+type composeService struct {
+	Command []string `yaml:"command"`
+	Ports   []string `yaml:"ports"`
+	Volumes []string `yaml:"volumes"`
+}
+
+// writeComposeHints writes a docker-compose style outline of the components to path
+func writeComposeHints(path string, components []topologyComponent) error {
+	hints := composeHints{Services: map[string]composeService{}}
+	for _, component := range components {
+		service := composeService{
+			Command: []string{"ziti", component.Kind, "run", "/etc/ziti/" + filepath.Base(component.File)},
+			Volumes: []string{filepath.Dir(component.File) + ":/etc/ziti"},
+		}
+		for _, port := range component.Ports {
+			service.Ports = append(service.Ports, port+":"+port)
+		}
+		hints.Services[component.Name] = service
+	}
+
+	out, err := yaml.Marshal(hints)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode compose hints")
+	}
+	if err = os.WriteFile(path, append([]byte(topologyComposeHintsFileHeader), out...), 0644); err != nil {
+		return errors.Wrapf(err, "unable to write compose hints file: %s", path)
+	}
+	logrus.Debugf("Compose hints written to: %s", path)
+	return nil
+}
+
+func printTopologySummary(out io.Writer, dir string, components []topologyComponent, composeHints bool) {
+	_, _ = fmt.Fprintf(out, "Created %d configs in %s\n", len(components), dir)
+	for _, component := range components {
+		_, _ = fmt.Fprintf(out, "  %-10s %-20s %-24s ports %v\n", component.Kind, component.Name, filepath.Base(component.File), component.Ports)
+	}
+	if composeHints {
+		_, _ = fmt.Fprintf(out, "Compose hints written to %s\n", filepath.Join(dir, topologyComposeHintsFile))
+	}
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func createTopology(t *testing.T, args ...string) (string, string) {
+	clearOptionsAndTemplateData()
+	dir := filepath.Join(t.TempDir(), "topology")
+
+	cmd := NewCmdCreateConfigTopology()
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetArgs(append([]string{"--output", dir}, args...))
+	require.NoError(t, cmd.Execute())
+	return dir, out.String()
+}
+
+func readTopologyRouter(t *testing.T, path string) RouterConfig {
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	config := RouterConfig{}
+	require.NoError(t, yaml.Unmarshal(raw, &config))
+	return config
+}
+
+func TestTopologyCreatesCoherentConfigs(t *testing.T) {
+	dir, summary := createTopology(t, "--routers", "3", "--controller-host", "ctrl.example.org")
+
+	_, err := os.Stat(filepath.Join(dir, topologyControllerFile))
+	require.NoError(t, err)
+
+	edgePorts := map[string]bool{}
+	for i, name := range []string{"router1", "router2", "router3"} {
+		config := readTopologyRouter(t, filepath.Join(dir, name+".yml"))
+		assert.Equal(t, "tls:ctrl.example.org:6262", config.Ctrl.Endpoint)
+		assert.Equal(t, filepath.Join(dir, name+".cert"), config.Identity.Cert)
+
+		edge := config.Listeners[0].Address
+		assert.False(t, edgePorts[edge], "router %d reuses edge listener %s", i+1, edge)
+		edgePorts[edge] = true
+		assert.Contains(t, summary, name+".yml")
+	}
+	assert.Equal(t, "tls:0.0.0.0:3042", readTopologyRouter(t, filepath.Join(dir, "router3.yml")).Listeners[0].Address)
+
+	_, err = os.Stat(filepath.Join(dir, topologyComposeHintsFile))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTopologyComposeHints(t *testing.T) {
+	dir, _ := createTopology(t, "--routers", "2", "--controller-host", "ctrl.example.org", "--router-prefix", "edge-", "--compose-hints")
+
+	raw, err := os.ReadFile(filepath.Join(dir, topologyComposeHintsFile))
+	require.NoError(t, err)
+
+	hints := composeHints{}
+	require.NoError(t, yaml.Unmarshal(raw, &hints))
+	require.Contains(t, hints.Services, "edge-2")
+	assert.Equal(t, []string{"3032:3032", "10090:10090"}, hints.Services["edge-2"].Ports)
+	assert.Equal(t, []string{"ziti", "router", "run", "/etc/ziti/edge-2.yml"}, hints.Services["edge-2"].Command)
+	assert.Len(t, hints.Services, 3)
+}
+
+func TestTopologyRejectsInvalidOptions(t *testing.T) {
+	for _, options := range []*CreateConfigTopologyOptions{
+		{Routers: 0, RouterPortStep: defaultRouterPortStep, ControllerHost: "ctrl.example.org"},
+		{Routers: 2, RouterPortStep: 0, ControllerHost: "ctrl.example.org"},
+		{Routers: 2, RouterPortStep: 60000, ControllerHost: "ctrl.example.org"},
+		{Routers: 2, RouterPortStep: defaultRouterPortStep},
+	} {
+		clearOptionsAndTemplateData()
+		options.Cmd = NewCmdCreateConfigTopology()
+		options.Output = t.TempDir()
+		options.RouterPrefix = defaultRouterPrefix
+		assert.Error(t, options.run(), "expected %+v to be rejected", *options)
+	}
+}
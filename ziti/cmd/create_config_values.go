@@ -0,0 +1,190 @@
+/*
+	Copyright NetFoundry Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	optionValues      = "values"
+	defaultValues     = ""
+	valuesDescription = "Read the template values from this yaml or json file, keyed by field as --" + optionDumpValues + " writes them, " +
+		"which it also accepts as is. Values left out of the file keep their defaults, and flags which are given override the file"
+
+	// sourceValuesFile marks template values read from --values in the --dump-values output
+	sourceValuesFile = "values file"
+)
+
+// readValuesFile reads the --values file over data, so the values it leaves out keep what they were set to. A file
+// written by --dump-values is accepted too, its values being taken from its values key. Keys which don't match a
+// template value are warned about and otherwise ignored, except for Features, where a misspelled feature fails as it
+// would with --enable.
+func readValuesFile(path string, data *ConfigTemplateValues) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read values file: %s", path)
+	}
+
+	// yaml is a superset of json, so either can be read as yaml
+	var decoded map[string]interface{}
+	if err = yaml.Unmarshal(contents, &decoded); err != nil {
+		return errors.Wrapf(err, "unable to parse values file: %s", path)
+	}
+	if dumped, ok := decoded["values"].(map[string]interface{}); ok {
+		decoded = dumped
+	}
+	if err = normalizeValuesFeatures(path, decoded); err != nil {
+		return err
+	}
+
+	if unknown, err := unknownValueKeys(decoded); err != nil {
+		return err
+	} else if len(unknown) > 0 {
+		logrus.Warnf("Ignoring keys in values file %s which aren't template values: %s", path, strings.Join(unknown, ", "))
+	}
+
+	encoded, err := json.Marshal(decoded)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read values file: %s", path)
+	}
+	if err = json.Unmarshal(encoded, data); err != nil {
+		return errors.Wrapf(err, "invalid values file: %s", path)
+	}
+	return nil
+}
+
+// normalizeValuesFeatures checks the Features keys of a values file against the known features, spelling them as the
+// templates do. Decoding matches struct fields ignoring case but map keys exactly, so without this a feature named in
+// a different case would be quietly left out, as would a misspelled one.
+func normalizeValuesFeatures(path string, decoded map[string]interface{}) error {
+	var keys []string
+	for key := range decoded {
+		if strings.EqualFold(key, "Features") {
+			keys = append(keys, key)
+		}
+	}
+	features := map[string]interface{}{}
+	for _, key := range keys {
+		if decoded[key] == nil {
+			delete(decoded, key)
+			continue
+		}
+		given, ok := decoded[key].(map[string]interface{})
+		if !ok {
+			return errors.Errorf("invalid values file: %s, Features must map feature names to true or false", path)
+		}
+		for name, enabled := range given {
+			known, found := configFeatureName(name)
+			if !found {
+				return errors.Errorf("invalid values file: %s, unknown feature [%s], must be one of %s", path, name, strings.Join(configFeatureNames(), ", "))
+			}
+			features[known] = enabled
+		}
+		delete(decoded, key)
+	}
+	if len(keys) > 0 {
+		decoded["Features"] = features
+	}
+	return nil
+}
+
+// unknownValueKeys returns the dotted paths in decoded which aren't template values. Matching ignores case, as
+// decoding the values does
+func unknownValueKeys(decoded map[string]interface{}) ([]string, error) {
+	// with every feature set, so the features, which normalizeValuesFeatures has already checked, are known paths
+	features := map[string]bool{}
+	for name := range configFeatures {
+		features[name] = true
+	}
+	known, err := flattenValues(&ConfigTemplateValues{Features: features})
+	if err != nil {
+		return nil, err
+	}
+	knownPaths := map[string]bool{}
+	for path := range known {
+		knownPaths[strings.ToLower(path)] = true
+	}
+
+	given := map[string]interface{}{}
+	flattenInto(given, "", decoded)
+	var unknown []string
+	for path := range given {
+		if !knownPaths[strings.ToLower(path)] {
+			unknown = append(unknown, path)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+// applyValuesFile reads --values over the router's template values. The --routerName flag wins over the file's
+// Router.Name, but one of them has to name the router. Identity files the file doesn't set follow the router name,
+// as they do without a values file.
+func (options *CreateConfigRouterOptions) applyValuesFile(data *ConfigTemplateValues) error {
+	// these follow the name, which the file may change
+	data.Router.Name = ""
+	data.Router.IdentityCert = ""
+	data.Router.IdentityServerCert = ""
+	data.Router.IdentityKey = ""
+	data.Router.IdentityCA = ""
+	if err := readValuesFile(options.ValuesFile, data); err != nil {
+		return err
+	}
+	options.valuesLoaded = true
+
+	if options.RouterName != "" {
+		data.Router.Name = options.RouterName
+	}
+	if data.Router.Name == "" {
+		return errors.Errorf("the router has no name, set --%s or Router.Name in the --%s file", optionRouterName, optionValues)
+	}
+
+	identity := &RouterTemplateValues{}
+	SetZitiRouterIdentity(identity, data.Router.Name)
+	for _, file := range []struct {
+		value   *string
+		derived string
+	}{
+		{&data.Router.IdentityCert, identity.IdentityCert},
+		{&data.Router.IdentityServerCert, identity.IdentityServerCert},
+		{&data.Router.IdentityKey, identity.IdentityKey},
+		{&data.Router.IdentityCA, identity.IdentityCA},
+	} {
+		if *file.value == "" {
+			*file.value = file.derived
+		}
+	}
+	return nil
+}
+
+// overridesValues returns true if the flag should set the template value it's for. Without --values every flag does,
+// as its default is what the value would be anyway. With it only the flags given do, so defaults don't undo the file.
+func (options *CreateConfigRouterOptions) overridesValues(flagName string) bool {
+	if !options.valuesLoaded || options.Cmd == nil {
+		return true
+	}
+	flag := options.Cmd.Flags().Lookup(flagName)
+	return flag != nil && flag.Changed
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValuesFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	dumped := filepath.Join(dir, "values.json")
+
+	clearOptionsAndTemplateData()
+	expected := createRouterConfig([]string{"edge", "--routerName", "myRouter", "--" + optionEdgeAdvertiseHost, "router.example.org", "--wss"})
+	clearOptionsAndTemplateData()
+	_ = createRouterConfig([]string{"edge", "--routerName", "myRouter", "--" + optionEdgeAdvertiseHost, "router.example.org", "--wss",
+		"--" + optionDumpValues + "=" + dumped})
+
+	// neither the name nor the flags are needed to render the same config again
+	clearOptionsAndTemplateData()
+	config := createRouterConfig([]string{"edge", "--" + optionValues, dumped})
+	assert.Equal(t, expected, config)
+	assert.Equal(t, "router.example.org:3023", config.Listeners[0].Options.Advertise)
+}
+
+func TestValuesFileWithFlagOverrides(t *testing.T) {
+	values := filepath.Join(t.TempDir(), "values.yml")
+	assert.NoError(t, os.WriteFile(values, []byte(`
+Router:
+  Name: fromFile
+  IsWss: true
+  Edge:
+    AdvertisedHost: file.example.org
+    Port: "4022"
+`), 0600))
+
+	clearOptionsAndTemplateData()
+	config := createRouterConfig([]string{"edge", "--" + optionValues, values})
+	assert.Equal(t, "ws:0.0.0.0:4022", config.Listeners[0].Address)
+	assert.Equal(t, "file.example.org:3023", config.Listeners[0].Options.Advertise)
+	assert.Equal(t, "fromFile.cert", filepath.Base(config.Identity.Cert))
+
+	clearOptionsAndTemplateData()
+	config = createRouterConfig([]string{"edge", "--" + optionValues, values, "--routerName", "fromFlag", "--wss=false",
+		"--" + optionEdgeListenPort, "5022"})
+	assert.Equal(t, "tls:0.0.0.0:5022", config.Listeners[0].Address)
+	assert.Equal(t, "file.example.org:5022", config.Listeners[0].Options.Advertise)
+	assert.Equal(t, "fromFlag.cert", filepath.Base(config.Identity.Cert))
+}
+
+func TestValuesFileNeedsRouterName(t *testing.T) {
+	values := filepath.Join(t.TempDir(), "values.yml")
+	assert.NoError(t, os.WriteFile(values, []byte("Router:\n  IsPrivate: true\n"), 0600))
+
+	options := &CreateConfigRouterOptions{ValuesFile: values}
+	err := options.applyValuesFile(&ConfigTemplateValues{})
+	assert.EqualError(t, err, "the router has no name, set --routerName or Router.Name in the --values file")
+
+	options.RouterName = "myRouter"
+	data := &ConfigTemplateValues{}
+	assert.NoError(t, options.applyValuesFile(data))
+	assert.True(t, data.Router.IsPrivate)
+	assert.Equal(t, "myRouter", data.Router.Name)
+}
+
+func TestUnknownValueKeys(t *testing.T) {
+	unknown, err := unknownValueKeys(map[string]interface{}{
+		"router": map[string]interface{}{"name": "r1", "Colour": "blue", "Edge": map[string]interface{}{"Port": "3022", "Extra": 1}},
+		"Extra":  true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Extra", "router.Colour", "router.Edge.Extra"}, unknown)
+}
@@ -73,6 +73,20 @@ func fatal(msg string, code int) {
 // status code 1.
 var ErrExit = fmt.Errorf("exit")
 
+// ExitError may be passed to CheckErr to exit with a specific status code, printing
+// Message first if it isn't empty.
+type ExitError struct {
+	Code    int
+	Message string
+}
+
+func (err *ExitError) Error() string {
+	if err.Message == "" {
+		return fmt.Sprintf("exit status %d", err.Code)
+	}
+	return err.Message
+}
+
 // CheckErr prints a user friendly error to STDERR and exits with a non-zero
 // exit code. Unrecognized errors will be printed with an "error: " prefix.
 //
@@ -136,6 +150,8 @@ func checkErr(prefix string, err error, handleErr func(string, int)) {
 				// do not print anything, only terminate with given error
 				handleErr("", err.ExitStatus())
 		*/
+		case *ExitError:
+			handleErr(err.Message, err.Code)
 		default: // for any other error type
 			msg, ok := StandardErrorMessage(err)
 			if !ok {